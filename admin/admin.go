@@ -0,0 +1,109 @@
+// Package admin is the JSON API for the operators of the bot, served by the
+// HTTP server. It calls the same functions as the Telegram commands do.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/teknologi-umum/captcha/analytics"
+	"github.com/teknologi-umum/captcha/captcha"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+// Actor is recorded as the actor of the captchas passed or failed through the API.
+const Actor = "admin_api"
+
+// Dependency contains the dependency injection struct
+// for methods in the admin package.
+type Dependency struct {
+	// Token is expected as the bearer token of every request.
+	Token   string
+	DB      *badger.DB
+	Bot     *tb.Bot
+	Captcha *captcha.Dependencies
+	// UnderAttack is optional, nil means the feature is disabled.
+	UnderAttack *underattack.Dependency
+	// Analytics is optional, nil means the feature is disabled.
+	Analytics *analytics.Dependency
+
+	// seen keeps the chats that are already stored, by ID and title.
+	seen sync.Map
+}
+
+// New creates a new admin dependency.
+func New(token string, db *badger.DB, bot *tb.Bot, captchaDependency *captcha.Dependencies, underAttack *underattack.Dependency, analyticsDependency *analytics.Dependency) (*Dependency, error) {
+	if token == "" {
+		return nil, fmt.Errorf("token is empty")
+	}
+
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if bot == nil {
+		return nil, fmt.Errorf("bot is nil")
+	}
+
+	if captchaDependency == nil {
+		return nil, fmt.Errorf("captcha dependency is nil")
+	}
+
+	return &Dependency{
+		Token:       token,
+		DB:          db,
+		Bot:         bot,
+		Captcha:     captchaDependency,
+		UnderAttack: underAttack,
+		Analytics:   analyticsDependency,
+	}, nil
+}
+
+// Handler serves the API under /admin/.
+func (d *Dependency) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/chats", d.listChats)
+	mux.HandleFunc("GET /admin/chats/{chat}/captchas", d.listCaptchas)
+	mux.HandleFunc("POST /admin/chats/{chat}/captchas/{user}/pass", d.passCaptcha)
+	mux.HandleFunc("POST /admin/chats/{chat}/captchas/{user}/fail", d.failCaptcha)
+	mux.HandleFunc("PUT /admin/chats/{chat}/underattack", d.toggleUnderAttack)
+	mux.HandleFunc("GET /admin/chats/{chat}/settings", d.getSettings)
+	mux.HandleFunc("PATCH /admin/chats/{chat}/settings", d.updateSettings)
+	mux.HandleFunc("GET /admin/chats/{chat}/audit", d.listAuditEvents)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (d *Dependency) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(d.Token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,231 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/teknologi-umum/captcha/admin"
+	"github.com/teknologi-umum/captcha/captcha"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/underattack"
+	"github.com/teknologi-umum/captcha/underattack/datastore"
+)
+
+const (
+	chatID = int64(-1001)
+	userID = int64(42)
+	token  = "secret"
+)
+
+// fakeTelegram answers every Bot API method with a plausible result.
+func fakeTelegram(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		var result string
+		switch method {
+		case "getChat":
+			result = `{"id":-1001,"title":"Teknologi Umum","type":"supergroup"}`
+		case "getChatMember":
+			result = `{"status":"member","user":{"id":42,"first_name":"Reinaldy"}}`
+		case "sendMessage":
+			result = `{"message_id":100,"date":0,"chat":{"id":-1001,"type":"supergroup"}}`
+		default:
+			result = `true`
+		}
+
+		_, _ = io.WriteString(w, `{"ok":true,"result":`+result+`}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func setup(t *testing.T) (*admin.Dependency, *badger.DB) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("opening badger: %s", err.Error())
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	memory, err := bigcache.New(context.Background(), bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("creating bigcache: %s", err.Error())
+	}
+
+	bot, err := tb.NewBot(tb.Settings{URL: fakeTelegram(t).URL, Token: "token", Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatalf("creating bot: %s", err.Error())
+	}
+
+	underAttackDatastore, err := datastore.NewInMemoryDatastore(memory)
+	if err != nil {
+		t.Fatalf("creating datastore: %s", err.Error())
+	}
+
+	dependency, err := admin.New(
+		token,
+		db,
+		bot,
+		&captcha.Dependencies{DB: db, Memory: memory, Bot: bot},
+		&underattack.Dependency{Datastore: underAttackDatastore, Memory: memory, Bot: bot},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("creating admin: %s", err.Error())
+	}
+
+	return dependency, db
+}
+
+// addPendingCaptcha stores a captcha the same way the captcha package does.
+func addPendingCaptcha(t *testing.T, db *badger.DB) {
+	value, err := json.Marshal(captcha.Captcha{
+		Answer:     "123",
+		Expiry:     time.Now().Add(captcha.Timeout),
+		ChatID:     chatID,
+		SenderID:   userID,
+		QuestionID: "99",
+	})
+	if err != nil {
+		t.Fatalf("marshaling captcha: %s", err.Error())
+	}
+
+	err = db.Update(func(txn *badger.Txn) error {
+		err := txn.Set([]byte("-1001:42"), value)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte("captcha:users:-1001"), []byte(";42"))
+	})
+	if err != nil {
+		t.Fatalf("storing captcha: %s", err.Error())
+	}
+}
+
+func request(t *testing.T, handler http.Handler, method, path, body string, response any) int {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if response != nil && w.Code < 300 {
+		err := json.NewDecoder(w.Body).Decode(response)
+		if err != nil {
+			t.Fatalf("%s %s: decoding response: %s", method, path, err.Error())
+		}
+	}
+
+	return w.Code
+}
+
+func TestAuthorization(t *testing.T) {
+	dependency, _ := setup(t)
+	handler := dependency.Handler()
+
+	for _, header := range []string{"", "secret", "Bearer wrong", "Basic c2VjcmV0"} {
+		r := httptest.NewRequest(http.MethodGet, "/admin/chats", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%q: expecting status 401, got %d", header, w.Code)
+		}
+	}
+}
+
+func TestPendingCaptcha(t *testing.T) {
+	dependency, db := setup(t)
+	handler := dependency.Handler()
+	addPendingCaptcha(t, db)
+
+	err := dependency.RememberChat(context.Background(), &tb.Chat{ID: chatID, Title: "Teknologi Umum", Type: tb.ChatSuperGroup})
+	if err != nil {
+		t.Fatalf("remembering chat: %s", err.Error())
+	}
+
+	var chats []admin.ChatSummary
+	if status := request(t, handler, http.MethodGet, "/admin/chats", "", &chats); status != http.StatusOK {
+		t.Fatalf("listing chats: expecting status 200, got %d", status)
+	}
+
+	if len(chats) != 1 || chats[0].ID != chatID || chats[0].PendingCaptchas != 1 {
+		t.Errorf("expecting the chat with 1 pending captcha, got %+v", chats)
+	}
+
+	var pending []admin.PendingCaptcha
+	request(t, handler, http.MethodGet, "/admin/chats/-1001/captchas", "", &pending)
+	if len(pending) != 1 || pending[0].UserID != userID {
+		t.Errorf("expecting the pending captcha of user 42, got %+v", pending)
+	}
+
+	if status := request(t, handler, http.MethodPost, "/admin/chats/-1001/captchas/42/pass", "", nil); status != http.StatusNoContent {
+		t.Fatalf("passing captcha: expecting status 204, got %d", status)
+	}
+
+	request(t, handler, http.MethodGet, "/admin/chats/-1001/captchas", "", &pending)
+	if len(pending) != 0 {
+		t.Errorf("expecting no pending captcha after passing it, got %+v", pending)
+	}
+
+	if status := request(t, handler, http.MethodPost, "/admin/chats/-1001/captchas/42/fail", "", nil); status != http.StatusNotFound {
+		t.Errorf("failing a passed captcha: expecting status 404, got %d", status)
+	}
+
+	var events []captcha.AuditEvent
+	request(t, handler, http.MethodGet, "/admin/chats/-1001/audit", "", &events)
+	if len(events) != 1 || events[0].Action != captcha.AuditPassed || events[0].Actor != admin.Actor {
+		t.Errorf("expecting a passed audit event by the admin API, got %+v", events)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	dependency, _ := setup(t)
+	handler := dependency.Handler()
+
+	var settings admin.Settings
+	request(t, handler, http.MethodGet, "/admin/chats/-1001/settings", "", &settings)
+	if settings.UnderAttackStrategy != underattack.StrategyBan || len(settings.UnderAttackSchedules) != 0 || settings.Analytics != nil {
+		t.Errorf("expecting the default settings, got %+v", settings)
+	}
+
+	status := request(t, handler, http.MethodPatch, "/admin/chats/-1001/settings", `{"analytics":true}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("analytics disabled: expecting status 400, got %d", status)
+	}
+
+	status = request(t, handler, http.MethodPatch, "/admin/chats/-1001/settings",
+		`{"underattack_strategy":"kick","underattack_schedules":["01:00-06:00","22:00-23:00"]}`, &settings)
+	if status != http.StatusOK {
+		t.Fatalf("updating settings: expecting status 200, got %d", status)
+	}
+
+	if settings.UnderAttackStrategy != underattack.StrategyKick || len(settings.UnderAttackSchedules) != 2 {
+		t.Errorf("expecting the updated settings, got %+v", settings)
+	}
+
+	// An invalid schedule must not change the strategy either.
+	status = request(t, handler, http.MethodPatch, "/admin/chats/-1001/settings",
+		`{"underattack_strategy":"lock","underattack_schedules":["tomorrow"]}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid schedule: expecting status 400, got %d", status)
+	}
+
+	request(t, handler, http.MethodPatch, "/admin/chats/-1001/settings", `{"underattack_schedules":["22:00-23:00"]}`, &settings)
+	if settings.UnderAttackStrategy != underattack.StrategyKick || len(settings.UnderAttackSchedules) != 1 || settings.UnderAttackSchedules[0] != "22:00-23:00" {
+		t.Errorf("expecting only the schedules to be replaced, got %+v", settings)
+	}
+}
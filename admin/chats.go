@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// Chat is a group the bot is in. Telegram can't list the chats of a bot, so
+// they are remembered as the bot receives updates from them.
+type Chat struct {
+	ID     int64       `json:"id"`
+	Title  string      `json:"title"`
+	Type   tb.ChatType `json:"type"`
+	SeenAt time.Time   `json:"seen_at"`
+}
+
+var chatPrefix = []byte("admin:chats:")
+
+func chatKey(chatID int64) []byte {
+	return []byte(string(chatPrefix) + strconv.FormatInt(chatID, 10))
+}
+
+// RememberChat stores the chat, unless it is a private chat. It only writes
+// when the chat is new to this process or its title has changed.
+func (d *Dependency) RememberChat(ctx context.Context, chat *tb.Chat) error {
+	if chat == nil || chat.Type == tb.ChatPrivate {
+		return nil
+	}
+
+	if title, ok := d.seen.Load(chat.ID); ok && title == chat.Title {
+		return nil
+	}
+
+	value, err := json.Marshal(Chat{ID: chat.ID, Title: chat.Title, Type: chat.Type, SeenAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("marshaling chat: %w", err)
+	}
+
+	err = d.DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(chatKey(chat.ID), value)
+	})
+	if err != nil {
+		return fmt.Errorf("storing chat: %w", err)
+	}
+
+	d.seen.Store(chat.ID, chat.Title)
+	return nil
+}
+
+// ForgetChat removes the chat, once the bot has left it.
+func (d *Dependency) ForgetChat(ctx context.Context, chatID int64) error {
+	err := d.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(chatKey(chatID))
+	})
+	if err != nil {
+		return fmt.Errorf("deleting chat: %w", err)
+	}
+
+	d.seen.Delete(chatID)
+	return nil
+}
+
+// Chats returns every chat the bot is in, as far as it knows.
+func (d *Dependency) Chats(ctx context.Context) ([]Chat, error) {
+	span := sentry.StartSpan(ctx, "admin.chats")
+	defer span.Finish()
+
+	chats := []Chat{}
+	err := d.DB.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = chatPrefix
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			value, err := iterator.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			var chat Chat
+			err = json.Unmarshal(value, &chat)
+			if err != nil {
+				return err
+			}
+
+			chats = append(chats, chat)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading chats: %w", err)
+	}
+
+	return chats, nil
+}
+
+// chat returns the stored chat, or asks Telegram for a chat the bot
+// has not heard from yet.
+func (d *Dependency) chat(ctx context.Context, chatID int64) (*tb.Chat, error) {
+	var chat Chat
+	err := d.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(chatKey(chatID))
+		if err != nil {
+			return err
+		}
+
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(value, &chat)
+	})
+	if err == nil {
+		return &tb.Chat{ID: chat.ID, Title: chat.Title, Type: chat.Type}, nil
+	}
+
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("reading chat: %w", err)
+	}
+
+	telegramChat, err := d.Bot.ChatByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.RememberChat(ctx, telegramChat)
+	if err != nil {
+		return nil, err
+	}
+
+	return telegramChat, nil
+}
+
+// MyChatMemberHandler keeps track of the bot being added to or removed from a group.
+func (d *Dependency) MyChatMemberHandler(ctx context.Context, c tb.Context) error {
+	update := c.ChatMember()
+	if update == nil || update.NewChatMember == nil {
+		return nil
+	}
+
+	switch update.NewChatMember.Role {
+	case tb.Left, tb.Kicked:
+		return d.ForgetChat(ctx, update.Chat.ID)
+	default:
+		return d.RememberChat(ctx, update.Chat)
+	}
+}
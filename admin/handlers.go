@@ -0,0 +1,465 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/analytics"
+	"github.com/teknologi-umum/captcha/captcha"
+	"github.com/teknologi-umum/captcha/internal/requestid"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+const (
+	// DefaultAuditLimit is the amount of audit events returned when no limit is given.
+	DefaultAuditLimit = 50
+	// MaxAuditLimit is the most audit events returned at once.
+	MaxAuditLimit = 500
+)
+
+// ChatSummary is a chat along with its pending captchas.
+type ChatSummary struct {
+	Chat
+	PendingCaptchas int `json:"pending_captchas"`
+}
+
+// PendingCaptcha is a captcha waiting for an answer. The answer is left out.
+type PendingCaptcha struct {
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UnderAttackRequest turns the under attack mode on or off. Duration is
+// a Go duration such as "2h", and defaults to underattack.DefaultDuration.
+type UnderAttackRequest struct {
+	Enabled  bool   `json:"enabled"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// UnderAttackResponse is the under attack state after the request.
+type UnderAttackResponse struct {
+	Enabled   bool      `json:"enabled"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// Settings are the settings of a group that can be changed through Telegram
+// commands. The settings of a disabled feature are left out.
+type Settings struct {
+	UnderAttackStrategy  underattack.Strategy `json:"underattack_strategy,omitempty"`
+	UnderAttackSchedules []string             `json:"underattack_schedules,omitempty"`
+	Analytics            *bool                `json:"analytics,omitempty"`
+	AnalyticsTimezone    string               `json:"analytics_timezone,omitempty"`
+}
+
+// SettingsUpdate changes the settings that are given, and leaves the rest.
+// The schedules replace every existing schedule of the group.
+type SettingsUpdate struct {
+	UnderAttackStrategy  *underattack.Strategy `json:"underattack_strategy"`
+	UnderAttackSchedules *[]string             `json:"underattack_schedules"`
+	Analytics            *bool                 `json:"analytics"`
+	AnalyticsTimezone    *string               `json:"analytics_timezone"`
+}
+
+// context prepares the request context the same way the Telegram handlers do.
+// A client that goes away should not leave a change halfway done.
+func (d *Dependency) context(r *http.Request, handler string) context.Context {
+	ctx := sentry.SetHubOnContext(context.WithoutCancel(r.Context()), sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	return shared.SetHandlerOnContext(ctx, "admin."+handler)
+}
+
+// internalError reports the error and hides it from the response.
+func internalError(ctx context.Context, w http.ResponseWriter, err error) {
+	shared.HandleError(ctx, err)
+	writeError(w, http.StatusInternalServerError, "internal error, request id "+requestid.GetRequestIdFromContext(ctx))
+}
+
+func pathID(r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	return id, err == nil
+}
+
+func (d *Dependency) listChats(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "listChats")
+
+	chats, err := d.Chats(ctx)
+	if err != nil {
+		internalError(ctx, w, err)
+		return
+	}
+
+	summaries := make([]ChatSummary, 0, len(chats))
+	for _, chat := range chats {
+		pending, err := d.Captcha.Pending(ctx, chat.ID)
+		if err != nil {
+			internalError(ctx, w, err)
+			return
+		}
+
+		summaries = append(summaries, ChatSummary{Chat: chat, PendingCaptchas: len(pending)})
+	}
+
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (d *Dependency) listCaptchas(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "listCaptchas")
+
+	chatID, ok := pathID(r, "chat")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	captchas, err := d.Captcha.Pending(ctx, chatID)
+	if err != nil {
+		internalError(ctx, w, err)
+		return
+	}
+
+	pending := make([]PendingCaptcha, 0, len(captchas))
+	for _, c := range captchas {
+		pending = append(pending, PendingCaptcha{UserID: c.SenderID, ExpiresAt: c.Expiry})
+	}
+
+	writeJSON(w, http.StatusOK, pending)
+}
+
+func (d *Dependency) passCaptcha(w http.ResponseWriter, r *http.Request) {
+	d.decideCaptcha(w, r, "passCaptcha", d.Captcha.Pass)
+}
+
+func (d *Dependency) failCaptcha(w http.ResponseWriter, r *http.Request) {
+	d.decideCaptcha(w, r, "failCaptcha", d.Captcha.Fail)
+}
+
+func (d *Dependency) decideCaptcha(w http.ResponseWriter, r *http.Request, handler string, decide func(ctx context.Context, chat *tb.Chat, user *tb.User, actor string) error) {
+	ctx := d.context(r, handler)
+
+	chatID, ok := pathID(r, "chat")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	userID, ok := pathID(r, "user")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	chat, err := d.chat(ctx, chatID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "getting chat: "+err.Error())
+		return
+	}
+
+	// The welcome and kick messages mention the user by their name.
+	member, err := d.Bot.ChatMemberOf(ctx, chat, &tb.User{ID: userID})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "getting chat member: "+err.Error())
+		return
+	}
+
+	err = decide(ctx, chat, member.User, Actor)
+	if err != nil {
+		if errors.Is(err, captcha.ErrNoPendingCaptcha) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		internalError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *Dependency) toggleUnderAttack(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "toggleUnderAttack")
+
+	if d.UnderAttack == nil {
+		writeError(w, http.StatusNotFound, "under attack feature is disabled")
+		return
+	}
+
+	chatID, ok := pathID(r, "chat")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	var request UnderAttackRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	duration := underattack.DefaultDuration
+	if request.Duration != "" {
+		duration, err = time.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 || duration > underattack.MaxDuration {
+			writeError(w, http.StatusBadRequest, "duration must be between 0 and "+underattack.MaxDuration.String())
+			return
+		}
+	}
+
+	underAttack, err := d.UnderAttack.AreWe(ctx, chatID)
+	if err != nil {
+		internalError(ctx, w, err)
+		return
+	}
+
+	if underAttack && request.Enabled {
+		writeError(w, http.StatusConflict, "under attack mode is already on")
+		return
+	}
+
+	if !underAttack && !request.Enabled {
+		writeError(w, http.StatusConflict, "under attack mode is already off")
+		return
+	}
+
+	chat, err := d.chat(ctx, chatID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "getting chat: "+err.Error())
+		return
+	}
+
+	if !request.Enabled {
+		err = d.UnderAttack.Disable(ctx, chat, 0)
+		if err != nil {
+			internalError(ctx, w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, UnderAttackResponse{Enabled: false})
+		return
+	}
+
+	expiresAt := time.Now().Add(duration)
+	err = d.UnderAttack.Enable(ctx, chat, expiresAt, underattack.TriggerManual, 0)
+	if err != nil {
+		internalError(ctx, w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UnderAttackResponse{Enabled: true, ExpiresAt: expiresAt})
+}
+
+func (d *Dependency) settings(ctx context.Context, chatID int64) (Settings, error) {
+	var settings Settings
+	if d.UnderAttack != nil {
+		strategy, err := d.UnderAttack.StrategyOf(ctx, chatID)
+		if err != nil {
+			return Settings{}, err
+		}
+
+		schedules, err := d.UnderAttack.Datastore.GetSchedules(ctx, chatID)
+		if err != nil {
+			return Settings{}, err
+		}
+
+		settings.UnderAttackStrategy = strategy
+		settings.UnderAttackSchedules = []string{}
+		for _, schedule := range schedules {
+			settings.UnderAttackSchedules = append(settings.UnderAttackSchedules, schedule.String())
+		}
+	}
+
+	if d.Analytics != nil {
+		enabled, err := d.Analytics.Enabled(ctx, chatID)
+		if err != nil {
+			return Settings{}, err
+		}
+
+		location, err := d.Analytics.Location(ctx, chatID)
+		if err != nil {
+			return Settings{}, err
+		}
+
+		settings.Analytics = &enabled
+		settings.AnalyticsTimezone = location.String()
+	}
+
+	return settings, nil
+}
+
+func (d *Dependency) getSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "getSettings")
+
+	chatID, ok := pathID(r, "chat")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	settings, err := d.settings(ctx, chatID)
+	if err != nil {
+		internalError(ctx, w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+func (d *Dependency) updateSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "updateSettings")
+
+	chatID, ok := pathID(r, "chat")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	var update SettingsUpdate
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if d.UnderAttack == nil && (update.UnderAttackStrategy != nil || update.UnderAttackSchedules != nil) {
+		writeError(w, http.StatusBadRequest, "under attack feature is disabled")
+		return
+	}
+
+	if d.Analytics == nil && (update.Analytics != nil || update.AnalyticsTimezone != nil) {
+		writeError(w, http.StatusBadRequest, "analytics feature is disabled")
+		return
+	}
+
+	// Parse everything first, so an invalid request changes nothing.
+	var schedules []underattack.Schedule
+	if update.UnderAttackSchedules != nil {
+		for _, window := range *update.UnderAttackSchedules {
+			schedule, err := underattack.ParseSchedule(chatID, window)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	if update.AnalyticsTimezone != nil {
+		_, err := analytics.LoadTimezone(*update.AnalyticsTimezone)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if update.UnderAttackStrategy != nil && !update.UnderAttackStrategy.Valid() {
+		writeError(w, http.StatusBadRequest, "invalid strategy "+strconv.Quote(string(*update.UnderAttackStrategy)))
+		return
+	}
+
+	if update.UnderAttackStrategy != nil {
+		err := d.UnderAttack.SetStrategy(ctx, chatID, *update.UnderAttackStrategy)
+		if err != nil {
+			internalError(ctx, w, err)
+			return
+		}
+	}
+
+	if update.UnderAttackSchedules != nil {
+		err := d.replaceSchedules(ctx, chatID, schedules)
+		if err != nil {
+			internalError(ctx, w, err)
+			return
+		}
+	}
+
+	if update.Analytics != nil {
+		err := d.Analytics.SetEnabled(ctx, chatID, *update.Analytics)
+		if err != nil {
+			internalError(ctx, w, err)
+			return
+		}
+	}
+
+	if update.AnalyticsTimezone != nil {
+		err := d.Analytics.SetTimezone(ctx, chatID, *update.AnalyticsTimezone)
+		if err != nil {
+			internalError(ctx, w, err)
+			return
+		}
+	}
+
+	settings, err := d.settings(ctx, chatID)
+	if err != nil {
+		internalError(ctx, w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// replaceSchedules removes the schedules of the group that are not wanted
+// anymore, and adds the new ones.
+func (d *Dependency) replaceSchedules(ctx context.Context, chatID int64, schedules []underattack.Schedule) error {
+	existing, err := d.UnderAttack.Datastore.GetSchedules(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range existing {
+		if !slices.Contains(schedules, schedule) {
+			err := d.UnderAttack.Datastore.RemoveSchedule(ctx, schedule)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, schedule := range schedules {
+		if !slices.Contains(existing, schedule) {
+			err := d.UnderAttack.Datastore.AddSchedule(ctx, schedule)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Dependency) listAuditEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "listAuditEvents")
+
+	chatID, ok := pathID(r, "chat")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+
+	limit := DefaultAuditLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > MaxAuditLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(MaxAuditLimit))
+			return
+		}
+
+		limit = parsed
+	}
+
+	events, err := d.Captcha.AuditEvents(ctx, chatID, limit)
+	if err != nil {
+		internalError(ctx, w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
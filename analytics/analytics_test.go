@@ -19,6 +19,9 @@ import (
 
 var dependency *analytics.Dependency
 
+// memory is the cache behind dependency.Memory, kept to be reset.
+var memory *bigcache.BigCache
+
 func TestMain(m *testing.M) {
 	databaseUrl, ok := os.LookupEnv("POSTGRES_URL")
 	if !ok {
@@ -37,12 +40,12 @@ func TestMain(m *testing.M) {
 
 	_ = sentry.Init(sentry.ClientOptions{})
 
-	memory, err := bigcache.New(context.Background(), bigcache.DefaultConfig(time.Hour*1))
+	memory, err = bigcache.New(context.Background(), bigcache.DefaultConfig(time.Hour*1))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = analytics.MustMigrate(db)
+	err = analytics.MustMigrate(db, 123456789)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -111,7 +114,15 @@ func Cleanup() {
 		log.Fatal(err)
 	}
 
-	_, err = tx.ExecContext(ctx, "TRUNCATE TABLE analytics_hourly RESTART IDENTITY CASCADE")
+	_, err = tx.ExecContext(ctx, "TRUNCATE TABLE analytics_messages RESTART IDENTITY CASCADE")
+	if err != nil {
+		if r := tx.Rollback(); r != nil {
+			log.Fatal(r)
+		}
+		log.Fatal(err)
+	}
+
+	_, err = tx.ExecContext(ctx, "TRUNCATE TABLE analytics_member_messages RESTART IDENTITY CASCADE")
 	if err != nil {
 		if r := tx.Rollback(); r != nil {
 			log.Fatal(r)
@@ -127,7 +138,7 @@ func Cleanup() {
 		log.Fatal(err)
 	}
 
-	err = dependency.Memory.Reset()
+	err = memory.Reset()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -158,7 +169,9 @@ func Teardown() error {
 		"DROP INDEX IF EXISTS idx_active",
 		"DROP TABLE IF EXISTS captcha_swarm",
 		"DROP TABLE IF EXISTS analytics",
-		"DROP TABLE IF EXISTS analytics_hourly",
+		"DROP TABLE IF EXISTS analytics_messages",
+		"DROP TABLE IF EXISTS analytics_member_messages",
+		"DROP TABLE IF EXISTS analytics_group",
 	}
 
 	for _, query := range queries {
@@ -181,7 +194,7 @@ func Teardown() error {
 		return err
 	}
 
-	err = dependency.Memory.Reset()
+	err = memory.Reset()
 	if err != nil {
 		return err
 	}
@@ -206,15 +219,14 @@ func Seed(ctx context.Context) error {
 		return err
 	}
 
-	// The lack of group_id value is intentional, because I want to check for
-	// null SQL values.
 	_, err = tx.ExecContext(
 		ctx,
 		`INSERT INTO analytics
-			(user_id, username, display_name, counter, created_at, joined_at, updated_at)
+			(user_id, group_id, username, display_name, counter, created_at, joined_at, updated_at)
 			VALUES
-			($1, $2, $3, $4, $5, $6, $7)`,
+			($1, $2, $3, $4, $5, $6, $7, $8)`,
 		90,
+		dependency.HomeGroupID,
 		"user1",
 		"User 1",
 		1,
@@ -230,39 +242,52 @@ func Seed(ctx context.Context) error {
 		return err
 	}
 
-	// create a dummy hourly type
-	hourly := []analytics.HourlyMap{
-		{
-			TodaysDate: time.Now().Add(time.Hour * -48).Format("2006-01-02"),
-			ZeroHour:   14,
-			OneHour:    15,
-			TwoHour:    16,
-		},
-		{
-			TodaysDate: time.Now().Add(time.Hour * -24).Format("2006-01-02"),
-			ZeroHour:   3,
-			OneHour:    4,
-			TwoHour:    5,
-		},
-		{
-			TodaysDate: time.Now().Format("2006-01-02"),
-			ZeroHour:   6,
-			OneHour:    7,
-			TwoHour:    8,
-		},
+	// create dummy hourly buckets within the last 3 days
+	hour := time.Now().UTC().Truncate(time.Hour)
+	buckets := []analytics.Bucket{
+		{Start: hour.Add(time.Hour * -48), Count: 14},
+		{Start: hour.Add(time.Hour * -47), Count: 15},
+		{Start: hour.Add(time.Hour * -46), Count: 16},
+		{Start: hour.Add(time.Hour * -24), Count: 3},
+		{Start: hour.Add(time.Hour * -23), Count: 4},
+		{Start: hour.Add(time.Hour * -22), Count: 5},
+		{Start: hour.Add(time.Hour * -2), Count: 6},
+		{Start: hour.Add(time.Hour * -1), Count: 7},
+		{Start: hour, Count: 8},
+	}
+
+	for _, bucket := range buckets {
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO analytics_messages
+				(group_id, bucket_start, count)
+				VALUES
+				($1, $2, $3)`,
+			dependency.HomeGroupID,
+			bucket.Start,
+			bucket.Count,
+		)
+		if err != nil {
+			if e := tx.Rollback(); e != nil {
+				return e
+			}
+
+			return err
+		}
 	}
 
-	for _, hour := range hourly {
+	// user 90 sent a message within the last hour, and two more 20 days ago
+	for _, bucket := range []analytics.Bucket{{Start: hour, Count: 1}, {Start: hour.AddDate(0, 0, -20), Count: 2}} {
 		_, err = tx.ExecContext(
 			ctx,
-			`INSERT INTO analytics_hourly
-				(todays_date, zero_hour, one_hour, two_hour)
+			`INSERT INTO analytics_member_messages
+				(group_id, user_id, bucket_start, count)
 				VALUES
 				($1, $2, $3, $4)`,
-			hour.TodaysDate,
-			hour.ZeroHour,
-			hour.OneHour,
-			hour.TwoHour,
+			dependency.HomeGroupID,
+			90,
+			bucket.Start,
+			bucket.Count,
 		)
 		if err != nil {
 			if e := tx.Rollback(); e != nil {
@@ -3,6 +3,7 @@ package analytics
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/teknologi-umum/captcha/shared"
 
@@ -10,8 +11,9 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Returns a slice of GroupMember from the database.
-func (d *Dependency) GetUserDataFromDB(ctx context.Context) ([]GroupMember, error) {
+// Returns a slice of GroupMember of the group from the database, for the
+// members that sent a message since the given time.
+func (d *Dependency) GetUserDataFromDB(ctx context.Context, groupID int64, since time.Time) ([]GroupMember, error) {
 	c, err := d.DB.Connx(ctx)
 	if err != nil {
 		return []GroupMember{}, nil
@@ -28,7 +30,26 @@ func (d *Dependency) GetUserDataFromDB(ctx context.Context) ([]GroupMember, erro
 		return []GroupMember{}, err
 	}
 
-	rows, err := tx.QueryxContext(ctx, "SELECT * FROM analytics WHERE counter > 0 AND created_at > NOW() - INTERVAL '90 DAYS'")
+	rows, err := tx.QueryxContext(
+		ctx,
+		`SELECT
+			user_id,
+			group_id,
+			COALESCE(username, '') AS username,
+			COALESCE(display_name, '') AS display_name,
+			counter,
+			COALESCE(created_at, NOW()) AS created_at,
+			COALESCE(joined_at, created_at, NOW()) AS joined_at,
+			COALESCE(updated_at, created_at, NOW()) AS updated_at
+		FROM
+			analytics
+		WHERE
+			group_id = $1
+			AND counter > 0
+			AND COALESCE(updated_at, created_at) >= $2`,
+		groupID,
+		since,
+	)
 	if err != nil {
 		if r := tx.Rollback(); r != nil {
 			return []GroupMember{}, err
@@ -66,59 +87,45 @@ func (d *Dependency) GetUserDataFromDB(ctx context.Context) ([]GroupMember, erro
 	return users, nil
 }
 
-// Return a slice of HourlyMap from the database.
-func (d *Dependency) GetHourlyDataFromDB(ctx context.Context) ([]HourlyMap, error) {
-	c, err := d.DB.Connx(ctx)
+// GetMessagesFromDB returns the message counts of the group from the given
+// time, rolled up by the hour or by the day on the timezone of the group.
+// Buckets without any message are left out.
+func (d *Dependency) GetMessagesFromDB(ctx context.Context, groupID int64, since time.Time, rollup Rollup) ([]Bucket, error) {
+	location, err := d.Location(ctx, groupID)
 	if err != nil {
-		return []HourlyMap{}, nil
+		return []Bucket{}, err
 	}
-	defer func(c *sqlx.Conn) {
-		err := c.Close()
-		if err != nil && !errors.Is(err, sql.ErrConnDone) {
-			shared.HandleError(ctx, err)
-		}
-	}(c)
 
-	tx, err := c.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	var buckets []Bucket
+	err = d.readOnly(ctx, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(
+			ctx,
+			&buckets,
+			`SELECT
+				date_trunc($3, bucket_start, $4) AS bucket_start,
+				SUM(count) AS count
+			FROM
+				analytics_messages
+			WHERE
+				group_id = $1
+				AND bucket_start >= $2
+			GROUP BY
+				1
+			ORDER BY
+				1`,
+			groupID,
+			since,
+			string(rollup),
+			location.String(),
+		)
+	})
 	if err != nil {
-		return []HourlyMap{}, err
+		return []Bucket{}, err
 	}
 
-	rows, err := tx.QueryxContext(ctx, "SELECT * FROM analytics_hourly WHERE to_date(todays_date, 'YYYY-MM-DD') > NOW() - INTERVAL '90 DAYS'")
-	if err != nil {
-		if r := tx.Rollback(); r != nil {
-			return []HourlyMap{}, nil
-		}
-		return []HourlyMap{}, err
-	}
-	defer func(rows *sqlx.Rows) {
-		err := rows.Close()
-		if err != nil {
-			shared.HandleError(ctx, err)
-		}
-	}(rows)
-
-	var hourly []HourlyMap
-	for rows.Next() {
-		var hour HourlyMap
-		err := rows.StructScan(&hour)
-		if err != nil {
-			if r := tx.Rollback(); r != nil {
-				return []HourlyMap{}, nil
-			}
-			return []HourlyMap{}, err
-		}
-
-		hourly = append(hourly, hour)
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		if r := tx.Rollback(); r != nil {
-			return []HourlyMap{}, nil
-		}
-		return []HourlyMap{}, err
+	for i := range buckets {
+		buckets[i].Start = buckets[i].Start.In(location)
 	}
 
-	return hourly, nil
+	return buckets, nil
 }
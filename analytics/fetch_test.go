@@ -4,13 +4,15 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/teknologi-umum/captcha/analytics"
 )
 
 func TestGetUserDataFromDB(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
 
-	data, err := dependency.GetUserDataFromDB(ctx)
+	data, err := dependency.GetUserDataFromDB(ctx, dependency.HomeGroupID, time.Now().AddDate(0, 0, -90))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -32,16 +34,34 @@ func TestGetUserDataFromDB(t *testing.T) {
 	}
 }
 
-func TestGetHourlyDataFromDB(t *testing.T) {
+func TestGetMessagesFromDB(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
 
-	data, err := dependency.GetHourlyDataFromDB(ctx)
+	hourly, err := dependency.GetMessagesFromDB(ctx, dependency.HomeGroupID, time.Now().AddDate(0, 0, -90), analytics.RollupHourly)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	if len(hourly) != 9 {
+		t.Errorf("hourly buckets should be 9, got %d", len(hourly))
+	}
+
+	daily, err := dependency.GetMessagesFromDB(ctx, dependency.HomeGroupID, time.Now().AddDate(0, 0, -90), analytics.RollupDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int
+	for _, bucket := range daily {
+		total += bucket.Count
+
+		if bucket.Start.Hour() != 0 || bucket.Start.Location().String() != analytics.DefaultTimezone {
+			t.Errorf("daily bucket should start at midnight on %s, got %s", analytics.DefaultTimezone, bucket.Start)
+		}
 	}
 
-	if len(data) != 3 {
-		t.Error("data length should be 3, got", len(data))
+	if len(daily) < 3 || len(daily) > 4 || total != 78 {
+		t.Errorf("daily buckets should add up to 78 messages over 3 or 4 days, got %d over %d", total, len(daily))
 	}
 }
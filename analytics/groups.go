@@ -0,0 +1,277 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// The timezones of the groups are loaded on images without a zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/cache"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/utils"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// DefaultTimezone is the timezone of the groups that have not set one.
+const DefaultTimezone = "Asia/Jakarta"
+
+// ErrInvalidTimezone is returned for a name that is not on the IANA time zone database.
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// groupFreshness is how long the settings of a group are read from memory
+// before asking the database again.
+const groupFreshness = time.Minute
+
+// groupSettings are the analytics settings of a group.
+type groupSettings struct {
+	Enabled  bool      `json:"enabled"`
+	Timezone string    `json:"timezone"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+func groupCacheKey(groupID int64) string {
+	return "analytics:group:" + strconv.FormatInt(groupID, 10)
+}
+
+// LoadTimezone loads the timezone by its IANA name, such as Asia/Jakarta.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimezone, name)
+	}
+
+	return location, nil
+}
+
+func (d *Dependency) groupSettings(ctx context.Context, groupID int64) (groupSettings, error) {
+	cached, err := d.Memory.Get(groupCacheKey(groupID))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return groupSettings{}, err
+	}
+
+	if err == nil {
+		var settings groupSettings
+		err := json.Unmarshal(cached, &settings)
+		if err != nil {
+			return groupSettings{}, err
+		}
+
+		if time.Since(settings.CachedAt) < groupFreshness {
+			return settings, nil
+		}
+	}
+
+	settings := groupSettings{Enabled: groupID == d.HomeGroupID, Timezone: DefaultTimezone, CachedAt: time.Now()}
+	err = d.readOnly(ctx, func(tx *sqlx.Tx) error {
+		var timezone string
+		err := tx.QueryRowxContext(
+			ctx,
+			"SELECT enabled, COALESCE(timezone, '') FROM analytics_group WHERE group_id = $1",
+			groupID,
+		).Scan(&settings.Enabled, &timezone)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+
+		if timezone != "" {
+			settings.Timezone = timezone
+		}
+
+		return err
+	})
+	if err != nil {
+		return groupSettings{}, fmt.Errorf("selecting analytics group: %w", err)
+	}
+
+	value, err := json.Marshal(settings)
+	if err != nil {
+		return groupSettings{}, err
+	}
+
+	return settings, d.Memory.Set(groupCacheKey(groupID), value)
+}
+
+// Enabled tells whether the analytics are recorded for the group. The home
+// group is recorded until it opts out, every other group has to opt in.
+func (d *Dependency) Enabled(ctx context.Context, groupID int64) (bool, error) {
+	settings, err := d.groupSettings(ctx, groupID)
+	if err != nil {
+		return false, err
+	}
+
+	return settings.Enabled, nil
+}
+
+// Location returns the timezone the stats of the group are shown in.
+func (d *Dependency) Location(ctx context.Context, groupID int64) (*time.Location, error) {
+	settings, err := d.groupSettings(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadTimezone(settings.Timezone)
+}
+
+// SetEnabled opts the group in or out of the analytics. Opting out stops
+// the recording, the data recorded before is kept.
+func (d *Dependency) SetEnabled(ctx context.Context, groupID int64, enabled bool) error {
+	return d.updateGroup(
+		ctx,
+		groupID,
+		`INSERT INTO analytics_group
+			(group_id, enabled, updated_at)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (group_id)
+		DO UPDATE
+		SET enabled = $2,
+			updated_at = $3`,
+		groupID,
+		enabled,
+		time.Now(),
+	)
+}
+
+// SetTimezone changes the timezone the stats of the group are shown in.
+func (d *Dependency) SetTimezone(ctx context.Context, groupID int64, timezone string) error {
+	_, err := LoadTimezone(timezone)
+	if err != nil {
+		return err
+	}
+
+	return d.updateGroup(
+		ctx,
+		groupID,
+		`INSERT INTO analytics_group
+			(group_id, enabled, timezone, updated_at)
+		VALUES
+			($1, $2, $3, $4)
+		ON CONFLICT (group_id)
+		DO UPDATE
+		SET timezone = $3,
+			updated_at = $4`,
+		groupID,
+		groupID == d.HomeGroupID,
+		timezone,
+		time.Now(),
+	)
+}
+
+// updateGroup runs the upsert on analytics_group, and forgets the cached settings.
+func (d *Dependency) updateGroup(ctx context.Context, groupID int64, query string, args ...any) error {
+	c, err := d.DB.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(c *sqlx.Conn) {
+		err := c.Close()
+		if err != nil && !errors.Is(err, sql.ErrConnDone) {
+			shared.HandleError(ctx, err)
+		}
+	}(c)
+
+	t, err := c.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	_, err = t.ExecContext(ctx, query, args...)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	err = d.Memory.Delete(groupCacheKey(groupID))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// EnableHandler handles the /analytics command. Admins of the group can turn
+// the analytics on or off, or change the timezone of the stats with
+// "/analytics timezone Asia/Jakarta". Anyone else is ignored.
+func (d *Dependency) EnableHandler(ctx context.Context, c tb.Context) error {
+	if c.Message().Private() || c.Sender().IsBot {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "bot.analytics_handler", sentry.WithTransactionSource(sentry.SourceTask),
+		sentry.WithTransactionName("Analytics EnableHandler"))
+	defer span.Finish()
+	ctx = span.Context()
+
+	admins, err := c.Bot().AdminsOf(ctx, c.Chat())
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	if !utils.IsAdmin(admins, c.Sender()) {
+		return nil
+	}
+
+	var reply string
+	switch args := c.Args(); {
+	case len(args) > 0 && strings.EqualFold(args[0], "on"):
+		err = d.SetEnabled(ctx, c.Chat().ID, true)
+		reply = "Analytics untuk grup ini sudah dinyalakan."
+	case len(args) > 0 && strings.EqualFold(args[0], "off"):
+		err = d.SetEnabled(ctx, c.Chat().ID, false)
+		reply = "Analytics untuk grup ini sudah dimatikan. Data yang sudah tercatat tidak dihapus."
+	case len(args) > 1 && strings.EqualFold(args[0], "timezone"):
+		err = d.SetTimezone(ctx, c.Chat().ID, args[1])
+		reply = "Statistik grup ini sekarang ditampilkan dengan zona waktu " + args[1] + "."
+		if errors.Is(err, ErrInvalidTimezone) {
+			err = nil
+			reply = "Zona waktu tidak dikenal. Gunakan nama seperti Asia/Jakarta, Asia/Makassar, atau Asia/Jayapura."
+		}
+	default:
+		var settings groupSettings
+		settings, err = d.groupSettings(ctx, c.Chat().ID)
+		reply = "Analytics untuk grup ini sedang mati. Kirim /analytics on untuk menyalakan."
+		if settings.Enabled {
+			reply = "Analytics untuk grup ini sedang menyala. Kirim /analytics off untuk mematikan."
+		}
+		reply += "\nZona waktu statistik: " + settings.Timezone + ". Kirim /analytics timezone <zona waktu> untuk mengganti."
+	}
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	_, err = c.Bot().Send(ctx, c.Chat(), reply, &tb.SendOptions{ReplyTo: c.Message(), AllowWithoutReply: true})
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package analytics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/teknologi-umum/captcha/analytics"
+)
+
+func TestEnabled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	const otherGroupID = int64(987654321)
+
+	tests := []struct {
+		name    string
+		groupID int64
+		// set opts the group in or out before checking it.
+		set      bool
+		expected bool
+	}{
+		{name: "home group by default", groupID: dependency.HomeGroupID, expected: true},
+		{name: "other group by default", groupID: otherGroupID, expected: false},
+		{name: "other group opts in", groupID: otherGroupID, set: true, expected: true},
+		{name: "home group opts out", groupID: dependency.HomeGroupID, set: true, expected: false},
+	}
+
+	// The home group has to stay enabled for the other tests.
+	defer func() {
+		err := dependency.SetEnabled(ctx, dependency.HomeGroupID, true)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for _, test := range tests {
+		if test.set {
+			err := dependency.SetEnabled(ctx, test.groupID, test.expected)
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err.Error())
+			}
+		}
+
+		enabled, err := dependency.Enabled(ctx, test.groupID)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err.Error())
+		}
+
+		if enabled != test.expected {
+			t.Errorf("%s: expecting %v, got %v", test.name, test.expected, enabled)
+		}
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	const groupID = int64(24680)
+
+	location, err := dependency.Location(ctx, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if location.String() != analytics.DefaultTimezone {
+		t.Errorf("expecting the default timezone, got %s", location)
+	}
+
+	err = dependency.SetTimezone(ctx, groupID, "Mars/Olympus_Mons")
+	if !errors.Is(err, analytics.ErrInvalidTimezone) {
+		t.Errorf("expecting an invalid timezone error, got %v", err)
+	}
+
+	err = dependency.SetTimezone(ctx, groupID, "Asia/Jayapura")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err = dependency.Location(ctx, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if location.String() != "Asia/Jayapura" {
+		t.Errorf("expecting Asia/Jayapura, got %s", location)
+	}
+
+	// Setting the timezone does not opt the group in.
+	enabled, err := dependency.Enabled(ctx, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if enabled {
+		t.Error("expecting the group to stay opted out")
+	}
+}
@@ -3,7 +3,6 @@ package analytics
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"time"
 
 	"github.com/teknologi-umum/captcha/shared"
@@ -39,9 +38,9 @@ func (d *Dependency) IncrementUserDB(ctx context.Context, member GroupMember) er
 			(user_id, username, display_name, counter, created_at, joined_at, updated_at, group_id)
 			VALUES
 			($1, $2, $3, $4, $5, $6, $7, $8)
-			ON CONFLICT (user_id)
+			ON CONFLICT (group_id, user_id)
 			DO UPDATE
-			SET counter = (SELECT counter FROM analytics WHERE user_id = $1)+$4,
+			SET counter = analytics.counter + $4,
 				username = $2,
 				display_name = $3,
 				updated_at = $7`,
@@ -61,22 +60,40 @@ func (d *Dependency) IncrementUserDB(ctx context.Context, member GroupMember) er
 		return err
 	}
 
-	hourlyQuery := fmt.Sprintf(
-		`INSERT INTO analytics_hourly
-			(todays_date, %s)
+	// The buckets are kept by the hour, the timezone of the group only
+	// matters when they are read.
+	_, err = t.ExecContext(
+		ctx,
+		`INSERT INTO analytics_messages
+			(group_id, bucket_start, count)
 		VALUES
-			($1, 1)
-		ON CONFLICT (todays_date) DO UPDATE
-		SET %s = (SELECT %s FROM analytics_hourly WHERE todays_date = $1)+1`,
-		HourMapper[now.Hour()],
-		HourMapper[now.Hour()],
-		HourMapper[now.Hour()],
+			($1, $2, 1)
+		ON CONFLICT (group_id, bucket_start) DO UPDATE
+		SET count = analytics_messages.count + 1`,
+		member.GroupID.Int64,
+		now.UTC().Truncate(time.Hour),
 	)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+		return err
+	}
 
+	// The messages of each member are bucketed the same way, so the most
+	// active members can be ranked within any period.
 	_, err = t.ExecContext(
 		ctx,
-		hourlyQuery,
-		fmt.Sprintf("%d-%d-%d", now.Year(), now.Month(), now.Day()),
+		`INSERT INTO analytics_member_messages
+			(group_id, user_id, bucket_start, count)
+		VALUES
+			($1, $2, $3, $4)
+		ON CONFLICT (group_id, user_id, bucket_start) DO UPDATE
+		SET count = analytics_member_messages.count + $4`,
+		member.GroupID.Int64,
+		member.UserID,
+		now.UTC().Truncate(time.Hour),
+		member.Counter,
 	)
 	if err != nil {
 		if r := t.Rollback(); r != nil {
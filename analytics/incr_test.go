@@ -47,6 +47,15 @@ func TestIncrementUsrDB(t *testing.T) {
 
 	err := dependency.IncrementUserDB(ctx, users[0])
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	members, err := dependency.TopMembersSince(ctx, 5, time.Now().Add(-time.Hour), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(members) != 1 || members[0].UserID != 1 || members[0].Counter != 10 {
+		t.Errorf("expecting the messages of user 1 to be bucketed, got %+v", members)
 	}
 }
@@ -20,13 +20,23 @@ import (
 // reason, their data should still be here. But, their joined date
 // will be updated to their newest join date.
 func (d *Dependency) NewUser(ctx context.Context, m *tb.Message, user *tb.User) {
-	if !m.FromGroup() || m.Chat.ID != d.HomeGroupID {
+	if !m.FromGroup() {
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	enabled, err := d.Enabled(ctx, m.Chat.ID)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return
+	}
+
+	if !enabled {
+		return
+	}
+
 	c, err := d.DB.Connx(ctx)
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, m)
@@ -51,7 +61,7 @@ func (d *Dependency) NewUser(ctx context.Context, m *tb.Message, user *tb.User)
 			(user_id, group_id, username, display_name, counter, created_at, joined_at, updated_at)
 			VALUES
 			($1, $2, $3, $4, $5, $6, $7, $8)
-			ON CONFLICT (user_id)
+			ON CONFLICT (group_id, user_id)
 			DO UPDATE
 				SET joined_at = $9,
 					updated_at = $10`,
@@ -3,6 +3,10 @@ package analytics
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/teknologi-umum/captcha/shared"
@@ -13,12 +17,13 @@ import (
 
 // MustMigrate is the same as Migrate, but you don't
 // need to explicitly create a Dependency struct
-// instance. Just supply the database, and you're good
-// to go. It will not panic on error, instead it will
-// just return an error.
-func MustMigrate(db *sqlx.DB) error {
+// instance. Just supply the database and the home group,
+// and you're good to go. It will not panic on error,
+// instead it will just return an error.
+func MustMigrate(db *sqlx.DB, homeGroupID int64) error {
 	d := &Dependency{
-		DB: db,
+		DB:          db,
+		HomeGroupID: homeGroupID,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
@@ -30,6 +35,13 @@ func MustMigrate(db *sqlx.DB) error {
 // Migrate creates a migration to the database.
 // This can be called multiple times as it uses PostgreSQL
 // syntax of `IF NOT EXISTS`.
+//
+// The analytics used to only track the home group, keyed by the user
+// and by the date alone. Those rows are moved to the home group, and
+// the tables are keyed by the group as well. The message counts used
+// to live on analytics_hourly, which is moved to analytics_messages.
+// The messages of each member are kept on analytics_member_messages,
+// starting from the first message after it is created.
 func (d *Dependency) Migrate(ctx context.Context) error {
 	c, err := d.DB.Connx(ctx)
 	if err != nil {
@@ -69,14 +81,15 @@ func (d *Dependency) Migrate(ctx context.Context) error {
 	_, err = t.ExecContext(
 		ctx,
 		`CREATE TABLE IF NOT EXISTS analytics (
-			user_id 		BIGINT	 		PRIMARY KEY,
-			group_id 		BIGINT,
+			user_id 		BIGINT	 		NOT NULL,
+			group_id 		BIGINT			NOT NULL,
 			username 		VARCHAR(255),
 			display_name 	VARCHAR(255),
 			counter 		INTEGER 		DEFAULT 0,
 			created_at 		TIMESTAMP 		DEFAULT CURRENT_TIMESTAMP,
 			joined_at 		TIMESTAMP,
-			updated_at 		TIMESTAMP
+			updated_at 		TIMESTAMP,
+			CONSTRAINT analytics_group_user_pkey PRIMARY KEY (group_id, user_id)
 		)`,
 	)
 	if err != nil {
@@ -87,6 +100,39 @@ func (d *Dependency) Migrate(ctx context.Context) error {
 		return err
 	}
 
+	_, err = t.ExecContext(
+		ctx,
+		`UPDATE analytics SET group_id = $1 WHERE group_id IS NULL`,
+		d.HomeGroupID,
+	)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	// The old primary key is user_id alone, so one user can't be counted on two groups.
+	_, err = t.ExecContext(
+		ctx,
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'analytics_group_user_pkey') THEN
+				ALTER TABLE analytics DROP CONSTRAINT IF EXISTS analytics_pkey;
+				ALTER TABLE analytics ADD CONSTRAINT analytics_group_user_pkey PRIMARY KEY (group_id, user_id);
+			END IF;
+		END
+		$$`,
+	)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
 	_, err = t.ExecContext(
 		ctx,
 		`CREATE INDEX IF NOT EXISTS idx_counter ON analytics (counter)`,
@@ -113,32 +159,11 @@ func (d *Dependency) Migrate(ctx context.Context) error {
 
 	_, err = t.ExecContext(
 		ctx,
-		`CREATE TABLE IF NOT EXISTS analytics_hourly (
-			todays_date 		VARCHAR(20)	UNIQUE,
-			zero_hour 			INTEGER 	DEFAULT 0,
-			one_hour 			INTEGER 	DEFAULT 0,
-			two_hour 			INTEGER 	DEFAULT 0,
-			three_hour 			INTEGER 	DEFAULT 0,
-			four_hour 			INTEGER 	DEFAULT 0,
-			five_hour 			INTEGER 	DEFAULT 0,
-			six_hour 			INTEGER 	DEFAULT 0,
-			seven_hour 			INTEGER 	DEFAULT 0,
-			eight_hour 			INTEGER 	DEFAULT 0,
-			nine_hour 			INTEGER 	DEFAULT 0,
-			ten_hour 			INTEGER 	DEFAULT 0,
-			eleven_hour 		INTEGER 	DEFAULT 0,
-			twelve_hour 		INTEGER 	DEFAULT 0,
-			thirteen_hour 		INTEGER 	DEFAULT 0,
-			fourteen_hour 		INTEGER 	DEFAULT 0,
-			fifteen_hour 		INTEGER 	DEFAULT 0,
-			sixteen_hour 		INTEGER 	DEFAULT 0,
-			seventeen_hour 		INTEGER 	DEFAULT 0,
-			eighteen_hour 		INTEGER 	DEFAULT 0,
-			nineteen_hour 		INTEGER 	DEFAULT 0,
-			twenty_hour 		INTEGER 	DEFAULT 0,
-			twentyone_hour 		INTEGER 	DEFAULT 0,
-			twentytwo_hour 		INTEGER 	DEFAULT 0,
-			twentythree_hour 	INTEGER 	DEFAULT 0
+		`CREATE TABLE IF NOT EXISTS analytics_messages (
+			group_id 		BIGINT 			NOT NULL,
+			bucket_start 	TIMESTAMPTZ 	NOT NULL,
+			count 			INTEGER 		NOT NULL DEFAULT 0,
+			PRIMARY KEY (group_id, bucket_start)
 		)`,
 	)
 	if err != nil {
@@ -149,6 +174,74 @@ func (d *Dependency) Migrate(ctx context.Context) error {
 		return err
 	}
 
+	_, err = t.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS analytics_member_messages (
+			group_id 		BIGINT 			NOT NULL,
+			user_id 		BIGINT 			NOT NULL,
+			bucket_start 	TIMESTAMPTZ 	NOT NULL,
+			count 			INTEGER 		NOT NULL DEFAULT 0,
+			PRIMARY KEY (group_id, user_id, bucket_start)
+		)`,
+	)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	err = d.migrateHourly(ctx, t)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	_, err = t.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS analytics_group (
+			group_id 	BIGINT 		PRIMARY KEY,
+			enabled 	BOOLEAN 	NOT NULL,
+			timezone 	VARCHAR(64),
+			updated_at 	TIMESTAMP 	NOT NULL
+		)`,
+	)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	_, err = t.ExecContext(
+		ctx,
+		`ALTER TABLE analytics_group ADD COLUMN IF NOT EXISTS timezone VARCHAR(64)`,
+	)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	_, err = t.ExecContext(
+		ctx,
+		`CREATE INDEX IF NOT EXISTS idx_captcha_swarm_group_joined ON captcha_swarm (group_id, joined_at)`,
+	)
+	if err != nil {
+		if r := t.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
 	err = t.Commit()
 	if err != nil {
 		if r := t.Rollback(); r != nil {
@@ -160,3 +253,103 @@ func (d *Dependency) Migrate(ctx context.Context) error {
 
 	return nil
 }
+
+// legacyHourColumns are the columns of analytics_hourly, one for each hour of the day.
+var legacyHourColumns = [24]string{
+	"zero_hour", "one_hour", "two_hour", "three_hour", "four_hour", "five_hour",
+	"six_hour", "seven_hour", "eight_hour", "nine_hour", "ten_hour", "eleven_hour",
+	"twelve_hour", "thirteen_hour", "fourteen_hour", "fifteen_hour", "sixteen_hour",
+	"seventeen_hour", "eighteen_hour", "nineteen_hour", "twenty_hour", "twentyone_hour",
+	"twentytwo_hour", "twentythree_hour",
+}
+
+// migrateHourly moves the rows of analytics_hourly, which has a column for
+// each hour of a day, to analytics_messages in a single statement, then drops
+// the table. The dates and hours were written on the local time of the bot,
+// so they are read on the local time as well.
+func (d *Dependency) migrateHourly(ctx context.Context, t *sqlx.Tx) error {
+	var exists bool
+	err := t.GetContext(ctx, &exists, `SELECT to_regclass('analytics_hourly') IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	// The oldest tables don't have a group, they only covered the home group.
+	_, err = t.ExecContext(ctx, `ALTER TABLE analytics_hourly ADD COLUMN IF NOT EXISTS group_id BIGINT`)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(legacyHourColumns))
+	for _, column := range legacyHourColumns {
+		columns = append(columns, "COALESCE("+column+", 0)")
+	}
+
+	// The hours are numbered from 1 by WITH ORDINALITY. A date might have been
+	// written more than once, so the buckets are added up before the insert.
+	_, err = t.ExecContext(
+		ctx,
+		`INSERT INTO analytics_messages
+			(group_id, bucket_start, count)
+		SELECT
+			COALESCE(analytics_hourly.group_id, $1),
+			(CAST(analytics_hourly.todays_date AS DATE) + make_interval(hours => hours.hour::INTEGER - 1))
+				AT TIME ZONE COALESCE(NULLIF($2, ''), current_setting('TimeZone')),
+			SUM(hours.count)
+		FROM
+			analytics_hourly
+			CROSS JOIN LATERAL unnest(ARRAY[`+strings.Join(columns, ", ")+`]) WITH ORDINALITY AS hours(count, hour)
+		WHERE
+			analytics_hourly.todays_date IS NOT NULL
+			AND hours.count > 0
+		GROUP BY
+			1, 2
+		ON CONFLICT (group_id, bucket_start) DO UPDATE
+		SET count = analytics_messages.count + EXCLUDED.count`,
+		d.HomeGroupID,
+		localTimezone(),
+	)
+	if err != nil {
+		return fmt.Errorf("moving analytics_hourly to analytics_messages: %w", err)
+	}
+
+	_, err = t.ExecContext(ctx, `DROP TABLE analytics_hourly`)
+	return err
+}
+
+// localTimezone returns the IANA name of the local time of the bot, the same
+// way the time package finds it, or an empty string if it has no name.
+func localTimezone() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		if tz == "" {
+			return "UTC"
+		}
+
+		_, err := time.LoadLocation(tz)
+		if err != nil {
+			return ""
+		}
+
+		return tz
+	}
+
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "UTC"
+		}
+
+		return ""
+	}
+
+	_, name, ok := strings.Cut(target, "zoneinfo/")
+	if !ok {
+		return ""
+	}
+
+	return name
+}
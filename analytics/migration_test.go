@@ -0,0 +1,79 @@
+package analytics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateHourly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	columns := []string{"todays_date VARCHAR(20) UNIQUE"}
+	for _, hour := range []string{
+		"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "eleven", "twelve",
+		"thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen", "twenty", "twentyone",
+		"twentytwo", "twentythree",
+	} {
+		columns = append(columns, hour+"_hour INTEGER DEFAULT 0")
+	}
+
+	// The table before the analytics covered every group.
+	_, err := dependency.DB.ExecContext(ctx, "CREATE TABLE analytics_hourly ("+strings.Join(columns, ", ")+")")
+	if err != nil {
+		t.Fatalf("creating analytics_hourly: %s", err.Error())
+	}
+
+	_, err = dependency.DB.ExecContext(ctx, "INSERT INTO analytics_hourly (todays_date, one_hour, twentythree_hour) VALUES ('2020-1-2', 4, 6)")
+	if err != nil {
+		t.Fatalf("inserting analytics_hourly: %s", err.Error())
+	}
+
+	defer func() {
+		_, err := dependency.DB.ExecContext(context.Background(), "DELETE FROM analytics_messages WHERE bucket_start < '2021-01-01'")
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	err = dependency.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("migrating: %s", err.Error())
+	}
+
+	var exists bool
+	err = dependency.DB.GetContext(ctx, &exists, "SELECT to_regclass('analytics_hourly') IS NOT NULL")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exists {
+		t.Error("expecting analytics_hourly to be dropped")
+	}
+
+	for hour, expected := range map[int]int{1: 4, 23: 6} {
+		var count int
+		err := dependency.DB.GetContext(
+			ctx,
+			&count,
+			"SELECT count FROM analytics_messages WHERE group_id = $1 AND bucket_start = $2",
+			dependency.HomeGroupID,
+			time.Date(2020, time.January, 2, hour, 0, 0, 0, time.Local),
+		)
+		if err != nil {
+			t.Fatalf("selecting the bucket of %d o'clock: %s", hour, err.Error())
+		}
+
+		if count != expected {
+			t.Errorf("expecting %d messages at %d o'clock, got %d", expected, hour, count)
+		}
+	}
+
+	// Migrating again changes nothing.
+	err = dependency.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("migrating again: %s", err.Error())
+	}
+}
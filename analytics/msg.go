@@ -15,17 +15,22 @@ func (d *Dependency) NewMessage(m *tb.Message) error {
 		return nil
 	}
 
-	if m.Chat.ID != d.HomeGroupID {
-		return nil
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	enabled, err := d.Enabled(ctx, m.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return nil
+	}
+
 	usr := ParseGroupMember(m)
 	usr.Counter = 1
 
-	err := d.IncrementUserDB(ctx, usr)
+	err = d.IncrementUserDB(ctx, usr)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,188 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/teknologi-umum/captcha/shared"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// DailyJoin is the amount of users that joined a group on a day,
+// and how many of them finished their captcha.
+type DailyJoin struct {
+	Day      time.Time `json:"day" db:"day"`
+	Joined   int       `json:"joined" db:"joined"`
+	Finished int       `json:"finished" db:"finished"`
+}
+
+// Heatmap adds up the hourly buckets by weekday, from Monday, and by hour,
+// on the timezone of the buckets.
+func Heatmap(buckets []Bucket) [7][24]int {
+	var cells [7][24]int
+	for _, bucket := range buckets {
+		weekday := (int(bucket.Start.Weekday()) + 6) % 7
+		cells[weekday][bucket.Start.Hour()] += bucket.Count
+	}
+
+	return cells
+}
+
+// TopMembers returns the members of the group with the most messages, up to limit.
+func (d *Dependency) TopMembers(ctx context.Context, groupID int64, limit int) ([]GroupMember, error) {
+	var members []GroupMember
+	err := d.readOnly(ctx, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(
+			ctx,
+			&members,
+			`SELECT
+				user_id,
+				group_id,
+				COALESCE(username, '') AS username,
+				COALESCE(display_name, '') AS display_name,
+				counter,
+				COALESCE(created_at, NOW()) AS created_at,
+				COALESCE(joined_at, created_at, NOW()) AS joined_at,
+				COALESCE(updated_at, created_at, NOW()) AS updated_at
+			FROM
+				analytics
+			WHERE
+				group_id = $1
+				AND counter > 0
+			ORDER BY
+				counter DESC
+			LIMIT $2`,
+			groupID,
+			limit,
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting top members: %w", err)
+	}
+
+	return members, nil
+}
+
+// TopMembersSince returns the members of the group with the most messages
+// since the given time, up to limit. Their Counter only has the messages
+// sent within that time.
+func (d *Dependency) TopMembersSince(ctx context.Context, groupID int64, since time.Time, limit int) ([]GroupMember, error) {
+	var members []GroupMember
+	err := d.readOnly(ctx, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(
+			ctx,
+			&members,
+			`SELECT
+				analytics.user_id,
+				analytics.group_id,
+				COALESCE(analytics.username, '') AS username,
+				COALESCE(analytics.display_name, '') AS display_name,
+				SUM(analytics_member_messages.count) AS counter,
+				COALESCE(analytics.created_at, NOW()) AS created_at,
+				COALESCE(analytics.joined_at, analytics.created_at, NOW()) AS joined_at,
+				COALESCE(analytics.updated_at, analytics.created_at, NOW()) AS updated_at
+			FROM
+				analytics_member_messages
+				JOIN analytics
+					ON analytics.group_id = analytics_member_messages.group_id
+					AND analytics.user_id = analytics_member_messages.user_id
+			WHERE
+				analytics_member_messages.group_id = $1
+				AND analytics_member_messages.bucket_start >= $2
+			GROUP BY
+				analytics.group_id,
+				analytics.user_id
+			ORDER BY
+				SUM(analytics_member_messages.count) DESC,
+				analytics.user_id
+			LIMIT $3`,
+			groupID,
+			since,
+			limit,
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting top members since %s: %w", since, err)
+	}
+
+	return members, nil
+}
+
+// DailyJoins returns the joins of the group for each day since the given time,
+// oldest first, split on midnight of the timezone of the group. Days
+// without any join are left out.
+func (d *Dependency) DailyJoins(ctx context.Context, groupID int64, since time.Time) ([]DailyJoin, error) {
+	location, err := d.Location(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	// joined_at has no timezone, it is written on the local time of the bot.
+	var joins []DailyJoin
+	err = d.readOnly(ctx, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(
+			ctx,
+			&joins,
+			`SELECT
+				date_trunc('day', joined_at AT TIME ZONE COALESCE(NULLIF($3, ''), current_setting('TimeZone')), $4) AS day,
+				COUNT(*) AS joined,
+				COUNT(*) FILTER (WHERE finished_captcha) AS finished
+			FROM
+				captcha_swarm
+			WHERE
+				group_id = $1
+				AND joined_at AT TIME ZONE COALESCE(NULLIF($3, ''), current_setting('TimeZone')) >= $2
+			GROUP BY
+				day
+			ORDER BY
+				day`,
+			groupID,
+			since,
+			localTimezone(),
+			location.String(),
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting daily joins since %s: %w", since, err)
+	}
+
+	for i := range joins {
+		joins[i].Day = joins[i].Day.In(location)
+	}
+
+	return joins, nil
+}
+
+// readOnly runs fn inside a read only transaction.
+func (d *Dependency) readOnly(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	c, err := d.DB.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(c *sqlx.Conn) {
+		err := c.Close()
+		if err != nil && !errors.Is(err, sql.ErrConnDone) {
+			shared.HandleError(ctx, err)
+		}
+	}(c)
+
+	tx, err := c.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		if r := tx.Rollback(); r != nil {
+			return r
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
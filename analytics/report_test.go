@@ -0,0 +1,56 @@
+package analytics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/captcha/analytics"
+)
+
+func TestHeatmap(t *testing.T) {
+	jakarta, err := analytics.LoadTimezone("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cells := analytics.Heatmap([]analytics.Bucket{
+		{Start: time.Date(2023, time.January, 2, 1, 0, 0, 0, jakarta), Count: 3},
+		{Start: time.Date(2023, time.January, 8, 23, 0, 0, 0, jakarta), Count: 5},
+		{Start: time.Date(2023, time.January, 9, 1, 0, 0, 0, jakarta), Count: 2},
+	})
+
+	if cells[0][1] != 5 || cells[6][23] != 5 || cells[0][0] != 0 {
+		t.Errorf("unexpected heatmap: %v", cells)
+	}
+}
+
+func TestTopMembers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	members, err := dependency.TopMembers(ctx, dependency.HomeGroupID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(members) == 0 || members[0].UserID != 90 || members[0].Counter != 1 {
+		t.Errorf("expecting user 90 on top, got %+v", members)
+	}
+}
+
+func TestDailyJoins(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	joins, err := dependency.DailyJoins(ctx, dependency.HomeGroupID, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, join := range joins {
+		if join.Day.Location().String() != analytics.DefaultTimezone || join.Day.Hour() != 0 {
+			t.Errorf("expecting the day to start at midnight on %s, got %v", analytics.DefaultTimezone, join.Day)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/shared"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// statsPeriods are the days each summary of /stats goes back, the longest last.
+var statsPeriods = []int{7, 30, 90}
+
+// statsTopLimit is how many of the most active members are listed for each period.
+const statsTopLimit = 5
+
+var weekdays = [7]string{"Sen", "Sel", "Rab", "Kam", "Jum", "Sab", "Min"}
+
+// heatmapShades go from an hour without any message to the busiest hour.
+var heatmapShades = []rune("·░▒▓█")
+
+// PeriodStats summarizes a group over the last days.
+type PeriodStats struct {
+	Days     int
+	Messages int
+	// NewMembers joined within the period, Passed of them finished their captcha.
+	NewMembers int
+	Passed     int
+	// TopMembers sent the most messages within the period, their Counter
+	// only has the messages within the period.
+	TopMembers []GroupMember
+}
+
+// Stats summarizes the group for each of the statsPeriods, ending at now.
+// The periods start at midnight on the timezone of the group.
+func (d *Dependency) Stats(ctx context.Context, groupID int64, now time.Time) ([]PeriodStats, error) {
+	location, err := d.Location(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	longest := statsPeriods[len(statsPeriods)-1]
+	since := startOfDay(now.In(location).AddDate(0, 0, -longest))
+
+	daily, err := d.GetMessagesFromDB(ctx, groupID, since, RollupDaily)
+	if err != nil {
+		return nil, err
+	}
+
+	joins, err := d.DailyJoins(ctx, groupID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []PeriodStats
+	for _, days := range statsPeriods {
+		start := startOfDay(now.In(location).AddDate(0, 0, -days))
+		period := PeriodStats{Days: days}
+
+		period.TopMembers, err = d.TopMembersSince(ctx, groupID, start, statsTopLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bucket := range daily {
+			if !bucket.Start.Before(start) {
+				period.Messages += bucket.Count
+			}
+		}
+
+		for _, join := range joins {
+			if !join.Day.Before(start) {
+				period.NewMembers += join.Joined
+				period.Passed += join.Finished
+			}
+		}
+
+		stats = append(stats, period)
+	}
+
+	return stats, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Member returns the member of the group with the username, and false if the
+// member has not sent a message there since the given time.
+func (d *Dependency) Member(ctx context.Context, groupID int64, username string, since time.Time) (GroupMember, bool, error) {
+	members, err := d.GetUserDataFromDB(ctx, groupID, since)
+	if err != nil {
+		return GroupMember{}, false, fmt.Errorf("getting members: %w", err)
+	}
+
+	username = strings.TrimPrefix(username, "@")
+	for _, member := range members {
+		if strings.EqualFold(member.Username, username) {
+			return member, true, nil
+		}
+	}
+
+	return GroupMember{}, false, nil
+}
+
+// StatsHandler handles the /stats command. Without any argument, it sends the
+// summaries of the group. "/stats hours" sends the messages by weekday and hour,
+// and "/stats @username" sends the record of one member.
+func (d *Dependency) StatsHandler(ctx context.Context, c tb.Context) error {
+	if c.Message().Private() || c.Sender().IsBot {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "bot.stats_handler", sentry.WithTransactionSource(sentry.SourceTask),
+		sentry.WithTransactionName("Analytics StatsHandler"))
+	defer span.Finish()
+	ctx = span.Context()
+
+	groupID := c.Chat().ID
+	enabled, err := d.Enabled(ctx, groupID)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	location, err := d.Location(ctx, groupID)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	var text string
+	switch args := c.Args(); {
+	case !enabled:
+		text = "Analytics untuk grup ini sedang mati, jadi belum ada statistik. Admin grup bisa menyalakannya dengan /analytics on."
+	case len(args) == 0:
+		var stats []PeriodStats
+		stats, err = d.Stats(ctx, groupID, time.Now())
+		text = formatStats(stats)
+	case strings.EqualFold(args[0], "hours"):
+		var hourly []Bucket
+		hourly, err = d.GetMessagesFromDB(ctx, groupID, time.Now().AddDate(0, 0, -statsPeriods[len(statsPeriods)-1]), RollupHourly)
+		text = formatHours(Heatmap(hourly), location)
+	case strings.HasPrefix(args[0], "@") && len(args[0]) > 1:
+		var member GroupMember
+		var ok bool
+		longest := statsPeriods[len(statsPeriods)-1]
+		member, ok, err = d.Member(ctx, groupID, args[0], time.Now().AddDate(0, 0, -longest))
+		text = "Belum ada catatan untuk " + html.EscapeString(args[0]) + " di grup ini dalam " + strconv.Itoa(longest) + " hari terakhir."
+		if ok {
+			text = formatMember(member, location)
+		}
+	default:
+		text = "Kirim /stats untuk ringkasan grup, /stats hours untuk pesan per jam, atau /stats @username untuk satu anggota."
+	}
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	_, err = c.Bot().Send(
+		ctx,
+		c.Chat(),
+		text,
+		&tb.SendOptions{ParseMode: tb.ModeHTML, ReplyTo: c.Message(), AllowWithoutReply: true, DisableWebPagePreview: true},
+	)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
+
+	return nil
+}
+
+func displayName(member GroupMember) string {
+	name := html.EscapeString(member.DisplayName)
+	if member.Username != "" {
+		name += " (@" + html.EscapeString(member.Username) + ")"
+	}
+
+	return name
+}
+
+func formatStats(stats []PeriodStats) string {
+	var text strings.Builder
+	text.WriteString("<b>Statistik grup</b>\n")
+
+	for _, period := range stats {
+		text.WriteString("\n<b>" + strconv.Itoa(period.Days) + " hari terakhir</b>\n")
+		text.WriteString("Pesan: " + strconv.Itoa(period.Messages) + "\n")
+		text.WriteString("Anggota baru: " + strconv.Itoa(period.NewMembers))
+		if period.NewMembers > 0 {
+			text.WriteString(", " + strconv.Itoa(period.Passed) + " lolos captcha (" +
+				strconv.Itoa(period.Passed*100/period.NewMembers) + "%)")
+		}
+		text.WriteString("\n")
+
+		if len(period.TopMembers) > 0 {
+			text.WriteString("Paling aktif:\n")
+		}
+
+		for i, member := range period.TopMembers {
+			text.WriteString(strconv.Itoa(i+1) + ". " + displayName(member) + ": " + strconv.Itoa(member.Counter) + " pesan\n")
+		}
+	}
+
+	return text.String()
+}
+
+func formatHours(cells [7][24]int, location *time.Location) string {
+	var highest, busiestHour int
+	var hours [24]int
+	for _, row := range cells {
+		for hour, value := range row {
+			highest = max(highest, value)
+			hours[hour] += value
+		}
+	}
+
+	for hour, value := range hours {
+		if value > hours[busiestHour] {
+			busiestHour = hour
+		}
+	}
+
+	var text strings.Builder
+	text.WriteString("<b>Pesan per jam dalam " + strconv.Itoa(statsPeriods[len(statsPeriods)-1]) + " hari terakhir</b>\n")
+	text.WriteString("Zona waktu " + html.EscapeString(location.String()) + "\n<pre>")
+	text.WriteString("    00    06    12    18\n")
+
+	for day, row := range cells {
+		text.WriteString(weekdays[day] + " ")
+
+		for _, value := range row {
+			shade := 0
+			if value > 0 {
+				shade = (value*(len(heatmapShades)-1) + highest - 1) / highest
+			}
+
+			text.WriteRune(heatmapShades[shade])
+		}
+
+		text.WriteString("\n")
+	}
+
+	text.WriteString("</pre>")
+
+	if highest > 0 {
+		text.WriteString("Jam tersibuk: " + strconv.Itoa(busiestHour) + ".00, " + strconv.Itoa(hours[busiestHour]) + " pesan")
+	}
+
+	return text.String()
+}
+
+func formatMember(member GroupMember, location *time.Location) string {
+	return "<b>" + displayName(member) + "</b>\n" +
+		"Pesan: " + strconv.Itoa(member.Counter) + "\n" +
+		"Bergabung: " + member.JoinedAt.In(location).Format("02 Jan 2006") + "\n" +
+		"Terakhir aktif: " + member.UpdatedAt.In(location).Format("02 Jan 2006 15:04 MST")
+}
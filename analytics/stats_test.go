@@ -0,0 +1,113 @@
+package analytics_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/analytics"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+func TestStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	stats, err := dependency.Stats(ctx, dependency.HomeGroupID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 3 || stats[0].Days != 7 || stats[2].Days != 90 {
+		t.Fatalf("expecting the stats of 7, 30 and 90 days, got %+v", stats)
+	}
+
+	// The seeded hourly rows add up to 78 messages within the last 3 days.
+	if stats[0].Messages < 78 || stats[2].Messages != stats[0].Messages {
+		t.Errorf("expecting every period to count the seeded messages, got %d and %d", stats[0].Messages, stats[2].Messages)
+	}
+
+	// Each period only counts the messages of the members within it.
+	for i, expected := range []int{1, 3, 3} {
+		var counter int
+		for _, member := range stats[i].TopMembers {
+			if member.UserID == 90 {
+				counter = member.Counter
+			}
+		}
+
+		if counter != expected {
+			t.Errorf("expecting user 90 with %d messages over %d days, got %+v", expected, stats[i].Days, stats[i].TopMembers)
+		}
+	}
+
+	other, err := dependency.Stats(ctx, 987654321, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other[2].Messages != 0 || len(other[2].TopMembers) != 0 {
+		t.Errorf("expecting another group to have nothing, got %+v", other[2])
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(telegram)
+	defer server.Close()
+
+	bot, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "token", Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatalf("creating bot: %s", err.Error())
+	}
+
+	statsDependency := &analytics.Dependency{
+		DB:          dependency.DB,
+		Memory:      dependency.Memory,
+		Bot:         bot,
+		HomeGroupID: dependency.HomeGroupID,
+	}
+
+	tests := []struct {
+		payload  string
+		chatID   int64
+		expected string
+	}{
+		{payload: "", chatID: dependency.HomeGroupID, expected: "User 1 (@user1): 1 pesan"},
+		{payload: "hours", chatID: dependency.HomeGroupID, expected: "Jam tersibuk"},
+		{payload: "@User1", chatID: dependency.HomeGroupID, expected: "<b>User 1 (@user1)</b>"},
+		{payload: "@nobody", chatID: dependency.HomeGroupID, expected: "Belum ada catatan untuk @nobody"},
+		{payload: "", chatID: 555, expected: "/analytics on"},
+	}
+
+	for _, test := range tests {
+		c := bot.NewContext(tb.Update{Message: &tb.Message{
+			ID:      1,
+			Chat:    &tb.Chat{ID: test.chatID, Type: tb.ChatSuperGroup},
+			Sender:  &tb.User{ID: 90, Username: "user1"},
+			Text:    strings.TrimSpace("/stats " + test.payload),
+			Payload: test.payload,
+		}})
+
+		err := statsDependency.StatsHandler(ctx, c)
+		if err != nil {
+			t.Fatalf("/stats %s: %s", test.payload, err.Error())
+		}
+
+		telegram.mu.Lock()
+		sent := telegram.sent
+		telegram.sent = nil
+		telegram.mu.Unlock()
+
+		if len(sent) != 1 || !strings.Contains(sent[0], test.expected) {
+			t.Errorf("/stats %s: expecting a reply with %q, got %q", test.payload, test.expected, sent)
+		}
+	}
+}
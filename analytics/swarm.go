@@ -13,12 +13,18 @@ import (
 )
 
 func (d *Dependency) SwarmLog(user *tb.User, groupID int64, finishedCaptcha bool) {
-	if groupID != d.HomeGroupID {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	enabled, err := d.Enabled(ctx, groupID)
+	if err != nil {
+		shared.HandleError(ctx, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
+	if !enabled {
+		return
+	}
 
 	c, err := d.DB.Connx(ctx)
 	if err != nil {
@@ -69,12 +75,18 @@ func (d *Dependency) SwarmLog(user *tb.User, groupID int64, finishedCaptcha bool
 }
 
 func (d *Dependency) UpdateSwarm(user *tb.User, groupID int64, finishedCaptcha bool) {
-	if groupID != d.HomeGroupID {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	enabled, err := d.Enabled(ctx, groupID)
+	if err != nil {
+		shared.HandleError(ctx, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
+	if !enabled {
+		return
+	}
 
 	c, err := d.DB.Connx(ctx)
 	if err != nil {
@@ -135,7 +147,7 @@ func (d *Dependency) PurgeBots(ctx context.Context, m *tb.Message) {
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	c, err := d.DB.Connx(ctx)
@@ -158,9 +170,12 @@ func (d *Dependency) PurgeBots(ctx context.Context, m *tb.Message) {
 		FROM
 			captcha_swarm
 		WHERE
+			group_id = $1
+		AND
 			finished_captcha = false
 		AND
 			joined_at > NOW() - INTERVAL '1 day'`,
+		m.Chat.ID,
 	)
 	if err != nil {
 		if e := tx.Rollback(); e != nil {
@@ -213,8 +228,11 @@ func (d *Dependency) PurgeBots(ctx context.Context, m *tb.Message) {
 			`DELETE FROM
 				captcha_swarm
 			WHERE
-				user_id = $1`,
+				user_id = $1
+			AND
+				group_id = $2`,
 			userID,
+			m.Chat.ID,
 		)
 		if err != nil {
 			if e := tx.Rollback(); e != nil {
@@ -0,0 +1,203 @@
+package analytics_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+	"github.com/teknologi-umum/captcha/analytics"
+	"github.com/teknologi-umum/captcha/captcha"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// fakeTelegram answers every Bot API method with a plausible result,
+// and keeps the users that were banned and the messages that were sent.
+type fakeTelegram struct {
+	mu     sync.Mutex
+	banned []string
+	sent   []string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	var params map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&params)
+
+	var result string
+	switch method {
+	case "getChatAdministrators":
+		result = `[{"status":"creator","user":{"id":1,"first_name":"Admin"}}]`
+	case "sendMessage":
+		f.mu.Lock()
+		f.sent = append(f.sent, fmt.Sprint(params["text"]))
+		f.mu.Unlock()
+		result = `{"message_id":100,"date":0,"chat":{"id":123456789,"type":"supergroup"}}`
+	case "kickChatMember":
+		f.mu.Lock()
+		f.banned = append(f.banned, fmt.Sprint(params["user_id"]))
+		f.mu.Unlock()
+		result = `true`
+	default:
+		result = `true`
+	}
+
+	_, _ = io.WriteString(w, `{"ok":true,"result":`+result+`}`)
+}
+
+// finishedCaptcha returns the finished_captcha of the user on captcha_swarm.
+func finishedCaptcha(ctx context.Context, t *testing.T, userID int64) (bool, bool) {
+	var finished bool
+	err := dependency.DB.QueryRowContext(
+		ctx,
+		"SELECT finished_captcha FROM captcha_swarm WHERE user_id = $1 AND group_id = $2",
+		userID,
+		dependency.HomeGroupID,
+	).Scan(&finished)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, false
+		}
+
+		t.Fatalf("selecting captcha swarm: %s", err.Error())
+	}
+
+	return finished, true
+}
+
+// eventually polls the condition until it holds or the context is done, since
+// the captcha swarm is written in the background.
+func eventually(ctx context.Context, condition func() bool) bool {
+	for !condition() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	return true
+}
+
+// TestCaptchaSwarm goes through a join that completes the captcha, a join that
+// does not, and purges the latter with /purgebots.
+func TestCaptchaSwarm(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(telegram)
+	defer server.Close()
+
+	bot, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "token", Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatalf("creating bot: %s", err.Error())
+	}
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("opening badger: %s", err.Error())
+	}
+	defer db.Close()
+
+	memory, err := bigcache.New(ctx, bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("creating bigcache: %s", err.Error())
+	}
+
+	analyticsDependency := &analytics.Dependency{
+		DB:          dependency.DB,
+		Memory:      dependency.Memory,
+		Bot:         bot,
+		HomeGroupID: dependency.HomeGroupID,
+	}
+	captchaDependency := &captcha.Dependencies{
+		DB:            db,
+		Memory:        memory,
+		Bot:           bot,
+		TeknumGroupID: dependency.HomeGroupID,
+		Analytics:     analyticsDependency,
+	}
+
+	chat := &tb.Chat{ID: dependency.HomeGroupID, Type: tb.ChatSuperGroup}
+	human := &tb.User{ID: 1001, FirstName: "Human"}
+	robot := &tb.User{ID: 1002, FirstName: "Robot"}
+
+	captchaDependency.CaptchaUserJoin(ctx, &tb.Message{ID: 1, Chat: chat, Sender: human, UserJoined: human}, captcha.DifficultyStandard)
+	eventually(ctx, func() bool {
+		_, ok := finishedCaptcha(ctx, t, human.ID)
+		return ok
+	})
+	if finished, ok := finishedCaptcha(ctx, t, human.ID); !ok || finished {
+		t.Fatalf("expecting the join to be logged without a finished captcha, got %v %v", finished, ok)
+	}
+
+	var answer captcha.Captcha
+	err = db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(strconv.FormatInt(chat.ID, 10) + ":" + strconv.FormatInt(human.ID, 10)))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(value []byte) error {
+			return json.Unmarshal(value, &answer)
+		})
+	})
+	if err != nil {
+		t.Fatalf("reading captcha: %s", err.Error())
+	}
+
+	captchaDependency.WaitForAnswer(ctx, &tb.Message{ID: 2, Chat: chat, Sender: human, Text: answer.Answer})
+	finished := eventually(ctx, func() bool {
+		finished, _ := finishedCaptcha(ctx, t, human.ID)
+		return finished
+	})
+	if !finished {
+		t.Errorf("expecting the captcha to be finished after answering it")
+	}
+
+	captchaDependency.CaptchaUserJoin(ctx, &tb.Message{ID: 3, Chat: chat, Sender: robot, UserJoined: robot}, captcha.DifficultyStandard)
+	eventually(ctx, func() bool {
+		_, ok := finishedCaptcha(ctx, t, robot.ID)
+		return ok
+	})
+
+	// Only an admin may purge the bots.
+	analyticsDependency.PurgeBots(ctx, &tb.Message{ID: 4, Chat: chat, Sender: robot})
+	if _, ok := finishedCaptcha(ctx, t, robot.ID); !ok {
+		t.Fatalf("expecting a non admin to be ignored")
+	}
+
+	analyticsDependency.PurgeBots(ctx, &tb.Message{ID: 5, Chat: chat, Sender: &tb.User{ID: 1}})
+
+	telegram.mu.Lock()
+	banned := slices.Clone(telegram.banned)
+	telegram.mu.Unlock()
+
+	if !slices.Equal(banned, []string{"1002"}) {
+		t.Errorf("expecting only the robot to be banned, got %v", banned)
+	}
+
+	if _, ok := finishedCaptcha(ctx, t, robot.ID); ok {
+		t.Errorf("expecting the robot to be removed from captcha swarm")
+	}
+
+	if _, ok := finishedCaptcha(ctx, t, human.ID); !ok {
+		t.Errorf("expecting the human to stay on captcha swarm")
+	}
+}
@@ -0,0 +1,22 @@
+// Package cache provides the key-value cache that is shared by the features,
+// backed by either bigcache in the process or Redis across replicas.
+package cache
+
+import (
+	"github.com/allegro/bigcache/v3"
+)
+
+// ErrNotFound is returned by Get and Delete when the key does not exist. It is
+// the same error as bigcache.ErrEntryNotFound, so a *bigcache.BigCache can be
+// used as a Cache as it is.
+var ErrNotFound = bigcache.ErrEntryNotFound
+
+// Cache is a key-value cache. Entries may be evicted at any time, so it must
+// not hold anything that can't be recomputed.
+type Cache interface {
+	Get(key string) ([]byte, error)
+	Set(key string, entry []byte) error
+	Delete(key string) error
+}
+
+var _ Cache = (*bigcache.BigCache)(nil)
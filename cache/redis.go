@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Cache backed by Redis, so several replicas of the bot can share it.
+type Redis struct {
+	client *redis.Client
+	// ttl is how long an entry lives, like the life window of bigcache.
+	ttl time.Duration
+	// timeout bounds every call, since the Cache methods do not take a context.
+	timeout time.Duration
+}
+
+var _ Cache = (*Redis)(nil)
+
+// NewRedis creates a Cache whose entries live for ttl.
+func NewRedis(client *redis.Client, ttl time.Duration) (*Redis, error) {
+	if client == nil {
+		return nil, fmt.Errorf("nil client")
+	}
+
+	if ttl <= 0 {
+		return nil, fmt.Errorf("ttl must be positive")
+	}
+
+	return &Redis{client: client, ttl: ttl, timeout: time.Second * 5}, nil
+}
+
+// Get returns the entry of the key, or ErrNotFound if there is none.
+func (r *Redis) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	value, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Set stores the entry of the key.
+func (r *Redis) Set(key string, entry []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return r.client.Set(ctx, key, entry, r.ttl).Err()
+}
+
+// Delete removes the entry of the key, or returns ErrNotFound if there is none.
+func (r *Redis) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	deleted, err := r.client.Del(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
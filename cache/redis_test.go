@@ -0,0 +1,83 @@
+package cache_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/teknologi-umum/captcha/cache"
+)
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	memory, err := cache.NewRedis(client, time.Hour)
+	if err != nil {
+		t.Fatalf("creating redis cache: %s", err.Error())
+	}
+
+	t.Run("Nil client", func(t *testing.T) {
+		_, err := cache.NewRedis(nil, time.Hour)
+		if err == nil {
+			t.Error("expecting an error, got nil")
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := memory.Get("missing")
+		if !errors.Is(err, cache.ErrNotFound) {
+			t.Errorf("expecting ErrNotFound, got %v", err)
+		}
+
+		err = memory.Delete("missing")
+		if !errors.Is(err, cache.ErrNotFound) {
+			t.Errorf("expecting ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Set, get and delete", func(t *testing.T) {
+		err := memory.Set("key", []byte("value"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		value, err := memory.Get("key")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if string(value) != "value" {
+			t.Errorf("expecting %q, got %q", "value", value)
+		}
+
+		err = memory.Delete("key")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		_, err = memory.Get("key")
+		if !errors.Is(err, cache.ErrNotFound) {
+			t.Errorf("expecting ErrNotFound after delete, got %v", err)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		err := memory.Set("expiring", []byte("value"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		server.FastForward(time.Hour + time.Second)
+
+		_, err = memory.Get("expiring")
+		if !errors.Is(err, cache.ErrNotFound) {
+			t.Errorf("expecting the entry to expire, got %v", err)
+		}
+	})
+}
@@ -97,6 +97,9 @@ func (d *Dependencies) WaitForAnswer(ctx context.Context, m *tb.Message) {
 			return
 		}
 
+		wrongTotal.Inc(chatLabel(m.Chat.ID))
+		d.audit(ctx, AuditEvent{ChatID: m.Chat.ID, UserID: m.Sender.ID, Action: AuditWrongAnswer})
+
 		wrongMsg, err := d.Bot.Send(
 			ctx,
 			m.Chat,
@@ -111,7 +114,7 @@ func (d *Dependencies) WaitForAnswer(ctx context.Context, m *tb.Message) {
 			},
 		)
 		if err != nil {
-			if strings.Contains(err.Error(), "retry after") {
+			if tb.IsRetryable(err) {
 				// If this happens, probably we're in a spam bot surge and would
 				// probably don't care with the user captcha after all.
 				// If they're human, they'll complete the captcha anyway,
@@ -120,11 +123,6 @@ func (d *Dependencies) WaitForAnswer(ctx context.Context, m *tb.Message) {
 				return
 			}
 
-			if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-				// Yep, including this one.
-				return
-			}
-
 			shared.HandleBotError(ctx, err, d.Bot, m)
 			return
 		}
@@ -138,10 +136,29 @@ func (d *Dependencies) WaitForAnswer(ctx context.Context, m *tb.Message) {
 		return
 	}
 
-	err = d.removeUserFromCache(ctx, m.Sender.ID, m.Chat.ID)
+	err = d.accept(ctx, m, captcha, "")
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, m)
-		return
+	}
+}
+
+// accept lets the sender of the message in, as they have completed the captcha.
+// The actor is who passed them on their behalf, empty if they answered it themselves.
+func (d *Dependencies) accept(ctx context.Context, m *tb.Message, captcha Captcha, actor string) error {
+	err := d.removeUserFromCache(ctx, m.Sender.ID, m.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	passedTotal.Inc(chatLabel(m.Chat.ID))
+	pending.Dec(chatLabel(m.Chat.ID))
+	solveSeconds.Observe(solveDuration(captcha).Seconds())
+	d.audit(ctx, AuditEvent{ChatID: m.Chat.ID, UserID: m.Sender.ID, Action: AuditPassed, Actor: actor})
+
+	if d.Analytics != nil {
+		d.writeSwarm(m.Chat.ID, m.Sender.ID, func() {
+			d.Analytics.UpdateSwarm(m.Sender, m.Chat.ID, true)
+		})
 	}
 
 	sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
@@ -156,12 +173,18 @@ func (d *Dependencies) WaitForAnswer(ctx context.Context, m *tb.Message) {
 		Timestamp: time.Now(),
 	}, &sentry.BreadcrumbHint{})
 
+	if d.Probation != nil {
+		err = d.Probation.Start(ctx, m)
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, m)
+		}
+	}
+
 	// Congratulate the user, delete the message, then delete user from captcha:users
 	// Send the welcome message to the user.
 	err = d.sendWelcomeMessage(ctx, m)
 	if err != nil {
-		shared.HandleBotError(ctx, err, d.Bot, m)
-		return
+		return err
 	}
 
 	var messageToBeDeleted []tb.Editable
@@ -195,9 +218,10 @@ func (d *Dependencies) WaitForAnswer(ctx context.Context, m *tb.Message) {
 
 	err = d.deleteMessageBlocking(ctx, messageToBeDeleted)
 	if err != nil {
-		shared.HandleBotError(ctx, err, d.Bot, m)
-		return
+		return err
 	}
+
+	return nil
 }
 
 // It... remove the user from cache. What else do you expect?
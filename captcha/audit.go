@@ -0,0 +1,105 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+// AuditRetention is how long the audit events are kept.
+const AuditRetention = time.Hour * 24 * 7
+
+// AuditAction is what happened to a captcha.
+type AuditAction string
+
+const (
+	AuditPresented   AuditAction = "presented"
+	AuditWrongAnswer AuditAction = "wrong_answer"
+	AuditPassed      AuditAction = "passed"
+	AuditFailed      AuditAction = "failed"
+	AuditLeft        AuditAction = "left"
+)
+
+// AuditEvent is a single step of a captcha of a user.
+type AuditEvent struct {
+	ChatID int64       `json:"chat_id"`
+	UserID int64       `json:"user_id"`
+	Action AuditAction `json:"action"`
+	// Actor is who passed or failed the captcha on behalf of the user,
+	// empty if it happened the usual way.
+	Actor string    `json:"actor,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// auditPrefix is under "captcha:" so Cleanup does not mistake the events for captchas.
+func auditPrefix(chatID int64) []byte {
+	return []byte("captcha:audit:" + strconv.FormatInt(chatID, 10) + ":")
+}
+
+// audit records the event. Failing to record it should not stop the captcha.
+func (d *Dependencies) audit(ctx context.Context, event AuditEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("marshaling audit event: %w", err))
+		return
+	}
+
+	// The zero padded timestamp keeps the keys in chronological order.
+	key := fmt.Appendf(auditPrefix(event.ChatID), "%020d:%d", event.At.UnixNano(), event.UserID)
+	err = d.DB.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(key, value).WithTTL(AuditRetention))
+	})
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("recording audit event: %w", err))
+	}
+}
+
+// AuditEvents returns up to limit of the latest audit events of the group, newest first.
+func (d *Dependencies) AuditEvents(ctx context.Context, chatID int64, limit int) ([]AuditEvent, error) {
+	span := sentry.StartSpan(ctx, "captcha.audit_events")
+	defer span.Finish()
+
+	prefix := auditPrefix(chatID)
+	events := []AuditEvent{}
+	err := d.DB.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Reverse = true
+		iteratorOptions.Prefix = prefix
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		// A reverse iterator starts from the last key that is not greater than the seek key.
+		for iterator.Seek(append(bytes.Clone(prefix), 0xff)); iterator.ValidForPrefix(prefix) && len(events) < limit; iterator.Next() {
+			value, err := iterator.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			var event AuditEvent
+			err = json.Unmarshal(value, &event)
+			if err != nil {
+				return err
+			}
+
+			events = append(events, event)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading audit events: %w", err)
+	}
+
+	return events, nil
+}
@@ -1,16 +1,49 @@
 package captcha
 
 import (
-	"github.com/allegro/bigcache/v3"
 	"github.com/dgraph-io/badger/v4"
+	"github.com/teknologi-umum/captcha/analytics"
+	"github.com/teknologi-umum/captcha/cache"
 	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/probation"
 )
 
 // Dependencies contains the dependency injection struct for
 // methods in the captcha package.
 type Dependencies struct {
 	DB            *badger.DB
-	Memory        *bigcache.BigCache
+	Memory        cache.Cache
 	Bot           *tb.Bot
 	TeknumGroupID int64
+	// Probation puts users who completed the captcha on probation.
+	// It is optional, nil means the feature is disabled.
+	Probation *probation.Dependency
+	// Analytics records the joins of the groups that have the analytics enabled,
+	// and whether they completed the captcha. It is written in the background, so
+	// a slow database does not hold the captcha back. It is optional, nil means
+	// the feature is disabled.
+	Analytics *analytics.Dependency
+
+	swarm swarmWrites
+}
+
+// Difficulty specifies how hard the captcha challenge that is
+// presented to the user.
+type Difficulty int
+
+const (
+	// DifficultyStandard is the usual 3 characters captcha.
+	DifficultyStandard Difficulty = iota
+	// DifficultyHard is a 5 characters captcha, used for users
+	// that look suspicious according to the join policy.
+	DifficultyHard
+)
+
+// answerLength returns the amount of characters of the captcha answer.
+func (d Difficulty) answerLength() int {
+	if d == DifficultyHard {
+		return 5
+	}
+
+	return 3
 }
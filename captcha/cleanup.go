@@ -12,11 +12,20 @@ import (
 	tb "github.com/teknologi-umum/captcha/internal/telebot"
 )
 
-// Cleanup will iterate over every keys and make sure the expiry has not been exceeded by 10 seconds.
-// If it is, we'll kick the person.
+// Cleanup will iterate over every keys and make sure the expiry has not been exceeded by a minute.
+// If it is, we'll kick the person. Those are left behind by a restart, since waitOrDelete kicks
+// everyone else right on the expiry.
 func (d *Dependencies) Cleanup() {
 	var captchaPrefix = []byte("captcha:")
 
+	ctx := sentry.SetHubOnContext(context.Background(), sentry.CurrentHub().Clone())
+
+	// The gauge starts from zero, the captchas from before the restart are still pending.
+	err := d.restorePendingGauge(ctx)
+	if err != nil {
+		sentry.CaptureException(err)
+	}
+
 	for {
 		var captchas []Captcha
 		err := d.DB.View(func(txn *badger.Txn) error {
@@ -45,6 +54,12 @@ func (d *Dependencies) Cleanup() {
 					continue
 				}
 
+				// Other features store their own data on the same database,
+				// anything without an answer is not a captcha.
+				if captcha.ChatID == 0 || captcha.Answer == "" {
+					continue
+				}
+
 				captchas = append(captchas, captcha)
 			}
 
@@ -57,13 +72,22 @@ func (d *Dependencies) Cleanup() {
 		}
 
 		for _, captcha := range captchas {
-			if captcha.Expiry.Unix() < time.Now().Unix() {
-				err := d.removeUserFromGroup(context.WithoutCancel(context.Background()), &tb.Chat{ID: captcha.ChatID}, &tb.User{ID: captcha.SenderID}, captcha)
+			if time.Since(captcha.Expiry) > time.Minute {
+				chat := &tb.Chat{ID: captcha.ChatID}
+				sender := &tb.User{ID: captcha.SenderID}
+
+				// The captcha only keeps the IDs, the name is needed to tell the group who is kicked.
+				member, err := d.Bot.ChatMemberOf(ctx, chat, sender)
+				if err == nil && member.User != nil {
+					sender = member.User
+				}
+
+				err = d.reject(ctx, &tb.Message{Chat: chat, Sender: sender}, captcha, "")
 				if err != nil {
 					sentry.CaptureException(err)
 				}
 
-				err = d.removeUserFromCache(context.Background(), captcha.SenderID, captcha.ChatID)
+				err = d.removeUserFromCache(ctx, captcha.SenderID, captcha.ChatID)
 				if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
 					sentry.CaptureException(err)
 				}
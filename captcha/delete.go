@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -22,34 +21,14 @@ func (d *Dependencies) deleteMessage(ctx context.Context, messages []tb.Editable
 
 	c := make(chan struct{}, 1)
 	time.AfterFunc(time.Minute*1, func() {
-		for {
-			err := d.Bot.DeleteMany(ctx, messages)
-			if err != nil && !strings.Contains(err.Error(), "message to delete not found") {
-				var floodError tb.FloodError
-				if errors.As(err, &floodError) {
-					if floodError.RetryAfter == 0 {
-						floodError.RetryAfter = 15
-					}
-
-					slog.WarnContext(ctx, "Received FloodError", slog.String("error", err.Error()), slog.Int("retry_after", floodError.RetryAfter))
-					time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-					continue
-				}
-
-				if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-					slog.WarnContext(ctx, "Received Gateway Timeout, retrying in 10 seconds", slog.String("error", err.Error()))
-					time.Sleep(time.Second * 10)
-					continue
-				}
-
-				slog.ErrorContext(ctx, "Failed to delete message", slog.String("error", err.Error()), slog.Any("messages", messages))
-				shared.HandleError(ctx, err)
-			}
-
-			break
+		err := d.Bot.DeleteMany(ctx, messages)
+		if err != nil && !errors.Is(err, tb.ErrNotFoundToDelete) {
+			slog.ErrorContext(ctx, "Failed to delete message", slog.String("error", err.Error()), slog.Any("messages", messages))
+			shared.HandleError(ctx, err)
+		} else {
+			slog.DebugContext(ctx, "Message successfully deleted", slog.Any("messages", messages))
 		}
 
-		slog.DebugContext(ctx, "Message successfully deleted", slog.Any("messages", messages))
 		c <- struct{}{}
 	})
 
@@ -61,30 +40,9 @@ func (d *Dependencies) deleteMessageBlocking(ctx context.Context, messages []tb.
 	ctx = span.Context()
 	defer span.Finish()
 
-	for {
-		err := d.Bot.DeleteMany(ctx, messages)
-		if err != nil && !strings.Contains(err.Error(), "message to delete not found") {
-			var floodError tb.FloodError
-			if errors.As(err, &floodError) {
-				if floodError.RetryAfter == 0 {
-					floodError.RetryAfter = 15
-				}
-
-				slog.DebugContext(ctx, fmt.Sprintf("Received flood error, retrying in %d seconds", floodError.RetryAfter), slog.Any("messages", messages))
-				time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-				continue
-			}
-
-			if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-				slog.DebugContext(ctx, "Received Gateway Timeout, retrying in 10 seconds", slog.Any("messages", messages))
-				time.Sleep(time.Second * 10)
-				continue
-			}
-
-			return fmt.Errorf("error deleting message: %w", err)
-		}
-
-		break
+	err := d.Bot.DeleteMany(ctx, messages)
+	if err != nil && !errors.Is(err, tb.ErrNotFoundToDelete) {
+		return fmt.Errorf("error deleting message: %w", err)
 	}
 
 	slog.DebugContext(ctx, "Message successfully deleted", slog.Any("messages", messages))
@@ -13,11 +13,10 @@ import (
 	"github.com/dgraph-io/badger/v4"
 	"github.com/getsentry/sentry-go"
 
+	"github.com/teknologi-umum/captcha/cache"
 	"github.com/teknologi-umum/captcha/shared"
 	"github.com/teknologi-umum/captcha/utils"
 
-	"github.com/allegro/bigcache/v3"
-
 	tb "github.com/teknologi-umum/captcha/internal/telebot"
 )
 
@@ -62,7 +61,9 @@ var DefaultQuestion = "Halo, {user}!\n\n" +
 //
 // At the end of the function, it will create 2 goroutines in which
 // both of them are responsible for kicking the user out of the group.
-func (d *Dependencies) CaptchaUserJoin(ctx context.Context, m *tb.Message) {
+//
+// The difficulty determines how long the captcha answer will be.
+func (d *Dependencies) CaptchaUserJoin(ctx context.Context, m *tb.Message, difficulty Difficulty) {
 	span := sentry.StartSpan(ctx, "captcha.user_join")
 	defer span.Finish()
 	ctx = span.Context()
@@ -73,18 +74,12 @@ func (d *Dependencies) CaptchaUserJoin(ctx context.Context, m *tb.Message) {
 	var admins []tb.ChatMember
 	groupAdmins, err := d.Memory.Get("group-admins:" + strconv.FormatInt(m.Chat.ID, 10))
 	if err != nil {
-		if errors.Is(err, bigcache.ErrEntryNotFound) {
+		if errors.Is(err, cache.ErrNotFound) {
 			slog.DebugContext(ctx, "Setting cache entry for group admins", slog.Int64("group_id", m.Chat.ID))
 			// Find and set
 			admins, err = d.Bot.AdminsOf(ctx, m.Chat)
 			if err != nil {
-				if !strings.Contains(err.Error(), "Gateway Timeout (504)") && !strings.Contains(err.Error(), "retry after") {
-					slog.ErrorContext(ctx, "failed to get group admins", slog.String("error", err.Error()), slog.Int64("group_id", m.Chat.ID))
-					shared.HandleBotError(ctx, err, d.Bot, m)
-					return
-				}
-
-				slog.WarnContext(ctx, "failed to get group admins", slog.String("error", err.Error()), slog.Int64("group_id", m.Chat.ID))
+				slog.ErrorContext(ctx, "failed to get group admins", slog.String("error", err.Error()), slog.Int64("group_id", m.Chat.ID))
 				shared.HandleBotError(ctx, err, d.Bot, m)
 				return
 			}
@@ -127,8 +122,8 @@ func (d *Dependencies) CaptchaUserJoin(ctx context.Context, m *tb.Message) {
 		return
 	}
 
-	// randNum generates a random number (3 digit) in string format
-	var randNum = utils.GenerateRandomNumber()
+	// randNum generates a random number (3 digit, or 5 for the hard one) in string format
+	var randNum = utils.GenerateRandomNumberWithLength(difficulty.answerLength())
 	// captcha generates ascii art from the randNum value
 	var captcha = utils.GenerateAscii(randNum)
 
@@ -142,7 +137,6 @@ func (d *Dependencies) CaptchaUserJoin(ctx context.Context, m *tb.Message) {
 		1,
 	)
 
-SENDMSG_RETRY:
 	// Send the question first.
 	msgQuestion, err := d.Bot.Send(
 		ctx,
@@ -156,29 +150,9 @@ SENDMSG_RETRY:
 		},
 	)
 	if err != nil {
-		var floodError tb.FloodError
-		if errors.As(err, &floodError) {
-			if floodError.RetryAfter == 0 {
-				floodError.RetryAfter = 15
-			}
-
-			slog.DebugContext(ctx, "Received FloodError", slog.String("error", err.Error()), slog.Int64("group_id", m.Chat.ID), slog.Int64("user_id", m.Sender.ID), slog.Int("retry_after", floodError.RetryAfter))
-			time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-			goto SENDMSG_RETRY
-		}
-
-		if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-			slog.DebugContext(ctx, "Received Gateway Timeout, retrying in 10 seconds", slog.String("error", err.Error()), slog.Int64("group_id", m.Chat.ID), slog.Int64("user_id", m.Sender.ID))
-			time.Sleep(time.Second * 10)
-			goto SENDMSG_RETRY
-		}
-
-		// err could possibly be nil at this point, so we better check it out.
-		if err != nil {
-			slog.ErrorContext(ctx, "Failed to send question", slog.String("error", err.Error()), slog.Int64("group_id", m.Chat.ID), slog.Int64("user_id", m.Sender.ID))
-			shared.HandleBotError(ctx, err, d.Bot, m)
-			return
-		}
+		slog.ErrorContext(ctx, "Failed to send question", slog.String("error", err.Error()), slog.Int64("group_id", m.Chat.ID), slog.Int64("user_id", m.Sender.ID))
+		shared.HandleBotError(ctx, err, d.Bot, m)
+		return
 	}
 
 	// OK. We've sent the question. Now we are going to prepare the data that will
@@ -235,6 +209,16 @@ SENDMSG_RETRY:
 		return
 	}
 
+	presentedTotal.Inc(chatLabel(m.Chat.ID))
+	pending.Inc(chatLabel(m.Chat.ID))
+	d.audit(ctx, AuditEvent{ChatID: m.Chat.ID, UserID: m.Sender.ID, Action: AuditPresented})
+
+	if d.Analytics != nil {
+		d.writeSwarm(m.Chat.ID, m.Sender.ID, func() {
+			d.Analytics.SwarmLog(m.Sender, m.Chat.ID, false)
+		})
+	}
+
 	// Invoking it on a goroutine since we got a nil-pointer error somehow.
 	go d.waitOrDelete(ctx, m)
 }
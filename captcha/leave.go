@@ -86,6 +86,10 @@ func (d *Dependencies) CaptchaUserLeave(ctx context.Context, m *tb.Message) {
 		return
 	}
 
+	leftTotal.Inc(chatLabel(m.Chat.ID))
+	pending.Dec(chatLabel(m.Chat.ID))
+	d.audit(ctx, AuditEvent{ChatID: m.Chat.ID, UserID: m.Sender.ID, Action: AuditLeft})
+
 	// Build message to be deleted
 	messagesToBeDeleted := []tb.Editable{
 		// Delete question message
@@ -0,0 +1,32 @@
+package captcha
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/teknologi-umum/captcha/metrics"
+)
+
+var (
+	presentedTotal = metrics.NewCounter("captcha_presented_total", "Captchas sent to new members.", "chat_id")
+	passedTotal    = metrics.NewCounter("captcha_passed_total", "Captchas answered correctly.", "chat_id")
+	failedTotal    = metrics.NewCounter("captcha_failed_total", "Captchas that were not answered in time.", "chat_id")
+	leftTotal      = metrics.NewCounter("captcha_left_total", "Members that left before answering their captcha.", "chat_id")
+	wrongTotal     = metrics.NewCounter("captcha_wrong_answers_total", "Wrong answers to a captcha.", "chat_id")
+	pending        = metrics.NewGauge("captcha_pending", "Captchas waiting for an answer.", "chat_id")
+	solveSeconds   = metrics.NewHistogram(
+		"captcha_solve_seconds",
+		"Time between the captcha being sent and answered correctly.",
+		[]float64{5, 10, 15, 20, 30, 40, 50, 60},
+	)
+)
+
+// solveDuration derives how long the captcha took from its expiry, since the
+// captcha is valid for exactly Timeout.
+func solveDuration(captcha Captcha) time.Duration {
+	return Timeout - time.Until(captcha.Expiry)
+}
+
+func chatLabel(chatID int64) string {
+	return strconv.FormatInt(chatID, 10)
+}
@@ -0,0 +1,186 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// ErrNoPendingCaptcha is returned when the user has no captcha waiting for an answer.
+var ErrNoPendingCaptcha = errors.New("no pending captcha")
+
+// Pending returns the captchas of the group that are waiting for an answer.
+func (d *Dependencies) Pending(ctx context.Context, chatID int64) ([]Captcha, error) {
+	span := sentry.StartSpan(ctx, "captcha.pending")
+	defer span.Finish()
+
+	captchas := []Captcha{}
+	err := d.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("captcha:users:" + strconv.FormatInt(chatID, 10)))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+
+			return err
+		}
+
+		users, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range strings.Split(string(users), ";") {
+			if user == "" {
+				continue
+			}
+
+			item, err := txn.Get([]byte(strconv.FormatInt(chatID, 10) + ":" + user))
+			if err != nil {
+				// The user got kicked, their captcha is gone but they are
+				// still listed on captcha:users.
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+
+				return err
+			}
+
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			var captcha Captcha
+			err = json.Unmarshal(value, &captcha)
+			if err != nil {
+				return err
+			}
+
+			captchas = append(captchas, captcha)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading pending captchas: %w", err)
+	}
+
+	return captchas, nil
+}
+
+// restorePendingGauge sets the pending gauge of every group from the captchas that
+// are still waiting for an answer, since the gauge starts from zero on every start.
+func (d *Dependencies) restorePendingGauge(ctx context.Context) error {
+	prefix := []byte("captcha:users:")
+
+	var chatIDs []int64
+	err := d.DB.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		options.PrefetchValues = false
+
+		iterator := txn.NewIterator(options)
+		defer iterator.Close()
+
+		for iterator.Seek(prefix); iterator.ValidForPrefix(prefix); iterator.Next() {
+			chatID, err := strconv.ParseInt(string(bytes.TrimPrefix(iterator.Item().Key(), prefix)), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			chatIDs = append(chatIDs, chatID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("listing groups with pending captchas: %w", err)
+	}
+
+	for _, chatID := range chatIDs {
+		captchas, err := d.Pending(ctx, chatID)
+		if err != nil {
+			return err
+		}
+
+		pending.Set(float64(len(captchas)), chatLabel(chatID))
+	}
+
+	return nil
+}
+
+// Pass lets the user in as if they have answered the captcha correctly.
+// The actor is recorded on the audit event.
+func (d *Dependencies) Pass(ctx context.Context, chat *tb.Chat, user *tb.User, actor string) error {
+	span := sentry.StartSpan(ctx, "captcha.pass")
+	defer span.Finish()
+	ctx = span.Context()
+
+	captcha, err := d.captchaOf(chat.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return d.accept(ctx, &tb.Message{Chat: chat, Sender: user}, captcha, actor)
+}
+
+// Fail kicks the user as if their captcha has expired.
+// The actor is recorded on the audit event.
+func (d *Dependencies) Fail(ctx context.Context, chat *tb.Chat, user *tb.User, actor string) error {
+	span := sentry.StartSpan(ctx, "captcha.fail")
+	defer span.Finish()
+	ctx = span.Context()
+
+	captcha, err := d.captchaOf(chat.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = d.reject(ctx, &tb.Message{Chat: chat, Sender: user}, captcha, actor)
+	if err != nil {
+		return err
+	}
+
+	// Take them off captcha:users as well, so their messages are no longer checked.
+	err = d.removeUserFromCache(ctx, user.ID, chat.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Dependencies) captchaOf(chatID int64, userID int64) (Captcha, error) {
+	var captcha Captcha
+	err := d.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(userID, 10)))
+		if err != nil {
+			return err
+		}
+
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(value, &captcha)
+	})
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return Captcha{}, ErrNoPendingCaptcha
+		}
+
+		return Captcha{}, fmt.Errorf("reading captcha: %w", err)
+	}
+
+	return captcha, nil
+}
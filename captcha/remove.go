@@ -3,10 +3,8 @@ package captcha
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -21,7 +19,6 @@ func (d *Dependencies) removeUserFromGroup(ctx context.Context, chat *tb.Chat, s
 
 	slog.DebugContext(ctx, "Trying to remove user from group", slog.Int64("group_id", chat.ID), slog.Int64("user_id", sender.ID))
 
-BanRetry:
 	// Even if the keyword is Ban, it's just kicking them.
 	// If the RestrictedUntil value is below zero, it means
 	// they are banned forever.
@@ -30,23 +27,6 @@ BanRetry:
 		User:            sender,
 	}, true)
 	if err != nil {
-		var floodError tb.FloodError
-		if errors.As(err, &floodError) {
-			if floodError.RetryAfter == 0 {
-				floodError.RetryAfter = 15
-			}
-
-			slog.DebugContext(ctx, fmt.Sprintf("Received flood error, retrying in %d seconds", floodError.RetryAfter), slog.Int64("group_id", chat.ID), slog.Int64("user_id", sender.ID), slog.Int("retry_after", floodError.RetryAfter))
-			time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-			goto BanRetry
-		}
-
-		if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-			slog.DebugContext(ctx, "Received Gateway Timeout, retrying in 10 seconds", slog.Int64("group_id", chat.ID), slog.Int64("user_id", sender.ID))
-			time.Sleep(time.Second * 10)
-			goto BanRetry
-		}
-
 		return err
 	}
 
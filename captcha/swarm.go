@@ -0,0 +1,47 @@
+package captcha
+
+import (
+	"strconv"
+	"sync"
+)
+
+// swarmWrites keeps the swarm log writes of every user in order.
+type swarmWrites struct {
+	mu sync.Mutex
+	// last is closed once the last write of the user is done.
+	last map[string]chan struct{}
+}
+
+// writeSwarm runs a swarm log write of the user in the background, after the
+// previous writes of the same user are done. Otherwise, marking the captcha as
+// finished could run before the row of the join is inserted, and update nothing.
+func (d *Dependencies) writeSwarm(groupID int64, userID int64, write func()) {
+	key := strconv.FormatInt(groupID, 10) + ":" + strconv.FormatInt(userID, 10)
+	done := make(chan struct{})
+
+	d.swarm.mu.Lock()
+	if d.swarm.last == nil {
+		d.swarm.last = make(map[string]chan struct{})
+	}
+	previous := d.swarm.last[key]
+	d.swarm.last[key] = done
+	d.swarm.mu.Unlock()
+
+	go func() {
+		defer func() {
+			close(done)
+
+			d.swarm.mu.Lock()
+			if d.swarm.last[key] == done {
+				delete(d.swarm.last, key)
+			}
+			d.swarm.mu.Unlock()
+		}()
+
+		if previous != nil {
+			<-previous
+		}
+
+		write()
+	}()
+}
@@ -3,10 +3,8 @@ package captcha
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log/slog"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -68,58 +66,55 @@ func (d *Dependencies) waitOrDelete(ctx context.Context, msgUser *tb.Message) {
 			return
 		}
 
-		slog.DebugContext(ctx, "Will try to kick the user", slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
-
-	KICKMSG_RETRY:
-		// Goodbye, user!
-		kickMsg, err := d.Bot.Send(
-			ctx,
-			msgUser.Chat,
-			"<a href=\"tg://user?id="+strconv.FormatInt(msgUser.Sender.ID, 10)+"\">"+
-				utils.SanitizeInput(msgUser.Sender.FirstName)+
-				utils.ShouldAddSpace(msgUser.Sender)+
-				utils.SanitizeInput(msgUser.Sender.LastName)+
-				"</a> tidak menyelesaikan captcha, saya kick!",
-			&tb.SendOptions{
-				ParseMode: tb.ModeHTML,
-			})
+		err = d.reject(ctx, msgUser, captcha, "")
 		if err != nil {
-			var floodError tb.FloodError
-			if errors.As(err, &floodError) {
-				if floodError.RetryAfter == 0 {
-					floodError.RetryAfter = 15
-				}
-
-				slog.WarnContext(ctx, fmt.Sprintf("Received FloodError, retrying in %d seconds", floodError.RetryAfter), slog.String("error", err.Error()), slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID), slog.Int("retry_after", floodError.RetryAfter))
-				time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-				goto KICKMSG_RETRY
-			}
-
-			if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-				slog.WarnContext(ctx, "Received Gateway Timeout, retrying in 10 seconds", slog.String("error", err.Error()), slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
-				time.Sleep(time.Second * 10)
-				goto KICKMSG_RETRY
-			}
-
-			slog.ErrorContext(ctx, "Failed to send a kick message to user", slog.String("error", err.Error()), slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
+			slog.ErrorContext(ctx, "Failed to remove user from group", slog.String("error", err.Error()), slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
 			shared.HandleBotError(ctx, err, d.Bot, msgUser)
 		}
+	}
+}
 
-		if kickMsg != nil {
-			slog.DebugContext(ctx, "Deleting the kick message", slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
-			go d.deleteMessage(
-				ctx,
-				[]tb.Editable{&tb.StoredMessage{
-					MessageID: strconv.Itoa(kickMsg.ID),
-					ChatID:    msgUser.Chat.ID,
-				}},
-			)
-		}
+// reject kicks the user out of the group, as they have not completed the captcha.
+// The actor is who failed them before the timer ran out, empty if it did.
+func (d *Dependencies) reject(ctx context.Context, msgUser *tb.Message, captcha Captcha, actor string) error {
+	slog.DebugContext(ctx, "Will try to kick the user", slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
+	failedTotal.Inc(chatLabel(msgUser.Chat.ID))
+	pending.Dec(chatLabel(msgUser.Chat.ID))
+	d.audit(ctx, AuditEvent{ChatID: msgUser.Chat.ID, UserID: msgUser.Sender.ID, Action: AuditFailed, Actor: actor})
+
+	if d.Analytics != nil {
+		d.writeSwarm(msgUser.Chat.ID, msgUser.Sender.ID, func() {
+			d.Analytics.UpdateSwarm(msgUser.Sender, msgUser.Chat.ID, false)
+		})
+	}
 
-		err = d.removeUserFromGroup(ctx, msgUser.Chat, msgUser.Sender, captcha)
-		if err != nil {
-			slog.ErrorContext(ctx, "Failed to remove user from group", slog.String("error", err.Error()), slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
-			shared.HandleBotError(ctx, err, d.Bot, msgUser)
-		}
+	// Goodbye, user!
+	kickMsg, err := d.Bot.Send(
+		ctx,
+		msgUser.Chat,
+		"<a href=\"tg://user?id="+strconv.FormatInt(msgUser.Sender.ID, 10)+"\">"+
+			utils.SanitizeInput(msgUser.Sender.FirstName)+
+			utils.ShouldAddSpace(msgUser.Sender)+
+			utils.SanitizeInput(msgUser.Sender.LastName)+
+			"</a> tidak menyelesaikan captcha, saya kick!",
+		&tb.SendOptions{
+			ParseMode: tb.ModeHTML,
+		})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send a kick message to user", slog.String("error", err.Error()), slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
+		shared.HandleBotError(ctx, err, d.Bot, msgUser)
 	}
+
+	if kickMsg != nil {
+		slog.DebugContext(ctx, "Deleting the kick message", slog.Int64("group_id", msgUser.Chat.ID), slog.Int64("user_id", msgUser.Sender.ID))
+		go d.deleteMessage(
+			ctx,
+			[]tb.Editable{&tb.StoredMessage{
+				MessageID: strconv.Itoa(kickMsg.ID),
+				ChatID:    msgUser.Chat.ID,
+			}},
+		)
+	}
+
+	return d.removeUserFromGroup(ctx, msgUser.Chat, msgUser.Sender, captcha)
 }
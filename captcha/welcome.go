@@ -2,12 +2,10 @@ package captcha
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"math/rand/v2"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/getsentry/sentry-go"
 
@@ -76,51 +74,35 @@ func (d *Dependencies) sendWelcomeMessage(ctx context.Context, m *tb.Message) er
 		msgToSend = currentWelcomeMessages[randomNum()]
 	}
 
-	for {
-		msg, err := d.Bot.Send(
-			ctx,
-			m.Chat,
-			strings.NewReplacer(
-				"{user}",
-				"<a href=\"tg://user?id="+strconv.FormatInt(m.Sender.ID, 10)+"\">"+
-					utils.SanitizeInput(m.Sender.FirstName)+utils.ShouldAddSpace(m.Sender)+utils.SanitizeInput(m.Sender.LastName)+
-					"</a>",
-				"{groupname}",
-				utils.SanitizeInput(m.Chat.Title),
-			).Replace(msgToSend),
-			&tb.SendOptions{
-				ReplyTo:               m,
-				ParseMode:             tb.ModeHTML,
-				DisableWebPagePreview: true,
-				DisableNotification:   false,
-				AllowWithoutReply:     false,
-			},
-		)
-		if err != nil {
-			var floodError tb.FloodError
-			if errors.As(err, &floodError) {
-				if floodError.RetryAfter == 0 {
-					floodError.RetryAfter = 15
-				}
-
-				time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-				continue
-			}
-
-			if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-				time.Sleep(time.Second * 10)
-				continue
-			}
-			return fmt.Errorf("failed to send welcome message: %w", err)
-		}
-
-		go d.deleteMessage(
-			ctx,
-			[]tb.Editable{&tb.StoredMessage{MessageID: strconv.Itoa(msg.ID), ChatID: m.Chat.ID}},
-		)
-		break
+	msg, err := d.Bot.Send(
+		// Welcoming can wait behind kicks and bans when the queue is busy.
+		tb.WithPriority(ctx, tb.PriorityLow),
+		m.Chat,
+		strings.NewReplacer(
+			"{user}",
+			"<a href=\"tg://user?id="+strconv.FormatInt(m.Sender.ID, 10)+"\">"+
+				utils.SanitizeInput(m.Sender.FirstName)+utils.ShouldAddSpace(m.Sender)+utils.SanitizeInput(m.Sender.LastName)+
+				"</a>",
+			"{groupname}",
+			utils.SanitizeInput(m.Chat.Title),
+		).Replace(msgToSend),
+		&tb.SendOptions{
+			ReplyTo:               m,
+			ParseMode:             tb.ModeHTML,
+			DisableWebPagePreview: true,
+			DisableNotification:   false,
+			AllowWithoutReply:     false,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to send welcome message: %w", err)
 	}
 
+	go d.deleteMessage(
+		ctx,
+		[]tb.Editable{&tb.StoredMessage{MessageID: strconv.Itoa(msg.ID), ChatID: m.Chat.ID}},
+	)
+
 	return nil
 }
 
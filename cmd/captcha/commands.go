@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
+	"github.com/teknologi-umum/captcha/admin"
 	"github.com/teknologi-umum/captcha/deletion"
 	"github.com/teknologi-umum/captcha/internal/requestid"
+	"github.com/teknologi-umum/captcha/joinpolicy"
+	"github.com/teknologi-umum/captcha/namefilter"
+	"github.com/teknologi-umum/captcha/probation"
 	"github.com/teknologi-umum/captcha/reminder"
 	"github.com/teknologi-umum/captcha/setir"
 
@@ -34,6 +39,11 @@ type Dependency struct {
 	Setir       *setir.Dependency
 	Reminder    *reminder.Dependency
 	Deletion    *deletion.Dependency
+	JoinPolicy  *joinpolicy.Dependency
+	NameFilter  *namefilter.Dependency
+	Probation   *probation.Dependency
+	// Admin is optional, nil means the admin API is disabled.
+	Admin *admin.Dependency
 }
 
 // New returns a pointer struct of Dependency
@@ -57,6 +67,18 @@ func New(deps Dependency) (*Dependency, error) {
 		return nil, fmt.Errorf("reminder feature is enabled, but reminder dependency is nil")
 	}
 
+	if deps.FeatureFlag.JoinPolicy && deps.JoinPolicy == nil {
+		return nil, fmt.Errorf("join policy feature is enabled, but joinpolicy dependency is nil")
+	}
+
+	if deps.FeatureFlag.NameFilter && deps.NameFilter == nil {
+		return nil, fmt.Errorf("name filter feature is enabled, but namefilter dependency is nil")
+	}
+
+	if deps.FeatureFlag.Probation && deps.Probation == nil {
+		return nil, fmt.Errorf("probation feature is enabled, but probation dependency is nil")
+	}
+
 	return &deps, nil
 }
 
@@ -66,9 +88,26 @@ func (d *Dependency) OnTextHandler(c tb.Context) error {
 	defer cancel()
 
 	ctx = requestid.SetRequestIdOnContext(sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone()))
+	ctx = shared.SetHandlerOnContext(ctx, "OnTextHandler")
+
+	d.rememberChat(ctx, c.Chat())
 
 	d.Captcha.WaitForAnswer(ctx, c.Message())
 
+	if d.FeatureFlag.NameFilter {
+		_, err := d.NameFilter.CheckProfileChange(ctx, c.Message())
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+	}
+
+	if d.FeatureFlag.Probation {
+		err := d.Probation.Inspect(ctx, c.Message())
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+	}
+
 	if d.FeatureFlag.Analytics {
 		err := d.Analytics.NewMessage(c.Message())
 		if err != nil {
@@ -93,36 +132,98 @@ func (d *Dependency) OnUserJoinHandler(c tb.Context) error {
 		sentry.WithTransactionName("Captcha OnUserJoinHandler"))
 	defer span.Finish()
 	ctx = requestid.SetRequestIdOnContext(span.Context())
+	ctx = shared.SetHandlerOnContext(ctx, "OnUserJoinHandler")
+
+	var tempSender *tb.User
+	if c.Message().UserJoined.ID != 0 {
+		tempSender = c.Message().UserJoined
+	} else {
+		tempSender = c.Message().Sender
+	}
+
+	joinsTotal.Inc(strconv.FormatInt(c.Chat().ID, 10))
+	d.rememberChat(ctx, c.Chat())
 
+	// locked is true when the group is under attack with StrategyLock, so
+	// nobody can answer a captcha until it's over.
+	var locked bool
 	if d.FeatureFlag.UnderAttack {
+		err := d.UnderAttack.ObserveJoin(ctx, c.Chat())
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+
 		underAttack, err := d.UnderAttack.AreWe(ctx, c.Chat().ID)
 		if err != nil {
 			shared.HandleError(ctx, err)
 		}
 
 		if underAttack {
-			slog.DebugContext(ctx, "State is on under attack mode, preventing a user to come through", requestid.GetSlogAttributesFromContext(ctx)...)
-			err := d.UnderAttack.Kicker(ctx, c)
+			// Users allowed by an admin go through the normal captcha instead.
+			allowed, err := d.UnderAttack.UsePass(ctx, c.Chat().ID, tempSender)
 			if err != nil {
-				shared.HandleBotError(ctx, err, c.Bot(), c.Message())
+				shared.HandleError(ctx, err)
+			}
+
+			if !allowed {
+				slog.DebugContext(ctx, "State is on under attack mode, preventing a user to come through", requestid.GetSlogAttributesFromContext(ctx)...)
+				err := d.UnderAttack.Kicker(ctx, c)
+				if err != nil {
+					shared.HandleBotError(ctx, err, c.Bot(), c.Message())
+				}
+				return nil
+			}
+
+			strategy, err := d.UnderAttack.StrategyOf(ctx, c.Chat().ID)
+			if err != nil {
+				shared.HandleError(ctx, err)
 			}
+
+			locked = strategy == underattack.StrategyLock
+		}
+	}
+
+	if d.FeatureFlag.Analytics {
+		go d.Analytics.NewUser(ctx, c.Message(), tempSender)
+	}
+
+	if d.FeatureFlag.NameFilter && !tempSender.IsBot && !c.Message().Private() {
+		blocked, err := d.NameFilter.CheckUserJoin(ctx, c.Chat(), tempSender)
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+
+		if blocked {
 			return nil
 		}
 	}
 
-	var tempSender *tb.User
-	if c.Message().UserJoined.ID != 0 {
-		tempSender = c.Message().UserJoined
-	} else {
-		tempSender = c.Message().Sender
+	difficulty := captcha.DifficultyStandard
+	if d.FeatureFlag.JoinPolicy && !tempSender.IsBot && !c.Message().Private() {
+		decision := d.JoinPolicy.Evaluate(ctx, c.Chat().ID, tempSender)
+		switch decision.Action {
+		case joinpolicy.ActionSkip:
+			return nil
+		case joinpolicy.ActionRestrict, joinpolicy.ActionBan:
+			err := d.JoinPolicy.Enforce(ctx, c.Chat(), tempSender, decision)
+			if err != nil {
+				shared.HandleBotError(ctx, err, c.Bot(), c.Message())
+			}
+			return nil
+		case joinpolicy.ActionHardCaptcha:
+			difficulty = captcha.DifficultyHard
+		}
 	}
 
-	if d.FeatureFlag.Analytics {
-		go d.Analytics.NewUser(ctx, c.Message(), tempSender)
+	if locked {
+		// The user has a pass, but they can't send the answer while the group
+		// is locked, so they would only be kicked when the captcha expires.
+		slog.DebugContext(ctx, "Skipping the captcha of a user with a pass on a locked group", slog.String("user_name", tempSender.Username), slog.Int64("user_id", tempSender.ID))
+		return nil
 	}
 
 	slog.DebugContext(ctx, "Presenting a captcha challenge to the user", slog.String("user_name", tempSender.Username), slog.Int64("user_id", tempSender.ID))
-	d.Captcha.CaptchaUserJoin(ctx, c.Message())
+	d.Captcha.CaptchaUserJoin(ctx, c.Message(), difficulty)
 
 	return nil
 }
@@ -134,9 +235,24 @@ func (d *Dependency) OnNonTextHandler(c tb.Context) error {
 	defer cancel()
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "OnNonTextHandler")
 
 	d.Captcha.NonTextListener(ctx, c.Message())
 
+	if d.FeatureFlag.NameFilter {
+		_, err := d.NameFilter.CheckProfileChange(ctx, c.Message())
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+	}
+
+	if d.FeatureFlag.Probation {
+		err := d.Probation.Inspect(ctx, c.Message())
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+	}
+
 	if d.FeatureFlag.Analytics {
 		err := d.Analytics.NewMessage(c.Message())
 		if err != nil {
@@ -154,6 +270,7 @@ func (d *Dependency) OnUserLeftHandler(c tb.Context) error {
 	defer cancel()
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "OnUserLeftHandler")
 
 	d.Captcha.CaptchaUserLeave(ctx, c.Message())
 	return nil
@@ -169,6 +286,7 @@ func (d *Dependency) EnableUnderAttackModeHandler(c tb.Context) error {
 	defer cancel()
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "EnableUnderAttackModeHandler")
 
 	return d.UnderAttack.EnableUnderAttackModeHandler(ctx, c)
 }
@@ -183,10 +301,87 @@ func (d *Dependency) DisableUnderAttackModeHandler(c tb.Context) error {
 	defer cancel()
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "DisableUnderAttackModeHandler")
 
 	return d.UnderAttack.DisableUnderAttackModeHandler(ctx, c)
 }
 
+// AllowJoinHandler provides a handler for /allowjoin command.
+func (d *Dependency) AllowJoinHandler(c tb.Context) error {
+	if !d.FeatureFlag.UnderAttack {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "AllowJoinHandler")
+
+	return d.UnderAttack.AllowJoinHandler(ctx, c)
+}
+
+// AttacksHandler provides a handler for /attacks command.
+func (d *Dependency) AttacksHandler(c tb.Context) error {
+	if !d.FeatureFlag.UnderAttack {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "AttacksHandler")
+
+	return d.UnderAttack.AttacksHandler(ctx, c)
+}
+
+// ReviewCallbackHandler handles the inline buttons of the /attacks review.
+func (d *Dependency) ReviewCallbackHandler(c tb.Context) error {
+	if !d.FeatureFlag.UnderAttack {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "ReviewCallbackHandler")
+
+	return d.UnderAttack.ReviewCallbackHandler(ctx, c)
+}
+
+// InviteLinkCallbackHandler handles the button that restores a normal invite link
+// once the under attack mode is over.
+func (d *Dependency) InviteLinkCallbackHandler(c tb.Context) error {
+	if !d.FeatureFlag.UnderAttack {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "InviteLinkCallbackHandler")
+
+	return d.UnderAttack.InviteLinkCallbackHandler(ctx, c)
+}
+
+// OnChatJoinRequestHandler handles pending join requests while the group is under attack.
+func (d *Dependency) OnChatJoinRequestHandler(c tb.Context) error {
+	if !d.FeatureFlag.UnderAttack {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "OnChatJoinRequestHandler")
+
+	return d.UnderAttack.JoinRequestHandler(ctx, c)
+}
+
 func (d *Dependency) ReminderHandler(c tb.Context) error {
 	if !d.FeatureFlag.Reminder {
 		return nil
@@ -196,6 +391,7 @@ func (d *Dependency) ReminderHandler(c tb.Context) error {
 	defer cancel()
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "ReminderHandler")
 
 	span := sentry.StartSpan(ctx, "bot.reminder_handler", sentry.WithTransactionSource(sentry.SourceTask),
 		sentry.WithTransactionName("Captcha ReminderHandler"))
@@ -205,11 +401,76 @@ func (d *Dependency) ReminderHandler(c tb.Context) error {
 	return d.Reminder.Handler(ctx, c)
 }
 
+// PurgeBotsHandler provides a handler for /purgebots command. It bans the users
+// that joined within the last day without completing their captcha.
+func (d *Dependency) PurgeBotsHandler(c tb.Context) error {
+	if !d.FeatureFlag.Analytics || c.Message().Private() {
+		return nil
+	}
+
+	// Every ban is followed by a pause, so this may take a while.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "PurgeBotsHandler")
+
+	d.Analytics.PurgeBots(ctx, c.Message())
+	return nil
+}
+
+// AnalyticsHandler provides a handler for /analytics command. Admins use it
+// to opt the group in or out of the analytics.
+func (d *Dependency) AnalyticsHandler(c tb.Context) error {
+	if !d.FeatureFlag.Analytics {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "AnalyticsHandler")
+
+	return d.Analytics.EnableHandler(ctx, c)
+}
+
+// StatsHandler provides a handler for /stats command.
+func (d *Dependency) StatsHandler(c tb.Context) error {
+	if !d.FeatureFlag.Analytics {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "StatsHandler")
+
+	return d.Analytics.StatsHandler(ctx, c)
+}
+
+// TrustHandler provides a handler for /trust command.
+func (d *Dependency) TrustHandler(c tb.Context) error {
+	if !d.FeatureFlag.Probation {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "TrustHandler")
+
+	return d.Probation.TrustHandler(ctx, c)
+}
+
 func (d *Dependency) SetirHandler(c tb.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "SetirHandler")
 
 	return d.Setir.Handler(ctx, c)
 }
@@ -223,6 +484,7 @@ func (d *Dependency) DeletionHandler(c tb.Context) error {
 	defer cancel()
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "DeletionHandler")
 
 	span := sentry.StartSpan(ctx, "bot.deletion_handler", sentry.WithTransactionSource(sentry.SourceTask),
 		sentry.WithTransactionName("Captcha DeletionHandler"))
@@ -231,3 +493,36 @@ func (d *Dependency) DeletionHandler(c tb.Context) error {
 
 	return d.Deletion.Handler(ctx, c)
 }
+
+// OnMyChatMemberHandler keeps the chats of the admin API up to date when
+// the bot is added to or removed from a group.
+func (d *Dependency) OnMyChatMemberHandler(c tb.Context) error {
+	if d.Admin == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	ctx = shared.SetHandlerOnContext(ctx, "OnMyChatMemberHandler")
+
+	err := d.Admin.MyChatMemberHandler(ctx, c)
+	if err != nil {
+		shared.HandleError(ctx, err)
+	}
+
+	return nil
+}
+
+// rememberChat lets the admin API list the chats the bot has heard from.
+func (d *Dependency) rememberChat(ctx context.Context, chat *tb.Chat) {
+	if d.Admin == nil {
+		return
+	}
+
+	err := d.Admin.RememberChat(ctx, chat)
+	if err != nil {
+		shared.HandleError(ctx, err)
+	}
+}
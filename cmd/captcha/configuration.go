@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -15,6 +16,9 @@ type FeatureFlag struct {
 	Reminder    bool `yaml:"reminder" json:"reminder" env:"FEATURE_FLAG_REMINDER" env-default:"false"`
 	Deletion    bool `yaml:"deletion" json:"deletion" env:"FEATURE_FLAG_DELETION" env-default:"false"`
 	HttpServer  bool `yaml:"http_server" json:"http_server" env:"FEATURE_FLAG_HTTP_SERVER" env-default:"false"`
+	JoinPolicy  bool `yaml:"join_policy" json:"join_policy" env:"FEATURE_FLAG_JOIN_POLICY" env-default:"false"`
+	NameFilter  bool `yaml:"name_filter" json:"name_filter" env:"FEATURE_FLAG_NAME_FILTER" env-default:"false"`
+	Probation   bool `yaml:"probation" json:"probation" env:"FEATURE_FLAG_PROBATION" env-default:"false"`
 }
 
 type Configuration struct {
@@ -29,17 +33,79 @@ type Configuration struct {
 		SentryTracesSampleRate float64 `yaml:"sentry_traces_sample_rate" json:"sentry_traces_sample_rate" env:"SENTRY_TRACES_SAMPLE_RATE" env-default:"0.2"`
 	} `yaml:"sentry_config" json:"sentry_config"`
 	LogLevel string `yaml:"log_level" json:"log_level" env:"LOG_LEVEL" env-default:"info"`
+	// ErrorNotification routes the errors to an admin or log chat instead of the group
+	// they happened in.
+	ErrorNotification struct {
+		ChatID        int64         `yaml:"chat_id" json:"chat_id" env:"ERROR_NOTIFICATION__CHAT_ID"`
+		Cooldown      time.Duration `yaml:"cooldown" json:"cooldown" env:"ERROR_NOTIFICATION__COOLDOWN" env-default:"10m"`
+		PerMinute     int           `yaml:"per_minute" json:"per_minute" env:"ERROR_NOTIFICATION__PER_MINUTE" env-default:"10"`
+		GroupMessage  bool          `yaml:"group_message" json:"group_message" env:"ERROR_NOTIFICATION__GROUP_MESSAGE" env-default:"false"`
+		GroupCooldown time.Duration `yaml:"group_cooldown" json:"group_cooldown" env:"ERROR_NOTIFICATION__GROUP_COOLDOWN" env-default:"30m"`
+	} `yaml:"error_notification" json:"error_notification"`
 	Database struct {
 		PostgresUrl string `yaml:"postgres_url" json:"postgres_url" env:"POSTGRES_URL"`
 		BadgerPath  string `yaml:"badger_path" json:"badger_path" env:"BADGER_PATH"`
+		RedisUrl    string `yaml:"redis_url" json:"redis_url" env:"REDIS_URL"`
 	} `yaml:"database" json:"database"`
+	Cache struct {
+		// Provider is either "memory" or "redis". Redis lets several replicas of the bot
+		// share the group admins, the under attack state and the reminder limits.
+		Provider string `yaml:"provider" json:"provider" env:"CACHE__PROVIDER" env-default:"memory"`
+	} `yaml:"cache" json:"cache"`
 	HTTPServer struct {
 		ListeningHost string `yaml:"listening_host" json:"listening_host" env:"HTTP_HOST"`
 		ListeningPort string `yaml:"listening_port" json:"listening_port" env:"HTTP_PORT" env-default:"8080"`
+		// ReadinessUpdateTimeout fails /readyz when the bot has not heard from Telegram for
+		// this long. Zero only reports the last time, as does the webhook mode. Either way,
+		// /readyz fails until the bot has heard from Telegram once.
+		ReadinessUpdateTimeout time.Duration `yaml:"readiness_update_timeout" json:"readiness_update_timeout" env:"HTTP_READINESS_UPDATE_TIMEOUT" env-default:"5m"`
 	}
+	Webhook struct {
+		// PublicURL is where Telegram sends the updates to. Setting it switches the bot from
+		// long polling to a webhook, which is served by the HTTP server on the path of this URL.
+		PublicURL string `yaml:"public_url" json:"public_url" env:"WEBHOOK__PUBLIC_URL"`
+		// SecretToken is sent by Telegram on every update, so the webhook can reject requests
+		// from anyone else. Defaults to a token derived from the bot token.
+		SecretToken        string `yaml:"secret_token" json:"secret_token" env:"WEBHOOK__SECRET_TOKEN"`
+		MaxConnections     int    `yaml:"max_connections" json:"max_connections" env:"WEBHOOK__MAX_CONNECTIONS" env-default:"40"`
+		DropPendingUpdates bool   `yaml:"drop_pending_updates" json:"drop_pending_updates" env:"WEBHOOK__DROP_PENDING_UPDATES" env-default:"false"`
+	} `yaml:"webhook" json:"webhook"`
+	AdminAPI struct {
+		// Token enables the admin API on the HTTP server, every request must have it
+		// as the bearer token.
+		Token string `yaml:"token" json:"token" env:"ADMIN_API__TOKEN"`
+	} `yaml:"admin_api" json:"admin_api"`
+	Dashboard struct {
+		// Token enables the dashboard on the HTTP server, anyone who knows it can log in.
+		Token string `yaml:"token" json:"token" env:"DASHBOARD__TOKEN"`
+		// TelegramLogin enables the dashboard with the Telegram Login Widget, only for
+		// the users on admin_ids. The domain must be linked to the bot with /setdomain on @BotFather.
+		TelegramLogin bool `yaml:"telegram_login" json:"telegram_login" env:"DASHBOARD__TELEGRAM_LOGIN" env-default:"false"`
+	} `yaml:"dashboard" json:"dashboard"`
 	UnderAttack struct {
 		DatastoreProvider string `yaml:"datastore_provider" json:"datastore_provider" env:"UNDER_ATTACK__DATASTORE_PROVIDER" env-default:"memory"`
+		// AutoTriggerThreshold is the amount of joins within AutoTriggerWindow that enables
+		// the under attack mode automatically. Zero disables the automatic trigger.
+		AutoTriggerThreshold int           `yaml:"auto_trigger_threshold" json:"auto_trigger_threshold" env:"UNDER_ATTACK__AUTO_TRIGGER_THRESHOLD" env-default:"0"`
+		AutoTriggerWindow    time.Duration `yaml:"auto_trigger_window" json:"auto_trigger_window" env:"UNDER_ATTACK__AUTO_TRIGGER_WINDOW" env-default:"60s"`
+		// DefaultStrategy is used for groups that have not chosen their own strategy
+		// with "/underattack strategy".
+		DefaultStrategy string `yaml:"default_strategy" json:"default_strategy" env:"UNDER_ATTACK__DEFAULT_STRATEGY" env-default:"ban"`
+		// RotateInviteLink revokes the invite links of the group when the under attack
+		// mode is enabled, and sends a fresh join-request-only link to the admins.
+		RotateInviteLink bool `yaml:"rotate_invite_link" json:"rotate_invite_link" env:"UNDER_ATTACK__ROTATE_INVITE_LINK" env-default:"false"`
 	}
+	JoinPolicy struct {
+		ConfigurationFile string `yaml:"configuration_file" json:"configuration_file" env:"JOIN_POLICY__CONFIGURATION_FILE"`
+	} `yaml:"join_policy" json:"join_policy"`
+	NameFilter struct {
+		ConfigurationFile string `yaml:"configuration_file" json:"configuration_file" env:"NAME_FILTER__CONFIGURATION_FILE"`
+	} `yaml:"name_filter" json:"name_filter"`
+	Probation struct {
+		Duration      time.Duration `yaml:"duration" json:"duration" env:"PROBATION__DURATION" env-default:"24h"`
+		Messages      int           `yaml:"messages" json:"messages" env:"PROBATION__MESSAGES" env-default:"0"`
+		MaxViolations int           `yaml:"max_violations" json:"max_violations" env:"PROBATION__MAX_VIOLATIONS" env-default:"3"`
+	} `yaml:"probation" json:"probation"`
 }
 
 func ParseConfiguration(configurationFilePath string) (Configuration, error) {
@@ -23,13 +23,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/teknologi-umum/captcha/admin"
+	"github.com/teknologi-umum/captcha/analytics"
 	"github.com/teknologi-umum/captcha/ascii"
+	"github.com/teknologi-umum/captcha/cache"
 	"github.com/teknologi-umum/captcha/captcha"
+	"github.com/teknologi-umum/captcha/dashboard"
 	"github.com/teknologi-umum/captcha/deletion"
+	"github.com/teknologi-umum/captcha/health"
+	"github.com/teknologi-umum/captcha/joinpolicy"
+	"github.com/teknologi-umum/captcha/metrics"
+	"github.com/teknologi-umum/captcha/namefilter"
+	"github.com/teknologi-umum/captcha/probation"
+	"github.com/teknologi-umum/captcha/ratelimit"
 	"github.com/teknologi-umum/captcha/reminder"
 	"github.com/teknologi-umum/captcha/setir"
 	"github.com/teknologi-umum/captcha/shared"
@@ -40,6 +49,7 @@ import (
 	"github.com/allegro/bigcache/v3"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 
 	// Others third party stuff
 	"github.com/getsentry/sentry-go"
@@ -142,7 +152,7 @@ func main() {
 	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
 
 	// Setup in memory cache
-	cache, err := bigcache.New(context.Background(), bigcache.Config{
+	memoryCache, err := bigcache.New(context.Background(), bigcache.Config{
 		Shards:             1024,
 		LifeWindow:         time.Hour * 12,
 		CleanWindow:        time.Hour,
@@ -165,7 +175,61 @@ func main() {
 			os.Exit(1)
 			return
 		}
-	}(cache)
+	}(memoryCache)
+
+	var redisClient *redis.Client
+	if configuration.Cache.Provider == "redis" || (configuration.FeatureFlag.UnderAttack && configuration.UnderAttack.DatastoreProvider == "redis") {
+		redisOptions, err := redis.ParseURL(configuration.Database.RedisUrl)
+		if err != nil {
+			slog.Error("parsing redis url", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+
+		redisClient = redis.NewClient(redisOptions)
+		err = redisClient.Ping(ctx).Err()
+		if err != nil {
+			slog.Error("connecting to redis", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+	defer func(client *redis.Client) {
+		if client != nil {
+			slog.Debug("Closing redis")
+			err := client.Close()
+			if err != nil {
+				slog.Warn("closing the redis client", slog.String("error", err.Error()))
+			}
+		}
+	}(redisClient)
+
+	// The shared cache is kept on Redis when the bot runs as several replicas,
+	// so they agree on the group admins, the under attack state and the reminder limits.
+	var sharedCache cache.Cache = memoryCache
+	if configuration.Cache.Provider == "redis" {
+		sharedCache, err = cache.NewRedis(redisClient, time.Hour*12)
+		if err != nil {
+			slog.Error("creating a redis cache", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+
+	// The rate limits follow the cache, so the replicas share them too.
+	var rateLimitStore ratelimit.Store
+	if configuration.Cache.Provider == "redis" {
+		rateLimitStore, err = ratelimit.NewRedisStore(redisClient)
+		if err != nil {
+			slog.Error("creating a redis rate limit store", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	} else {
+		memoryRateLimitStore := ratelimit.NewMemoryStore(ratelimit.DefaultMaxKeys, ratelimit.DefaultSweepInterval)
+		defer memoryRateLimitStore.Close()
+		rateLimitStore = memoryRateLimitStore
+	}
 
 	fileStorage, err := badger.Open(badger.DefaultOptions(configuration.Database.BadgerPath))
 	if err != nil {
@@ -183,13 +247,29 @@ func main() {
 		}
 	}(fileStorage)
 
+	// Receive the updates through a webhook if it is configured, otherwise poll for them.
+	webhook, webhookPath, err := newWebhook(configuration)
+	if err != nil {
+		slog.Error("creating webhook", slog.String("error", err.Error()))
+		os.Exit(1)
+		return
+	}
+
+	var poller tb.Poller = &tb.LongPoller{Timeout: 10 * time.Second}
+	if webhook != nil {
+		poller = webhook
+	}
+
 	// Setup Telegram Bot
 	b, err := tb.NewBot(tb.Settings{
 		Token:       configuration.BotToken,
-		Poller:      &tb.LongPoller{Timeout: 10 * time.Second},
+		Poller:      poller,
 		Synchronous: false,
+		// Every outgoing request goes through a single queue that follows
+		// Telegram's rate limits and retries flood errors.
+		Scheduler: &tb.SchedulerSettings{},
 		OnError: func(err error, ctx tb.Context) {
-			if strings.Contains(err.Error(), "Conflict: terminated by other getUpdates request") {
+			if tb.IsConflict(err) {
 				// This error means the bot is currently being deployed
 				return
 			}
@@ -230,6 +310,22 @@ func main() {
 		}
 	}()
 
+	// Errors are reported to the admin chat, not to the group they happened in.
+	errorNotifier, err := shared.NewErrorNotifier(b, shared.ErrorNotifierConfig{
+		ChatID:        configuration.ErrorNotification.ChatID,
+		Cooldown:      configuration.ErrorNotification.Cooldown,
+		PerMinute:     configuration.ErrorNotification.PerMinute,
+		GroupMessage:  configuration.ErrorNotification.GroupMessage,
+		GroupCooldown: configuration.ErrorNotification.GroupCooldown,
+	})
+	if err != nil {
+		slog.Error("creating error notifier", slog.String("error", err.Error()))
+		os.Exit(1)
+		return
+	}
+	shared.SetErrorNotifier(errorNotifier)
+	defer errorNotifier.Wait()
+
 	// This is for recovering from panic.
 	defer func() {
 		r := recover()
@@ -250,10 +346,24 @@ func main() {
 				os.Exit(1)
 				return
 			}
+		case "badger":
+			underAttackDatastore, err = datastore.NewBadgerDatastore(fileStorage)
+			if err != nil {
+				slog.ErrorContext(ctx, "creating badger datastore for under attack feature", slog.String("error", err.Error()))
+				os.Exit(1)
+				return
+			}
+		case "redis":
+			underAttackDatastore, err = datastore.NewRedisDatastore(redisClient)
+			if err != nil {
+				slog.ErrorContext(ctx, "creating redis datastore for under attack feature", slog.String("error", err.Error()))
+				os.Exit(1)
+				return
+			}
 		case "memory":
 			fallthrough
 		default:
-			underAttackDatastore, err = datastore.NewInMemoryDatastore(cache)
+			underAttackDatastore, err = datastore.NewInMemoryDatastore(memoryCache)
 			if err != nil {
 				slog.ErrorContext(ctx, "creating in memory datastore for under attack feature", slog.String("error", err.Error()))
 				os.Exit(1)
@@ -270,10 +380,53 @@ func main() {
 			return
 		}
 
+		defaultStrategy := underattack.Strategy(configuration.UnderAttack.DefaultStrategy)
+		if !defaultStrategy.Valid() {
+			slog.ErrorContext(ctx, "invalid under attack default strategy", slog.String("strategy", configuration.UnderAttack.DefaultStrategy))
+			os.Exit(1)
+			return
+		}
+
+		underAttackRateLimiter, err := ratelimit.NewTokenBucket("underattack", rateLimitStore, underattack.DefaultRateLimit, 1)
+		if err != nil {
+			slog.ErrorContext(ctx, "creating rate limiter for under attack feature", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+
 		underAttackDependency = &underattack.Dependency{
-			Datastore: underAttackDatastore,
-			Memory:    cache,
-			Bot:       b,
+			Datastore:        underAttackDatastore,
+			Memory:           sharedCache,
+			Bot:              b,
+			DefaultStrategy:  defaultStrategy,
+			RotateInviteLink: configuration.UnderAttack.RotateInviteLink,
+			RateLimiter:      underAttackRateLimiter,
+		}
+
+		// Other replicas cache the entries too, they must know when one is changed.
+		if configuration.UnderAttack.DatastoreProvider == "postgres" {
+			underAttackListener, err := datastore.NewPostgresListener(configuration.Database.PostgresUrl)
+			if err != nil {
+				slog.ErrorContext(ctx, "creating postgres listener for under attack feature", slog.String("error", err.Error()))
+				os.Exit(1)
+				return
+			}
+			defer func() {
+				slog.Debug("Closing under attack listener")
+				err := underAttackListener.Close()
+				if err != nil {
+					slog.Warn("closing the under attack listener", slog.String("error", err.Error()))
+				}
+			}()
+
+			underAttackDependency.Changes = underAttackListener
+		}
+
+		if configuration.UnderAttack.AutoTriggerThreshold > 0 {
+			underAttackDependency.JoinRate = underattack.NewJoinRateDetector(
+				configuration.UnderAttack.AutoTriggerThreshold,
+				configuration.UnderAttack.AutoTriggerWindow,
+			)
 		}
 	}
 
@@ -288,7 +441,7 @@ func main() {
 
 	var reminderDependency *reminder.Dependency
 	if configuration.FeatureFlag.Reminder {
-		reminderDependency, err = reminder.New(cache)
+		reminderDependency, err = reminder.New(sharedCache)
 		if err != nil {
 			sentry.CaptureException(err)
 			slog.ErrorContext(ctx, "creating reminder dependency", slog.String("error", err.Error()))
@@ -308,19 +461,138 @@ func main() {
 		}
 	}
 
+	var joinPolicyDependency *joinpolicy.Dependency
+	if configuration.FeatureFlag.JoinPolicy {
+		joinPolicyConfiguration, err := joinpolicy.LoadFile(configuration.JoinPolicy.ConfigurationFile)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "loading join policy configuration", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+
+		joinPolicyDependency, err = joinpolicy.New(joinPolicyConfiguration, b)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "creating join policy dependency", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+
+	var nameFilterDependency *namefilter.Dependency
+	if configuration.FeatureFlag.NameFilter {
+		nameFilterConfiguration, err := namefilter.LoadFile(configuration.NameFilter.ConfigurationFile)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "loading name filter configuration", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+
+		nameFilterDependency, err = namefilter.New(nameFilterConfiguration, sharedCache, b)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "creating name filter dependency", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+
+	var probationDependency *probation.Dependency
+	if configuration.FeatureFlag.Probation {
+		probationDependency, err = probation.New(
+			fileStorage,
+			b,
+			configuration.Probation.Duration,
+			configuration.Probation.Messages,
+			configuration.Probation.MaxViolations,
+		)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "creating probation dependency", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+
+	var analyticsDependency *analytics.Dependency
+	if configuration.FeatureFlag.Analytics {
+		analyticsDependency = &analytics.Dependency{
+			Memory:      sharedCache,
+			Bot:         b,
+			DB:          db,
+			HomeGroupID: configuration.HomeGroupID,
+		}
+
+		err = analyticsDependency.Migrate(ctx)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "migrating analytics tables schema", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+
+	captchaDependency := &captcha.Dependencies{
+		Memory:        sharedCache,
+		Bot:           b,
+		TeknumGroupID: configuration.HomeGroupID,
+		DB:            fileStorage,
+		Probation:     probationDependency,
+		Analytics:     analyticsDependency,
+	}
+
+	var adminDependency *admin.Dependency
+	if configuration.AdminAPI.Token != "" {
+		adminDependency, err = admin.New(configuration.AdminAPI.Token, fileStorage, b, captchaDependency, underAttackDependency, analyticsDependency)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "creating admin dependency", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+
+	var dashboardDependency *dashboard.Dependency
+	if configuration.Dashboard.Token != "" || configuration.Dashboard.TelegramLogin {
+		var botUsername string
+		if configuration.Dashboard.TelegramLogin {
+			botUsername = b.Me.Username
+		}
+
+		dashboardDependency, err = dashboard.New(dashboard.Dependency{
+			Token:       configuration.Dashboard.Token,
+			BotToken:    configuration.BotToken,
+			BotUsername: botUsername,
+			AdminIDs:    configuration.AdminIds,
+			HomeGroupID: configuration.HomeGroupID,
+			Captcha:     captchaDependency,
+			Admin:       adminDependency,
+			UnderAttack: underAttackDependency,
+			Analytics:   analyticsDependency,
+		})
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.ErrorContext(ctx, "creating dashboard dependency", slog.String("error", err.Error()))
+			os.Exit(1)
+			return
+		}
+	}
+
 	program, err := New(Dependency{
 		FeatureFlag: configuration.FeatureFlag,
-		Captcha: &captcha.Dependencies{
-			Memory:        cache,
-			Bot:           b,
-			TeknumGroupID: configuration.HomeGroupID,
-			DB:            fileStorage,
-		},
+		Captcha:     captchaDependency,
 		Ascii:       &ascii.Dependencies{Bot: b},
+		Analytics:   analyticsDependency,
 		UnderAttack: underAttackDependency,
 		Setir:       setirDependency,
 		Reminder:    reminderDependency,
 		Deletion:    deletionDependency,
+		JoinPolicy:  joinPolicyDependency,
+		NameFilter:  nameFilterDependency,
+		Probation:   probationDependency,
+		Admin:       adminDependency,
 	})
 	if err != nil {
 		sentry.CaptureException(err)
@@ -330,11 +602,45 @@ func main() {
 	}
 
 	var httpServer *http.Server
-	if configuration.FeatureFlag.HttpServer {
-		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// The webhook needs the HTTP server, even if it is not enabled by itself.
+	if configuration.FeatureFlag.HttpServer || webhook != nil {
+		checker := &health.Checker{}
+		checker.Register(health.Check{Name: "badger", Liveness: true, Func: health.Badger(fileStorage)})
+		checker.Register(health.Check{Name: "bigcache", Liveness: true, Func: health.Cache(memoryCache)})
+		if db != nil {
+			checker.Register(health.Check{Name: "postgres", Func: health.Postgres(db)})
+		}
+		if redisClient != nil {
+			checker.Register(health.Check{Name: "redis", Func: health.Redis(redisClient)})
+		}
+		// A quiet group sends nothing to the webhook, and Telegram can't reach a
+		// replica that is not ready, so the updates are only reported. It still
+		// fails until the webhook is registered.
+		updateTimeout := configuration.HTTPServer.ReadinessUpdateTimeout
+		if webhook != nil {
+			updateTimeout = 0
+		}
+		checker.Register(health.Check{Name: "updates", Func: health.Updates(b, updateTimeout)})
+		checker.Register(health.Check{Name: "scheduler", Func: health.Scheduler(b)})
+
+		h := http.NewServeMux()
+		h.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("ok"))
 		})
+		h.Handle("GET /healthz", checker.LivenessHandler())
+		h.Handle("GET /readyz", checker.ReadinessHandler())
+		h.Handle("GET /metrics", metrics.Handler())
+		if adminDependency != nil {
+			h.Handle("/admin/", adminDependency.Handler())
+		}
+		if dashboardDependency != nil {
+			h.Handle("/dashboard/", dashboardDependency.Handler())
+		}
+		if webhook != nil {
+			h.Handle(webhookPath, webhook)
+		}
+
 		httpServer = &http.Server{
 			Addr:              net.JoinHostPort(configuration.HTTPServer.ListeningHost, configuration.HTTPServer.ListeningPort),
 			Handler:           h,
@@ -345,7 +651,8 @@ func main() {
 		}
 	}
 
-	// This is basically just for health check.
+	// Lets the admins see whether the bot responds in a group. The HTTP server
+	// has /healthz and /readyz for the actual health checks.
 	b.Handle("/start", func(c tb.Context) error {
 		if c.Message().FromGroup() {
 			_, err := c.Bot().Send(context.Background(), c.Message().Chat, "ok")
@@ -369,16 +676,50 @@ func main() {
 	b.Handle(tb.OnVoice, program.OnNonTextHandler)
 	b.Handle(tb.OnVideoNote, program.OnNonTextHandler)
 	b.Handle(tb.OnUserLeft, program.OnUserLeftHandler)
+	b.Handle(tb.OnMyChatMember, program.OnMyChatMemberHandler)
 
 	// Under attack handlers
 	b.Handle("/underattack", program.EnableUnderAttackModeHandler)
 	b.Handle("/disableunderattack", program.DisableUnderAttackModeHandler)
+	b.Handle(tb.OnChatJoinRequest, program.OnChatJoinRequestHandler)
+	b.Handle("/attacks", program.AttacksHandler)
+	b.Handle("/allowjoin", program.AllowJoinHandler)
+	b.Handle("\f"+underattack.ReviewCallbackUnique, program.ReviewCallbackHandler)
+	b.Handle("\f"+underattack.InviteLinkCallbackUnique, program.InviteLinkCallbackHandler)
+
+	// Analytics handlers
+	b.Handle("/purgebots", program.PurgeBotsHandler)
+	b.Handle("/analytics", program.AnalyticsHandler)
+	// Every /stats runs several queries on PostgreSQL, and the result is the same
+	// for everyone in the group, so it is limited by the group.
+	statsRateLimiter, err := ratelimit.NewTokenBucket("stats", rateLimitStore, time.Second*30, 2)
+	if err != nil {
+		slog.ErrorContext(ctx, "creating rate limiter for stats", slog.String("error", err.Error()))
+		os.Exit(1)
+		return
+	}
+	b.Handle("/stats", program.StatsHandler, ratelimit.Middleware(statsRateLimiter, ratelimit.ByChat, nil))
+
+	// Probation handlers
+	b.Handle("/trust", program.TrustHandler)
 
 	// Reminder (temporary feature)
-	b.Handle("/remind", program.ReminderHandler)
+	reminderRateLimiter, err := ratelimit.NewSlidingWindow("remind", rateLimitStore, 5, time.Minute)
+	if err != nil {
+		slog.ErrorContext(ctx, "creating rate limiter for reminder feature", slog.String("error", err.Error()))
+		os.Exit(1)
+		return
+	}
+	b.Handle("/remind", program.ReminderHandler, ratelimit.Middleware(reminderRateLimiter, ratelimit.Keys(ratelimit.ByChat, ratelimit.BySender), nil))
 
 	// Deletion (temporary feature)
-	b.Handle("/delete", program.DeletionHandler)
+	deletionRateLimiter, err := ratelimit.NewTokenBucket("delete", rateLimitStore, time.Second*20, 3)
+	if err != nil {
+		slog.ErrorContext(ctx, "creating rate limiter for deletion feature", slog.String("error", err.Error()))
+		os.Exit(1)
+		return
+	}
+	b.Handle("/delete", program.DeletionHandler, ratelimit.Middleware(deletionRateLimiter, ratelimit.Keys(ratelimit.ByChat, ratelimit.BySender), nil))
 
 	// <redacted>
 	b.Handle("/setir", program.SetirHandler)
@@ -427,6 +768,18 @@ func main() {
 		program.Captcha.Cleanup()
 	}()
 
+	if program.FeatureFlag.UnderAttack {
+		go func() {
+			// Run the scheduled under attack windows
+			program.UnderAttack.RunScheduler(sentry.SetHubOnContext(context.Background(), sentry.CurrentHub().Clone()))
+		}()
+
+		go func() {
+			// Drop the cached entries changed by other replicas
+			program.UnderAttack.ListenForChanges(sentry.SetHubOnContext(context.Background(), sentry.CurrentHub().Clone()))
+		}()
+	}
+
 	// Lesson learned: do not start bot on a goroutine
 	slog.InfoContext(ctx, "Bot started!")
 	b.Start()
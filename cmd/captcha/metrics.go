@@ -0,0 +1,9 @@
+package main
+
+import "github.com/teknologi-umum/captcha/metrics"
+
+var (
+	joinsTotal    = metrics.NewCounter("captcha_joins_total", "New members joining a group.", "chat_id")
+	apiCallsTotal = metrics.NewCounter("telegram_api_calls_total", "Outbound Bot API calls.", "method", "status")
+	floodWaits    = metrics.NewCounter("telegram_flood_waits_total", "Bot API calls rejected with 429 Too Many Requests.", "method")
+)
@@ -14,7 +14,11 @@ type SentryTransportWrapper struct {
 	OriginalTransport *http.Transport
 }
 
-func (s *SentryTransportWrapper) RoundTrip(request *http.Request) (*http.Response, error) {
+func (s *SentryTransportWrapper) RoundTrip(request *http.Request) (response *http.Response, err error) {
+	defer func() {
+		observeAPICall(request, response)
+	}()
+
 	ignoredMethods := []string{"getme", "logout", "close", "getupdates"}
 	if slices.Contains(ignoredMethods, strings.ToLower(request.URL.Path)) {
 		return s.OriginalTransport.RoundTrip(request)
@@ -41,7 +45,7 @@ func (s *SentryTransportWrapper) RoundTrip(request *http.Request) (*http.Respons
 	span.SetData("http.fragment", request.URL.Fragment)
 	span.SetData("http.request.method", request.Method)
 
-	response, err := s.OriginalTransport.RoundTrip(request)
+	response, err = s.OriginalTransport.RoundTrip(request)
 
 	if response != nil {
 		span.Status = sentry.HTTPtoSpanStatus(response.StatusCode)
@@ -51,3 +55,22 @@ func (s *SentryTransportWrapper) RoundTrip(request *http.Request) (*http.Respons
 
 	return response, err
 }
+
+// observeAPICall counts the call by its Bot API method, which is the last
+// segment of the path. A nil response means the request did not go through.
+func observeAPICall(request *http.Request, response *http.Response) {
+	method := "file"
+	if strings.HasPrefix(request.URL.Path, "/bot") {
+		method = request.URL.Path[strings.LastIndex(request.URL.Path, "/")+1:]
+	}
+
+	status := "error"
+	if response != nil {
+		status = strconv.Itoa(response.StatusCode)
+		if response.StatusCode == http.StatusTooManyRequests {
+			floodWaits.Inc(method)
+		}
+	}
+
+	apiCallsTotal.Inc(method, status)
+}
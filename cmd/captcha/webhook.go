@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// newWebhook creates the webhook poller, along with the path of the HTTP server
+// it should be mounted on. It returns nil when the bot should use long polling.
+func newWebhook(configuration Configuration) (*tb.Webhook, string, error) {
+	if configuration.Webhook.PublicURL == "" {
+		return nil, "", nil
+	}
+
+	publicURL, err := url.Parse(configuration.Webhook.PublicURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing webhook public url: %w", err)
+	}
+
+	if publicURL.Scheme != "https" || publicURL.Host == "" {
+		return nil, "", fmt.Errorf("webhook public url must be an absolute https url, got %q", configuration.Webhook.PublicURL)
+	}
+
+	// The root path already serves the health check.
+	if publicURL.Path == "" || publicURL.Path == "/" {
+		return nil, "", fmt.Errorf("webhook public url must have a path, such as https://%s/telegram", publicURL.Host)
+	}
+
+	secretToken := configuration.Webhook.SecretToken
+	if secretToken == "" {
+		// Derived from the bot token, so every replica and every deployment
+		// agrees on it without any extra configuration.
+		sum := sha256.Sum256([]byte("webhook:" + configuration.BotToken))
+		secretToken = hex.EncodeToString(sum[:])
+	}
+
+	return &tb.Webhook{
+		SecretToken:    secretToken,
+		MaxConnections: configuration.Webhook.MaxConnections,
+		DropUpdates:    configuration.Webhook.DropPendingUpdates,
+		Endpoint:       &tb.WebhookEndpoint{PublicURL: publicURL.String()},
+	}, publicURL.Path, nil
+}
@@ -0,0 +1,204 @@
+package dashboard
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SessionDuration is how long a login lasts.
+	SessionDuration = time.Hour * 12
+	// telegramLoginMaxAge is how old the data of the Telegram Login Widget may be,
+	// so a leaked login URL can't be used forever.
+	telegramLoginMaxAge = time.Hour * 24
+
+	sessionCookie = "dashboard_session"
+)
+
+var errInvalidLogin = errors.New("invalid login")
+
+// deriveSessionKey derives the key that signs the sessions from the bot token,
+// so every replica agrees on it. Changing the static token logs everyone out.
+func deriveSessionKey(botToken string, token string) []byte {
+	sum := sha256.Sum256([]byte("dashboard:" + botToken + ":" + token))
+	return sum[:]
+}
+
+// sign returns the session of the subject, which is either "token"
+// or the ID of the Telegram user.
+func (d *Dependency) sign(subject string, expiresAt time.Time) string {
+	payload := subject + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	mac := hmac.New(sha256.New, d.sessionKey)
+	mac.Write([]byte(payload))
+	return payload + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// session returns the subject of the request's session, if it is valid.
+func (d *Dependency) session(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+
+	fields := strings.Split(cookie.Value, ".")
+	if len(fields) != 3 {
+		return "", false
+	}
+
+	expiresAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(cookie.Value), []byte(d.sign(fields[0], time.Unix(expiresAt, 0)))) {
+		return "", false
+	}
+
+	// The login method may have been disabled, or the user is no longer an admin.
+	if fields[0] == "token" && d.Token == "" || fields[0] != "token" && !slices.Contains(d.AdminIDs, fields[0]) {
+		return "", false
+	}
+
+	return fields[0], true
+}
+
+func (d *Dependency) startSession(w http.ResponseWriter, r *http.Request, subject string) {
+	expiresAt := time.Now().Add(SessionDuration)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    d.sign(subject, expiresAt),
+		Path:     "/dashboard/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/dashboard/", http.StatusSeeOther)
+}
+
+type loginData struct {
+	Token       bool
+	BotUsername string
+	AuthURL     string
+	Error       string
+}
+
+func (d *Dependency) loginPage(w http.ResponseWriter, r *http.Request) {
+	d.renderLogin(w, r, http.StatusOK, "")
+}
+
+func (d *Dependency) renderLogin(w http.ResponseWriter, r *http.Request, status int, message string) {
+	scheme := "http"
+	if secure(r) {
+		scheme = "https"
+	}
+
+	render(w, r, status, "login.html", loginData{
+		Token:       d.Token != "",
+		BotUsername: d.BotUsername,
+		AuthURL:     scheme + "://" + r.Host + "/dashboard/login/telegram",
+		Error:       message,
+	})
+}
+
+// tokenLogin handles the login form of the static token.
+func (d *Dependency) tokenLogin(w http.ResponseWriter, r *http.Request) {
+	token := r.PostFormValue("token")
+	if d.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(d.Token)) != 1 {
+		d.renderLogin(w, r, http.StatusUnauthorized, "Wrong token.")
+		return
+	}
+
+	d.startSession(w, r, "token")
+}
+
+// telegramLogin handles the redirect of the Telegram Login Widget.
+func (d *Dependency) telegramLogin(w http.ResponseWriter, r *http.Request) {
+	if d.BotUsername == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID, err := verifyTelegramLogin(d.BotToken, r.URL.Query(), time.Now())
+	if err != nil {
+		d.renderLogin(w, r, http.StatusUnauthorized, "Invalid Telegram login, please try again.")
+		return
+	}
+
+	if !slices.Contains(d.AdminIDs, strconv.FormatInt(userID, 10)) {
+		d.renderLogin(w, r, http.StatusForbidden, "This Telegram account is not an admin of the bot.")
+		return
+	}
+
+	d.startSession(w, r, strconv.FormatInt(userID, 10))
+}
+
+func (d *Dependency) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/dashboard/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/dashboard/login", http.StatusSeeOther)
+}
+
+// verifyTelegramLogin checks the hash of the data sent by the Telegram Login Widget,
+// as described on https://core.telegram.org/widgets/login#checking-authorization,
+// and returns the ID of the user.
+func verifyTelegramLogin(botToken string, query url.Values, now time.Time) (int64, error) {
+	hash := query.Get("hash")
+	if hash == "" {
+		return 0, errInvalidLogin
+	}
+
+	var fields []string
+	for key := range query {
+		if key == "hash" {
+			continue
+		}
+
+		fields = append(fields, key+"="+query.Get(key))
+	}
+	sort.Strings(fields)
+
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(fields, "\n")))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(hash)) {
+		return 0, errInvalidLogin
+	}
+
+	authDate, err := strconv.ParseInt(query.Get("auth_date"), 10, 64)
+	if err != nil || now.Sub(time.Unix(authDate, 0)) > telegramLoginMaxAge {
+		return 0, errInvalidLogin
+	}
+
+	userID, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil {
+		return 0, errInvalidLogin
+	}
+
+	return userID, nil
+}
+
+// secure tells whether the dashboard is reached over HTTPS, directly
+// or through a reverse proxy.
+func secure(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,153 @@
+package dashboard
+
+import (
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The charts are plain SVG, rendered on the server so the dashboard needs no
+// JavaScript. Their colors come from the stylesheet.
+
+var wib = time.FixedZone("WIB", 7*60*60)
+
+var weekdays = [7]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// chartBar is a single bar of a chart. The parts of a bar are stacked on top of each other.
+type chartBar struct {
+	Label string
+	Parts []int
+}
+
+func (b chartBar) total() int {
+	var total int
+	for _, part := range b.Parts {
+		total += part
+	}
+	return total
+}
+
+// heatmapChart renders the counters by weekday, from Monday, and by hour.
+func heatmapChart(cells [7][24]int) template.HTML {
+	const cell, left, top = 22, 40, 20
+
+	var highest int
+	for _, row := range cells {
+		for _, value := range row {
+			highest = max(highest, value)
+		}
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg class="chart heatmap" viewBox="0 0 %d %d" role="img" aria-label="Message volume by weekday and hour">`, left+cell*24, top+cell*7)
+
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(&svg, `<text x="%d" y="14">%02d</text>`, left+hour*cell, hour)
+	}
+
+	for day, row := range cells {
+		fmt.Fprintf(&svg, `<text x="0" y="%d">%s</text>`, top+day*cell+15, weekdays[day])
+
+		for hour, value := range row {
+			opacity := 0.0
+			if highest > 0 {
+				opacity = float64(value) / float64(highest)
+			}
+
+			fmt.Fprintf(&svg,
+				`<rect x="%d" y="%d" width="%d" height="%d" fill-opacity="%.2f"><title>%s %02d:00, %d</title></rect>`,
+				left+hour*cell, top+day*cell, cell-2, cell-2, max(opacity, 0.05), weekdays[day], hour, value,
+			)
+		}
+	}
+
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+// barChart renders horizontal bars, one per row, with their value at the end.
+func barChart(bars []chartBar) template.HTML {
+	const row, label, width = 24, 160, 320
+
+	var highest int
+	for _, bar := range bars {
+		highest = max(highest, bar.total())
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg class="chart bars" viewBox="0 0 %d %d" role="img">`, label+width+60, max(len(bars), 1)*row)
+
+	for i, bar := range bars {
+		y := i * row
+		fmt.Fprintf(&svg, `<text x="0" y="%d">%s</text>`, y+16, template.HTMLEscapeString(truncate(bar.Label, 24)))
+
+		x := label
+		for part, value := range bar.Parts {
+			length := 0
+			if highest > 0 {
+				length = value * width / highest
+			}
+
+			fmt.Fprintf(&svg, `<rect class="part-%d" x="%d" y="%d" width="%d" height="%d"/>`, part, x, y+4, length, row-8)
+			x += length
+		}
+
+		fmt.Fprintf(&svg, `<text x="%d" y="%d">%s</text>`, x+6, y+16, strconv.Itoa(bar.total()))
+	}
+
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+// stackedBarChart renders vertical bars, one per column, with the labels below.
+func stackedBarChart(bars []chartBar) template.HTML {
+	const column, height, bottom = 18, 160, 40
+
+	var highest int
+	for _, bar := range bars {
+		highest = max(highest, bar.total())
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg class="chart columns" viewBox="0 0 %d %d" role="img">`, max(len(bars), 1)*column, height+bottom)
+
+	for i, bar := range bars {
+		x := i * column
+		y := height
+		for part, value := range bar.Parts {
+			length := 0
+			if highest > 0 {
+				length = value * height / highest
+			}
+
+			y -= length
+			fmt.Fprintf(&svg, `<rect class="part-%d" x="%d" y="%d" width="%d" height="%d"><title>%s: %d</title></rect>`,
+				part, x+2, y, column-4, length, template.HTMLEscapeString(bar.Label), value)
+		}
+
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" transform="rotate(90 %d %d)">%s</text>`,
+			x+5, height+6, x+5, height+6, template.HTMLEscapeString(bar.Label))
+	}
+
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+
+	return string(runes[:length-1]) + "…"
+}
+
+func formatDateTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.In(wib).Format("02 Jan 2006 15:04 MST")
+}
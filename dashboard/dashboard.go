@@ -0,0 +1,111 @@
+// Package dashboard is a read only web dashboard for the admins, served by the
+// HTTP server. It shows what the analytics tables, the captcha audit events and
+// the under attack incidents have recorded, without anyone writing SQL.
+package dashboard
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/teknologi-umum/captcha/admin"
+	"github.com/teknologi-umum/captcha/analytics"
+	"github.com/teknologi-umum/captcha/captcha"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+//go:embed templates static
+var assets embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"heatmap":  heatmapChart,
+	"bars":     barChart,
+	"stacked":  stackedBarChart,
+	"datetime": formatDateTime,
+}).ParseFS(assets, "templates/*.html"))
+
+// Dependency contains the dependency injection struct
+// for methods in the dashboard package.
+type Dependency struct {
+	// Token lets anyone who knows it log in. Empty disables the token login.
+	Token string
+	// BotToken verifies the logins of the Telegram Login Widget.
+	BotToken string
+	// BotUsername is shown on the Telegram Login Widget. Empty disables the
+	// Telegram login.
+	BotUsername string
+	// AdminIDs are the Telegram users that are allowed to log in with the widget.
+	AdminIDs    []string
+	HomeGroupID int64
+	Captcha     *captcha.Dependencies
+	// Admin is optional, it knows every group the bot is in. Without it the
+	// dashboard only shows the home group.
+	Admin *admin.Dependency
+	// UnderAttack is optional, nil means the feature is disabled.
+	UnderAttack *underattack.Dependency
+	// Analytics is optional, nil means the feature is disabled.
+	Analytics *analytics.Dependency
+
+	sessionKey []byte
+}
+
+// New creates a new dashboard dependency.
+func New(dependency Dependency) (*Dependency, error) {
+	if dependency.Token == "" && dependency.BotUsername == "" {
+		return nil, fmt.Errorf("neither the token nor the telegram login is configured")
+	}
+
+	if dependency.BotToken == "" {
+		return nil, fmt.Errorf("bot token is empty")
+	}
+
+	if dependency.Captcha == nil {
+		return nil, fmt.Errorf("captcha dependency is nil")
+	}
+
+	dependency.sessionKey = deriveSessionKey(dependency.BotToken, dependency.Token)
+	return &dependency, nil
+}
+
+// Handler serves the dashboard under /dashboard/.
+func (d *Dependency) Handler() http.Handler {
+	static, _ := fs.Sub(assets, "static")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /dashboard/static/", http.StripPrefix("/dashboard/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /dashboard/login", d.loginPage)
+	mux.HandleFunc("POST /dashboard/login", d.tokenLogin)
+	mux.HandleFunc("GET /dashboard/login/telegram", d.telegramLogin)
+	mux.HandleFunc("POST /dashboard/logout", d.logout)
+	mux.Handle("GET /dashboard/{$}", d.authenticated(d.overviewPage))
+	mux.Handle("GET /dashboard/chats/{chat}", d.authenticated(d.chatPage))
+
+	return mux
+}
+
+// authenticated sends the visitors without a valid session to the login page.
+func (d *Dependency) authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := d.session(r); !ok {
+			http.Redirect(w, r, "/dashboard/login", http.StatusSeeOther)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+func render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+
+	err := templates.ExecuteTemplate(w, name, data)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "rendering dashboard template", slog.String("template", name), slog.String("error", err.Error()))
+	}
+}
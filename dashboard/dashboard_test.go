@@ -0,0 +1,211 @@
+package dashboard_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/teknologi-umum/captcha/captcha"
+	"github.com/teknologi-umum/captcha/dashboard"
+	"github.com/teknologi-umum/captcha/underattack"
+	"github.com/teknologi-umum/captcha/underattack/datastore"
+)
+
+const (
+	chatID   = int64(-1001)
+	token    = "secret"
+	botToken = "123:bot-token"
+	adminID  = "42"
+)
+
+func setup(t *testing.T) (http.Handler, *badger.DB) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("opening badger: %s", err.Error())
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	memory, err := bigcache.New(context.Background(), bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("creating bigcache: %s", err.Error())
+	}
+
+	underAttackDatastore, err := datastore.NewInMemoryDatastore(memory)
+	if err != nil {
+		t.Fatalf("creating datastore: %s", err.Error())
+	}
+
+	dependency, err := dashboard.New(dashboard.Dependency{
+		Token:       token,
+		BotToken:    botToken,
+		BotUsername: "TeknumCaptchaBot",
+		AdminIDs:    []string{adminID},
+		HomeGroupID: chatID,
+		Captcha:     &captcha.Dependencies{DB: db, Memory: memory},
+		UnderAttack: &underattack.Dependency{Datastore: underAttackDatastore, Memory: memory},
+	})
+	if err != nil {
+		t.Fatalf("creating dashboard: %s", err.Error())
+	}
+
+	return dependency.Handler(), db
+}
+
+// telegramLogin returns the query the Telegram Login Widget redirects with,
+// signed by botToken.
+func telegramLogin(userID string, authDate time.Time, botToken string) url.Values {
+	query := url.Values{
+		"id":         {userID},
+		"first_name": {"Reinaldy"},
+		"username":   {"aldy505"},
+		"auth_date":  {strconv.FormatInt(authDate.Unix(), 10)},
+	}
+
+	var fields []string
+	for key := range query {
+		fields = append(fields, key+"="+query.Get(key))
+	}
+	sort.Strings(fields)
+
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(fields, "\n")))
+	query.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+
+	return query
+}
+
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func login(t *testing.T, handler http.Handler) *http.Cookie {
+	r := httptest.NewRequest(http.MethodPost, "/dashboard/login", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := serve(handler, r)
+	if w.Code != http.StatusSeeOther || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("logging in: expecting a redirect with a session cookie, got %d", w.Code)
+	}
+
+	return w.Result().Cookies()[0]
+}
+
+func TestTokenLogin(t *testing.T) {
+	handler, _ := setup(t)
+
+	w := serve(handler, httptest.NewRequest(http.MethodGet, "/dashboard/", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/dashboard/login" {
+		t.Errorf("without a session: expecting a redirect to the login page, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/dashboard/login", strings.NewReader("token=wrong"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if w := serve(handler, r); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: expecting status 401, got %d", w.Code)
+	}
+
+	cookie := login(t, handler)
+
+	r = httptest.NewRequest(http.MethodGet, "/dashboard/", nil)
+	r.AddCookie(cookie)
+	if w := serve(handler, r); w.Code != http.StatusOK {
+		t.Errorf("with a session: expecting status 200, got %d", w.Code)
+	}
+
+	// A tampered session must not be accepted.
+	r = httptest.NewRequest(http.MethodGet, "/dashboard/", nil)
+	r.AddCookie(&http.Cookie{Name: cookie.Name, Value: strings.Replace(cookie.Value, "token", adminID, 1)})
+	if w := serve(handler, r); w.Code != http.StatusSeeOther {
+		t.Errorf("tampered session: expecting a redirect, got %d", w.Code)
+	}
+}
+
+func TestTelegramLogin(t *testing.T) {
+	handler, _ := setup(t)
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int
+	}{
+		{name: "valid", query: telegramLogin(adminID, time.Now(), botToken), status: http.StatusSeeOther},
+		{name: "not an admin", query: telegramLogin("7", time.Now(), botToken), status: http.StatusForbidden},
+		{name: "other bot", query: telegramLogin(adminID, time.Now(), "456:other-token"), status: http.StatusUnauthorized},
+		{name: "too old", query: telegramLogin(adminID, time.Now().Add(-time.Hour*48), botToken), status: http.StatusUnauthorized},
+		{name: "no hash", query: url.Values{"id": {adminID}}, status: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(handler, httptest.NewRequest(http.MethodGet, "/dashboard/login/telegram?"+test.query.Encode(), nil))
+			if w.Code != test.status {
+				t.Errorf("expecting status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+
+	// The user ID is part of the signed data.
+	query := telegramLogin(adminID, time.Now(), botToken)
+	query.Set("id", "7")
+	if w := serve(handler, httptest.NewRequest(http.MethodGet, "/dashboard/login/telegram?"+query.Encode(), nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("changed user id: expecting status 401, got %d", w.Code)
+	}
+}
+
+func TestChatPage(t *testing.T) {
+	handler, db := setup(t)
+	cookie := login(t, handler)
+
+	value, err := json.Marshal(captcha.Captcha{Answer: "123", Expiry: time.Now().Add(captcha.Timeout), ChatID: chatID, SenderID: 4242})
+	if err != nil {
+		t.Fatalf("marshaling captcha: %s", err.Error())
+	}
+
+	err = db.Update(func(txn *badger.Txn) error {
+		err := txn.Set([]byte("-1001:4242"), value)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte("captcha:users:-1001"), []byte(";4242"))
+	})
+	if err != nil {
+		t.Fatalf("storing captcha: %s", err.Error())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/dashboard/", nil)
+	r.AddCookie(cookie)
+	w := serve(handler, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="/dashboard/chats/-1001"`) {
+		t.Errorf("overview: expecting the home group to be listed, got %d", w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/dashboard/chats/-1001", nil)
+	r.AddCookie(cookie)
+	w = serve(handler, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat page: expecting status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, expected := range []string{"<td>4242</td>", `<svg class="chart bars"`, "never been under attack", "analytics are disabled"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("chat page: expecting %q on the page", expected)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/admin"
+	"github.com/teknologi-umum/captcha/analytics"
+	"github.com/teknologi-umum/captcha/captcha"
+	"github.com/teknologi-umum/captcha/internal/requestid"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+const (
+	// incidentsLimit is how many recent under attack incidents are shown.
+	incidentsLimit = 20
+	// auditLimit caps the audit events that make up the captcha funnel.
+	auditLimit = 10_000
+	// topMembersLimit is how many of the most active members are shown.
+	topMembersLimit = 15
+	// joinDays is how many days the join chart goes back.
+	joinDays = 30
+	// heatmapDays is how many days the message heatmap goes back.
+	heatmapDays = 90
+)
+
+type chatSummary struct {
+	admin.Chat
+	PendingCaptchas int
+	UnderAttack     bool
+}
+
+type pendingCaptcha struct {
+	UserID    int64
+	ExpiresAt time.Time
+}
+
+type chatData struct {
+	admin.Chat
+	PendingCaptchas []pendingCaptcha
+	Funnel          []chartBar
+	// UnderAttack tells whether the under attack feature is enabled.
+	UnderAttack bool
+	Incidents   []underattack.Incident
+	// Analytics tells whether the chat opted in to the analytics.
+	Analytics bool
+	// Timezone is the timezone of the weekdays and hours of the heatmap.
+	Timezone   string
+	Heatmap    [7][24]int
+	TopMembers []chartBar
+	Joins      []chartBar
+}
+
+func (d *Dependency) context(r *http.Request, handler string) context.Context {
+	ctx := sentry.SetHubOnContext(context.WithoutCancel(r.Context()), sentry.CurrentHub().Clone())
+	ctx = requestid.SetRequestIdOnContext(ctx)
+	return shared.SetHandlerOnContext(ctx, "dashboard."+handler)
+}
+
+// internalError reports the error and hides it from the page.
+func internalError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	shared.HandleError(ctx, err)
+	render(w, r, http.StatusInternalServerError, "error.html", "Something went wrong, request id "+requestid.GetRequestIdFromContext(ctx))
+}
+
+// chats returns the chats known by the admin API, with the home group always on it.
+func (d *Dependency) chats(ctx context.Context) ([]admin.Chat, error) {
+	var chats []admin.Chat
+	if d.Admin != nil {
+		var err error
+		chats, err = d.Admin.Chats(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.HomeGroupID != 0 && !slices.ContainsFunc(chats, func(chat admin.Chat) bool { return chat.ID == d.HomeGroupID }) {
+		chats = append(chats, admin.Chat{ID: d.HomeGroupID})
+	}
+
+	return chats, nil
+}
+
+func (d *Dependency) overviewPage(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "overviewPage")
+
+	chats, err := d.chats(ctx)
+	if err != nil {
+		internalError(ctx, w, r, err)
+		return
+	}
+
+	summaries := make([]chatSummary, 0, len(chats))
+	for _, chat := range chats {
+		pending, err := d.Captcha.Pending(ctx, chat.ID)
+		if err != nil {
+			internalError(ctx, w, r, err)
+			return
+		}
+
+		var underAttack bool
+		if d.UnderAttack != nil {
+			underAttack, err = d.UnderAttack.AreWe(ctx, chat.ID)
+			if err != nil {
+				internalError(ctx, w, r, err)
+				return
+			}
+		}
+
+		summaries = append(summaries, chatSummary{Chat: chat, PendingCaptchas: len(pending), UnderAttack: underAttack})
+	}
+
+	render(w, r, http.StatusOK, "overview.html", summaries)
+}
+
+func (d *Dependency) chatPage(w http.ResponseWriter, r *http.Request) {
+	ctx := d.context(r, "chatPage")
+
+	chatID, err := strconv.ParseInt(r.PathValue("chat"), 10, 64)
+	if err != nil {
+		render(w, r, http.StatusNotFound, "error.html", "Unknown chat.")
+		return
+	}
+
+	chats, err := d.chats(ctx)
+	if err != nil {
+		internalError(ctx, w, r, err)
+		return
+	}
+
+	data := chatData{Chat: admin.Chat{ID: chatID}}
+	if index := slices.IndexFunc(chats, func(chat admin.Chat) bool { return chat.ID == chatID }); index >= 0 {
+		data.Chat = chats[index]
+	}
+
+	captchas, err := d.Captcha.Pending(ctx, chatID)
+	if err != nil {
+		internalError(ctx, w, r, err)
+		return
+	}
+
+	for _, c := range captchas {
+		data.PendingCaptchas = append(data.PendingCaptchas, pendingCaptcha{UserID: c.SenderID, ExpiresAt: c.Expiry})
+	}
+
+	data.Funnel, err = d.funnel(ctx, chatID)
+	if err != nil {
+		internalError(ctx, w, r, err)
+		return
+	}
+
+	if d.UnderAttack != nil {
+		data.UnderAttack = true
+
+		data.Incidents, err = d.UnderAttack.Datastore.ListIncidents(ctx, chatID, incidentsLimit)
+		if err != nil {
+			internalError(ctx, w, r, fmt.Errorf("listing incidents: %w", err))
+			return
+		}
+	}
+
+	if d.Analytics != nil {
+		data.Analytics, err = d.Analytics.Enabled(ctx, chatID)
+		if err != nil {
+			internalError(ctx, w, r, err)
+			return
+		}
+	}
+
+	if data.Analytics {
+		err = d.fillAnalytics(ctx, &data)
+		if err != nil {
+			internalError(ctx, w, r, err)
+			return
+		}
+	}
+
+	render(w, r, http.StatusOK, "chat.html", data)
+}
+
+// funnel counts the captcha audit events of the group, the captchas
+// presented first and what became of them after.
+func (d *Dependency) funnel(ctx context.Context, chatID int64) ([]chartBar, error) {
+	events, err := d.Captcha.AuditEvents(ctx, chatID, auditLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[captcha.AuditAction]int)
+	for _, event := range events {
+		counts[event.Action]++
+	}
+
+	return []chartBar{
+		{Label: "Presented", Parts: []int{counts[captcha.AuditPresented]}},
+		{Label: "Wrong answers", Parts: []int{counts[captcha.AuditWrongAnswer]}},
+		{Label: "Passed", Parts: []int{counts[captcha.AuditPassed]}},
+		{Label: "Failed", Parts: []int{counts[captcha.AuditFailed]}},
+		{Label: "Left", Parts: []int{counts[captcha.AuditLeft]}},
+	}, nil
+}
+
+func (d *Dependency) fillAnalytics(ctx context.Context, data *chatData) error {
+	location, err := d.Analytics.Location(ctx, data.ID)
+	if err != nil {
+		return err
+	}
+
+	hourly, err := d.Analytics.GetMessagesFromDB(ctx, data.ID, time.Now().AddDate(0, 0, -heatmapDays), analytics.RollupHourly)
+	if err != nil {
+		return fmt.Errorf("getting hourly data: %w", err)
+	}
+
+	data.Timezone = location.String()
+	data.Heatmap = analytics.Heatmap(hourly)
+
+	members, err := d.Analytics.TopMembers(ctx, data.ID, topMembersLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		label := member.DisplayName
+		if member.Username != "" {
+			label += " (@" + member.Username + ")"
+		}
+
+		data.TopMembers = append(data.TopMembers, chartBar{Label: label, Parts: []int{member.Counter}})
+	}
+
+	joins, err := d.Analytics.DailyJoins(ctx, data.ID, time.Now().AddDate(0, 0, -joinDays))
+	if err != nil {
+		return err
+	}
+
+	for _, join := range joins {
+		data.Joins = append(data.Joins, chartBar{
+			Label: join.Day.Format("02 Jan"),
+			Parts: []int{join.Finished, join.Joined - join.Finished},
+		})
+	}
+
+	return nil
+}
@@ -33,6 +33,9 @@ func ScanSpace(data []byte, atEOF bool) (advance int, token []byte, err error) {
 
 var clockRegex = regexp.MustCompile("^[0-9]{1,2}:[0-9]{2}(:[0-9]{2})?$")
 
+// compactRegex matches a value and its unit without a space in between, like "2h" or "30menit".
+var compactRegex = regexp.MustCompile("^([0-9]+)([a-z]+)$")
+
 func ParseDuration(ctx context.Context, s string) (time.Duration, error) {
 	span := sentry.StartSpan(ctx, "deletion.parse_duration")
 	defer span.Finish()
@@ -44,7 +47,7 @@ func ParseDuration(ctx context.Context, s string) (time.Duration, error) {
 	for scanner.Scan() {
 		part := scanner.Text()
 
-		if slices.Contains([]string{"dalam", "pada", "in", "on", "at"}, part) {
+		if slices.Contains([]string{"dalam", "pada", "sampai", "hingga", "in", "on", "at", "until"}, part) {
 			continue
 		}
 
@@ -57,6 +60,25 @@ func ParseDuration(ctx context.Context, s string) (time.Duration, error) {
 			break
 		}
 
+		if matches := compactRegex.FindStringSubmatch(part); matches != nil {
+			if duration != 0 {
+				break
+			}
+
+			value, err := strconv.ParseInt(matches[1], 10, 64)
+			if err != nil || value <= 0 {
+				continue
+			}
+
+			unit, ok := parseUnit(matches[2])
+			if !ok {
+				continue
+			}
+
+			duration = time.Duration(value) * unit
+			continue
+		}
+
 		if clockRegex.MatchString(part) {
 			if duration != 0 {
 				continue
@@ -73,24 +95,27 @@ func ParseDuration(ctx context.Context, s string) (time.Duration, error) {
 			break
 		}
 
-		switch part {
-		case "detik", "second", "seconds", "sec", "s":
-			duration = duration * time.Second
-			break
-		case "menit", "m", "mnt", "minute", "minutes":
-			duration = duration * time.Minute
-			break
-		case "jam", "j", "hour", "hours":
-			duration = duration * time.Hour
-			break
-		default:
-			continue
+		if unit, ok := parseUnit(part); ok {
+			duration = duration * unit
 		}
 	}
 
 	return duration, nil
 }
 
+func parseUnit(s string) (time.Duration, bool) {
+	switch s {
+	case "detik", "second", "seconds", "sec", "s":
+		return time.Second, true
+	case "menit", "m", "mnt", "minute", "minutes":
+		return time.Minute, true
+	case "jam", "j", "h", "hour", "hours":
+		return time.Hour, true
+	default:
+		return 0, false
+	}
+}
+
 var ErrParseClock = errors.New("parse clock")
 
 func ParseClock(s string) (hour int, minute int, err error) {
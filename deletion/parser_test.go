@@ -35,6 +35,18 @@ func TestParseDuration(t *testing.T) {
 			input:  "in 1 minute 10 second",
 			expect: time.Minute,
 		},
+		{
+			input:  "2h",
+			expect: time.Hour * 2,
+		},
+		{
+			input:  "dalam 30menit",
+			expect: time.Minute * 30,
+		},
+		{
+			input:  "until 11:30",
+			expect: time.Date(n.Year(), n.Month(), n.Day(), 11, 30, 0, 0, time.FixedZone("UTC+7", 7*60*60)).Sub(n),
+		},
 		{
 			input:  "in 11:30",
 			expect: time.Date(n.Year(), n.Month(), n.Day(), 11, 30, 0, 0, time.FixedZone("UTC+7", 7*60*60)).Sub(n),
@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/teknologi-umum/captcha/cache"
+)
+
+// probeKey is read from the datastores, it does not need to exist.
+const probeKey = "health:probe"
+
+// Badger checks that a read transaction can be opened and used.
+func Badger(db *badger.DB) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		err := db.View(func(txn *badger.Txn) error {
+			_, err := txn.Get([]byte(probeKey))
+			if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading badger: %w", err)
+		}
+
+		return nil, nil
+	}
+}
+
+// Pinger is implemented by *sql.DB and *sqlx.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Postgres checks that the database answers a ping.
+func Postgres(db Pinger) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		err := db.PingContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("pinging postgres: %w", err)
+		}
+
+		return nil, nil
+	}
+}
+
+// Redis checks that the server answers a ping.
+func Redis(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		err := client.Ping(ctx).Err()
+		if err != nil {
+			return nil, fmt.Errorf("pinging redis: %w", err)
+		}
+
+		return nil, nil
+	}
+}
+
+// Cache checks that an entry can be written to and read back from the cache.
+func Cache(c cache.Cache) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		want := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+		err := c.Set(probeKey, want)
+		if err != nil {
+			return nil, fmt.Errorf("writing cache: %w", err)
+		}
+
+		got, err := c.Get(probeKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading cache: %w", err)
+		}
+
+		// Another replica may have written its own probe in the meantime on a
+		// shared cache, so only an empty entry is suspicious.
+		if len(got) == 0 {
+			return nil, fmt.Errorf("reading cache: empty entry")
+		}
+
+		return nil, nil
+	}
+}
+
+// Poller is implemented by *telebot.Bot.
+type Poller interface {
+	LastPoll() time.Time
+}
+
+// Updates checks that the bot has heard from Telegram within maxAge, through
+// either getUpdates or the webhook. A zero maxAge only reports the last time,
+// but still fails until the bot has heard from Telegram once, which is when
+// the webhook is registered.
+func Updates(bot Poller, maxAge time.Duration) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		lastPoll := bot.LastPoll()
+		if lastPoll.IsZero() {
+			return nil, errors.New("no updates received yet")
+		}
+
+		age := time.Since(lastPoll)
+		details := map[string]any{
+			"last_update": lastPoll.UTC().Format(time.RFC3339),
+			"age_seconds": int64(age.Seconds()),
+		}
+
+		if maxAge > 0 && age > maxAge {
+			return details, fmt.Errorf("no updates received for %s", age.Truncate(time.Second))
+		}
+
+		return details, nil
+	}
+}
+
+// Queue is implemented by *telebot.Bot.
+type Queue interface {
+	Backlog() int
+}
+
+// Scheduler reports how many requests are waiting in the send queue. A backlog
+// is expected during a raid, so it never fails.
+func Scheduler(bot Queue) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		return map[string]int{"backlog": bot.Backlog()}, nil
+	}
+}
@@ -0,0 +1,156 @@
+// Package health serves the liveness and readiness endpoints of the bot.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long a single check may take before it is considered failed.
+const DefaultTimeout = time.Second * 5
+
+// CheckFunc checks a single component. The details are reported as they are,
+// and a non-nil error marks the component as failing.
+type CheckFunc func(ctx context.Context) (details any, err error)
+
+// Check is a named component check.
+type Check struct {
+	Name string
+	// Liveness makes the liveness probe fail along with this check. Only local
+	// resources belong here. An unreachable database should not get the bot
+	// restarted, it should only stop it from receiving traffic.
+	Liveness bool
+	Func     CheckFunc
+}
+
+// Result is the status of a single check.
+type Result struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+	// Duration is in milliseconds.
+	Duration int64 `json:"duration_ms"`
+}
+
+// Report is the response body of both endpoints.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker runs the registered checks.
+type Checker struct {
+	// Timeout limits every check. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []Check
+}
+
+// Register adds a check. Registering a check with the name of an existing one replaces it.
+func (c *Checker) Register(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, existing := range c.checks {
+		if existing.Name == check.Name {
+			c.checks[i] = check
+			return
+		}
+	}
+
+	c.checks = append(c.checks, check)
+}
+
+// Run runs the checks concurrently. With liveness set, only the checks marked
+// with Liveness decide the overall status, but every check is still reported.
+func (c *Checker) Run(ctx context.Context, liveness bool) Report {
+	c.mu.RLock()
+	checks := append([]Check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check.Func, timeout)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status == StatusFail && (!liveness || check.Liveness) {
+			report.Status = StatusFail
+		}
+	}
+
+	return report
+}
+
+func run(ctx context.Context, fn CheckFunc, timeout time.Duration) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result = Result{Status: StatusFail, Error: fmt.Sprintf("panic: %v", r)}
+		}
+		result.Duration = time.Since(start).Milliseconds()
+	}()
+
+	details, err := fn(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		return Result{Status: StatusFail, Error: err.Error(), Details: details}
+	}
+
+	return Result{Status: StatusOK, Details: details}
+}
+
+// LivenessHandler serves /healthz.
+func (c *Checker) LivenessHandler() http.Handler {
+	return c.handler(true)
+}
+
+// ReadinessHandler serves /readyz.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return c.handler(false)
+}
+
+func (c *Checker) handler(liveness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context(), liveness)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != StatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
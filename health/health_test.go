@@ -0,0 +1,146 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/teknologi-umum/captcha/health"
+)
+
+type fakePoller time.Time
+
+func (f fakePoller) LastPoll() time.Time { return time.Time(f) }
+
+type fakeQueue int
+
+func (f fakeQueue) Backlog() int { return int(f) }
+
+func TestChecker(t *testing.T) {
+	failing := func(ctx context.Context) (any, error) { return nil, errors.New("connection refused") }
+	passing := func(ctx context.Context) (any, error) { return nil, nil }
+
+	checker := &health.Checker{}
+	checker.Register(health.Check{Name: "local", Liveness: true, Func: passing})
+	checker.Register(health.Check{Name: "remote", Func: failing})
+
+	for _, tc := range []struct {
+		handler http.Handler
+		status  int
+	}{
+		// A remote dependency being down must not fail the liveness probe.
+		{checker.LivenessHandler(), http.StatusOK},
+		{checker.ReadinessHandler(), http.StatusServiceUnavailable},
+	} {
+		w := httptest.NewRecorder()
+		tc.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != tc.status {
+			t.Errorf("expecting status %d, got %d", tc.status, w.Code)
+		}
+
+		var report health.Report
+		err := json.NewDecoder(w.Body).Decode(&report)
+		if err != nil {
+			t.Fatalf("decoding report: %s", err.Error())
+		}
+
+		if report.Checks["remote"].Status != health.StatusFail || report.Checks["remote"].Error != "connection refused" {
+			t.Errorf("expecting the remote check to be reported as failing, got %+v", report.Checks["remote"])
+		}
+
+		if report.Checks["local"].Status != health.StatusOK {
+			t.Errorf("expecting the local check to be reported as ok, got %+v", report.Checks["local"])
+		}
+	}
+
+	checker.Register(health.Check{Name: "local", Liveness: true, Func: failing})
+	if report := checker.Run(context.Background(), true); report.Status != health.StatusFail {
+		t.Errorf("expecting a failing liveness check to fail the liveness probe, got %s", report.Status)
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	checker := &health.Checker{Timeout: time.Millisecond * 20}
+	checker.Register(health.Check{Name: "slow", Func: func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, nil
+	}})
+
+	report := checker.Run(context.Background(), false)
+	if report.Status != health.StatusFail {
+		t.Errorf("expecting a check that timed out to fail, got %s", report.Status)
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := health.Updates(fakePoller(time.Time{}), time.Minute)(ctx)
+	if err == nil {
+		t.Error("expecting an error before any update, got nil")
+	}
+
+	_, err = health.Updates(fakePoller(time.Now().Add(-time.Second)), time.Minute)(ctx)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	_, err = health.Updates(fakePoller(time.Now().Add(-time.Hour)), time.Minute)(ctx)
+	if err == nil {
+		t.Error("expecting an error for stale updates, got nil")
+	}
+
+	_, err = health.Updates(fakePoller(time.Now().Add(-time.Hour)), 0)(ctx)
+	if err != nil {
+		t.Errorf("expecting no error without a maximum age, got %s", err.Error())
+	}
+
+	_, err = health.Updates(fakePoller(time.Time{}), 0)(ctx)
+	if err == nil {
+		t.Error("expecting an error before any update even without a maximum age, got nil")
+	}
+}
+
+func TestLocalChecks(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("opening badger: %s", err.Error())
+	}
+	defer db.Close()
+
+	memory, err := bigcache.New(ctx, bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("creating bigcache: %s", err.Error())
+	}
+
+	for name, check := range map[string]health.CheckFunc{
+		"badger":    health.Badger(db),
+		"bigcache":  health.Cache(memory),
+		"scheduler": health.Scheduler(fakeQueue(3)),
+	} {
+		_, err := check(ctx)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err.Error())
+		}
+	}
+
+	details, _ := health.Scheduler(fakeQueue(3))(ctx)
+	if backlog := details.(map[string]int)["backlog"]; backlog != 3 {
+		t.Errorf("expecting a backlog of 3, got %d", backlog)
+	}
+
+	_ = db.Close()
+	_, err = health.Badger(db)(ctx)
+	if err == nil {
+		t.Error("expecting an error for a closed badger, got nil")
+	}
+}
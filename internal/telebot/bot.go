@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -53,6 +54,10 @@ func NewBot(pref Settings) (*Bot, error) {
 		client:      client,
 	}
 
+	if pref.Scheduler != nil {
+		bot.scheduler = newScheduler(*pref.Scheduler, bot.raw)
+	}
+
 	if pref.Offline {
 		bot.Me = &User{}
 	} else {
@@ -86,6 +91,11 @@ type Bot struct {
 
 	stopMu     sync.RWMutex
 	stopClient chan struct{}
+
+	scheduler *scheduler
+
+	// lastPoll is the unix nanoseconds of the last time the poller heard from Telegram.
+	lastPoll atomic.Int64
 }
 
 // Settings represents a utility struct for passing certain
@@ -123,6 +133,11 @@ type Settings struct {
 
 	// Offline allows to create a bot without network for testing purposes.
 	Offline bool
+
+	// Scheduler queues the outgoing requests to stay within the limits of
+	// Telegram, and retries the ones that hit them anyway. Nil sends every
+	// request right away.
+	Scheduler *SchedulerSettings
 }
 
 var defaultOnError = func(err error, c Context) {
@@ -143,6 +158,32 @@ func (b *Bot) debug(err error) {
 	}
 }
 
+// LastPoll returns the last time the poller heard from Telegram: a successful
+// getUpdates call, a registered webhook, or an update delivered to the webhook.
+// It is zero until then.
+func (b *Bot) LastPoll() time.Time {
+	nanos := b.lastPoll.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
+
+func (b *Bot) markPolled() {
+	b.lastPoll.Store(time.Now().UnixNano())
+}
+
+// Backlog returns how many requests are waiting in the send queue. It is always
+// zero without a scheduler.
+func (b *Bot) Backlog() int {
+	if b.scheduler == nil {
+		return 0
+	}
+
+	return b.scheduler.backlog()
+}
+
 // Group returns a new group.
 func (b *Bot) Group() *Group {
 	return &Group{b: b}
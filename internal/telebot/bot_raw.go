@@ -13,13 +13,29 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Raw lets you call any method of Bot API manually.
 // It also handles API errors, so you only need to unwrap
 // result field from json data.
+//
+// If the bot has a scheduler, the request waits for its turn
+// and is retried when it hits the limits of Telegram.
 func (b *Bot) Raw(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	if b.scheduler != nil {
+		return b.scheduler.do(ctx, method, payload)
+	}
+
+	return b.raw(ctx, method, payload)
+}
+
+func (b *Bot) raw(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	if files, ok := payload.(*filesPayload); ok {
+		return b.rawFiles(ctx, method, files)
+	}
+
 	url := b.URL + "/bot" + b.Token + "/" + method
 
 	var buf bytes.Buffer
@@ -73,6 +89,7 @@ func (b *Bot) Raw(ctx context.Context, method string, payload interface{}) ([]by
 
 func (b *Bot) sendFiles(ctx context.Context, method string, files map[string]File, params map[string]string) ([]byte, error) {
 	rawFiles := make(map[string]interface{})
+	offsets := make(map[string]int64)
 	for name, f := range files {
 		switch {
 		case f.InCloud():
@@ -83,6 +100,14 @@ func (b *Bot) sendFiles(ctx context.Context, method string, files map[string]Fil
 			rawFiles[name] = f.FileLocal
 		case f.FileReader != nil:
 			rawFiles[name] = f.FileReader
+			// Remember where the reader starts, so a retried upload
+			// can send it again from the same place.
+			if seeker, ok := f.FileReader.(io.Seeker); ok {
+				offset, err := seeker.Seek(0, io.SeekCurrent)
+				if err == nil {
+					offsets[name] = offset
+				}
+			}
 		default:
 			return nil, fmt.Errorf("telebot: file for field %s doesn't exist", name)
 		}
@@ -92,19 +117,75 @@ func (b *Bot) sendFiles(ctx context.Context, method string, files map[string]Fil
 		return b.Raw(ctx, method, params)
 	}
 
+	// The upload goes through Raw as well, so it waits for its turn
+	// on the scheduler like every other request.
+	return b.Raw(ctx, method, &filesPayload{files: files, rawFiles: rawFiles, offsets: offsets, params: params})
+}
+
+// filesPayload is the payload of a request that uploads files, which raw
+// sends as multipart/form-data instead of JSON.
+type filesPayload struct {
+	files    map[string]File
+	rawFiles map[string]interface{}
+	// offsets are the starting positions of the readers that can seek.
+	offsets map[string]int64
+	params  map[string]string
+
+	mu   sync.Mutex
+	sent bool
+}
+
+// rewind prepares the files to be sent again. It fails if a reader was
+// sent already and can't be read from the start again.
+func (p *filesPayload) rewind() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.sent {
+		p.sent = true
+		return nil
+	}
+
+	for field, file := range p.rawFiles {
+		reader, ok := file.(io.Reader)
+		if !ok {
+			continue
+		}
+
+		seeker, ok := reader.(io.Seeker)
+		offset, known := p.offsets[field]
+		if !ok || !known {
+			return fmt.Errorf("telebot: file for field %s can't be sent again", field)
+		}
+
+		_, err := seeker.Seek(offset, io.SeekStart)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Bot) rawFiles(ctx context.Context, method string, payload *filesPayload) ([]byte, error) {
+	err := payload.rewind()
+	if err != nil {
+		return nil, err
+	}
+
 	pipeReader, pipeWriter := io.Pipe()
 	writer := multipart.NewWriter(pipeWriter)
 
 	go func() {
 		defer pipeWriter.Close()
 
-		for field, file := range rawFiles {
-			if err := addFileToWriter(writer, files[field].fileName, field, file); err != nil {
+		for field, file := range payload.rawFiles {
+			if err := addFileToWriter(writer, payload.files[field].fileName, field, file); err != nil {
 				pipeWriter.CloseWithError(err)
 				return
 			}
 		}
-		for field, value := range params {
+		for field, value := range payload.params {
 			if err := writer.WriteField(field, value); err != nil {
 				pipeWriter.CloseWithError(err)
 				return
@@ -118,7 +199,15 @@ func (b *Bot) sendFiles(ctx context.Context, method string, files map[string]Fil
 
 	url := b.URL + "/bot" + b.Token + "/" + method
 
-	resp, err := b.client.Post(url, writer.FormDataContentType(), pipeReader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, pipeReader)
+	if err != nil {
+		err = wrapError(err)
+		pipeReader.CloseWithError(err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := b.client.Do(req)
 	if err != nil {
 		err = wrapError(err)
 		pipeReader.CloseWithError(err)
@@ -251,6 +340,8 @@ func (b *Bot) getUpdates(ctx context.Context, offset, limit int, timeout time.Du
 	if err := json.Unmarshal(data, &resp); err != nil {
 		return nil, wrapError(err)
 	}
+
+	b.markPolled()
 	return resp.Result, nil
 }
 
@@ -321,17 +412,16 @@ func extractOk(data []byte) error {
 
 	switch e.Code {
 	case http.StatusTooManyRequests:
-		retryAfter, ok := e.Parameters["retry_after"]
-		if !ok {
-			return NewError(e.Code, e.Description)
-		}
+		// Telegram does not always say how long to wait. Keep it a FloodError
+		// anyway, so callers can rely on RetryAfter.
+		retryAfter, _ := e.Parameters["retry_after"].(float64)
 
 		err = FloodError{
 			err:        NewError(e.Code, e.Description),
-			RetryAfter: int(retryAfter.(float64)),
+			RetryAfter: int(retryAfter),
 		}
 	default:
-		err = fmt.Errorf("telegram: %s (%d)", e.Description, e.Code)
+		err = NewError(e.Code, e.Description)
 	}
 
 	return err
@@ -3,7 +3,9 @@ package telebot
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 )
 
 type (
@@ -44,16 +46,38 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("telegram: %s (%d)", msg, err.Code)
 }
 
+// Is reports whether err belongs to the error class target, or whether
+// target is an Error with the same code whose description err starts with.
+// The latter lets a detailed description, such as "message is not modified:
+// specified new message content ...", match its shorter sentinel.
+func (err *Error) Is(target error) bool {
+	if t, ok := target.(*Error); ok {
+		return err.Code == t.Code && t.Description != "" && strings.HasPrefix(err.Description, t.Description)
+	}
+
+	return target != nil && target == classify(err.Code, err.Description)
+}
+
 // Error implements error interface.
 func (err FloodError) Error() string {
 	return err.err.Error()
 }
 
+// Unwrap returns the underlying Error.
+func (err FloodError) Unwrap() error {
+	return err.err
+}
+
 // Error implements error interface.
 func (err GroupError) Error() string {
 	return err.err.Error()
 }
 
+// Unwrap returns the underlying Error.
+func (err GroupError) Unwrap() error {
+	return err.err
+}
+
 // NewError returns new Error instance with given description.
 // First element of msgs is Description. The second is optional Message.
 func NewError(code int, msgs ...string) *Error {
@@ -123,6 +147,7 @@ var (
 	ErrHideRequesterMissing   = NewError(400, "Bad Request: HIDE_REQUESTER_MISSING")
 	ErrChannelsTooMuch        = NewError(400, "Bad Request: CHANNELS_TOO_MUCH")
 	ErrChannelsTooMuchUser    = NewError(400, "Bad Request: USER_CHANNELS_TOO_MUCH")
+	ErrInviteHashExpired      = NewError(400, "Bad Request: INVITE_HASH_EXPIRED")
 )
 
 // Forbidden errors
@@ -136,6 +161,11 @@ var (
 	ErrNotChannelMember     = NewError(403, "Forbidden: bot is not a member of the channel chat")
 )
 
+// Conflict errors
+var (
+	ErrConflict = NewError(409, "Conflict: terminated by other getUpdates request; make sure that only one bot instance is running")
+)
+
 // Err returns Error instance by given description.
 func Err(s string) error {
 	switch s {
@@ -251,8 +281,12 @@ func Err(s string) error {
 		return ErrChannelsTooMuch
 	case ErrChannelsTooMuchUser.ʔ():
 		return ErrChannelsTooMuchUser
+	case ErrInviteHashExpired.ʔ():
+		return ErrInviteHashExpired
 	case ErrNotChannelMember.ʔ():
 		return ErrNotChannelMember
+	case ErrConflict.ʔ():
+		return ErrConflict
 	default:
 		return nil
 	}
@@ -263,6 +297,81 @@ func ErrIs(s string, err error) bool {
 	return errors.Is(err, Err(s))
 }
 
+// Error classes. Every error returned by the Bot API belongs to at most one
+// of them, and can be matched with errors.Is, e.g.
+// errors.Is(err, ErrClassNotFound).
+var (
+	// ErrClassRetryable matches flood errors and server side failures, which
+	// are likely to succeed when the request is sent again later.
+	ErrClassRetryable = errors.New("telegram: retryable error")
+
+	// ErrClassNotFound matches errors about a message, chat or user that
+	// no longer exists, such as a message that was already deleted.
+	ErrClassNotFound = errors.New("telegram: not found")
+
+	// ErrClassForbidden matches errors about the bot lacking access, such as
+	// being kicked from the group, blocked by the user, or missing admin rights.
+	ErrClassForbidden = errors.New("telegram: forbidden")
+
+	// ErrClassConflict matches errors about another instance of the bot
+	// polling or setting the webhook at the same time.
+	ErrClassConflict = errors.New("telegram: conflict")
+)
+
+// classify maps an HTTP status code and a Bot API description to its error class.
+func classify(code int, description string) error {
+	description = strings.ToLower(description)
+
+	switch {
+	case code == http.StatusTooManyRequests, code >= http.StatusInternalServerError:
+		return ErrClassRetryable
+	case code == http.StatusConflict:
+		return ErrClassConflict
+	case code == http.StatusForbidden,
+		strings.Contains(description, "not enough rights"),
+		strings.Contains(description, "have no rights"),
+		strings.Contains(description, "chat_admin_required"):
+		return ErrClassForbidden
+	case code == http.StatusNotFound,
+		strings.Contains(description, "not found"),
+		strings.Contains(description, "invite_hash_expired"):
+		return ErrClassNotFound
+	default:
+		return nil
+	}
+}
+
+// IsRetryable reports whether the request that failed with err is worth sending again.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrClassRetryable)
+}
+
+// IsNotFound reports whether err is about a message, chat or user that no longer exists.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrClassNotFound)
+}
+
+// IsForbidden reports whether err is about the bot lacking access.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrClassForbidden)
+}
+
+// IsConflict reports whether err is caused by another instance of the bot.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrClassConflict)
+}
+
+// RetryAfter returns how long Telegram asks to wait before sending another
+// request, or zero if err is not a flood error.
+func RetryAfter(err error) time.Duration {
+	var floodError FloodError
+	if errors.As(err, &floodError) {
+		return time.Duration(floodError.RetryAfter) * time.Second
+	}
+
+	return 0
+}
+
 // wrapError returns new wrapped telebot-related error.
 func wrapError(err error) error {
 	return fmt.Errorf("telebot: %w", err)
@@ -0,0 +1,65 @@
+package telebot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClasses(t *testing.T) {
+	for _, tc := range []struct {
+		data  string
+		class error
+	}{
+		{`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 8","parameters":{"retry_after":8}}`, ErrClassRetryable},
+		{`{"ok":false,"error_code":429,"description":"Too Many Requests"}`, ErrClassRetryable},
+		{`{"ok":false,"error_code":504,"description":"Gateway Timeout"}`, ErrClassRetryable},
+		{`{"ok":false,"error_code":502,"description":"Bad Gateway"}`, ErrClassRetryable},
+		{`{"ok":false,"error_code":400,"description":"Bad Request: message to delete not found"}`, ErrClassNotFound},
+		{`{"ok":false,"error_code":400,"description":"Bad Request: user not found"}`, ErrClassNotFound},
+		{`{"ok":false,"error_code":400,"description":"Bad Request: INVITE_HASH_EXPIRED"}`, ErrClassNotFound},
+		{`{"ok":false,"error_code":403,"description":"Forbidden: bot was kicked from the supergroup chat"}`, ErrClassForbidden},
+		{`{"ok":false,"error_code":400,"description":"Bad Request: not enough rights to restrict/unrestrict chat member"}`, ErrClassForbidden},
+		{`{"ok":false,"error_code":409,"description":"Conflict: terminated by other getUpdates request; make sure that only one bot instance is running"}`, ErrClassConflict},
+		{`{"ok":false,"error_code":400,"description":"Bad Request: message text is empty"}`, nil},
+	} {
+		err := extractOk([]byte(tc.data))
+		if !assert.Error(t, err, tc.data) {
+			continue
+		}
+
+		// Classes survive wrapping by the callers.
+		wrapped := fmt.Errorf("sending message: %w", err)
+		for _, class := range []error{ErrClassRetryable, ErrClassNotFound, ErrClassForbidden, ErrClassConflict} {
+			assert.Equal(t, class == tc.class, errors.Is(wrapped, class), "%s is %s", tc.data, class)
+		}
+	}
+
+	assert.False(t, IsNotFound(nil))
+	assert.False(t, IsRetryable(errors.New("telegram: Gateway Timeout (504)")))
+}
+
+func TestErrorIs(t *testing.T) {
+	err := extractOk([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: message is not modified: specified new message content and reply markup are exactly the same as a current content and reply markup of the message"}`))
+	assert.ErrorIs(t, err, ErrMessageNotModified)
+	assert.ErrorIs(t, err, ErrSameMessageContent)
+	assert.NotErrorIs(t, err, ErrNotFoundToDelete)
+
+	err = extractOk([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: message to delete not found"}`))
+	assert.ErrorIs(t, fmt.Errorf("deleting: %w", err), ErrNotFoundToDelete)
+	assert.True(t, IsNotFound(err))
+}
+
+func TestRetryAfter(t *testing.T) {
+	err := extractOk([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 8","parameters":{"retry_after":8}}`))
+	assert.Equal(t, 8*time.Second, RetryAfter(fmt.Errorf("sending message: %w", err)))
+
+	err = extractOk([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests"}`))
+	assert.Equal(t, time.Duration(0), RetryAfter(err))
+	assert.True(t, IsRetryable(err))
+
+	assert.Equal(t, time.Duration(0), RetryAfter(ErrNotFoundToDelete))
+}
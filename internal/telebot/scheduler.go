@@ -0,0 +1,565 @@
+package telebot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchedulerSettings configures the queue of outgoing requests. Zero values
+// fall back to the limits documented by Telegram.
+type SchedulerSettings struct {
+	// GlobalPerSecond is how many rate limited requests are sent per second
+	// across every chat. Defaults to 30.
+	GlobalPerSecond int
+
+	// GroupPerMinute is how many messages are sent per minute to a single
+	// group or channel. Defaults to 20.
+	GroupPerMinute int
+
+	// PrivatePerSecond is how many messages are sent per second to a single
+	// private chat. Defaults to 1.
+	PrivatePerSecond int
+
+	// MaxRetries is how many times a request that failed on Telegram's side,
+	// or hit a flood error, is retried. Defaults to 5.
+	MaxRetries int
+
+	// RetryDelay is how long to wait before retrying a request that failed
+	// on Telegram's side. Defaults to 10 seconds.
+	RetryDelay time.Duration
+}
+
+// Priority decides which queued request is sent first, when the limits
+// do not allow sending all of them right away.
+type Priority int
+
+const (
+	// PriorityHigh is the default of moderation requests, such as bans,
+	// restrictions and deletions.
+	PriorityHigh Priority = iota
+	// PriorityNormal is the default of every other request.
+	PriorityNormal
+	// PriorityLow is for messages that can wait, such as welcome messages.
+	PriorityLow
+
+	priorityCount
+)
+
+type priorityKey struct{}
+
+// WithPriority sets the priority of the requests made with the context,
+// overriding the default of their method.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// groupBurst is how many messages can be sent to a group at once, before
+// falling back to GroupPerMinute.
+const groupBurst = 3
+
+// maxDeleteMessages is how many messages a single deleteMessages call accepts.
+const maxDeleteMessages = 100
+
+// moderationMethods are sent before everything else, since they stop the spam
+// while the other requests just talk.
+var moderationMethods = map[string]bool{
+	"banChatMember":          true,
+	"unbanChatMember":        true,
+	"restrictChatMember":     true,
+	"setChatPermissions":     true,
+	"declineChatJoinRequest": true,
+	"approveChatJoinRequest": true,
+	"deleteMessage":          true,
+	"deleteMessages":         true,
+	"banChatSenderChat":      true,
+}
+
+// rateLimited reports whether the method counts against the global limit, and
+// whether it counts against the limit of the chat as well.
+func rateLimited(method string) (global bool, perChat bool) {
+	switch {
+	case strings.HasPrefix(method, "send"), strings.HasPrefix(method, "forward"), strings.HasPrefix(method, "copy"):
+		return true, method != "sendChatAction"
+	case strings.HasPrefix(method, "edit"), moderationMethods[method]:
+		return true, false
+	default:
+		return false, false
+	}
+}
+
+type schedulerResult struct {
+	data []byte
+	err  error
+}
+
+type schedulerJob struct {
+	ctx      context.Context
+	method   string
+	payload  interface{}
+	chat     string
+	perChat  bool
+	priority Priority
+
+	notBefore time.Time
+	attempts  int
+
+	// messageIDs are kept for deleteMessages, so the calls on the same chat
+	// can be coalesced while they wait.
+	messageIDs []string
+	waiters    []chan schedulerResult
+}
+
+// scheduler queues the rate limited requests, sends them as fast as the limits
+// allow, highest priority first, and retries the ones that hit the limits anyway.
+type scheduler struct {
+	settings SchedulerSettings
+	raw      func(ctx context.Context, method string, payload interface{}) ([]byte, error)
+
+	mu           sync.Mutex
+	queues       [priorityCount][]*schedulerJob
+	global       *bucket
+	chats        map[string]*bucket
+	blockedUntil time.Time
+	lastCleanup  time.Time
+
+	wake chan struct{}
+}
+
+func newScheduler(settings SchedulerSettings, raw func(ctx context.Context, method string, payload interface{}) ([]byte, error)) *scheduler {
+	if settings.GlobalPerSecond <= 0 {
+		settings.GlobalPerSecond = 30
+	}
+	if settings.GroupPerMinute <= 0 {
+		settings.GroupPerMinute = 20
+	}
+	if settings.PrivatePerSecond <= 0 {
+		settings.PrivatePerSecond = 1
+	}
+	if settings.MaxRetries <= 0 {
+		settings.MaxRetries = 5
+	}
+	if settings.RetryDelay <= 0 {
+		settings.RetryDelay = 10 * time.Second
+	}
+
+	s := &scheduler{
+		settings: settings,
+		raw:      raw,
+		global:   newBucket(settings.GlobalPerSecond, time.Second/time.Duration(settings.GlobalPerSecond)),
+		chats:    make(map[string]*bucket),
+		wake:     make(chan struct{}, 1),
+	}
+
+	go s.dispatch()
+
+	return s
+}
+
+// do sends the request through the queue if the method is rate limited, and
+// retries it on flood errors and gateway timeouts.
+func (s *scheduler) do(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	if method == "getUpdates" {
+		return s.raw(ctx, method, payload)
+	}
+
+	global, perChat := rateLimited(method)
+	if !global {
+		return s.retry(ctx, method, payload)
+	}
+
+	priority := PriorityNormal
+	if moderationMethods[method] {
+		priority = PriorityHigh
+	}
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= PriorityHigh && p < priorityCount {
+		priority = p
+	}
+
+	job := &schedulerJob{
+		ctx:      ctx,
+		method:   method,
+		payload:  payload,
+		chat:     chatOf(payload),
+		perChat:  perChat,
+		priority: priority,
+		waiters:  []chan schedulerResult{make(chan schedulerResult, 1)},
+	}
+	done := job.waiters[0]
+
+	if method == "deleteMessages" {
+		job.messageIDs = messageIDsOf(payload)
+	}
+
+	s.enqueue(job)
+
+	select {
+	case result := <-done:
+		return result.data, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// retry sends a request that is not rate limited right away, retrying it
+// like the queued ones.
+func (s *scheduler) retry(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	attempts := 0
+	for {
+		data, err := s.raw(ctx, method, payload)
+		wait, ok := s.retryAfter(err, &attempts)
+		if !ok {
+			return data, err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryAfter reports how long to wait before retrying a failed request, or false
+// if it must not be retried.
+func (s *scheduler) retryAfter(err error, attempts *int) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	// Flood errors count against MaxRetries as well, since a request that
+	// keeps hitting them would otherwise be retried forever.
+	if *attempts >= s.settings.MaxRetries {
+		return 0, false
+	}
+
+	var floodError FloodError
+	if errors.As(err, &floodError) {
+		*attempts++
+		if floodError.RetryAfter <= 0 {
+			return 15 * time.Second, true
+		}
+
+		return RetryAfter(err), true
+	}
+
+	if IsRetryable(err) {
+		*attempts++
+		return s.settings.RetryDelay, true
+	}
+
+	return 0, false
+}
+
+func (s *scheduler) enqueue(job *schedulerJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+
+	// Join a deleteMessages call of the same chat that is still waiting, instead
+	// of spending another request on it.
+	if job.method == "deleteMessages" && job.chat != "" && job.messageIDs != nil {
+		for _, queued := range s.queues[job.priority] {
+			if queued.method != job.method || queued.chat != job.chat || queued.messageIDs == nil ||
+				len(queued.messageIDs)+len(job.messageIDs) > maxDeleteMessages {
+				continue
+			}
+
+			// The merged call no longer belongs to a single caller,
+			// so it must not be cancelled by any of them.
+			if len(queued.waiters) == 1 {
+				queued.ctx = context.WithoutCancel(queued.ctx)
+			}
+
+			queued.messageIDs = append(queued.messageIDs, job.messageIDs...)
+			queued.waiters = append(queued.waiters, job.waiters...)
+			queued.payload = deleteMessagesPayload(queued.chat, queued.messageIDs)
+			return
+		}
+	}
+
+	s.queues[job.priority] = append(s.queues[job.priority], job)
+}
+
+// backlog counts the jobs that are waiting for their turn.
+func (s *scheduler) backlog() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, queue := range s.queues {
+		total += len(queue)
+	}
+	return total
+}
+
+// requeue puts a job that hit the limits back in front of its queue.
+func (s *scheduler) requeue(job *schedulerJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+
+	s.queues[job.priority] = append([]*schedulerJob{job}, s.queues[job.priority]...)
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) dispatch() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		job, wait := s.next(time.Now())
+		s.mu.Unlock()
+
+		if job != nil {
+			go s.send(job)
+			continue
+		}
+
+		if wait <= 0 {
+			wait = time.Hour
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// next takes the first job that the limits allow to be sent now. Otherwise, it
+// returns how long until one might be, or zero if there is nothing to wait for.
+// The caller must hold the lock.
+func (s *scheduler) next(now time.Time) (*schedulerJob, time.Duration) {
+	s.cleanup(now)
+
+	if now.Before(s.blockedUntil) {
+		return nil, s.blockedUntil.Sub(now)
+	}
+
+	var wait time.Duration
+	waitFor := func(d time.Duration) {
+		if d > 0 && (wait == 0 || d < wait) {
+			wait = d
+		}
+	}
+
+	for priority := range s.queues {
+		queue := s.queues[priority]
+		for i := 0; i < len(queue); i++ {
+			job := queue[i]
+
+			if err := job.ctx.Err(); err != nil {
+				queue = append(queue[:i], queue[i+1:]...)
+				s.queues[priority] = queue
+				i--
+				job.finish(nil, err)
+				continue
+			}
+
+			if now.Before(job.notBefore) {
+				waitFor(job.notBefore.Sub(now))
+				continue
+			}
+
+			var chat *bucket
+			if job.chat != "" {
+				chat = s.chatBucket(job.chat)
+				if now.Before(chat.blockedUntil) {
+					waitFor(chat.blockedUntil.Sub(now))
+					continue
+				}
+
+				if job.perChat {
+					if d := chat.wait(now); d > 0 {
+						waitFor(d)
+						continue
+					}
+				}
+			}
+
+			// Nothing else can be sent either.
+			if d := s.global.wait(now); d > 0 {
+				waitFor(d)
+				return nil, wait
+			}
+
+			s.global.take(now)
+			if job.perChat && chat != nil {
+				chat.take(now)
+			}
+
+			s.queues[priority] = append(queue[:i], queue[i+1:]...)
+			return job, 0
+		}
+	}
+
+	return nil, wait
+}
+
+func (s *scheduler) send(job *schedulerJob) {
+	data, err := s.raw(job.ctx, job.method, job.payload)
+
+	wait, ok := s.retryAfter(err, &job.attempts)
+	if !ok {
+		job.finish(data, err)
+		return
+	}
+
+	var floodError FloodError
+	if errors.As(err, &floodError) {
+		s.mu.Lock()
+		until := time.Now().Add(wait)
+		if job.chat != "" {
+			s.chatBucket(job.chat).blockedUntil = until
+		} else {
+			s.blockedUntil = until
+		}
+		s.mu.Unlock()
+	}
+
+	job.notBefore = time.Now().Add(wait)
+	s.requeue(job)
+}
+
+func (job *schedulerJob) finish(data []byte, err error) {
+	for _, waiter := range job.waiters {
+		waiter <- schedulerResult{data: data, err: err}
+	}
+}
+
+// chatBucket returns the bucket of the chat, creating it if needed.
+// The caller must hold the lock.
+func (s *scheduler) chatBucket(chat string) *bucket {
+	b, ok := s.chats[chat]
+	if ok {
+		return b
+	}
+
+	// Groups get a small burst, so a few users joining at once don't wait
+	// for each other's captcha.
+	if strings.HasPrefix(chat, "-") || strings.HasPrefix(chat, "@") {
+		b = newBucket(groupBurst, time.Minute/time.Duration(s.settings.GroupPerMinute))
+	} else {
+		b = newBucket(1, time.Second/time.Duration(s.settings.PrivatePerSecond))
+	}
+
+	s.chats[chat] = b
+	return b
+}
+
+// cleanup forgets the buckets that are full again, so the chats that have been
+// quiet for a while take no memory. The caller must hold the lock.
+func (s *scheduler) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < time.Minute {
+		return
+	}
+	s.lastCleanup = now
+
+	for chat, b := range s.chats {
+		b.refill(now)
+		if b.tokens >= float64(b.size) && !now.Before(b.blockedUntil) {
+			delete(s.chats, chat)
+		}
+	}
+}
+
+// bucket is a token bucket that holds up to size tokens, and gets a new one
+// every interval.
+type bucket struct {
+	size         int
+	every        time.Duration
+	tokens       float64
+	updatedAt    time.Time
+	blockedUntil time.Time
+}
+
+func newBucket(size int, every time.Duration) *bucket {
+	return &bucket{size: size, every: every, tokens: float64(size), updatedAt: time.Now()}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.updatedAt) {
+		b.tokens = min(float64(b.size), b.tokens+float64(now.Sub(b.updatedAt))/float64(b.every))
+		b.updatedAt = now
+	}
+}
+
+// wait returns how long until a token is available.
+func (b *bucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) * float64(b.every))
+}
+
+func (b *bucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+// chatOf returns the chat_id of the payload, or an empty string if there is none.
+func chatOf(payload interface{}) string {
+	switch p := payload.(type) {
+	case map[string]string:
+		return p["chat_id"]
+	case *filesPayload:
+		return p.params["chat_id"]
+	case map[string]interface{}:
+		switch chat := p["chat_id"].(type) {
+		case string:
+			return chat
+		case int64:
+			return strconv.FormatInt(chat, 10)
+		case int:
+			return strconv.Itoa(chat)
+		}
+	}
+
+	return ""
+}
+
+// messageIDsOf returns the message_ids of a deleteMessages payload, or nil if it
+// can't be read, in which case the call is not coalesced.
+func messageIDsOf(payload interface{}) []string {
+	params, ok := payload.(map[string]string)
+	if !ok {
+		return nil
+	}
+
+	var ids []string
+	if json.Unmarshal([]byte(params["message_ids"]), &ids) != nil || len(ids) == 0 {
+		return nil
+	}
+
+	return ids
+}
+
+func deleteMessagesPayload(chat string, ids []string) map[string]string {
+	data, _ := json.Marshal(ids)
+	return map[string]string{
+		"chat_id":     chat,
+		"message_ids": string(data),
+	}
+}
@@ -0,0 +1,268 @@
+package telebot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedCall struct {
+	method  string
+	payload interface{}
+}
+
+type fakeRaw struct {
+	mu    sync.Mutex
+	calls []recordedCall
+	fail  func(method string, attempt int) error
+}
+
+func (f *fakeRaw) raw(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, recordedCall{method: method, payload: payload})
+	attempt := 0
+	for _, call := range f.calls {
+		if call.method == method {
+			attempt++
+		}
+	}
+	f.mu.Unlock()
+
+	if f.fail != nil {
+		if err := f.fail(method, attempt); err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(`{"ok":true,"result":true}`), nil
+}
+
+func (f *fakeRaw) methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	methods := make([]string, 0, len(f.calls))
+	for _, call := range f.calls {
+		methods = append(methods, call.method)
+	}
+	return methods
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	fake := &fakeRaw{}
+	s := newScheduler(SchedulerSettings{GlobalPerSecond: 10}, fake.raw)
+
+	ctx := context.Background()
+
+	// Drain the global bucket, so the next calls have to wait in the queue.
+	for i := 0; i < 10; i++ {
+		_, err := s.do(ctx, "sendMessage", map[string]string{"chat_id": strconv.Itoa(i + 1)})
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, call := range []struct {
+		ctx    context.Context
+		method string
+	}{
+		{WithPriority(ctx, PriorityLow), "sendMessage"},
+		{ctx, "sendMessage"},
+		{ctx, "banChatMember"},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.do(call.ctx, call.method, map[string]string{"chat_id": "-100"})
+			assert.NoError(t, err)
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	methods := fake.methods()
+	require.Len(t, methods, 13)
+	assert.Equal(t, "banChatMember", methods[10])
+	assert.Equal(t, "sendMessage", methods[11])
+}
+
+func TestSchedulerFloodError(t *testing.T) {
+	fake := &fakeRaw{fail: func(method string, attempt int) error {
+		if attempt == 1 {
+			return FloodError{err: NewError(429, "Too Many Requests: retry after 1"), RetryAfter: 1}
+		}
+		return nil
+	}}
+	s := newScheduler(SchedulerSettings{}, fake.raw)
+
+	start := time.Now()
+	_, err := s.do(context.Background(), "sendMessage", map[string]string{"chat_id": "-100"})
+	require.NoError(t, err)
+
+	assert.Len(t, fake.methods(), 2)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestSchedulerFloodErrorLimit(t *testing.T) {
+	fake := &fakeRaw{fail: func(method string, attempt int) error {
+		return FloodError{err: NewError(429, "Too Many Requests: retry after 1"), RetryAfter: 1}
+	}}
+	s := newScheduler(SchedulerSettings{MaxRetries: 1}, fake.raw)
+
+	_, err := s.do(context.Background(), "sendMessage", map[string]string{"chat_id": "-100"})
+	assert.ErrorAs(t, err, &FloodError{})
+	assert.Len(t, fake.methods(), 2)
+}
+
+func TestSchedulerFiles(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		attempt := len(bodies)
+		mu.Unlock()
+
+		if attempt == 1 {
+			_, _ = io.WriteString(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":-100,"type":"supergroup"},"photo":[{"file_id":"photo"}]}}`)
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(Settings{URL: server.URL, Token: "token", Offline: true, Scheduler: &SchedulerSettings{}})
+	require.NoError(t, err)
+
+	// The upload waits behind the flood error like any other request,
+	// then sends the same file again.
+	_, err = bot.Send(context.Background(), &Chat{ID: -100}, &Photo{File: FromReader(bytes.NewReader([]byte("captcha image")))})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, bodies, 2)
+	assert.Contains(t, bodies[0], "captcha image")
+	assert.Contains(t, bodies[1], "captcha image")
+}
+
+func TestSchedulerGatewayTimeout(t *testing.T) {
+	gatewayTimeout := NewError(504, "Gateway Timeout")
+	fake := &fakeRaw{fail: func(method string, attempt int) error {
+		return gatewayTimeout
+	}}
+	s := newScheduler(SchedulerSettings{MaxRetries: 2, RetryDelay: 10 * time.Millisecond}, fake.raw)
+
+	_, err := s.do(context.Background(), "banChatMember", map[string]string{"chat_id": "-100"})
+	assert.ErrorIs(t, err, gatewayTimeout)
+	assert.Len(t, fake.methods(), 3)
+
+	// Requests that are not rate limited are retried as well.
+	_, err = s.do(context.Background(), "getChat", map[string]string{"chat_id": "-100"})
+	assert.ErrorIs(t, err, gatewayTimeout)
+	assert.Len(t, fake.methods(), 6)
+}
+
+func TestSchedulerCoalesceDeleteMany(t *testing.T) {
+	fake := &fakeRaw{}
+	s := newScheduler(SchedulerSettings{GlobalPerSecond: 5}, fake.raw)
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		_, err := s.do(ctx, "sendMessage", map[string]string{"chat_id": strconv.Itoa(i + 1)})
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, ids := range []string{`["1","2"]`, `["3"]`, `["4","5"]`} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.do(ctx, "deleteMessages", map[string]string{"chat_id": "-100", "message_ids": ids})
+			assert.NoError(t, err)
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	require.Len(t, fake.calls, 6)
+	assert.Equal(t, "deleteMessages", fake.calls[5].method)
+	assert.Equal(t, map[string]string{"chat_id": "-100", "message_ids": `["1","2","3","4","5"]`}, fake.calls[5].payload)
+}
+
+func TestSchedulerPerChat(t *testing.T) {
+	fake := &fakeRaw{}
+	s := newScheduler(SchedulerSettings{GroupPerMinute: 600}, fake.raw)
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < groupBurst; i++ {
+		_, err := s.do(ctx, "sendMessage", map[string]string{"chat_id": "-100"})
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// Other chats are not held back by a busy one.
+	_, err := s.do(ctx, "sendMessage", map[string]string{"chat_id": "-200"})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	_, err = s.do(ctx, "sendMessage", map[string]string{"chat_id": "-100"})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	fake := &fakeRaw{}
+	s := newScheduler(SchedulerSettings{GroupPerMinute: 1}, fake.raw)
+
+	for i := 0; i < groupBurst; i++ {
+		_, err := s.do(context.Background(), "sendMessage", map[string]string{"chat_id": "-100"})
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := s.do(ctx, "sendMessage", map[string]string{"chat_id": "-100"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, fake.methods(), groupBurst)
+}
+
+func TestSchedulerBacklog(t *testing.T) {
+	fake := &fakeRaw{}
+	s := newScheduler(SchedulerSettings{GroupPerMinute: 600}, fake.raw)
+
+	ctx := context.Background()
+	for i := 0; i < groupBurst; i++ {
+		_, err := s.do(ctx, "sendMessage", map[string]string{"chat_id": "-100"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, s.backlog())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.do(ctx, "sendMessage", map[string]string{"chat_id": "-100"})
+			assert.NoError(t, err)
+		}()
+	}
+
+	assert.Eventually(t, func() bool { return s.backlog() > 0 }, time.Second, time.Millisecond*5)
+	wg.Wait()
+	assert.Equal(t, 0, s.backlog())
+}
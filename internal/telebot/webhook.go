@@ -2,10 +2,13 @@ package telebot
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 )
 
 // A WebhookTLS specifies the path to a key and a cert so the poller can open
@@ -58,6 +61,9 @@ type Webhook struct {
 	TLS      *WebhookTLS
 	Endpoint *WebhookEndpoint
 
+	// mu guards dest and bot, since the handler may already be mounted on
+	// a running HTTP server before the poller starts.
+	mu   sync.RWMutex
 	dest chan<- Update
 	bot  *Bot
 }
@@ -122,16 +128,19 @@ func (h *Webhook) getParams() map[string]string {
 func (h *Webhook) Poll(ctx context.Context, b *Bot, dest chan Update, stop chan struct{}) {
 	// by default, the set webhook method will be called, to ignore it, set IgnoreSetWebhook to true
 	if !h.IgnoreSetWebhook {
-		if err := b.SetWebhook(ctx, h); err != nil {
-			b.OnError(err, nil)
-			close(stop)
+		if !h.register(ctx, b, stop) {
+			h.waitForStop(stop)
 			return
 		}
+
+		b.markPolled()
 	}
 
 	// store the variables so the HTTP-handler can use 'em
+	h.mu.Lock()
 	h.dest = dest
 	h.bot = b
+	h.mu.Unlock()
 
 	if h.Listen == "" {
 		h.waitForStop(stop)
@@ -155,25 +164,82 @@ func (h *Webhook) Poll(ctx context.Context, b *Bot, dest chan Update, stop chan
 	}
 }
 
+// register calls setWebhook until it succeeds, waiting longer after every
+// failure, up to a minute. It returns false if the poller is stopped first.
+func (h *Webhook) register(ctx context.Context, b *Bot, stop chan struct{}) bool {
+	backoff := time.Second
+	for {
+		err := b.SetWebhook(ctx, h)
+		if err == nil {
+			return true
+		}
+
+		b.OnError(fmt.Errorf("setting webhook, retrying in %s: %w", backoff, err), nil)
+
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
 func (h *Webhook) waitForStop(stop chan struct{}) {
+	// The stop channel is closed by the bot, closing it again would panic.
 	<-stop
-	close(stop)
+
+	h.mu.Lock()
+	h.dest = nil
+	h.mu.Unlock()
 }
 
 // The handler simply reads the update from the body of the requests
 // and writes them to the update channel.
+//
+// Requests without the secret token are rejected. While the poller is not
+// running, requests are answered with 503, so Telegram delivers the update
+// again later instead of dropping it.
 func (h *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if h.SecretToken != "" && r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != h.SecretToken {
-		h.bot.debug(fmt.Errorf("invalid secret token in request"))
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.RLock()
+	dest, bot := h.dest, h.bot
+	h.mu.RUnlock()
+
+	if h.SecretToken != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Telegram-Bot-Api-Secret-Token")), []byte(h.SecretToken)) != 1 {
+		if bot != nil {
+			bot.debug(fmt.Errorf("invalid secret token in request"))
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if dest == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
 		return
 	}
 
 	var update Update
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		h.bot.debug(fmt.Errorf("cannot decode update: %v", err))
+		bot.debug(fmt.Errorf("cannot decode update: %v", err))
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	h.dest <- update
+
+	bot.markPolled()
+
+	select {
+	case dest <- update:
+	case <-r.Context().Done():
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 }
 
 // Webhook returns the current webhook status.
@@ -0,0 +1,97 @@
+package telebot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	b, err := NewBot(Settings{Offline: true, Synchronous: true})
+	require.NoError(t, err)
+
+	handled := make(chan string, 1)
+	b.Handle(OnText, func(c Context) error {
+		handled <- c.Text()
+		return nil
+	})
+
+	webhook := &Webhook{SecretToken: "secret", IgnoreSetWebhook: true}
+	b.Poller = webhook
+
+	post := func(token string, body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("X-Telegram-Bot-Api-Secret-Token", token)
+		}
+
+		w := httptest.NewRecorder()
+		webhook.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	update := `{"update_id":1,"message":{"message_id":1,"text":"hello","chat":{"id":1,"type":"private"}}}`
+
+	// The bot has not started yet, Telegram should try again later.
+	assert.Equal(t, http.StatusServiceUnavailable, post("secret", update))
+
+	go b.Start()
+	require.Eventually(t, func() bool {
+		webhook.mu.RLock()
+		defer webhook.mu.RUnlock()
+		return webhook.dest != nil
+	}, time.Second, time.Millisecond*10)
+
+	assert.Equal(t, http.StatusUnauthorized, post("", update))
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", update))
+	assert.Equal(t, http.StatusBadRequest, post("secret", "{"))
+	assert.True(t, b.LastPoll().IsZero())
+
+	assert.Equal(t, http.StatusOK, post("secret", update))
+	assert.WithinDuration(t, time.Now(), b.LastPoll(), time.Second)
+
+	select {
+	case text := <-handled:
+		assert.Equal(t, "hello", text)
+	case <-time.After(time.Second):
+		t.Fatal("update was not handled")
+	}
+
+	// Stopping the bot must not panic, and the handler stops accepting updates.
+	b.Stop()
+	assert.Equal(t, http.StatusServiceUnavailable, post("secret", update))
+}
+
+func TestWebhookRegister(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails, the bot should keep trying.
+		if calls.Add(1) == 1 {
+			_, _ = io.WriteString(w, `{"ok":false,"error_code":400,"description":"Bad Request: bad webhook"}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"ok":true,"result":true}`)
+	}))
+	defer server.Close()
+
+	b, err := NewBot(Settings{URL: server.URL, Token: "token", Offline: true, Synchronous: true, OnError: func(error, Context) {}})
+	require.NoError(t, err)
+
+	b.Poller = &Webhook{Endpoint: &WebhookEndpoint{PublicURL: "https://example.com/webhook"}}
+
+	go b.Start()
+	defer b.Stop()
+
+	require.Eventually(t, func() bool {
+		return !b.LastPoll().IsZero()
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,160 @@
+package joinpolicy
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// Script is a group of writing systems that is detected on a display name.
+type Script string
+
+const (
+	ScriptLatin    Script = "latin"
+	ScriptCyrillic Script = "cyrillic"
+	ScriptCJK      Script = "cjk"
+	// ScriptRTL covers right-to-left scripts, like Arabic and Hebrew.
+	ScriptRTL Script = "rtl"
+)
+
+// Valid checks whether the script is one of the known scripts.
+func (s Script) Valid() bool {
+	switch s {
+	case ScriptLatin, ScriptCyrillic, ScriptCJK, ScriptRTL:
+		return true
+	default:
+		return false
+	}
+}
+
+// Attributes are the properties of a user that are evaluated by a policy.
+type Attributes struct {
+	HasUsername    bool
+	NameLength     int
+	Scripts        []Script
+	NameHasLink    bool
+	NameHasMention bool
+	IsPremium      bool
+	LanguageCode   string
+	AccountAge     time.Duration
+}
+
+func (a Attributes) hasScript(script Script) bool {
+	for _, s := range a.Scripts {
+		if s == script {
+			return true
+		}
+	}
+
+	return false
+}
+
+var linkRegex = regexp.MustCompile(`(?i)(https?://|www\.|t\.me/|telegram\.(me|dog)/|\b[a-z0-9-]+\.(com|net|org|io|me|xyz|info|biz|ru|cc|co|top|site|online|link|app|gg|id)\b)`)
+
+// ExtractAttributes collects the attributes of a user that are used on
+// evaluating a policy.
+func ExtractAttributes(user *tb.User, now time.Time) Attributes {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+
+	return Attributes{
+		HasUsername:    user.Username != "",
+		NameLength:     utf8.RuneCountInString(name),
+		Scripts:        DetectScripts(name),
+		NameHasLink:    linkRegex.MatchString(name),
+		NameHasMention: strings.Contains(name, "@"),
+		IsPremium:      user.IsPremium,
+		LanguageCode:   user.LanguageCode,
+		AccountAge:     now.Sub(EstimateAccountCreation(user.ID, now)),
+	}
+}
+
+// DetectScripts returns the known scripts found on the given text,
+// in the order of their first appearance.
+func DetectScripts(text string) []Script {
+	var scripts []Script
+	var seen = make(map[Script]bool)
+	for _, r := range text {
+		var script Script
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			script = ScriptLatin
+		case unicode.Is(unicode.Cyrillic, r):
+			script = ScriptCyrillic
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			script = ScriptCJK
+		case unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko):
+			script = ScriptRTL
+		default:
+			continue
+		}
+
+		if !seen[script] {
+			seen[script] = true
+			scripts = append(scripts, script)
+		}
+	}
+
+	return scripts
+}
+
+// accountCreationPoints maps known Telegram user IDs to the approximate time
+// the account was created. User IDs are handed out incrementally, so the
+// creation time of any other ID can be interpolated between two points.
+var accountCreationPoints = []struct {
+	id        int64
+	createdAt time.Time
+}{
+	{id: 1_000_000, createdAt: time.Date(2013, time.August, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 100_000_000, createdAt: time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 200_000_000, createdAt: time.Date(2016, time.May, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 300_000_000, createdAt: time.Date(2016, time.December, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 400_000_000, createdAt: time.Date(2017, time.July, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 500_000_000, createdAt: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 805_000_000, createdAt: time.Date(2019, time.July, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 1_000_000_000, createdAt: time.Date(2019, time.October, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 1_970_000_000, createdAt: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 5_000_000_000, createdAt: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 6_000_000_000, createdAt: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 7_000_000_000, createdAt: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 7_500_000_000, createdAt: time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)},
+	{id: 8_000_000_000, createdAt: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+// EstimateAccountCreation estimates when a Telegram account was created
+// from its user ID. This is a rough estimation, it can be off by months,
+// but it's good enough to tell a fresh account from an old one. The
+// estimation is never later than now.
+func EstimateAccountCreation(userID int64, now time.Time) time.Time {
+	first := accountCreationPoints[0]
+	if userID <= first.id {
+		return first.createdAt
+	}
+
+	for i := 1; i < len(accountCreationPoints); i++ {
+		lower := accountCreationPoints[i-1]
+		upper := accountCreationPoints[i]
+		if userID <= upper.id {
+			return interpolate(lower.id, lower.createdAt, upper.id, upper.createdAt, userID)
+		}
+	}
+
+	// Newer than our last known point, extrapolate from the last segment,
+	// but never into the future.
+	lower := accountCreationPoints[len(accountCreationPoints)-2]
+	upper := accountCreationPoints[len(accountCreationPoints)-1]
+	estimated := interpolate(lower.id, lower.createdAt, upper.id, upper.createdAt, userID)
+	if estimated.After(now) {
+		return now
+	}
+
+	return estimated
+}
+
+func interpolate(lowerID int64, lowerTime time.Time, upperID int64, upperTime time.Time, id int64) time.Time {
+	ratio := float64(id-lowerID) / float64(upperID-lowerID)
+	return lowerTime.Add(time.Duration(ratio * float64(upperTime.Sub(lowerTime))))
+}
@@ -0,0 +1,75 @@
+package joinpolicy_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/joinpolicy"
+)
+
+func TestDetectScripts(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect []joinpolicy.Script
+	}{
+		{input: "John Doe", expect: []joinpolicy.Script{joinpolicy.ScriptLatin}},
+		{input: "Иван", expect: []joinpolicy.Script{joinpolicy.ScriptCyrillic}},
+		{input: "田中 Tanaka", expect: []joinpolicy.Script{joinpolicy.ScriptCJK, joinpolicy.ScriptLatin}},
+		{input: "محمد", expect: []joinpolicy.Script{joinpolicy.ScriptRTL}},
+		{input: "💰 123", expect: nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			got := joinpolicy.DetectScripts(testCase.input)
+			if !slices.Equal(got, testCase.expect) {
+				t.Errorf("expecting %v, got %v", testCase.expect, got)
+			}
+		})
+	}
+}
+
+func TestEstimateAccountCreation(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	older := joinpolicy.EstimateAccountCreation(150_000_000, now)
+	newer := joinpolicy.EstimateAccountCreation(6_500_000_000, now)
+	if !older.Before(newer) {
+		t.Errorf("expecting %v to be before %v", older, newer)
+	}
+
+	if older.Year() < 2015 || older.Year() > 2016 {
+		t.Errorf("expecting the estimation to be around 2015-2016, got %v", older)
+	}
+
+	if got := joinpolicy.EstimateAccountCreation(99_000_000_000, now); !got.Equal(now) {
+		t.Errorf("expecting the estimation to be clamped to %v, got %v", now, got)
+	}
+}
+
+func TestExtractAttributes(t *testing.T) {
+	attributes := joinpolicy.ExtractAttributes(&tb.User{
+		ID:           123_456_789,
+		FirstName:    "Admin",
+		LastName:     "@support",
+		Username:     "",
+		LanguageCode: "en",
+	}, time.Now())
+
+	if attributes.HasUsername {
+		t.Error("expecting HasUsername to be false")
+	}
+
+	if !attributes.NameHasMention {
+		t.Error("expecting NameHasMention to be true")
+	}
+
+	if attributes.NameHasLink {
+		t.Error("expecting NameHasLink to be false")
+	}
+
+	if attributes.NameLength != 14 {
+		t.Errorf("expecting NameLength to be 14, got %d", attributes.NameLength)
+	}
+}
@@ -0,0 +1,83 @@
+package joinpolicy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// Dependency contains the dependency injection struct
+// for methods in the joinpolicy package.
+type Dependency struct {
+	Configuration *Configuration
+	Bot           *tb.Bot
+}
+
+// New creates a new join policy dependency.
+func New(configuration *Configuration, bot *tb.Bot) (*Dependency, error) {
+	if configuration == nil {
+		return nil, fmt.Errorf("configuration is nil")
+	}
+
+	if bot == nil {
+		return nil, fmt.Errorf("bot is nil")
+	}
+
+	return &Dependency{Configuration: configuration, Bot: bot}, nil
+}
+
+// Evaluate picks the action for a newly joined user of a group and logs
+// the matched rule.
+func (d *Dependency) Evaluate(ctx context.Context, groupID int64, user *tb.User) Decision {
+	decision := d.Configuration.Evaluate(groupID, user, time.Now())
+
+	slog.InfoContext(
+		ctx,
+		"Join policy evaluated",
+		slog.String("rule", decision.Rule),
+		slog.String("action", string(decision.Action)),
+		slog.Int64("group_id", groupID),
+		slog.Int64("user_id", user.ID),
+		slog.String("user_name", user.Username),
+	)
+
+	return decision
+}
+
+// Enforce executes the actions that don't involve a captcha challenge,
+// which are ActionRestrict and ActionBan. Other actions are ignored.
+func (d *Dependency) Enforce(ctx context.Context, chat *tb.Chat, user *tb.User, decision Decision) error {
+	if decision.Action != ActionRestrict && decision.Action != ActionBan {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "joinpolicy.enforce")
+	ctx = span.Context()
+	defer span.Finish()
+
+	admins, err := d.Bot.AdminsOf(ctx, chat)
+	if err != nil {
+		return fmt.Errorf("getting group admins: %w", err)
+	}
+
+	if utils.IsAdmin(admins, user) {
+		return nil
+	}
+
+	if decision.Action == ActionBan {
+		err = d.Bot.Ban(ctx, chat, &tb.ChatMember{User: user, RestrictedUntil: tb.Forever()}, true)
+	} else {
+		err = d.Bot.Restrict(ctx, chat, &tb.ChatMember{User: user, Rights: tb.NoRights(), RestrictedUntil: tb.Forever()})
+	}
+	if err != nil {
+		return fmt.Errorf("enforcing %s: %w", decision.Action, err)
+	}
+
+	slog.DebugContext(ctx, "Join policy enforced", slog.String("rule", decision.Rule), slog.String("action", string(decision.Action)), slog.Int64("group_id", chat.ID), slog.Int64("user_id", user.ID))
+	return nil
+}
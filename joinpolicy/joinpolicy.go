@@ -0,0 +1,287 @@
+package joinpolicy
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is the thing that should be done to a newly joined member
+// after the join policy is evaluated.
+type Action string
+
+const (
+	// ActionSkip lets the user through without any captcha.
+	ActionSkip Action = "skip"
+	// ActionCaptcha presents the standard captcha challenge.
+	ActionCaptcha Action = "captcha"
+	// ActionHardCaptcha presents a longer captcha challenge.
+	ActionHardCaptcha Action = "hard_captcha"
+	// ActionRestrict does not present any captcha, but the user
+	// is restricted from sending anything to the group.
+	ActionRestrict Action = "restrict"
+	// ActionBan bans the user right away.
+	ActionBan Action = "ban"
+)
+
+// Valid checks whether the action is one of the known actions.
+func (a Action) Valid() bool {
+	switch a {
+	case ActionSkip, ActionCaptcha, ActionHardCaptcha, ActionRestrict, ActionBan:
+		return true
+	default:
+		return false
+	}
+}
+
+// Condition describes the attributes of a user that a rule is looking for.
+// Every field is optional, an empty field is not evaluated.
+type Condition struct {
+	// HasUsername matches on whether the user has a Telegram username.
+	HasUsername *bool `yaml:"has_username"`
+	// NameLengthMin and NameLengthMax matches on the amount of characters
+	// of the user's display name (first name and last name).
+	NameLengthMin *int `yaml:"name_length_min"`
+	NameLengthMax *int `yaml:"name_length_max"`
+	// Scripts matches if the user's display name contains any of the
+	// given scripts. Available options: "latin", "cyrillic", "cjk", "rtl".
+	Scripts []Script `yaml:"scripts"`
+	// MixedScripts matches on whether the user's display name contains
+	// more than one script (from the known scripts above).
+	MixedScripts *bool `yaml:"mixed_scripts"`
+	// NameHasLink matches on whether the display name contains a link,
+	// like "t.me/something" or "example.com".
+	NameHasLink *bool `yaml:"name_has_link"`
+	// NameHasMention matches on whether the display name contains an "@".
+	NameHasMention *bool `yaml:"name_has_mention"`
+	// IsPremium matches on the user's Telegram Premium status.
+	IsPremium *bool `yaml:"is_premium"`
+	// LanguageCodes matches if the user's language code is one of the given
+	// values. Use an empty string to match users without a language code.
+	LanguageCodes []string `yaml:"language_codes"`
+	// AccountAgeLessThan and AccountAgeMoreThan matches on the estimated
+	// account age, see EstimateAccountCreation.
+	AccountAgeLessThan time.Duration `yaml:"account_age_less_than"`
+	AccountAgeMoreThan time.Duration `yaml:"account_age_more_than"`
+}
+
+// Rule is a single declarative rule of a join policy.
+type Rule struct {
+	// Name is used for logging which rule was matched.
+	Name string `yaml:"name"`
+	// Action that will be taken if the rule matches.
+	Action Action `yaml:"action"`
+	// Match determines how the conditions are combined. Available options
+	// are "all" (default), which requires every condition to match,
+	// and "any", which requires at least one condition to match.
+	Match      string      `yaml:"match"`
+	Conditions []Condition `yaml:"conditions"`
+}
+
+// Policy is an ordered list of rules for a group. The first rule
+// that matches wins. If nothing matches, DefaultAction is used.
+type Policy struct {
+	DefaultAction Action `yaml:"default_action"`
+	Rules         []Rule `yaml:"rules"`
+}
+
+// Configuration contains the policy for every group. Groups that are not
+// listed on Groups will fall back to the Default policy.
+type Configuration struct {
+	Default Policy           `yaml:"default"`
+	Groups  map[int64]Policy `yaml:"groups"`
+}
+
+// Decision is the result of evaluating a policy against a user.
+type Decision struct {
+	Action Action
+	// Rule is the name of the matched rule. It is empty if no rule matched
+	// and the default action is used.
+	Rule string
+}
+
+// Parse reads a YAML join policy configuration and validates it.
+func Parse(data []byte) (*Configuration, error) {
+	var configuration Configuration
+	err := yaml.Unmarshal(data, &configuration)
+	if err != nil {
+		return nil, fmt.Errorf("parsing join policy: %w", err)
+	}
+
+	err = configuration.Default.validate()
+	if err != nil {
+		return nil, fmt.Errorf("default policy: %w", err)
+	}
+
+	for groupID, policy := range configuration.Groups {
+		err := policy.validate()
+		if err != nil {
+			return nil, fmt.Errorf("policy for group %d: %w", groupID, err)
+		}
+	}
+
+	return &configuration, nil
+}
+
+// LoadFile reads and parses a YAML join policy configuration from the given path.
+func LoadFile(path string) (*Configuration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading join policy file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+func (p Policy) validate() error {
+	if p.DefaultAction != "" && !p.DefaultAction.Valid() {
+		return fmt.Errorf("invalid default action %q", p.DefaultAction)
+	}
+
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule #%d has no name", i)
+		}
+
+		if !rule.Action.Valid() {
+			return fmt.Errorf("rule %q has an invalid action %q", rule.Name, rule.Action)
+		}
+
+		if rule.Match != "" && rule.Match != "all" && rule.Match != "any" {
+			return fmt.Errorf("rule %q has an invalid match %q", rule.Name, rule.Match)
+		}
+
+		for _, condition := range rule.Conditions {
+			for _, script := range condition.Scripts {
+				if !script.Valid() {
+					return fmt.Errorf("rule %q has an invalid script %q", rule.Name, script)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// For returns the policy for the given group.
+func (c *Configuration) For(groupID int64) Policy {
+	if policy, ok := c.Groups[groupID]; ok {
+		return policy
+	}
+
+	return c.Default
+}
+
+// Evaluate picks the action for a newly joined user of a group.
+func (c *Configuration) Evaluate(groupID int64, user *tb.User, now time.Time) Decision {
+	return c.For(groupID).Evaluate(user, now)
+}
+
+// Evaluate picks the action for a newly joined user. It does not do
+// any network call, so it's safe to be used for testing a policy offline.
+func (p Policy) Evaluate(user *tb.User, now time.Time) Decision {
+	attributes := ExtractAttributes(user, now)
+
+	for _, rule := range p.Rules {
+		if rule.matches(attributes) {
+			return Decision{Action: rule.Action, Rule: rule.Name}
+		}
+	}
+
+	if p.DefaultAction == "" {
+		return Decision{Action: ActionCaptcha}
+	}
+
+	return Decision{Action: p.DefaultAction}
+}
+
+func (r Rule) matches(attributes Attributes) bool {
+	if len(r.Conditions) == 0 {
+		// A rule without any condition acts as a catch-all rule.
+		return true
+	}
+
+	for _, condition := range r.Conditions {
+		matched := condition.matches(attributes)
+		if r.Match == "any" && matched {
+			return true
+		}
+
+		if r.Match != "any" && !matched {
+			return false
+		}
+	}
+
+	return r.Match != "any"
+}
+
+func (c Condition) matches(attributes Attributes) bool {
+	if c.HasUsername != nil && *c.HasUsername != attributes.HasUsername {
+		return false
+	}
+
+	if c.NameLengthMin != nil && attributes.NameLength < *c.NameLengthMin {
+		return false
+	}
+
+	if c.NameLengthMax != nil && attributes.NameLength > *c.NameLengthMax {
+		return false
+	}
+
+	if len(c.Scripts) > 0 {
+		var found bool
+		for _, script := range c.Scripts {
+			if attributes.hasScript(script) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if c.MixedScripts != nil && *c.MixedScripts != (len(attributes.Scripts) > 1) {
+		return false
+	}
+
+	if c.NameHasLink != nil && *c.NameHasLink != attributes.NameHasLink {
+		return false
+	}
+
+	if c.NameHasMention != nil && *c.NameHasMention != attributes.NameHasMention {
+		return false
+	}
+
+	if c.IsPremium != nil && *c.IsPremium != attributes.IsPremium {
+		return false
+	}
+
+	if len(c.LanguageCodes) > 0 {
+		var found bool
+		for _, languageCode := range c.LanguageCodes {
+			if languageCode == attributes.LanguageCode {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if c.AccountAgeLessThan > 0 && attributes.AccountAge >= c.AccountAgeLessThan {
+		return false
+	}
+
+	if c.AccountAgeMoreThan > 0 && attributes.AccountAge <= c.AccountAgeMoreThan {
+		return false
+	}
+
+	return true
+}
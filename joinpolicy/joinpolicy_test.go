@@ -0,0 +1,142 @@
+package joinpolicy_test
+
+import (
+	"testing"
+	"time"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/joinpolicy"
+)
+
+const testConfiguration = `
+default:
+  default_action: captcha
+  rules:
+    - name: link-in-name
+      action: ban
+      conditions:
+        - name_has_link: true
+    - name: premium
+      action: skip
+      conditions:
+        - is_premium: true
+          has_username: true
+    - name: fresh-or-mixed
+      action: hard_captcha
+      match: any
+      conditions:
+        - account_age_less_than: 720h
+        - mixed_scripts: true
+groups:
+  -1001:
+    default_action: restrict
+    rules:
+      - name: russian
+        action: captcha
+        conditions:
+          - language_codes: ["ru"]
+`
+
+func TestParse(t *testing.T) {
+	configuration, err := joinpolicy.Parse([]byte(testConfiguration))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(configuration.Default.Rules) != 3 {
+		t.Errorf("expecting 3 default rules, got %d", len(configuration.Default.Rules))
+	}
+
+	if _, ok := configuration.Groups[-1001]; !ok {
+		t.Errorf("expecting group -1001 to exists")
+	}
+
+	t.Run("Invalid action", func(t *testing.T) {
+		_, err := joinpolicy.Parse([]byte("default:\n  rules:\n    - name: a\n      action: explode\n"))
+		if err == nil {
+			t.Error("expecting an error, got nil")
+		}
+	})
+
+	t.Run("Invalid script", func(t *testing.T) {
+		_, err := joinpolicy.Parse([]byte("default:\n  rules:\n    - name: a\n      action: ban\n      conditions:\n        - scripts: [klingon]\n"))
+		if err == nil {
+			t.Error("expecting an error, got nil")
+		}
+	})
+
+	t.Run("Nameless rule", func(t *testing.T) {
+		_, err := joinpolicy.Parse([]byte("default:\n  rules:\n    - action: ban\n"))
+		if err == nil {
+			t.Error("expecting an error, got nil")
+		}
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	configuration, err := joinpolicy.Parse([]byte(testConfiguration))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	now := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		groupID int64
+		user    *tb.User
+		expect  joinpolicy.Decision
+	}{
+		{
+			name:    "Link in name",
+			groupID: 1,
+			user:    &tb.User{ID: 123_456_789, FirstName: "Crypto Signals", LastName: "t.me/xyz"},
+			expect:  joinpolicy.Decision{Action: joinpolicy.ActionBan, Rule: "link-in-name"},
+		},
+		{
+			name:    "Premium with username",
+			groupID: 1,
+			user:    &tb.User{ID: 123_456_789, FirstName: "John", Username: "john", IsPremium: true},
+			expect:  joinpolicy.Decision{Action: joinpolicy.ActionSkip, Rule: "premium"},
+		},
+		{
+			name:    "Premium without username",
+			groupID: 1,
+			user:    &tb.User{ID: 123_456_789, FirstName: "John", IsPremium: true},
+			expect:  joinpolicy.Decision{Action: joinpolicy.ActionCaptcha},
+		},
+		{
+			name:    "Mixed scripts",
+			groupID: 1,
+			user:    &tb.User{ID: 123_456_789, FirstName: "John", LastName: "Иванов"},
+			expect:  joinpolicy.Decision{Action: joinpolicy.ActionHardCaptcha, Rule: "fresh-or-mixed"},
+		},
+		{
+			name:    "Fresh account",
+			groupID: 1,
+			user:    &tb.User{ID: 8_900_000_000, FirstName: "John"},
+			expect:  joinpolicy.Decision{Action: joinpolicy.ActionHardCaptcha, Rule: "fresh-or-mixed"},
+		},
+		{
+			name:    "Group specific rule",
+			groupID: -1001,
+			user:    &tb.User{ID: 123_456_789, FirstName: "Ivan", LanguageCode: "ru"},
+			expect:  joinpolicy.Decision{Action: joinpolicy.ActionCaptcha, Rule: "russian"},
+		},
+		{
+			name:    "Group specific default action",
+			groupID: -1001,
+			user:    &tb.User{ID: 123_456_789, FirstName: "John", LanguageCode: "en"},
+			expect:  joinpolicy.Decision{Action: joinpolicy.ActionRestrict},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := configuration.Evaluate(testCase.groupID, testCase.user, now)
+			if got != testCase.expect {
+				t.Errorf("expecting %+v, got %+v", testCase.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+// Package metrics is a small Prometheus registry. It only has what the bot
+// needs: counters, gauges and histograms with labels, written in the text
+// exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets used when none are given, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// Registry holds the metrics that are exposed together.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the registry the New functions register to.
+var Default = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collectors[c.name()]; ok {
+		panic("metrics: " + c.name() + " is already registered")
+	}
+
+	r.collectors[c.name()] = c
+}
+
+// Write writes every metric in the text exposition format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.RLock()
+	collectors := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+
+	for _, c := range collectors {
+		err := c.write(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler serves the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+// Handler serves the metrics of the Default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// desc is the part shared by every metric type.
+type desc struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+}
+
+func (d desc) name() string {
+	return d.metricName
+}
+
+func (d desc) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, d.kind)
+	return err
+}
+
+// key joins the label values into a map key. It panics on a wrong amount of
+// values, since that is always a programming error.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats the label set of key, with the extra pairs appended.
+func (d desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+"=\""+escapeLabel(value)+"\"")
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"=\""+escapeLabel(extra[i+1])+"\"")
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// series keeps the values of each label set of a metric.
+type series[T any] struct {
+	mu     sync.Mutex
+	values map[string]*T
+	// init creates the value of a new label set, zero when nil.
+	init func() *T
+}
+
+// update calls fn with the value of key, creating it if needed.
+func (s *series[T]) update(key string, fn func(value *T)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = make(map[string]*T)
+	}
+
+	value, ok := s.values[key]
+	if !ok {
+		if s.init != nil {
+			value = s.init()
+		} else {
+			value = new(T)
+		}
+		s.values[key] = value
+	}
+
+	fn(value)
+}
+
+// read returns a copy of the value of key.
+func (s *series[T]) read(key string) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	return *value, true
+}
+
+// each calls fn with every label set, in a stable order.
+func (s *series[T]) each(fn func(key string, value *T) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		err := fn(key, s.values[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	desc
+	series series[float64]
+}
+
+// NewCounter creates and registers a counter on the Default registry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter creates and registers a counter.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{metricName: name, help: help, kind: "counter", labels: labels}}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter of the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter of the label values. Negative values are ignored.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	c.series.update(c.key(labelValues), func(value *float64) { *value += v })
+}
+
+// Value returns the current value of the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	value, _ := c.series.read(c.key(labelValues))
+	return value
+}
+
+func (c *Counter) write(w io.Writer) error {
+	return writeScalars(w, c.desc, &c.series)
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	desc
+	series series[float64]
+}
+
+// NewGauge creates and registers a gauge on the Default registry.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// NewGauge creates and registers a gauge.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{metricName: name, help: help, kind: "gauge", labels: labels}}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge of the label values.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(value *float64) { *value = v })
+}
+
+// Add adds v, which may be negative, to the gauge of the label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.update(labelValues, func(value *float64) { *value += v })
+}
+
+// Inc adds one to the gauge of the label values.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec subtracts one from the gauge of the label values.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Value returns the current value of the label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	value, _ := g.series.read(g.key(labelValues))
+	return value
+}
+
+func (g *Gauge) update(labelValues []string, fn func(value *float64)) {
+	g.series.update(g.key(labelValues), fn)
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	return writeScalars(w, g.desc, &g.series)
+}
+
+func writeScalars(w io.Writer, d desc, s *series[float64]) error {
+	err := d.header(w)
+	if err != nil {
+		return err
+	}
+
+	return s.each(func(key string, value *float64) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", d.metricName, d.labelPairs(key), formatFloat(*value))
+		return err
+	})
+}
+
+// Histogram counts observations into buckets.
+type Histogram struct {
+	desc
+	buckets []float64
+	series  series[histogramValue]
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates and registers a histogram on the Default registry. Nil
+// buckets fall back to DefaultBuckets.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram creates and registers a histogram. Nil buckets fall back to DefaultBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	h := &Histogram{desc: desc{metricName: name, help: help, kind: "histogram", labels: labels}, buckets: buckets}
+	h.series.init = func() *histogramValue {
+		return &histogramValue{counts: make([]uint64, len(buckets))}
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records v for the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.series.update(h.key(labelValues), func(value *histogramValue) {
+		for i, bound := range h.buckets {
+			if v <= bound {
+				value.counts[i]++
+			}
+		}
+		value.count++
+		value.sum += v
+	})
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	err := h.header(w)
+	if err != nil {
+		return err
+	}
+
+	return h.series.each(func(key string, value *histogramValue) error {
+		for i, bound := range h.buckets {
+			_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(key, "le", formatFloat(bound)), value.counts[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.metricName, h.labelPairs(key, "le", "+Inf"), value.count,
+			h.metricName, h.labelPairs(key), formatFloat(value.sum),
+			h.metricName, h.labelPairs(key), value.count,
+		)
+		return err
+	})
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
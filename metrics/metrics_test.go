@@ -0,0 +1,82 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/teknologi-umum/captcha/metrics"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := metrics.NewRegistry()
+	calls := registry.NewCounter("api_calls_total", "Outbound calls.", "method", "status")
+	pending := registry.NewGauge("pending", "Pending \"things\".")
+	duration := registry.NewHistogram("solve_seconds", "Time to solve.", []float64{10, 5})
+
+	calls.Inc("sendMessage", "200")
+	calls.Add(2, "sendMessage", "200")
+	calls.Add(-1, "sendMessage", "200")
+	calls.Inc("ban\"Chat\nMember", "429")
+	pending.Inc()
+	pending.Inc()
+	pending.Dec()
+	duration.Observe(3)
+	duration.Observe(7)
+	duration.Observe(30)
+
+	if v := calls.Value("sendMessage", "200"); v != 3 {
+		t.Errorf("expecting 3 calls, got %v", v)
+	}
+
+	if v := pending.Value(); v != 1 {
+		t.Errorf("expecting 1 pending, got %v", v)
+	}
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+
+	expected := `# HELP api_calls_total Outbound calls.
+# TYPE api_calls_total counter
+api_calls_total{method="ban\"Chat\nMember",status="429"} 1
+api_calls_total{method="sendMessage",status="200"} 3
+# HELP pending Pending "things".
+# TYPE pending gauge
+pending 1
+# HELP solve_seconds Time to solve.
+# TYPE solve_seconds histogram
+solve_seconds_bucket{le="5"} 1
+solve_seconds_bucket{le="10"} 2
+solve_seconds_bucket{le="+Inf"} 3
+solve_seconds_sum 40
+solve_seconds_count 3
+`
+	if w.Body.String() != expected {
+		t.Errorf("unexpected exposition:\n%s", w.Body.String())
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounter("total", "Total.", "chat_id")
+
+	for name, fn := range map[string]func(){
+		"duplicate name":     func() { registry.NewGauge("total", "Total.") },
+		"wrong label values": func() { counter.Inc() },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expecting a panic", name)
+				}
+			}()
+
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,195 @@
+package namefilter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/cache"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// CheckUserJoin checks the display name of a newly joined user. It returns true
+// if the user has been banned or restricted, in which case no captcha should be
+// presented to them.
+func (d *Dependency) CheckUserJoin(ctx context.Context, chat *tb.Chat, user *tb.User) (bool, error) {
+	span := sentry.StartSpan(ctx, "namefilter.check_user_join")
+	ctx = span.Context()
+	defer span.Finish()
+
+	err := d.Memory.Set(nameCacheKey(chat.ID, user.ID), []byte(DisplayName(user)))
+	if err != nil {
+		return false, fmt.Errorf("setting display name cache: %w", err)
+	}
+
+	return d.check(ctx, chat, user)
+}
+
+// CheckProfileChange checks the display name of a message sender, but only if
+// their display name has changed since we saw them last time.
+func (d *Dependency) CheckProfileChange(ctx context.Context, m *tb.Message) (bool, error) {
+	if m.Sender == nil || m.Sender.IsBot || !m.FromGroup() {
+		return false, nil
+	}
+
+	name := DisplayName(m.Sender)
+	lastSeenName, err := d.Memory.Get(nameCacheKey(m.Chat.ID, m.Sender.ID))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return false, fmt.Errorf("getting display name cache: %w", err)
+	}
+
+	if err == nil && string(lastSeenName) == name {
+		return false, nil
+	}
+
+	span := sentry.StartSpan(ctx, "namefilter.check_profile_change")
+	ctx = span.Context()
+	defer span.Finish()
+
+	err = d.Memory.Set(nameCacheKey(m.Chat.ID, m.Sender.ID), []byte(name))
+	if err != nil {
+		return false, fmt.Errorf("setting display name cache: %w", err)
+	}
+
+	return d.check(ctx, m.Chat, m.Sender)
+}
+
+func (d *Dependency) check(ctx context.Context, chat *tb.Chat, user *tb.User) (bool, error) {
+	filter := d.Configuration.For(chat.ID)
+	if len(filter.Rules) == 0 && !filter.Impersonation.Enabled {
+		return false, nil
+	}
+
+	admins, err := d.groupAdmins(ctx, chat)
+	if err != nil {
+		return false, err
+	}
+
+	if utils.IsAdmin(admins, user) {
+		return false, nil
+	}
+
+	match, ok := filter.Check(user, admins)
+	if !ok {
+		return false, nil
+	}
+
+	slog.InfoContext(
+		ctx,
+		"Display name matched a name filter",
+		slog.String("rule", match.Rule),
+		slog.String("action", string(match.Action)),
+		slog.String("reason", match.Reason),
+		slog.Int64("group_id", chat.ID),
+		slog.Int64("user_id", user.ID),
+	)
+
+	err = d.enforce(ctx, chat, user, admins, match)
+	if err != nil {
+		return false, err
+	}
+
+	return match.Action != ActionFlag, nil
+}
+
+func (d *Dependency) enforce(ctx context.Context, chat *tb.Chat, user *tb.User, admins []tb.ChatMember, match Match) error {
+	if match.Action == ActionFlag {
+		return d.flag(ctx, chat, user, admins, match)
+	}
+
+	var err error
+	if match.Action == ActionBan {
+		err = d.Bot.Ban(ctx, chat, &tb.ChatMember{User: user, RestrictedUntil: tb.Forever()}, true)
+	} else {
+		err = d.Bot.Restrict(ctx, chat, &tb.ChatMember{User: user, Rights: tb.NoRights(), RestrictedUntil: tb.Forever()})
+	}
+	if err != nil {
+		return fmt.Errorf("enforcing %s: %w", match.Action, err)
+	}
+
+	return nil
+}
+
+// flag notifies the group admins through a private message. Not every admin
+// has started a conversation with the bot, so if nobody can be reached,
+// the notification is sent to the group instead.
+func (d *Dependency) flag(ctx context.Context, chat *tb.Chat, user *tb.User, admins []tb.ChatMember, match Match) error {
+	text := "⚠️ Nama <a href=\"tg://user?id=" + strconv.FormatInt(user.ID, 10) + "\">" +
+		utils.SanitizeInput(DisplayName(user)) +
+		"</a> (" + strconv.FormatInt(user.ID, 10) + ") di grup " + utils.SanitizeInput(chat.Title) +
+		" cocok dengan filter nama \"" + utils.SanitizeInput(match.Rule) + "\": " + utils.SanitizeInput(match.Reason) +
+		". Mohon dicek ya, admin."
+
+	var delivered bool
+	for _, admin := range admins {
+		if admin.User == nil || admin.User.IsBot {
+			continue
+		}
+
+		_, err := d.Bot.Send(ctx, admin.User, text, &tb.SendOptions{ParseMode: tb.ModeHTML, DisableWebPagePreview: true})
+		if err != nil {
+			slog.DebugContext(ctx, "Failed to notify an admin privately", slog.String("error", err.Error()), slog.Int64("admin_id", admin.User.ID))
+			continue
+		}
+
+		delivered = true
+	}
+
+	if delivered {
+		return nil
+	}
+
+	_, err := d.Bot.Send(ctx, chat, text, &tb.SendOptions{ParseMode: tb.ModeHTML, DisableWebPagePreview: true})
+	if err != nil {
+		return fmt.Errorf("sending flag notification: %w", err)
+	}
+
+	return nil
+}
+
+// groupAdmins returns the group admins along with their display names,
+// cached on memory to avoid calling the Bot API for every message.
+func (d *Dependency) groupAdmins(ctx context.Context, chat *tb.Chat) ([]tb.ChatMember, error) {
+	key := "namefilter:admins:" + strconv.FormatInt(chat.ID, 10)
+	cached, err := d.Memory.Get(key)
+	if err == nil {
+		var admins []tb.ChatMember
+		err := json.Unmarshal(cached, &admins)
+		if err == nil {
+			return admins, nil
+		}
+	} else if !errors.Is(err, cache.ErrNotFound) {
+		return nil, fmt.Errorf("getting group admins cache: %w", err)
+	}
+
+	admins, err := d.Bot.AdminsOf(ctx, chat)
+	if err != nil {
+		return nil, fmt.Errorf("getting group admins: %w", err)
+	}
+
+	var slim = make([]tb.ChatMember, 0, len(admins))
+	for _, admin := range admins {
+		slim = append(slim, tb.ChatMember{User: admin.User, Role: admin.Role})
+	}
+
+	value, err := json.Marshal(slim)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling group admins: %w", err)
+	}
+
+	err = d.Memory.Set(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("setting group admins cache: %w", err)
+	}
+
+	return slim, nil
+}
+
+func nameCacheKey(groupID int64, userID int64) string {
+	return "namefilter:name:" + strconv.FormatInt(groupID, 10) + ":" + strconv.FormatInt(userID, 10)
+}
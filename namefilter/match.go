@@ -0,0 +1,74 @@
+package namefilter
+
+import (
+	"strings"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// ImpersonationRule is the rule name reported when a display name
+// looks like one of the group admins.
+const ImpersonationRule = "impersonation"
+
+// Match describes why a display name matched a filter.
+type Match struct {
+	Rule   string
+	Action Action
+	Reason string
+}
+
+// DisplayName returns the first name and the last name of a user.
+func DisplayName(user *tb.User) string {
+	return user.FirstName + utils.ShouldAddSpace(user) + user.LastName
+}
+
+// Check evaluates the display name of a user against the filter.
+// The admins are used to detect impersonation, it can be nil if
+// impersonation is not enabled.
+func (f Filter) Check(user *tb.User, admins []tb.ChatMember) (Match, bool) {
+	name := DisplayName(user)
+	normalised := Normalise(name)
+	compact := strings.ReplaceAll(normalised, " ", "")
+
+	for _, rule := range f.Rules {
+		for _, pattern := range rule.compiledPatterns {
+			if pattern.MatchString(name) {
+				return Match{Rule: rule.Name, Action: rule.Action, Reason: "matches pattern " + pattern.String()}, true
+			}
+		}
+
+		for _, keyword := range rule.normalisedKeywords {
+			if keyword == "" {
+				continue
+			}
+
+			if strings.Contains(normalised, keyword) || strings.Contains(compact, strings.ReplaceAll(keyword, " ", "")) {
+				return Match{Rule: rule.Name, Action: rule.Action, Reason: "contains keyword " + keyword}, true
+			}
+		}
+	}
+
+	if f.Impersonation.Enabled && normalised != "" {
+		for _, admin := range admins {
+			if admin.User == nil || admin.User.ID == user.ID {
+				continue
+			}
+
+			adminName := Normalise(DisplayName(admin.User))
+			if adminName == "" {
+				continue
+			}
+
+			if Similarity(normalised, adminName) >= f.Impersonation.Threshold {
+				return Match{
+					Rule:   ImpersonationRule,
+					Action: f.Impersonation.Action,
+					Reason: "looks like the admin " + DisplayName(admin.User),
+				}, true
+			}
+		}
+	}
+
+	return Match{}, false
+}
@@ -0,0 +1,128 @@
+package namefilter_test
+
+import (
+	"testing"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/namefilter"
+)
+
+const testConfiguration = `
+default:
+  rules:
+    - name: crypto
+      action: ban
+      keywords: ["crypto signals", "airdrop"]
+    - name: links
+      action: restrict
+      patterns: ['(?i)t\.me/\w+']
+  impersonation:
+    enabled: true
+    action: flag
+groups:
+  -1001:
+    rules:
+      - name: nothing
+        action: flag
+        keywords: ["nothing"]
+`
+
+func TestParse(t *testing.T) {
+	_, err := namefilter.Parse([]byte(testConfiguration))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	t.Run("Invalid pattern", func(t *testing.T) {
+		_, err := namefilter.Parse([]byte("default:\n  rules:\n    - name: a\n      action: ban\n      patterns: ['(']\n"))
+		if err == nil {
+			t.Error("expecting an error, got nil")
+		}
+	})
+
+	t.Run("Invalid action", func(t *testing.T) {
+		_, err := namefilter.Parse([]byte("default:\n  rules:\n    - name: a\n      action: explode\n"))
+		if err == nil {
+			t.Error("expecting an error, got nil")
+		}
+	})
+}
+
+func TestFilter_Check(t *testing.T) {
+	configuration, err := namefilter.Parse([]byte(testConfiguration))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	admins := []tb.ChatMember{
+		{User: &tb.User{ID: 1, FirstName: "Reinaldy", LastName: "Rafli"}},
+	}
+
+	testCases := []struct {
+		name    string
+		groupID int64
+		user    *tb.User
+		matched bool
+		expect  namefilter.Match
+	}{
+		{
+			name:    "Keyword with confusables",
+			groupID: 1,
+			user:    &tb.User{ID: 2, FirstName: "СRYPT0 Signals 💰"},
+			matched: true,
+			expect:  namefilter.Match{Rule: "crypto", Action: namefilter.ActionBan, Reason: "contains keyword crypto signals"},
+		},
+		{
+			name:    "Spaced keyword",
+			groupID: 1,
+			user:    &tb.User{ID: 2, FirstName: "A I R D R O P"},
+			matched: true,
+			expect:  namefilter.Match{Rule: "crypto", Action: namefilter.ActionBan, Reason: "contains keyword airdrop"},
+		},
+		{
+			name:    "Pattern",
+			groupID: 1,
+			user:    &tb.User{ID: 2, FirstName: "Join", LastName: "t.me/xyz"},
+			matched: true,
+			expect:  namefilter.Match{Rule: "links", Action: namefilter.ActionRestrict, Reason: `matches pattern (?i)t\.me/\w+`},
+		},
+		{
+			name:    "Impersonation",
+			groupID: 1,
+			user:    &tb.User{ID: 2, FirstName: "Reinaldy", LastName: "RafIi"},
+			matched: true,
+			expect:  namefilter.Match{Rule: namefilter.ImpersonationRule, Action: namefilter.ActionFlag, Reason: "looks like the admin Reinaldy Rafli"},
+		},
+		{
+			name:    "Admin themselves",
+			groupID: 1,
+			user:    &tb.User{ID: 1, FirstName: "Reinaldy", LastName: "Rafli"},
+			matched: false,
+		},
+		{
+			name:    "Regular user",
+			groupID: 1,
+			user:    &tb.User{ID: 2, FirstName: "John", LastName: "Doe"},
+			matched: false,
+		},
+		{
+			name:    "Group specific filter",
+			groupID: -1001,
+			user:    &tb.User{ID: 2, FirstName: "Crypto Signals"},
+			matched: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, matched := configuration.For(testCase.groupID).Check(testCase.user, admins)
+			if matched != testCase.matched {
+				t.Fatalf("expecting matched to be %v, got %v (%+v)", testCase.matched, matched, got)
+			}
+
+			if got != testCase.expect {
+				t.Errorf("expecting %+v, got %+v", testCase.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package namefilter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/teknologi-umum/captcha/cache"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is the thing that should be done to a user whose
+// display name matches a filter.
+type Action string
+
+const (
+	// ActionBan bans the user from the group.
+	ActionBan Action = "ban"
+	// ActionRestrict restricts the user from sending anything to the group.
+	ActionRestrict Action = "restrict"
+	// ActionFlag only notifies the group admins.
+	ActionFlag Action = "flag"
+)
+
+// Valid checks whether the action is one of the known actions.
+func (a Action) Valid() bool {
+	switch a {
+	case ActionBan, ActionRestrict, ActionFlag:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rule matches a display name by regular expressions or keywords.
+// Keywords are matched against the normalised display name, so
+// "CRYPT0" and "сrурtо" (with Cyrillic letters) are both matched
+// by the "crypto" keyword.
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Action   Action   `yaml:"action"`
+	Patterns []string `yaml:"patterns"`
+	Keywords []string `yaml:"keywords"`
+
+	compiledPatterns   []*regexp.Regexp
+	normalisedKeywords []string
+}
+
+// Impersonation matches a display name that looks like one of the
+// current group admins' display name.
+type Impersonation struct {
+	Enabled bool   `yaml:"enabled"`
+	Action  Action `yaml:"action"`
+	// Threshold is the minimum similarity, between 0 and 1, of the
+	// normalised display names to be considered as an impersonation.
+	// Defaults to 0.85.
+	Threshold float64 `yaml:"threshold"`
+}
+
+// Filter is the set of name filter rules of a group.
+type Filter struct {
+	Rules         []Rule        `yaml:"rules"`
+	Impersonation Impersonation `yaml:"impersonation"`
+}
+
+// Configuration contains the filter for every group. Groups that are not
+// listed on Groups will fall back to the Default filter.
+type Configuration struct {
+	Default Filter           `yaml:"default"`
+	Groups  map[int64]Filter `yaml:"groups"`
+}
+
+// Parse reads a YAML name filter configuration, validates it,
+// and compiles the regular expressions.
+func Parse(data []byte) (*Configuration, error) {
+	var configuration Configuration
+	err := yaml.Unmarshal(data, &configuration)
+	if err != nil {
+		return nil, fmt.Errorf("parsing name filter: %w", err)
+	}
+
+	err = configuration.Default.prepare()
+	if err != nil {
+		return nil, fmt.Errorf("default filter: %w", err)
+	}
+
+	for groupID, filter := range configuration.Groups {
+		err := filter.prepare()
+		if err != nil {
+			return nil, fmt.Errorf("filter for group %d: %w", groupID, err)
+		}
+
+		configuration.Groups[groupID] = filter
+	}
+
+	return &configuration, nil
+}
+
+// LoadFile reads and parses a YAML name filter configuration from the given path.
+func LoadFile(path string) (*Configuration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading name filter file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+func (f *Filter) prepare() error {
+	for i := range f.Rules {
+		rule := &f.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule #%d has no name", i)
+		}
+
+		if !rule.Action.Valid() {
+			return fmt.Errorf("rule %q has an invalid action %q", rule.Name, rule.Action)
+		}
+
+		for _, pattern := range rule.Patterns {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("rule %q has an invalid pattern: %w", rule.Name, err)
+			}
+
+			rule.compiledPatterns = append(rule.compiledPatterns, compiled)
+		}
+
+		for _, keyword := range rule.Keywords {
+			rule.normalisedKeywords = append(rule.normalisedKeywords, Normalise(keyword))
+		}
+	}
+
+	if f.Impersonation.Enabled {
+		if f.Impersonation.Action == "" {
+			f.Impersonation.Action = ActionFlag
+		}
+
+		if !f.Impersonation.Action.Valid() {
+			return fmt.Errorf("impersonation has an invalid action %q", f.Impersonation.Action)
+		}
+
+		if f.Impersonation.Threshold <= 0 || f.Impersonation.Threshold > 1 {
+			f.Impersonation.Threshold = 0.85
+		}
+	}
+
+	return nil
+}
+
+// For returns the filter for the given group.
+func (c *Configuration) For(groupID int64) Filter {
+	if filter, ok := c.Groups[groupID]; ok {
+		return filter
+	}
+
+	return c.Default
+}
+
+// Dependency contains the dependency injection struct
+// for methods in the namefilter package.
+type Dependency struct {
+	Configuration *Configuration
+	Memory        cache.Cache
+	Bot           *tb.Bot
+}
+
+// New creates a new name filter dependency.
+func New(configuration *Configuration, memory cache.Cache, bot *tb.Bot) (*Dependency, error) {
+	if configuration == nil {
+		return nil, fmt.Errorf("configuration is nil")
+	}
+
+	if memory == nil {
+		return nil, fmt.Errorf("memory is nil")
+	}
+
+	if bot == nil {
+		return nil, fmt.Errorf("bot is nil")
+	}
+
+	return &Dependency{Configuration: configuration, Memory: memory, Bot: bot}, nil
+}
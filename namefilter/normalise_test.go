@@ -0,0 +1,49 @@
+package namefilter_test
+
+import (
+	"testing"
+
+	"github.com/teknologi-umum/captcha/namefilter"
+)
+
+func TestNormalise(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect string
+	}{
+		{input: "Crypto Signals 💰 t.me/xyz", expect: "crypto signals t me xyz"},
+		{input: "CRYPT0", expect: "crypto"},
+		{input: "сrурtо", expect: "crypto"},
+		{input: "𝐂𝐫𝐲𝐩𝐭𝐨", expect: "crypto"},
+		{input: "Ｃｒｙｐｔｏ", expect: "crypto"},
+		{input: "Réinaldy​", expect: "reinaldy"},
+		{input: "", expect: ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			got := namefilter.Normalise(testCase.input)
+			if got != testCase.expect {
+				t.Errorf("expecting %q, got %q", testCase.expect, got)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if got := namefilter.Similarity("reinaldy", "reinaldy"); got != 1 {
+		t.Errorf("expecting 1, got %v", got)
+	}
+
+	if got := namefilter.Similarity("reinaldy", "reinaldi"); got < 0.85 {
+		t.Errorf("expecting at least 0.85, got %v", got)
+	}
+
+	if got := namefilter.Similarity("reinaldy", "john"); got > 0.5 {
+		t.Errorf("expecting at most 0.5, got %v", got)
+	}
+
+	if got := namefilter.Similarity("", ""); got != 1 {
+		t.Errorf("expecting 1, got %v", got)
+	}
+}
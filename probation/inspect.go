@@ -0,0 +1,158 @@
+package probation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// Inspect checks an incoming group message against the probation rules.
+// Messages that are not allowed during probation are deleted, and the
+// user is restricted once they reached the maximum amount of violations.
+func (d *Dependency) Inspect(ctx context.Context, m *tb.Message) error {
+	if m.Sender == nil || m.Sender.IsBot || !m.FromGroup() {
+		return nil
+	}
+
+	probation, ok, err := d.get(m.Chat.ID, m.Sender.ID)
+	if err != nil {
+		return err
+	}
+
+	if !ok || m.ID <= probation.StartMessageID {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "probation.inspect")
+	ctx = span.Context()
+	defer span.Finish()
+
+	if time.Now().After(probation.Until) {
+		_, err := d.Lift(ctx, probation.ChatID, probation.UserID)
+		return err
+	}
+
+	violation, ok := ViolationOf(m)
+	if !ok {
+		if d.Messages == 0 {
+			return nil
+		}
+
+		probation, ok, err = d.update(probation.ChatID, probation.UserID, func(probation *Probation) {
+			probation.MessagesLeft--
+		})
+		if err != nil || !ok {
+			return err
+		}
+
+		if probation.MessagesLeft <= 0 {
+			_, err := d.Lift(ctx, probation.ChatID, probation.UserID)
+			return err
+		}
+
+		return nil
+	}
+
+	err = d.Bot.Delete(ctx, m)
+	if err != nil && !errors.Is(err, tb.ErrNotFoundToDelete) {
+		return fmt.Errorf("deleting message: %w", err)
+	}
+
+	// The count comes from the same transaction that increments it, so
+	// every message of a burst sees its own count.
+	probation, ok, err = d.update(probation.ChatID, probation.UserID, func(probation *Probation) {
+		probation.Violations++
+	})
+	if err != nil || !ok {
+		return err
+	}
+
+	slog.InfoContext(
+		ctx,
+		"Deleted a message from a user on probation",
+		slog.String("violation", string(violation)),
+		slog.Int("violations", probation.Violations),
+		slog.Int64("group_id", m.Chat.ID),
+		slog.Int64("user_id", m.Sender.ID),
+	)
+
+	if probation.Violations > d.MaxViolations {
+		// Another message of the same burst reached the limit first,
+		// and is restricting the user already.
+		return nil
+	}
+
+	if probation.Violations == d.MaxViolations {
+		return d.restrict(ctx, m, probation)
+	}
+
+	d.explain(
+		ctx,
+		m.Chat,
+		utils.SanitizeInput(m.Sender.FirstName+utils.ShouldAddSpace(m.Sender)+m.Sender.LastName)+
+			", pesan kamu dihapus karena anggota baru belum boleh mengirim "+violation.description()+
+			" sampai pukul "+probation.Until.In(time.FixedZone("WIB", 7*60*60)).Format("15:04 MST")+
+			". Peringatan "+strconv.Itoa(probation.Violations)+" dari "+strconv.Itoa(d.MaxViolations)+".",
+	)
+
+	return nil
+}
+
+// restrict mutes a user who keeps on violating the probation rules. The
+// probation state is removed afterwards, since the admins will handle it.
+func (d *Dependency) restrict(ctx context.Context, m *tb.Message, probation Probation) error {
+	restrictedUntil := time.Now().Add(time.Hour * 24)
+	if probation.Until.After(restrictedUntil) {
+		restrictedUntil = probation.Until
+	}
+
+	err := d.Bot.Restrict(ctx, m.Chat, &tb.ChatMember{User: m.Sender, Rights: tb.NoRights(), RestrictedUntil: restrictedUntil.Unix()})
+	if err != nil {
+		return fmt.Errorf("restricting user: %w", err)
+	}
+
+	_, err = d.Lift(ctx, probation.ChatID, probation.UserID)
+	if err != nil {
+		return err
+	}
+
+	d.explain(
+		ctx,
+		m.Chat,
+		utils.SanitizeInput(m.Sender.FirstName+utils.ShouldAddSpace(m.Sender)+m.Sender.LastName)+
+			" dibatasi sampai "+restrictedUntil.In(time.FixedZone("WIB", 7*60*60)).Format("02 Jan 15:04 MST")+
+			" karena berulang kali mengirim pesan yang belum diperbolehkan untuk anggota baru.",
+	)
+
+	return nil
+}
+
+// explain sends a short explanation to the group, then deletes it after a minute
+// to keep the group clean.
+func (d *Dependency) explain(ctx context.Context, chat *tb.Chat, text string) {
+	msg, err := d.Bot.Send(ctx, chat, text, &tb.SendOptions{ParseMode: tb.ModeHTML, DisableWebPagePreview: true})
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("sending probation explanation: %w", err))
+		return
+	}
+
+	go func(msg *tb.Message) {
+		time.Sleep(time.Minute)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+
+		err := d.Bot.Delete(ctx, msg)
+		if err != nil && !errors.Is(err, tb.ErrNotFoundToDelete) {
+			shared.HandleError(ctx, fmt.Errorf("deleting probation explanation: %w", err))
+		}
+	}(msg)
+}
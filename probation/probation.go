@@ -0,0 +1,247 @@
+package probation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// Dependency contains the dependency injection struct
+// for methods in the probation package.
+type Dependency struct {
+	DB  *badger.DB
+	Bot *tb.Bot
+	// Duration specifies how long a new member stays on probation.
+	Duration time.Duration
+	// Messages specifies the amount of clean messages that ends the
+	// probation earlier. Zero means only Duration is used.
+	Messages int
+	// MaxViolations specifies the amount of violations before
+	// the user is restricted.
+	MaxViolations int
+}
+
+// New creates a new probation dependency.
+func New(db *badger.DB, bot *tb.Bot, duration time.Duration, messages int, maxViolations int) (*Dependency, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	if bot == nil {
+		return nil, fmt.Errorf("bot is nil")
+	}
+
+	if duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+
+	if messages < 0 {
+		return nil, fmt.Errorf("messages must not be negative")
+	}
+
+	if maxViolations <= 0 {
+		return nil, fmt.Errorf("max violations must be positive")
+	}
+
+	return &Dependency{
+		DB:            db,
+		Bot:           bot,
+		Duration:      duration,
+		Messages:      messages,
+		MaxViolations: maxViolations,
+	}, nil
+}
+
+// Probation is the probation state of a user in a group.
+type Probation struct {
+	ChatID int64     `json:"chat_id"`
+	UserID int64     `json:"user_id"`
+	Until  time.Time `json:"until"`
+	// StartMessageID is the message that started the probation, which is
+	// the captcha answer. Messages up to this one are not inspected.
+	StartMessageID int `json:"start_message_id"`
+	// MessagesLeft is the amount of clean messages left before the
+	// probation ends. It is ignored if Dependency.Messages is zero.
+	MessagesLeft int `json:"messages_left"`
+	Violations   int `json:"violations"`
+}
+
+// Start puts the sender of the message on probation. The message is
+// expected to be the one that completed the captcha.
+func (d *Dependency) Start(ctx context.Context, m *tb.Message) error {
+	span := sentry.StartSpan(ctx, "probation.start")
+	defer span.Finish()
+
+	return d.set(Probation{
+		ChatID:         m.Chat.ID,
+		UserID:         m.Sender.ID,
+		Until:          time.Now().Add(d.Duration),
+		StartMessageID: m.ID,
+		MessagesLeft:   d.Messages,
+	})
+}
+
+// Lift ends the probation of a user in a group. It returns false when the
+// user was not on probation.
+func (d *Dependency) Lift(ctx context.Context, chatID int64, userID int64) (bool, error) {
+	span := sentry.StartSpan(ctx, "probation.lift")
+	defer span.Finish()
+
+	var lifted bool
+	err := d.DB.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(key(chatID, userID))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+
+			return err
+		}
+
+		lifted = true
+		return txn.Delete(key(chatID, userID))
+	})
+	if err != nil {
+		return false, fmt.Errorf("deleting probation: %w", err)
+	}
+
+	return lifted, nil
+}
+
+// get returns the probation state of a user, or false if the
+// user is not on probation.
+func (d *Dependency) get(chatID int64, userID int64) (Probation, bool, error) {
+	var value []byte
+	err := d.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key(chatID, userID))
+		if err != nil {
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return Probation{}, false, nil
+		}
+
+		return Probation{}, false, fmt.Errorf("getting probation: %w", err)
+	}
+
+	var probation Probation
+	err = json.Unmarshal(value, &probation)
+	if err != nil {
+		return Probation{}, false, fmt.Errorf("unmarshaling probation: %w", err)
+	}
+
+	return probation, true, nil
+}
+
+func (d *Dependency) set(probation Probation) error {
+	entry, err := newEntry(probation)
+	if err != nil {
+		return err
+	}
+
+	err = d.DB.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(entry)
+	})
+	if err != nil {
+		return fmt.Errorf("setting probation: %w", err)
+	}
+
+	return nil
+}
+
+// maxUpdateAttempts limits how many times update retries a transaction
+// that conflicted with another one.
+const maxUpdateAttempts = 10
+
+// update reads, modifies and writes the probation state of a user in a
+// single transaction, so concurrent messages of the same user never
+// overwrite each other's changes. It returns the updated state, or false
+// if the user is not on probation.
+func (d *Dependency) update(chatID int64, userID int64, modify func(probation *Probation)) (Probation, bool, error) {
+	var probation Probation
+	var ok bool
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		probation, ok, err = d.tryUpdate(chatID, userID, modify)
+		if !errors.Is(err, badger.ErrConflict) {
+			break
+		}
+	}
+	if err != nil {
+		return Probation{}, false, fmt.Errorf("updating probation: %w", err)
+	}
+
+	return probation, ok, nil
+}
+
+func (d *Dependency) tryUpdate(chatID int64, userID int64, modify func(probation *Probation)) (Probation, bool, error) {
+	var probation Probation
+	var ok bool
+	err := d.DB.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key(chatID, userID))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+
+			return err
+		}
+
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(value, &probation)
+		if err != nil {
+			return fmt.Errorf("unmarshaling probation: %w", err)
+		}
+
+		modify(&probation)
+
+		entry, err := newEntry(probation)
+		if err != nil {
+			return err
+		}
+
+		ok = true
+		return txn.SetEntry(entry)
+	})
+	if err != nil {
+		return Probation{}, false, err
+	}
+
+	return probation, ok, nil
+}
+
+func newEntry(probation Probation) (*badger.Entry, error) {
+	value, err := json.Marshal(probation)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling probation: %w", err)
+	}
+
+	// The TTL makes sure that users who never talk again
+	// do not stay in the database forever.
+	ttl := time.Until(probation.Until)
+	if ttl <= 0 {
+		ttl = time.Second
+	}
+
+	return badger.NewEntry(key(probation.ChatID, probation.UserID), value).WithTTL(ttl), nil
+}
+
+func key(chatID int64, userID int64) []byte {
+	return []byte("probation:" + strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(userID, 10))
+}
@@ -0,0 +1,135 @@
+package probation_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/probation"
+)
+
+func TestLift(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("opening badger: %s", err.Error())
+	}
+	defer db.Close()
+
+	bot, err := tb.NewBot(tb.Settings{Offline: true})
+	if err != nil {
+		t.Fatalf("creating bot: %s", err.Error())
+	}
+
+	dependency, err := probation.New(db, bot, time.Hour, 5, 3)
+	if err != nil {
+		t.Fatalf("creating probation: %s", err.Error())
+	}
+
+	ctx := context.Background()
+	err = dependency.Start(ctx, &tb.Message{ID: 1, Chat: &tb.Chat{ID: -1001}, Sender: &tb.User{ID: 42}})
+	if err != nil {
+		t.Fatalf("starting probation: %s", err.Error())
+	}
+
+	lifted, err := dependency.Lift(ctx, -1001, 42)
+	if err != nil || !lifted {
+		t.Errorf("expecting the probation to be lifted, got %v %v", lifted, err)
+	}
+
+	lifted, err = dependency.Lift(ctx, -1001, 42)
+	if err != nil || lifted {
+		t.Errorf("expecting nothing to lift the second time, got %v %v", lifted, err)
+	}
+}
+
+// fakeTelegram answers every Bot API method, and counts the calls
+// made to each of them.
+type fakeTelegram struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	f.mu.Lock()
+	f.calls[method]++
+	f.mu.Unlock()
+
+	result := `true`
+	if method == "sendMessage" {
+		result = `{"message_id":100,"date":0,"chat":{"id":-1001,"type":"supergroup"}}`
+	}
+
+	_, _ = io.WriteString(w, `{"ok":true,"result":`+result+`}`)
+}
+
+func TestInspectBurst(t *testing.T) {
+	telegram := &fakeTelegram{calls: make(map[string]int)}
+	server := httptest.NewServer(telegram)
+	defer server.Close()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("opening badger: %s", err.Error())
+	}
+	defer db.Close()
+
+	bot, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "token", Offline: true})
+	if err != nil {
+		t.Fatalf("creating bot: %s", err.Error())
+	}
+
+	dependency, err := probation.New(db, bot, time.Hour, 5, 3)
+	if err != nil {
+		t.Fatalf("creating probation: %s", err.Error())
+	}
+
+	ctx := context.Background()
+	chat := &tb.Chat{ID: -1001, Type: tb.ChatSuperGroup}
+	sender := &tb.User{ID: 42, FirstName: "Spammer"}
+	err = dependency.Start(ctx, &tb.Message{ID: 1, Chat: chat, Sender: sender})
+	if err != nil {
+		t.Fatalf("starting probation: %s", err.Error())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+
+			err := dependency.Inspect(ctx, &tb.Message{
+				ID:       id,
+				Chat:     chat,
+				Sender:   sender,
+				Text:     "https://example.com",
+				Entities: tb.Entities{{Type: tb.EntityURL, Offset: 0, Length: 19}},
+			})
+			if err != nil {
+				t.Errorf("inspecting message: %s", err.Error())
+			}
+		}(i + 2)
+	}
+	wg.Wait()
+
+	telegram.mu.Lock()
+	defer telegram.mu.Unlock()
+
+	if telegram.calls["restrictChatMember"] != 1 {
+		t.Errorf("expecting the user to be restricted once, got %d", telegram.calls["restrictChatMember"])
+	}
+
+	// Two warnings for the first two violations, and one explanation
+	// for the restriction.
+	if telegram.calls["sendMessage"] != 3 {
+		t.Errorf("expecting 3 messages to be sent, got %d", telegram.calls["sendMessage"])
+	}
+}
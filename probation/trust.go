@@ -0,0 +1,94 @@
+package probation
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// TrustHandler provides a handler for /trust command. Admins can lift the
+// probation of a user by replying to their message, or by giving their user ID.
+func (d *Dependency) TrustHandler(ctx context.Context, c tb.Context) error {
+	if c.Message().Private() || c.Sender().IsBot {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "bot.trust_handler", sentry.WithTransactionSource(sentry.SourceTask),
+		sentry.WithTransactionName("Captcha TrustHandler"))
+	defer span.Finish()
+	ctx = span.Context()
+
+	sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
+		Type:     "user",
+		Category: "command.triggered",
+		Message:  "/trust",
+		Data: map[string]interface{}{
+			"user": c.Sender(),
+			"chat": c.Chat(),
+		},
+		Level:     sentry.LevelInfo,
+		Timestamp: time.Now(),
+	}, &sentry.BreadcrumbHint{})
+
+	admins, err := c.Bot().AdminsOf(ctx, c.Chat())
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	if !utils.IsAdmin(admins, c.Sender()) {
+		return nil
+	}
+
+	var userID int64
+	if c.Message().IsReply() && c.Message().ReplyTo.Sender != nil {
+		userID = c.Message().ReplyTo.Sender.ID
+	} else if args := c.Args(); len(args) > 0 {
+		userID, err = strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			userID = 0
+		}
+	}
+
+	if userID == 0 {
+		_, err := c.Bot().Send(
+			ctx,
+			c.Chat(),
+			"Balas pesan user yang mau dipercaya dengan /trust, atau kirim /trust <user id>.",
+			&tb.SendOptions{ReplyTo: c.Message(), AllowWithoutReply: true},
+		)
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	lifted, err := d.Lift(ctx, c.Chat().ID, userID)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	reply := "Masa percobaan untuk user " + strconv.FormatInt(userID, 10) + " sudah dicabut."
+	if !lifted {
+		reply = "User " + strconv.FormatInt(userID, 10) + " sedang tidak dalam masa percobaan."
+	}
+
+	_, err = c.Bot().Send(
+		ctx,
+		c.Chat(),
+		reply,
+		&tb.SendOptions{ReplyTo: c.Message(), AllowWithoutReply: true},
+	)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
+
+	return nil
+}
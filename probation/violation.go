@@ -0,0 +1,63 @@
+package probation
+
+import (
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+// Violation is the reason why a message is not allowed during probation.
+type Violation string
+
+const (
+	// ViolationLink is a message that contains a URL or a text link.
+	ViolationLink Violation = "link"
+	// ViolationChannelForward is a message forwarded from a channel.
+	ViolationChannelForward Violation = "channel_forward"
+	// ViolationMedia is a message that contains media, other than stickers.
+	ViolationMedia Violation = "media"
+)
+
+// ViolationOf checks whether a message is not allowed to be sent by
+// a user on probation.
+func ViolationOf(m *tb.Message) (Violation, bool) {
+	if isChannelForward(m) {
+		return ViolationChannelForward, true
+	}
+
+	for _, entities := range []tb.Entities{m.Entities, m.CaptionEntities} {
+		for _, entity := range entities {
+			if entity.Type == tb.EntityURL || entity.Type == tb.EntityTextLink {
+				return ViolationLink, true
+			}
+		}
+	}
+
+	if m.Photo != nil || m.Video != nil || m.Animation != nil || m.Document != nil ||
+		m.Audio != nil || m.Voice != nil || m.VideoNote != nil {
+		return ViolationMedia, true
+	}
+
+	return "", false
+}
+
+func isChannelForward(m *tb.Message) bool {
+	if m.OriginalChat != nil && m.OriginalChat.Type == tb.ChatChannel {
+		return true
+	}
+
+	return m.Origin != nil && (m.Origin.Type == "channel" || (m.Origin.Chat != nil && m.Origin.Chat.Type == tb.ChatChannel))
+}
+
+// description returns the Indonesian description of the violation,
+// used on the explanation message.
+func (v Violation) description() string {
+	switch v {
+	case ViolationLink:
+		return "link"
+	case ViolationChannelForward:
+		return "forward dari channel"
+	case ViolationMedia:
+		return "media"
+	default:
+		return "pesan seperti itu"
+	}
+}
@@ -0,0 +1,73 @@
+package probation_test
+
+import (
+	"testing"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/probation"
+)
+
+func TestViolationOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   *tb.Message
+		violation probation.Violation
+		ok        bool
+	}{
+		{
+			name:    "Plain text",
+			message: &tb.Message{Text: "halo semuanya"},
+		},
+		{
+			name:      "URL entity",
+			message:   &tb.Message{Text: "cek example.com", Entities: tb.Entities{{Type: tb.EntityURL, Offset: 4, Length: 11}}},
+			violation: probation.ViolationLink,
+			ok:        true,
+		},
+		{
+			name:      "Text link on caption",
+			message:   &tb.Message{Caption: "klik", CaptionEntities: tb.Entities{{Type: tb.EntityTextLink, URL: "https://example.com"}}},
+			violation: probation.ViolationLink,
+			ok:        true,
+		},
+		{
+			name:      "Forward from channel",
+			message:   &tb.Message{Text: "promo", OriginalChat: &tb.Chat{ID: -100, Type: tb.ChatChannel}},
+			violation: probation.ViolationChannelForward,
+			ok:        true,
+		},
+		{
+			name:      "Forward origin from channel",
+			message:   &tb.Message{Text: "promo", Origin: &tb.MessageOrigin{Type: "channel"}},
+			violation: probation.ViolationChannelForward,
+			ok:        true,
+		},
+		{
+			name:    "Forward from user",
+			message: &tb.Message{Text: "halo", OriginalSender: &tb.User{ID: 1}},
+		},
+		{
+			name:      "Photo",
+			message:   &tb.Message{Photo: &tb.Photo{}},
+			violation: probation.ViolationMedia,
+			ok:        true,
+		},
+		{
+			name:    "Sticker",
+			message: &tb.Message{Sticker: &tb.Sticker{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violation, ok := probation.ViolationOf(test.message)
+			if ok != test.ok {
+				t.Errorf("expecting ok to be %t, got %t", test.ok, ok)
+			}
+
+			if violation != test.violation {
+				t.Errorf("expecting violation %q, got %q", test.violation, violation)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+// KeyFunc returns the key an update is limited by. An empty key means the
+// update is not limited.
+type KeyFunc func(c tb.Context) string
+
+// ByChat limits the updates of each chat together.
+func ByChat(c tb.Context) string {
+	if c.Chat() == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(c.Chat().ID, 10)
+}
+
+// BySender limits the updates of each user together, across chats.
+func BySender(c tb.Context) string {
+	if c.Sender() == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(c.Sender().ID, 10)
+}
+
+// ByCommand limits the updates of each command together, such as "/remind".
+// The bot username is left out of the command.
+func ByCommand(c tb.Context) string {
+	if c.Message() == nil {
+		return ""
+	}
+
+	command, _, _ := strings.Cut(c.Message().Text, " ")
+	command, _, _ = strings.Cut(command, "@")
+	if !strings.HasPrefix(command, "/") {
+		return ""
+	}
+
+	return strings.ToLower(command)
+}
+
+// Keys combines several KeyFunc, for example Keys(ByChat, BySender) limits each
+// user on each chat separately.
+func Keys(keys ...KeyFunc) KeyFunc {
+	return func(c tb.Context) string {
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			part := key(c)
+			if part == "" {
+				return ""
+			}
+
+			parts = append(parts, part)
+		}
+
+		return strings.Join(parts, ":")
+	}
+}
+
+// Middleware drops the updates that exceed the limiter. If onLimited is not nil,
+// it handles the dropped updates instead, for example to tell the user to slow down.
+// When the limiter fails, the update goes through, since a broken store must not
+// take every command down.
+func Middleware(limiter *Limiter, key KeyFunc, onLimited tb.HandlerFunc) tb.MiddlewareFunc {
+	return func(next tb.HandlerFunc) tb.HandlerFunc {
+		return func(c tb.Context) error {
+			k := key(c)
+			if k == "" {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+			allowed, err := limiter.Allow(ctx, k)
+			if err != nil {
+				shared.HandleError(ctx, err)
+				return next(c)
+			}
+
+			if !allowed {
+				if onLimited != nil {
+					return onLimited(c)
+				}
+
+				return nil
+			}
+
+			return next(c)
+		}
+	}
+}
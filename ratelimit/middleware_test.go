@@ -0,0 +1,62 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/ratelimit"
+)
+
+func TestMiddleware(t *testing.T) {
+	store := ratelimit.NewMemoryStore(0, 0)
+	defer store.Close()
+
+	limiter, err := ratelimit.NewTokenBucket("test", store, time.Hour, 1)
+	if err != nil {
+		t.Fatalf("creating limiter: %s", err.Error())
+	}
+
+	handled, limited := 0, 0
+	handler := ratelimit.Middleware(limiter, ratelimit.Keys(ratelimit.ByChat, ratelimit.BySender), func(c tb.Context) error {
+		limited++
+		return nil
+	})(func(c tb.Context) error {
+		handled++
+		return nil
+	})
+
+	update := func(chatID, senderID int64) tb.Context {
+		return tb.NewContext(nil, tb.Update{Message: &tb.Message{
+			Chat:   &tb.Chat{ID: chatID},
+			Sender: &tb.User{ID: senderID},
+			Text:   "/remind@TeknumCaptchaBot 5m",
+		}})
+	}
+
+	for _, c := range []tb.Context{update(1, 10), update(1, 10), update(1, 11), update(2, 10)} {
+		err := handler(c)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	if handled != 3 || limited != 1 {
+		t.Errorf("expecting 3 handled and 1 limited updates, got %d and %d", handled, limited)
+	}
+}
+
+func TestByCommand(t *testing.T) {
+	testCases := map[string]string{
+		"/remind@TeknumCaptchaBot 5m": "/remind",
+		"/Delete":                     "/delete",
+		"hello":                       "",
+	}
+
+	for text, want := range testCases {
+		c := tb.NewContext(nil, tb.Update{Message: &tb.Message{Text: text}})
+		if got := ratelimit.ByCommand(c); got != want {
+			t.Errorf("%q: expecting %q, got %q", text, want, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package ratelimit provides keyed rate limiters, with their state kept on a
+// Store so they can be shared across replicas of the bot.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Limiter decides whether a call identified by a key may go through.
+type Limiter struct {
+	name   string
+	store  Store
+	policy policy
+}
+
+// policy computes the next state of a key. It receives a nil state if the key
+// has none, and returns how long the new state must be kept.
+type policy interface {
+	take(state []byte, now time.Time) (next []byte, allowed bool, ttl time.Duration, err error)
+}
+
+// NewTokenBucket creates a limiter that allows burst calls at once, then one call
+// every interval. The name separates the keys of the limiter from the others on
+// the same store.
+func NewTokenBucket(name string, store Store, every time.Duration, burst int) (*Limiter, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store is nil")
+	}
+
+	if every <= 0 {
+		return nil, fmt.Errorf("every must be positive")
+	}
+
+	if burst <= 0 {
+		return nil, fmt.Errorf("burst must be positive")
+	}
+
+	return &Limiter{name: name, store: store, policy: tokenBucket{every: every, burst: burst}}, nil
+}
+
+// NewSlidingWindow creates a limiter that allows limit calls within any window.
+// The name separates the keys of the limiter from the others on the same store.
+func NewSlidingWindow(name string, store Store, limit int, window time.Duration) (*Limiter, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store is nil")
+	}
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	if window <= 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+
+	return &Limiter{name: name, store: store, policy: slidingWindow{limit: limit, window: window}}, nil
+}
+
+// Allow takes a call from the key, and reports whether it may go through.
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, error) {
+	var allowed bool
+	err := l.store.Update(ctx, "ratelimit:"+l.name+":"+key, func(state []byte) ([]byte, time.Duration, error) {
+		next, ok, ttl, err := l.policy.take(state, time.Now())
+		allowed = ok
+		return next, ttl, err
+	})
+	if err != nil {
+		return false, fmt.Errorf("updating rate limit of %s: %w", key, err)
+	}
+
+	return allowed, nil
+}
+
+type tokenBucket struct {
+	every time.Duration
+	burst int
+}
+
+type tokenBucketState struct {
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (t tokenBucket) take(state []byte, now time.Time) ([]byte, bool, time.Duration, error) {
+	current := tokenBucketState{Tokens: float64(t.burst), UpdatedAt: now}
+	if state != nil {
+		err := json.Unmarshal(state, &current)
+		if err != nil {
+			return nil, false, 0, fmt.Errorf("parsing token bucket state: %w", err)
+		}
+
+		elapsed := now.Sub(current.UpdatedAt)
+		current.Tokens = min(float64(t.burst), current.Tokens+float64(elapsed)/float64(t.every))
+		current.UpdatedAt = now
+	}
+
+	allowed := current.Tokens >= 1
+	if allowed {
+		current.Tokens--
+	}
+
+	next, err := json.Marshal(current)
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	// An empty bucket is full again after this long, which is the same as having no state.
+	return next, allowed, t.every * time.Duration(t.burst), nil
+}
+
+// slidingWindow approximates the calls within the last window from the count of the
+// current fixed window and a weighted count of the previous one, so the state stays
+// small no matter how many calls are made.
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+type slidingWindowState struct {
+	WindowStart time.Time `json:"window_start"`
+	Current     int       `json:"current"`
+	Previous    int       `json:"previous"`
+}
+
+func (s slidingWindow) take(state []byte, now time.Time) ([]byte, bool, time.Duration, error) {
+	windowStart := now.Truncate(s.window)
+
+	current := slidingWindowState{WindowStart: windowStart}
+	if state != nil {
+		err := json.Unmarshal(state, &current)
+		if err != nil {
+			return nil, false, 0, fmt.Errorf("parsing sliding window state: %w", err)
+		}
+
+		switch {
+		case current.WindowStart.Equal(windowStart):
+		case current.WindowStart.Add(s.window).Equal(windowStart):
+			current = slidingWindowState{WindowStart: windowStart, Previous: current.Current}
+		default:
+			current = slidingWindowState{WindowStart: windowStart}
+		}
+	}
+
+	elapsed := float64(now.Sub(windowStart)) / float64(s.window)
+	estimated := float64(current.Previous)*(1-elapsed) + float64(current.Current)
+
+	allowed := estimated < float64(s.limit)
+	if allowed {
+		current.Current++
+	}
+
+	next, err := json.Marshal(current)
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	return next, allowed, s.window * 2, nil
+}
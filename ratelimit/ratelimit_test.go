@@ -0,0 +1,232 @@
+package ratelimit_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/allegro/bigcache/v3"
+	"github.com/redis/go-redis/v9"
+	"github.com/teknologi-umum/captcha/ratelimit"
+)
+
+func stores(t *testing.T) map[string]ratelimit.Store {
+	memoryStore := ratelimit.NewMemoryStore(0, 0)
+	t.Cleanup(func() {
+		_ = memoryStore.Close()
+	})
+
+	memory, err := bigcache.New(context.Background(), bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("creating bigcache: %s", err.Error())
+	}
+
+	cacheStore, err := ratelimit.NewCacheStore(memory)
+	if err != nil {
+		t.Fatalf("creating cache store: %s", err.Error())
+	}
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	redisStore, err := ratelimit.NewRedisStore(client)
+	if err != nil {
+		t.Fatalf("creating redis store: %s", err.Error())
+	}
+
+	return map[string]ratelimit.Store{
+		"Memory": memoryStore,
+		"Cache":  cacheStore,
+		"Redis":  redisStore,
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+			defer cancel()
+
+			limiter, err := ratelimit.NewTokenBucket("test", store, time.Millisecond*100, 2)
+			if err != nil {
+				t.Fatalf("creating limiter: %s", err.Error())
+			}
+
+			for i, want := range []bool{true, true, false} {
+				allowed, err := limiter.Allow(ctx, "a")
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err.Error())
+				}
+
+				if allowed != want {
+					t.Errorf("call %d: expecting %t, got %t", i, want, allowed)
+				}
+			}
+
+			// Other keys have their own bucket.
+			allowed, err := limiter.Allow(ctx, "b")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if !allowed {
+				t.Error("expecting another key to be allowed, got false")
+			}
+
+			time.Sleep(time.Millisecond * 120)
+
+			allowed, err = limiter.Allow(ctx, "a")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if !allowed {
+				t.Error("expecting a refilled token to be allowed, got false")
+			}
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+			defer cancel()
+
+			limiter, err := ratelimit.NewSlidingWindow("test", store, 3, time.Millisecond*200)
+			if err != nil {
+				t.Fatalf("creating limiter: %s", err.Error())
+			}
+
+			allowedCalls := 0
+			for range 5 {
+				allowed, err := limiter.Allow(ctx, "a")
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err.Error())
+				}
+
+				if allowed {
+					allowedCalls++
+				}
+			}
+
+			if allowedCalls != 3 {
+				t.Errorf("expecting 3 allowed calls, got %d", allowedCalls)
+			}
+
+			// Two windows later, the previous calls no longer count.
+			time.Sleep(time.Millisecond * 400)
+
+			allowed, err := limiter.Allow(ctx, "a")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if !allowed {
+				t.Error("expecting a call after the window to be allowed, got false")
+			}
+		})
+	}
+}
+
+func TestConcurrentAllow(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+			defer cancel()
+
+			limiter, err := ratelimit.NewTokenBucket("test", store, time.Hour, 10)
+			if err != nil {
+				t.Fatalf("creating limiter: %s", err.Error())
+			}
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			allowedCalls := 0
+			for range 30 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					allowed, err := limiter.Allow(ctx, "a")
+					if err != nil {
+						t.Errorf("unexpected error: %s", err.Error())
+						return
+					}
+
+					if allowed {
+						mu.Lock()
+						allowedCalls++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if allowedCalls != 10 {
+				t.Errorf("expecting 10 allowed calls, got %d", allowedCalls)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	store := ratelimit.NewMemoryStore(5, time.Millisecond*20)
+	defer store.Close()
+
+	limiter, err := ratelimit.NewTokenBucket("test", store, time.Millisecond*10, 1)
+	if err != nil {
+		t.Fatalf("creating limiter: %s", err.Error())
+	}
+
+	for i := range 20 {
+		_, err := limiter.Allow(ctx, strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	if store.Len() > 5 {
+		t.Errorf("expecting at most 5 keys, got %d", store.Len())
+	}
+
+	time.Sleep(time.Millisecond * 100)
+
+	if store.Len() != 0 {
+		t.Errorf("expecting the expired keys to be swept, got %d keys", store.Len())
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	store := ratelimit.NewMemoryStore(0, 0)
+	defer store.Close()
+
+	_, err := ratelimit.NewTokenBucket("test", nil, time.Second, 1)
+	if err == nil || err.Error() != "store is nil" {
+		t.Errorf("expecting an error of 'store is nil', got %v", err)
+	}
+
+	_, err = ratelimit.NewTokenBucket("test", store, 0, 1)
+	if err == nil {
+		t.Error("expecting an error for a zero interval, got nil")
+	}
+
+	_, err = ratelimit.NewSlidingWindow("test", store, 0, time.Second)
+	if err == nil {
+		t.Error("expecting an error for a zero limit, got nil")
+	}
+
+	_, err = ratelimit.NewRedisStore(nil)
+	if err == nil || err.Error() != "nil client" {
+		t.Errorf("expecting an error of 'nil client', got %v", err)
+	}
+}
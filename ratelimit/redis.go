@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisUpdateAttempts is how many times an update is retried when another
+// replica changes the same key in the middle of it.
+const redisUpdateAttempts = 10
+
+// RedisStore keeps the state on Redis, so the limits are shared by every replica
+// of the bot. Updates are made atomic with optimistic transactions. Updates of the
+// same key from the same process are serialized beforehand, otherwise a burst of
+// calls would keep failing each other's transaction.
+type RedisStore struct {
+	client *redis.Client
+	locks  [64]sync.Mutex
+}
+
+var _ Store = (*RedisStore)(nil)
+
+func NewRedisStore(client *redis.Client) (*RedisStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("nil client")
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+func (r *RedisStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	lock := &r.locks[hash.Sum32()%uint32(len(r.locks))]
+	lock.Lock()
+	defer lock.Unlock()
+
+	for range redisUpdateAttempts {
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			state, err := tx.Get(ctx, key).Bytes()
+			if err != nil {
+				if !errors.Is(err, redis.Nil) {
+					return fmt.Errorf("getting state: %w", err)
+				}
+
+				state = nil
+			}
+
+			next, ttl, err := fn(state)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, next, ttl)
+				return nil
+			})
+			return err
+		}, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("updating %s: too much contention", key)
+}
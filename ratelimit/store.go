@@ -0,0 +1,192 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/teknologi-umum/captcha/cache"
+)
+
+// UpdateFunc receives the state of a key, or nil if it has none, and returns the
+// next state along with how long it must be kept. It might be called more than
+// once for a single update, so it must not have side effects.
+type UpdateFunc func(state []byte) (next []byte, ttl time.Duration, err error)
+
+// Store keeps the state of the limiters.
+type Store interface {
+	// Update replaces the state of the key with the result of fn, atomically.
+	Update(ctx context.Context, key string, fn UpdateFunc) error
+}
+
+const (
+	// DefaultMaxKeys is how many keys a MemoryStore keeps when none is given.
+	DefaultMaxKeys = 10_000
+	// DefaultSweepInterval is how often a MemoryStore removes the expired keys
+	// when no interval is given.
+	DefaultSweepInterval = time.Minute
+)
+
+// MemoryStore keeps the state in the process, up to a fixed amount of keys.
+// The expired keys are removed by a single sweeping goroutine.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	maxKeys int
+	done    chan struct{}
+	closed  sync.Once
+}
+
+type memoryEntry struct {
+	state     []byte
+	expiresAt time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a MemoryStore and starts its sweeping goroutine, which
+// runs until Close is called. Non-positive values fall back to DefaultMaxKeys and
+// DefaultSweepInterval.
+func NewMemoryStore(maxKeys int, sweepInterval time.Duration) *MemoryStore {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+
+	if sweepInterval <= 0 {
+		sweepInterval = DefaultSweepInterval
+	}
+
+	m := &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		maxKeys: maxKeys,
+		done:    make(chan struct{}),
+	}
+
+	go m.sweepEvery(sweepInterval)
+
+	return m
+}
+
+func (m *MemoryStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	var state []byte
+	entry, ok := m.entries[key]
+	if ok && entry.expiresAt.After(now) {
+		state = entry.state
+	}
+
+	next, ttl, err := fn(state)
+	if err != nil {
+		return err
+	}
+
+	if !ok && len(m.entries) >= m.maxKeys {
+		m.sweep(now)
+	}
+
+	// Still full, so forget an arbitrary key. It only means that key gets
+	// a fresh limit, which is better than growing without a bound.
+	if !ok && len(m.entries) >= m.maxKeys {
+		for evicted := range m.entries {
+			delete(m.entries, evicted)
+			break
+		}
+	}
+
+	m.entries[key] = memoryEntry{state: next, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// Len returns how many keys are kept, including the expired ones that have
+// not been swept yet.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.entries)
+}
+
+// Close stops the sweeping goroutine.
+func (m *MemoryStore) Close() error {
+	m.closed.Do(func() {
+		close(m.done)
+	})
+
+	return nil
+}
+
+func (m *MemoryStore) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			m.sweep(now)
+			m.mu.Unlock()
+		}
+	}
+}
+
+// sweep removes the expired keys. The caller must hold the lock.
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, entry := range m.entries {
+		if !entry.expiresAt.After(now) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// CacheStore keeps the state on a cache.Cache, such as the bigcache shared by the
+// features. Updates are only atomic within the process, and the entries live as
+// long as the cache keeps them. That is fine since an old state computes the same
+// result as no state at all.
+type CacheStore struct {
+	mu    sync.Mutex
+	cache cache.Cache
+}
+
+var _ Store = (*CacheStore)(nil)
+
+func NewCacheStore(memory cache.Cache) (*CacheStore, error) {
+	if memory == nil {
+		return nil, fmt.Errorf("memory is nil")
+	}
+
+	return &CacheStore{cache: memory}, nil
+}
+
+func (c *CacheStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, err := c.cache.Get(key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			return fmt.Errorf("getting state: %w", err)
+		}
+
+		state = nil
+	}
+
+	next, _, err := fn(state)
+	if err != nil {
+		return err
+	}
+
+	err = c.cache.Set(key, next)
+	if err != nil {
+		return fmt.Errorf("setting state: %w", err)
+	}
+
+	return nil
+}
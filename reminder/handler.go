@@ -155,6 +155,8 @@ func (d *Dependency) Handler(ctx context.Context, c tb.Context) error {
 		_, err := c.Bot().Send(ctx, c.Chat(), template, &tb.SendOptions{ParseMode: tb.ModeHTML, AllowWithoutReply: true})
 		if err != nil {
 			sentry.GetHubFromContext(ctx).CaptureException(err)
+		} else {
+			firedTotal.Inc()
 		}
 
 		err = d.DecrementUserLimit(ctx, c.Sender().ID)
@@ -163,6 +165,8 @@ func (d *Dependency) Handler(ctx context.Context, c tb.Context) error {
 		}
 	}(c, reminder)
 
+	scheduledTotal.Inc()
+
 	err = d.IncrementUserLimit(ctx, c.Sender().ID)
 	if err != nil {
 		sentry.GetHubFromContext(ctx).CaptureException(err)
@@ -0,0 +1,8 @@
+package reminder
+
+import "github.com/teknologi-umum/captcha/metrics"
+
+var (
+	scheduledTotal = metrics.NewCounter("reminder_scheduled_total", "Reminders created with /remind.")
+	firedTotal     = metrics.NewCounter("reminder_fired_total", "Reminders that were sent.")
+)
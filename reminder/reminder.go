@@ -4,14 +4,14 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/allegro/bigcache/v3"
+	"github.com/teknologi-umum/captcha/cache"
 )
 
 type Dependency struct {
-	memory *bigcache.BigCache
+	memory cache.Cache
 }
 
-func New(memory *bigcache.BigCache) (*Dependency, error) {
+func New(memory cache.Cache) (*Dependency, error) {
 	if memory == nil {
 		return nil, fmt.Errorf("memory is nil")
 	}
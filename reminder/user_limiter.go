@@ -6,8 +6,8 @@ import (
 	"fmt"
 	"strconv"
 
-	"github.com/allegro/bigcache/v3"
 	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/cache"
 )
 
 func (d *Dependency) CheckUserLimit(ctx context.Context, id int64) (n int, err error) {
@@ -15,7 +15,7 @@ func (d *Dependency) CheckUserLimit(ctx context.Context, id int64) (n int, err e
 	defer span.Finish()
 
 	value, err := d.memory.Get(fmt.Sprintf("reminder:user_limit:%d", id))
-	if err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
 		return 0, fmt.Errorf("acquiring value from memory: %w", err)
 	}
 
@@ -31,7 +31,7 @@ func (d *Dependency) IncrementUserLimit(ctx context.Context, id int64) error {
 	defer span.Finish()
 
 	value, err := d.memory.Get(fmt.Sprintf("reminder:user_limit:%d", id))
-	if err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
 		return fmt.Errorf("acquiring value from memory: %w", err)
 	}
 
@@ -57,7 +57,7 @@ func (d *Dependency) DecrementUserLimit(ctx context.Context, id int64) error {
 	defer span.Finish()
 
 	value, err := d.memory.Get(fmt.Sprintf("reminder:user_limit:%d", id))
-	if err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
 		return fmt.Errorf("acquiring value from memory: %w", err)
 	}
 
@@ -21,11 +21,18 @@ func HandleError(ctx context.Context, e error) {
 	} else {
 		sentry.CaptureException(errors.WithStack(e))
 	}
+
+	if notifier := errorNotifier.Load(); notifier != nil {
+		notifier.Notify(ctx, e, nil)
+	}
 }
 
 // HandleBotError is the handler for an error which a function has a
 // bot and a message instance.
 //
+// The error is reported to the admin chat of the ErrorNotifier, if one is set.
+// The group itself only hears about it when the notifier is configured to.
+//
 // For other errors that don't have one of those struct instance, use
 // HandleError instead.
 func HandleBotError(ctx context.Context, e error, bot tb.API, m *tb.Message) {
@@ -33,6 +40,11 @@ func HandleBotError(ctx context.Context, e error, bot tb.API, m *tb.Message) {
 		return
 	}
 
+	if notifier := errorNotifier.Load(); notifier != nil {
+		notifier.Notify(ctx, e, m.Chat)
+		notifier.NotifyGroup(ctx, bot, m.Chat)
+	}
+
 	hub := sentry.GetHubFromContext(ctx)
 	if hub == nil {
 		sentry.CaptureException(errors.WithStack(e))
@@ -52,17 +64,6 @@ func HandleBotError(ctx context.Context, e error, bot tb.API, m *tb.Message) {
 	})
 
 	hub.CaptureException(errors.WithStack(e))
-
-	_, err := bot.Send(
-		ctx,
-		m.Chat,
-		"Oh no, something went wrong with me! Can you guys help me to ping my masters?",
-		&tb.SendOptions{ParseMode: tb.ModeHTML},
-	)
-	if err != nil {
-		// Come on? Another error?
-		hub.CaptureException(errors.WithStack(err))
-	}
 }
 
 // HandleHttpError handles error that has a http.Request struct instance
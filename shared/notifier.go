@@ -0,0 +1,272 @@
+package shared
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+	"github.com/teknologi-umum/captcha/internal/requestid"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+)
+
+type handlerKeyType string
+
+const handlerKey handlerKeyType = "handler"
+
+// SetHandlerOnContext records the name of the handler that processes the update,
+// so error notifications can tell where an error came from.
+func SetHandlerOnContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, handlerKey, name)
+}
+
+// GetHandlerFromContext returns the handler name set by SetHandlerOnContext.
+func GetHandlerFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	name, _ := ctx.Value(handlerKey).(string)
+	return name
+}
+
+// ErrorNotifierConfig configures an ErrorNotifier.
+type ErrorNotifierConfig struct {
+	// ChatID is the admin or log chat that receives the error notifications.
+	// Zero disables the notifications, leaving the errors to Sentry.
+	ChatID int64
+
+	// Cooldown is how long errors with the same fingerprint are suppressed
+	// after one of them was notified. Defaults to 10 minutes.
+	Cooldown time.Duration
+
+	// PerMinute is how many notifications are sent per minute, regardless
+	// of their fingerprint. Defaults to 10.
+	PerMinute int
+
+	// GroupMessage sends a short message into the group where the error
+	// happened, asking the members to ping the maintainers.
+	GroupMessage bool
+
+	// GroupCooldown is how long a group is spared from another error message
+	// after one was sent into it. Defaults to 30 minutes.
+	GroupCooldown time.Duration
+}
+
+// ErrorNotifier routes error notifications to an admin or log chat instead
+// of the group the error happened in. Notifications are deduplicated by the
+// fingerprint of the error and throttled, so an outage does not flood the chat.
+type ErrorNotifier struct {
+	bot    tb.API
+	config ErrorNotifierConfig
+
+	mu           sync.Mutex
+	fingerprints map[string]*fingerprintState
+	groups       map[int64]time.Time
+	window       time.Time
+	sentInWindow int
+	// wg tracks the notifications that are still being sent.
+	wg sync.WaitGroup
+}
+
+type fingerprintState struct {
+	notifiedAt time.Time
+	suppressed int
+}
+
+// maxTrackedFingerprints bounds the memory used for deduplication. Older entries
+// are pruned once it is reached.
+const maxTrackedFingerprints = 1000
+
+// NewErrorNotifier creates an ErrorNotifier that sends its notifications with bot.
+func NewErrorNotifier(bot tb.API, config ErrorNotifierConfig) (*ErrorNotifier, error) {
+	if bot == nil {
+		return nil, fmt.Errorf("bot is nil")
+	}
+
+	if config.Cooldown <= 0 {
+		config.Cooldown = time.Minute * 10
+	}
+
+	if config.PerMinute <= 0 {
+		config.PerMinute = 10
+	}
+
+	if config.GroupCooldown <= 0 {
+		config.GroupCooldown = time.Minute * 30
+	}
+
+	return &ErrorNotifier{
+		bot:          bot,
+		config:       config,
+		fingerprints: make(map[string]*fingerprintState),
+		groups:       make(map[int64]time.Time),
+	}, nil
+}
+
+var errorNotifier atomic.Pointer[ErrorNotifier]
+
+// SetErrorNotifier sets the notifier used by HandleError and HandleBotError.
+// Without one, errors are only reported to Sentry.
+func SetErrorNotifier(n *ErrorNotifier) {
+	errorNotifier.Store(n)
+}
+
+// Notify sends a notification about e to the admin chat, unless an error with
+// the same fingerprint was notified recently. chat is the chat the error happened
+// in, and may be nil.
+func (n *ErrorNotifier) Notify(ctx context.Context, e error, chat *tb.Chat) {
+	if e == nil || n.config.ChatID == 0 {
+		return
+	}
+
+	handler := GetHandlerFromContext(ctx)
+	fingerprint := Fingerprint(handler, e)
+
+	suppressed, ok := n.allow(fingerprint)
+	if !ok {
+		return
+	}
+
+	text := "⚠️ <b>Error</b> <code>" + fingerprint + "</code>\n"
+	if handler != "" {
+		text += "Handler: <code>" + html.EscapeString(handler) + "</code>\n"
+	}
+
+	if chat != nil {
+		text += "Chat: " + html.EscapeString(chat.Title) + " (<code>" + strconv.FormatInt(chat.ID, 10) + "</code>)\n"
+	}
+
+	if requestID := requestid.GetRequestIdFromContext(ctx); requestID != "" {
+		text += "Request ID: <code>" + requestID + "</code>\n"
+	}
+
+	text += "\n<pre>" + html.EscapeString(e.Error()) + "</pre>"
+	if suppressed > 0 {
+		text += "\n\n" + strconv.Itoa(suppressed) + " similar errors were suppressed since the last notification."
+	}
+
+	n.send(ctx, n.bot, &tb.Chat{ID: n.config.ChatID}, text, &tb.SendOptions{ParseMode: tb.ModeHTML, DisableWebPagePreview: true})
+}
+
+// NotifyGroup sends the error message into the group where the error happened
+// with bot, if it is enabled and the group has not received one recently.
+func (n *ErrorNotifier) NotifyGroup(ctx context.Context, bot tb.API, chat *tb.Chat) {
+	if !n.config.GroupMessage || bot == nil || chat == nil {
+		return
+	}
+
+	n.mu.Lock()
+	now := time.Now()
+	if sentAt, ok := n.groups[chat.ID]; ok && now.Sub(sentAt) < n.config.GroupCooldown {
+		n.mu.Unlock()
+		return
+	}
+
+	if len(n.groups) >= maxTrackedFingerprints {
+		for id, sentAt := range n.groups {
+			if now.Sub(sentAt) >= n.config.GroupCooldown {
+				delete(n.groups, id)
+			}
+		}
+	}
+
+	n.groups[chat.ID] = now
+	n.mu.Unlock()
+
+	n.send(ctx, bot, chat, "Oh no, something went wrong with me! Can you guys help me to ping my masters?", &tb.SendOptions{ParseMode: tb.ModeHTML})
+}
+
+// Wait blocks until the notifications that are being sent are done.
+func (n *ErrorNotifier) Wait() {
+	n.wg.Wait()
+}
+
+// allow decides whether the fingerprint is notified now, and returns how many
+// notifications of it were suppressed in the meantime.
+func (n *ErrorNotifier) allow(fingerprint string) (int, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	state, ok := n.fingerprints[fingerprint]
+	if ok && now.Sub(state.notifiedAt) < n.config.Cooldown {
+		state.suppressed++
+		return 0, false
+	}
+
+	if now.Sub(n.window) >= time.Minute {
+		n.window = now
+		n.sentInWindow = 0
+	}
+
+	if n.sentInWindow >= n.config.PerMinute {
+		if ok {
+			state.suppressed++
+		}
+		return 0, false
+	}
+
+	n.sentInWindow++
+
+	if !ok {
+		if len(n.fingerprints) >= maxTrackedFingerprints {
+			for key, state := range n.fingerprints {
+				if now.Sub(state.notifiedAt) >= n.config.Cooldown {
+					delete(n.fingerprints, key)
+				}
+			}
+		}
+
+		state = &fingerprintState{}
+		n.fingerprints[fingerprint] = state
+	}
+
+	suppressed := state.suppressed
+	state.notifiedAt = now
+	state.suppressed = 0
+	return suppressed, true
+}
+
+// send sends the notification in the background, so a slow Telegram does not
+// hold up the handler that ran into the error.
+func (n *ErrorNotifier) send(ctx context.Context, bot tb.API, to tb.Recipient, text string, opts *tb.SendOptions) {
+	ctx = tb.WithPriority(context.WithoutCancel(ctx), tb.PriorityLow)
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+
+		_, err := bot.Send(ctx, to, text, opts)
+		if err != nil {
+			// Reporting it through HandleError would notify about itself.
+			slog.WarnContext(ctx, "Failed to send error notification", slog.String("error", err.Error()), slog.String("recipient", to.Recipient()))
+			if hub := sentry.GetHubFromContext(ctx); hub != nil {
+				hub.CaptureException(errors.WithStack(err))
+			} else {
+				sentry.CaptureException(errors.WithStack(err))
+			}
+		}
+	}()
+}
+
+var digits = regexp.MustCompile(`\d+`)
+
+// Fingerprint identifies similar errors of a handler. Numbers are ignored, so the
+// same failure on different chats, users or messages shares a fingerprint.
+func Fingerprint(handler string, e error) string {
+	sum := sha1.Sum([]byte(handler + "\x00" + digits.ReplaceAllString(e.Error(), "#")))
+	return hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,177 @@
+package shared_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/captcha/internal/requestid"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+type sentMessage struct {
+	chat string
+	text string
+}
+
+// fakeBot records the messages sent through it. Every other method of tb.API
+// panics, since the notifier is not supposed to call them.
+type fakeBot struct {
+	tb.API
+
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (f *fakeBot) Send(ctx context.Context, to tb.Recipient, what interface{}, opts ...interface{}) (*tb.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, sentMessage{chat: to.Recipient(), text: what.(string)})
+	return &tb.Message{}, nil
+}
+
+func (f *fakeBot) messages() []sentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]sentMessage(nil), f.sent...)
+}
+
+func TestErrorNotifierDeduplicates(t *testing.T) {
+	bot := &fakeBot{}
+	notifier, err := shared.NewErrorNotifier(bot, shared.ErrorNotifierConfig{ChatID: -1001, Cooldown: time.Millisecond * 100})
+	if err != nil {
+		t.Fatalf("creating notifier: %s", err.Error())
+	}
+
+	ctx := shared.SetHandlerOnContext(requestid.SetRequestIdOnContext(context.Background()), "OnUserJoinHandler")
+	chat := &tb.Chat{ID: -100123, Title: "Teknologi Umum"}
+
+	// The errors only differ by the user ID, so they share a fingerprint.
+	for _, e := range []error{errors.New("banning user 1: timeout"), errors.New("banning user 2: timeout"), errors.New("banning user 3: timeout")} {
+		notifier.Notify(ctx, e, chat)
+	}
+	notifier.Wait()
+
+	sent := bot.messages()
+	if len(sent) != 1 {
+		t.Fatalf("expecting 1 notification, got %d", len(sent))
+	}
+
+	if sent[0].chat != "-1001" {
+		t.Errorf("expecting the notification to be sent to -1001, got %s", sent[0].chat)
+	}
+
+	for _, want := range []string{"OnUserJoinHandler", "Teknologi Umum", "-100123", requestid.GetRequestIdFromContext(ctx), "banning user 1: timeout"} {
+		if !strings.Contains(sent[0].text, want) {
+			t.Errorf("expecting the notification to contain %q, got %q", want, sent[0].text)
+		}
+	}
+
+	time.Sleep(time.Millisecond * 150)
+
+	notifier.Notify(ctx, errors.New("banning user 4: timeout"), chat)
+	notifier.Wait()
+
+	sent = bot.messages()
+	if len(sent) != 2 {
+		t.Fatalf("expecting 2 notifications, got %d", len(sent))
+	}
+
+	if !strings.Contains(sent[1].text, "2 similar errors were suppressed") {
+		t.Errorf("expecting the suppressed errors to be counted, got %q", sent[1].text)
+	}
+}
+
+func TestErrorNotifierThrottles(t *testing.T) {
+	bot := &fakeBot{}
+	notifier, err := shared.NewErrorNotifier(bot, shared.ErrorNotifierConfig{ChatID: -1001, PerMinute: 2})
+	if err != nil {
+		t.Fatalf("creating notifier: %s", err.Error())
+	}
+
+	for _, e := range []error{errors.New("a"), errors.New("b"), errors.New("c")} {
+		notifier.Notify(context.Background(), e, nil)
+	}
+	notifier.Wait()
+
+	if len(bot.messages()) != 2 {
+		t.Errorf("expecting 2 notifications, got %d", len(bot.messages()))
+	}
+}
+
+func TestErrorNotifierGroupMessage(t *testing.T) {
+	chat := &tb.Chat{ID: -100123}
+
+	bot := &fakeBot{}
+	notifier, err := shared.NewErrorNotifier(bot, shared.ErrorNotifierConfig{})
+	if err != nil {
+		t.Fatalf("creating notifier: %s", err.Error())
+	}
+
+	notifier.Notify(context.Background(), errors.New("a"), chat)
+	notifier.NotifyGroup(context.Background(), bot, chat)
+	notifier.Wait()
+
+	if len(bot.messages()) != 0 {
+		t.Errorf("expecting no messages without an admin chat and group messages, got %d", len(bot.messages()))
+	}
+
+	notifier, err = shared.NewErrorNotifier(bot, shared.ErrorNotifierConfig{GroupMessage: true})
+	if err != nil {
+		t.Fatalf("creating notifier: %s", err.Error())
+	}
+
+	notifier.NotifyGroup(context.Background(), bot, chat)
+	notifier.NotifyGroup(context.Background(), bot, chat)
+	notifier.NotifyGroup(context.Background(), bot, &tb.Chat{ID: -100456})
+	notifier.Wait()
+
+	if len(bot.messages()) != 2 {
+		t.Errorf("expecting a single message for each group, got %d", len(bot.messages()))
+	}
+}
+
+func TestHandleBotError(t *testing.T) {
+	bot := &fakeBot{}
+	notifier, err := shared.NewErrorNotifier(bot, shared.ErrorNotifierConfig{ChatID: -1001})
+	if err != nil {
+		t.Fatalf("creating notifier: %s", err.Error())
+	}
+
+	shared.SetErrorNotifier(notifier)
+	defer shared.SetErrorNotifier(nil)
+
+	shared.HandleBotError(context.Background(), errors.New("something broke"), bot, &tb.Message{
+		Chat:   &tb.Chat{ID: -100123},
+		Sender: &tb.User{ID: 1},
+	})
+	notifier.Wait()
+
+	sent := bot.messages()
+	if len(sent) != 1 || sent[0].chat != "-1001" {
+		t.Errorf("expecting a single notification to the admin chat, got %v", sent)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := shared.Fingerprint("OnTextHandler", errors.New("deleting message 123 in -100456"))
+	b := shared.Fingerprint("OnTextHandler", errors.New("deleting message 789 in -100111"))
+	if a != b {
+		t.Errorf("expecting errors that only differ by numbers to share a fingerprint, got %s and %s", a, b)
+	}
+
+	c := shared.Fingerprint("OnUserJoinHandler", errors.New("deleting message 123 in -100456"))
+	if a == c {
+		t.Error("expecting errors of different handlers to have different fingerprints")
+	}
+
+	if _, err := shared.NewErrorNotifier(nil, shared.ErrorNotifierConfig{}); err == nil || err.Error() != "bot is nil" {
+		t.Errorf("expecting an error of 'bot is nil', got %v", err)
+	}
+}
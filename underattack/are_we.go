@@ -4,12 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"strconv"
 	"time"
 
 	"github.com/getsentry/sentry-go"
-
-	"github.com/allegro/bigcache/v3"
+	"github.com/teknologi-umum/captcha/cache"
 )
 
 // AreWe ...on under attack mode?
@@ -18,19 +16,21 @@ func (d *Dependency) AreWe(ctx context.Context, chatID int64) (bool, error) {
 	ctx = span.Context()
 	defer span.Finish()
 
-	underAttackCache, err := d.Memory.Get("UnderAttack:" + strconv.FormatInt(chatID, 10))
-	if err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+	underAttackCache, err := d.Memory.Get(underAttackCacheKey(chatID))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
 		return false, err
 	}
 
 	if err == nil {
-		var entry UnderAttack
+		var entry cachedEntry
 		err := json.Unmarshal(underAttackCache, &entry)
 		if err != nil {
 			return false, err
 		}
 
-		return entry.IsUnderAttack && entry.ExpiresAt.After(time.Now()), nil
+		if !d.stale(entry) {
+			return entry.IsUnderAttack && entry.ExpiresAt.After(time.Now()), nil
+		}
 	}
 
 	underAttackEntry, err := d.Datastore.GetUnderAttackEntry(ctx, chatID)
@@ -38,12 +38,12 @@ func (d *Dependency) AreWe(ctx context.Context, chatID int64) (bool, error) {
 		return false, err
 	}
 
-	marshaledEntry, err := json.Marshal(underAttackEntry)
+	marshaledEntry, err := json.Marshal(cachedEntry{UnderAttack: underAttackEntry, CachedAt: time.Now()})
 	if err != nil {
 		return false, err
 	}
 
-	err = d.Memory.Set("UnderAttack:"+strconv.FormatInt(chatID, 10), marshaledEntry)
+	err = d.Memory.Set(underAttackCacheKey(chatID), marshaledEntry)
 	if err != nil {
 		return false, err
 	}
@@ -4,13 +4,15 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/teknologi-umum/captcha/underattack"
 )
 
 func TestAreWe(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
-	err := dependency.Datastore.SetUnderAttackStatus(ctx, 1, true, time.Now().Add(time.Hour), 0)
+	err := dependency.Datastore.SetUnderAttackStatus(ctx, 1, true, time.Now().Add(time.Hour), 0, underattack.TriggerManual)
 	if err != nil {
 		t.Fatalf("setting under attack status: %s", err.Error())
 	}
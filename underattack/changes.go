@@ -0,0 +1,74 @@
+package underattack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/teknologi-umum/captcha/cache"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+// DefaultPollInterval is how long a cached under attack entry is trusted while
+// the ChangeListener is disconnected, when Dependency.PollInterval is not set.
+const DefaultPollInterval = time.Second * 15
+
+// ChangeListener reports the groups whose under attack entry was changed by any
+// replica of the bot, so the cached entries can be dropped.
+type ChangeListener interface {
+	// Changes delivers the ID of every group whose under attack entry was changed.
+	// It is closed once the listener is closed.
+	Changes() <-chan int64
+	// ListeningSince returns when the listener last connected, or the zero time
+	// if it is disconnected. Changes made before it might have been missed.
+	ListeningSince() time.Time
+}
+
+// cachedEntry is the under attack entry as kept on the cache. The entry is embedded
+// so the entries cached before CachedAt existed can still be read.
+type cachedEntry struct {
+	UnderAttack
+	CachedAt time.Time `json:"cached_at"`
+}
+
+func underAttackCacheKey(groupID int64) string {
+	return "UnderAttack:" + strconv.FormatInt(groupID, 10)
+}
+
+// stale checks whether the cached entry might have missed a change. Without a
+// ChangeListener, the cached entries are always trusted.
+func (d *Dependency) stale(entry cachedEntry) bool {
+	if d.Changes == nil {
+		return false
+	}
+
+	listeningSince := d.Changes.ListeningSince()
+	if listeningSince.IsZero() {
+		pollInterval := d.PollInterval
+		if pollInterval <= 0 {
+			pollInterval = DefaultPollInterval
+		}
+
+		return time.Since(entry.CachedAt) > pollInterval
+	}
+
+	return entry.CachedAt.Before(listeningSince)
+}
+
+// ListenForChanges drops the cached entry of every group reported by the
+// ChangeListener. It blocks until the listener is closed, and returns right
+// away if there is none.
+func (d *Dependency) ListenForChanges(ctx context.Context) {
+	if d.Changes == nil {
+		return
+	}
+
+	for groupID := range d.Changes.Changes() {
+		err := d.Memory.Delete(underAttackCacheKey(groupID))
+		if err != nil && !errors.Is(err, cache.ErrNotFound) {
+			shared.HandleError(ctx, fmt.Errorf("deleting under attack cache: %w", err))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package underattack_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/teknologi-umum/captcha/underattack"
+	"github.com/teknologi-umum/captcha/underattack/datastore"
+)
+
+type fakeChangeListener struct {
+	changes chan int64
+
+	mu             sync.Mutex
+	listeningSince time.Time
+}
+
+func (f *fakeChangeListener) Changes() <-chan int64 {
+	return f.changes
+}
+
+func (f *fakeChangeListener) ListeningSince() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.listeningSince
+}
+
+func (f *fakeChangeListener) setListeningSince(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.listeningSince = t
+}
+
+func TestListenForChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	memory, err := bigcache.New(ctx, bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("creating bigcache: %s", err.Error())
+	}
+
+	memoryDatastore, err := datastore.NewInMemoryDatastore(memory)
+	if err != nil {
+		t.Fatalf("creating in memory datastore: %s", err.Error())
+	}
+
+	listener := &fakeChangeListener{changes: make(chan int64), listeningSince: time.Now()}
+	dependency := &underattack.Dependency{
+		Memory:       memory,
+		Datastore:    memoryDatastore,
+		Changes:      listener,
+		PollInterval: time.Millisecond * 50,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		dependency.ListenForChanges(ctx)
+		close(done)
+	}()
+
+	const groupID = 41
+
+	setStatus := func(underAttack bool) {
+		err := memoryDatastore.SetUnderAttackStatus(ctx, groupID, underAttack, time.Now().Add(time.Hour), 0, underattack.TriggerManual)
+		if err != nil {
+			t.Fatalf("setting under attack status: %s", err.Error())
+		}
+	}
+
+	expect := func(want bool) {
+		t.Helper()
+
+		attacked, err := dependency.AreWe(ctx, groupID)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if attacked != want {
+			t.Errorf("expecting under attack to be %t, got %t", want, attacked)
+		}
+	}
+
+	setStatus(true)
+	expect(true)
+
+	// Another replica disables it, the cached entry is kept until we are notified.
+	setStatus(false)
+	expect(true)
+
+	listener.changes <- groupID
+	// Once the next change is received, the previous one has been handled.
+	listener.changes <- 0
+	expect(false)
+
+	// While disconnected, the cached entry is only trusted for the poll interval.
+	listener.setListeningSince(time.Time{})
+	setStatus(true)
+	expect(false)
+
+	time.Sleep(time.Millisecond * 100)
+	expect(true)
+
+	// Changes might have been missed before reconnecting.
+	setStatus(false)
+	listener.setListeningSince(time.Now().Add(time.Millisecond))
+	time.Sleep(time.Millisecond * 5)
+	expect(false)
+
+	close(listener.changes)
+	<-done
+}
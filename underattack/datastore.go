@@ -2,13 +2,56 @@ package underattack
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
 )
 
+// ErrIncidentNotFound is returned when the incident does not exist on the group.
+var ErrIncidentNotFound = errors.New("incident not found")
+
 type Datastore interface {
 	Migrate(ctx context.Context) error
 	GetUnderAttackEntry(ctx context.Context, groupID int64) (UnderAttack, error)
 	CreateNewEntry(ctx context.Context, groupID int64) error
-	SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64) error
+	SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64, triggeredBy Trigger) error
+	// ListExpiredEntries returns the entries that have expired by now, but are still
+	// marked as under attack, or still have locked permissions, banned users or
+	// restricted users.
+	ListExpiredEntries(ctx context.Context, now time.Time) ([]UnderAttack, error)
+	ListSchedules(ctx context.Context) ([]Schedule, error)
+	GetSchedules(ctx context.Context, groupID int64) ([]Schedule, error)
+	AddSchedule(ctx context.Context, schedule Schedule) error
+	RemoveSchedule(ctx context.Context, schedule Schedule) error
+	// ClaimScheduledWindow marks the occurrence of a scheduled window that starts at
+	// start as applied until end. It returns false if the occurrence was claimed already,
+	// which is done atomically so only one replica applies it.
+	ClaimScheduledWindow(ctx context.Context, groupID int64, start time.Time, end time.Time) (bool, error)
+	GetStrategy(ctx context.Context, groupID int64) (Strategy, error)
+	SetStrategy(ctx context.Context, groupID int64, strategy Strategy) error
+	// SaveLockedPermissions keeps the permissions until they are taken, it does nothing
+	// if the permissions of the group are already kept.
+	SaveLockedPermissions(ctx context.Context, groupID int64, permissions tb.Rights) error
+	TakeLockedPermissions(ctx context.Context, groupID int64) (tb.Rights, bool, error)
+	AddBannedUser(ctx context.Context, groupID int64, userID int64) error
+	TakeBannedUsers(ctx context.Context, groupID int64) ([]int64, error)
+	// AddRestrictedUser records a user who joined while the group was locked,
+	// so they can be removed when the under attack mode is over.
+	AddRestrictedUser(ctx context.Context, groupID int64, userID int64) error
+	TakeRestrictedUsers(ctx context.Context, groupID int64) ([]int64, error)
+	StartIncident(ctx context.Context, incident Incident) (Incident, error)
+	EndIncident(ctx context.Context, groupID int64, endedAt time.Time) error
+	RecordIncidentBan(ctx context.Context, groupID int64, ban IncidentBan) error
+	ListIncidents(ctx context.Context, groupID int64, limit int) ([]Incident, error)
+	GetIncident(ctx context.Context, groupID int64, incidentID int64) (Incident, error)
+	ListIncidentBans(ctx context.Context, incidentID int64) ([]IncidentBan, error)
+	MarkUnbanned(ctx context.Context, incidentID int64, userIDs []int64, unbannedAt time.Time) error
+	AddInviteLink(ctx context.Context, link InviteLink) error
+	ListInviteLinks(ctx context.Context, groupID int64, limit int) ([]InviteLink, error)
+	MarkInviteLinkRevoked(ctx context.Context, groupID int64, inviteLink string, revokedAt time.Time) error
+	AddPass(ctx context.Context, pass Pass) error
+	ListPasses(ctx context.Context, groupID int64, now time.Time) ([]Pass, error)
+	RemovePass(ctx context.Context, pass Pass) error
 	Close() error
 }
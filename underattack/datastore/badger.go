@@ -0,0 +1,791 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+const (
+	// badgerSchemaVersion is bumped whenever the key layout changes,
+	// so Migrate knows which keys to rewrite.
+	badgerSchemaVersion = 1
+
+	// entryRetention is how long an under attack entry is kept after it expires.
+	// It must outlive the under attack mode, since the entry is read once it is over.
+	entryRetention = time.Hour * 24 * 7
+	// incidentRetention is how long an incident and its bans are kept.
+	incidentRetention = time.Hour * 24 * 90
+)
+
+// badgerDatastore keeps every record of the under attack domain as JSON values
+// under the "underattack:" prefix, so it can share the badger DB with the
+// captcha state.
+type badgerDatastore struct {
+	db *badger.DB
+}
+
+func NewBadgerDatastore(db *badger.DB) (underattack.Datastore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("nil db")
+	}
+
+	return &badgerDatastore{db: db}, nil
+}
+
+func badgerKey(parts ...string) []byte {
+	key := "underattack"
+	for _, part := range parts {
+		key += ":" + part
+	}
+
+	return []byte(key)
+}
+
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// formatSequence pads the ID so the keys are sorted by the ID.
+func formatSequence(id int64) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+// getJSON decodes the value of the key into v. It returns false if the key does not exist.
+func getJSON(txn *badger.Txn, key []byte, v any) (bool, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	err = item.Value(func(value []byte) error {
+		return json.Unmarshal(value, v)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setJSON encodes v as the value of the key. A zero ttl keeps the key forever.
+func setJSON(txn *badger.Txn, key []byte, v any, ttl time.Duration) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	entry := badger.NewEntry(key, value)
+	if ttl > 0 {
+		entry = entry.WithTTL(ttl)
+	}
+
+	return txn.SetEntry(entry)
+}
+
+// eachJSON decodes every value under the prefix, in the order of the keys, or in the
+// reverse order if reverse is true. Iteration stops when fn returns false.
+func eachJSON[T any](txn *badger.Txn, prefix []byte, reverse bool, fn func(key []byte, value T) (bool, error)) error {
+	options := badger.DefaultIteratorOptions
+	options.Prefix = prefix
+	options.Reverse = reverse
+
+	iterator := txn.NewIterator(options)
+	defer iterator.Close()
+
+	seek := prefix
+	if reverse {
+		seek = append(bytes.Clone(prefix), 0xff)
+	}
+
+	for iterator.Seek(seek); iterator.ValidForPrefix(prefix); iterator.Next() {
+		var value T
+		err := iterator.Item().Value(func(raw []byte) error {
+			return json.Unmarshal(raw, &value)
+		})
+		if err != nil {
+			return err
+		}
+
+		next, err := fn(iterator.Item().KeyCopy(nil), value)
+		if err != nil {
+			return err
+		}
+
+		if !next {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Migrate records the schema version of the key layout. There is nothing to
+// rewrite yet, since this is the first version.
+func (b *badgerDatastore) Migrate(ctx context.Context) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.migrate")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		var version int
+		_, err := getJSON(txn, badgerKey("schema_version"), &version)
+		if err != nil {
+			return err
+		}
+
+		if version > badgerSchemaVersion {
+			return fmt.Errorf("badger schema version %d is newer than %d", version, badgerSchemaVersion)
+		}
+
+		return setJSON(txn, badgerKey("schema_version"), badgerSchemaVersion, 0)
+	})
+}
+
+// GetUnderAttackEntry will acquire under attack entry for specified groupID.
+// It returns an empty entry if the group has none.
+func (b *badgerDatastore) GetUnderAttackEntry(ctx context.Context, groupID int64) (underattack.UnderAttack, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.get_under_attack_entry")
+	defer span.Finish()
+
+	var entry underattack.UnderAttack
+	err := b.db.View(func(txn *badger.Txn) error {
+		_, err := getJSON(txn, badgerKey("entry", formatID(groupID)), &entry)
+		return err
+	})
+	if err != nil {
+		return underattack.UnderAttack{}, err
+	}
+
+	return entry, nil
+}
+
+// CreateNewEntry will create a new entry for given groupID.
+// It does nothing if the entry already exists.
+func (b *badgerDatastore) CreateNewEntry(ctx context.Context, groupID int64) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.create_new_entry")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		var entry underattack.UnderAttack
+		ok, err := getJSON(txn, badgerKey("entry", formatID(groupID)), &entry)
+		if err != nil || ok {
+			return err
+		}
+
+		return setJSON(txn, badgerKey("entry", formatID(groupID)), underattack.UnderAttack{
+			GroupID:     groupID,
+			TriggeredBy: underattack.TriggerManual,
+			UpdatedAt:   time.Now(),
+		}, entryRetention)
+	})
+}
+
+// SetUnderAttackStatus will update the given groupID entry to the given parameters.
+// The entry is kept until a while after it expires.
+func (b *badgerDatastore) SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64, triggeredBy underattack.Trigger) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.set_under_attack_status")
+	defer span.Finish()
+
+	ttl := entryRetention
+	if remaining := time.Until(expiresAt); remaining > 0 {
+		ttl += remaining
+	}
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, badgerKey("entry", formatID(groupID)), underattack.UnderAttack{
+			GroupID:               groupID,
+			IsUnderAttack:         underAttack,
+			NotificationMessageID: notificationMessageID,
+			ExpiresAt:             expiresAt,
+			TriggeredBy:           triggeredBy,
+			UpdatedAt:             time.Now(),
+		}, ttl)
+	})
+}
+
+// ListExpiredEntries will acquire the entries that have expired by now, but are still
+// marked as under attack, or still have locked permissions, banned or restricted users.
+func (b *badgerDatastore) ListExpiredEntries(ctx context.Context, now time.Time) ([]underattack.UnderAttack, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.list_expired_entries")
+	defer span.Finish()
+
+	var entries []underattack.UnderAttack
+	err := b.db.View(func(txn *badger.Txn) error {
+		return eachJSON(txn, badgerKey("entry", ""), false, func(_ []byte, entry underattack.UnderAttack) (bool, error) {
+			if entry.ExpiresAt.After(now) {
+				return true, nil
+			}
+
+			pending := entry.IsUnderAttack
+			if !pending {
+				_, err := txn.Get(badgerKey("locked_permissions", formatID(entry.GroupID)))
+				if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+					return false, err
+				}
+
+				pending = err == nil
+			}
+
+			if !pending {
+				pending = hasPrefix(txn, badgerKey("banned_user", formatID(entry.GroupID), ""))
+			}
+
+			if !pending {
+				pending = hasPrefix(txn, badgerKey("restricted_user", formatID(entry.GroupID), ""))
+			}
+
+			if pending {
+				entries = append(entries, entry)
+			}
+
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// hasPrefix tells whether there is any key under the prefix.
+func hasPrefix(txn *badger.Txn, prefix []byte) bool {
+	options := badger.DefaultIteratorOptions
+	options.Prefix = prefix
+	options.PrefetchValues = false
+
+	iterator := txn.NewIterator(options)
+	defer iterator.Close()
+
+	iterator.Seek(prefix)
+	return iterator.ValidForPrefix(prefix)
+}
+
+func (b *badgerDatastore) listSchedules(prefix []byte) ([]underattack.Schedule, error) {
+	var schedules []underattack.Schedule
+	err := b.db.View(func(txn *badger.Txn) error {
+		return eachJSON(txn, prefix, false, func(_ []byte, schedule underattack.Schedule) (bool, error) {
+			schedules = append(schedules, schedule)
+			return true, nil
+		})
+	})
+	return schedules, err
+}
+
+// ListSchedules will acquire the scheduled windows of every group.
+func (b *badgerDatastore) ListSchedules(ctx context.Context) ([]underattack.Schedule, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.list_schedules")
+	defer span.Finish()
+
+	return b.listSchedules(badgerKey("schedule", ""))
+}
+
+// GetSchedules will acquire the scheduled windows of the groupID.
+func (b *badgerDatastore) GetSchedules(ctx context.Context, groupID int64) ([]underattack.Schedule, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.get_schedules")
+	defer span.Finish()
+
+	return b.listSchedules(badgerKey("schedule", formatID(groupID), ""))
+}
+
+func scheduleKey(schedule underattack.Schedule) []byte {
+	return badgerKey("schedule", formatID(schedule.GroupID), strconv.Itoa(schedule.StartMinute), strconv.Itoa(schedule.EndMinute))
+}
+
+// AddSchedule will add a scheduled window. Adding an existing window does nothing.
+func (b *badgerDatastore) AddSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.add_schedule")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, scheduleKey(schedule), schedule, 0)
+	})
+}
+
+// RemoveSchedule will remove a scheduled window.
+func (b *badgerDatastore) RemoveSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.remove_schedule")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(scheduleKey(schedule))
+	})
+}
+
+// ClaimScheduledWindow will mark the occurrence of a scheduled window as applied until end.
+// It returns false if the occurrence was claimed already.
+func (b *badgerDatastore) ClaimScheduledWindow(ctx context.Context, groupID int64, start time.Time, end time.Time) (bool, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.claim_scheduled_window")
+	defer span.Finish()
+
+	key := badgerKey("applied_schedule", formatID(groupID), strconv.FormatInt(start.Unix(), 10))
+
+	var claimed bool
+	err := b.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		if err == nil || !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		claimed = true
+		return setJSON(txn, key, end, max(time.Until(end), time.Second))
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return claimed, nil
+}
+
+// GetStrategy will acquire the chosen strategy for specified groupID.
+// It returns an empty strategy if the group has not chosen one.
+func (b *badgerDatastore) GetStrategy(ctx context.Context, groupID int64) (underattack.Strategy, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.get_strategy")
+	defer span.Finish()
+
+	var strategy underattack.Strategy
+	err := b.db.View(func(txn *badger.Txn) error {
+		_, err := getJSON(txn, badgerKey("strategy", formatID(groupID)), &strategy)
+		return err
+	})
+	return strategy, err
+}
+
+// SetStrategy will set the strategy for specified groupID.
+func (b *badgerDatastore) SetStrategy(ctx context.Context, groupID int64, strategy underattack.Strategy) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.set_strategy")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, badgerKey("strategy", formatID(groupID)), strategy, 0)
+	})
+}
+
+// SaveLockedPermissions will keep the group permissions before they were locked.
+// It does nothing if the permissions of the groupID are already kept.
+func (b *badgerDatastore) SaveLockedPermissions(ctx context.Context, groupID int64, permissions tb.Rights) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.save_locked_permissions")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(badgerKey("locked_permissions", formatID(groupID)))
+		if err == nil || !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		return setJSON(txn, badgerKey("locked_permissions", formatID(groupID)), permissions, 0)
+	})
+}
+
+// TakeLockedPermissions will acquire and forget the permissions kept by SaveLockedPermissions.
+// It returns false if there are no permissions kept for the groupID.
+func (b *badgerDatastore) TakeLockedPermissions(ctx context.Context, groupID int64) (tb.Rights, bool, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.take_locked_permissions")
+	defer span.Finish()
+
+	var permissions tb.Rights
+	var ok bool
+	err := b.db.Update(func(txn *badger.Txn) error {
+		var err error
+		ok, err = getJSON(txn, badgerKey("locked_permissions", formatID(groupID)), &permissions)
+		if err != nil || !ok {
+			return err
+		}
+
+		return txn.Delete(badgerKey("locked_permissions", formatID(groupID)))
+	})
+	if err != nil {
+		return tb.Rights{}, false, err
+	}
+
+	return permissions, ok, nil
+}
+
+// AddBannedUser will record a user that is banned until the under attack mode is over.
+func (b *badgerDatastore) AddBannedUser(ctx context.Context, groupID int64, userID int64) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.add_banned_user")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, badgerKey("banned_user", formatID(groupID), formatID(userID)), userID, 0)
+	})
+}
+
+// TakeBannedUsers will acquire and forget the users recorded by AddBannedUser.
+func (b *badgerDatastore) TakeBannedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.take_banned_users")
+	defer span.Finish()
+
+	return b.takeUsers(badgerKey("banned_user", formatID(groupID), ""))
+}
+
+// AddRestrictedUser will record a user who joined while the group was locked.
+func (b *badgerDatastore) AddRestrictedUser(ctx context.Context, groupID int64, userID int64) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.add_restricted_user")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, badgerKey("restricted_user", formatID(groupID), formatID(userID)), userID, 0)
+	})
+}
+
+// TakeRestrictedUsers will acquire and forget the users recorded by AddRestrictedUser.
+func (b *badgerDatastore) TakeRestrictedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.take_restricted_users")
+	defer span.Finish()
+
+	return b.takeUsers(badgerKey("restricted_user", formatID(groupID), ""))
+}
+
+// takeUsers acquires and deletes every user ID under the prefix.
+func (b *badgerDatastore) takeUsers(prefix []byte) ([]int64, error) {
+	var userIDs []int64
+	err := b.db.Update(func(txn *badger.Txn) error {
+		userIDs = nil
+
+		var keys [][]byte
+		err := eachJSON(txn, prefix, false, func(key []byte, userID int64) (bool, error) {
+			userIDs = append(userIDs, userID)
+			keys = append(keys, key)
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			err := txn.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+func incidentKey(groupID int64, incidentID int64) []byte {
+	return badgerKey("incident", formatID(groupID), formatSequence(incidentID))
+}
+
+func incidentBanKey(incidentID int64, userID int64) []byte {
+	return badgerKey("incident_ban", formatSequence(incidentID), formatID(userID))
+}
+
+// StartIncident will record a new incident, and returns it with its ID.
+func (b *badgerDatastore) StartIncident(ctx context.Context, incident underattack.Incident) (underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.start_incident")
+	defer span.Finish()
+
+	err := b.db.Update(func(txn *badger.Txn) error {
+		var sequence int64
+		_, err := getJSON(txn, badgerKey("incident_sequence"), &sequence)
+		if err != nil {
+			return err
+		}
+
+		sequence++
+		err = setJSON(txn, badgerKey("incident_sequence"), sequence, 0)
+		if err != nil {
+			return err
+		}
+
+		incident.ID = sequence
+		incident.EndedAt = time.Time{}
+		incident.BannedCount = 0
+		return setJSON(txn, incidentKey(incident.GroupID, incident.ID), incident, incidentRetention)
+	})
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	return incident, nil
+}
+
+// EndIncident will end every ongoing incident of the groupID. An incident never
+// ends after it expires.
+func (b *badgerDatastore) EndIncident(ctx context.Context, groupID int64, endedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.end_incident")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		var ongoing []underattack.Incident
+		err := eachJSON(txn, badgerKey("incident", formatID(groupID), ""), false, func(_ []byte, incident underattack.Incident) (bool, error) {
+			if incident.EndedAt.IsZero() {
+				ongoing = append(ongoing, incident)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, incident := range ongoing {
+			incident.EndedAt = endedAt
+			if incident.ExpiresAt.Before(endedAt) {
+				incident.EndedAt = incident.ExpiresAt
+			}
+
+			err := setJSON(txn, incidentKey(groupID, incident.ID), incident, incidentRetention)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RecordIncidentBan will record a banned user into the latest ongoing incident of the groupID.
+// It does nothing if there is no ongoing incident, or if the user is already recorded.
+func (b *badgerDatastore) RecordIncidentBan(ctx context.Context, groupID int64, ban underattack.IncidentBan) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.record_incident_ban")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		var incident underattack.Incident
+		var found bool
+		err := eachJSON(txn, badgerKey("incident", formatID(groupID), ""), true, func(_ []byte, value underattack.Incident) (bool, error) {
+			if value.EndedAt.IsZero() {
+				incident = value
+				found = true
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil || !found {
+			return err
+		}
+
+		var existing underattack.IncidentBan
+		ok, err := getJSON(txn, incidentBanKey(incident.ID, ban.UserID), &existing)
+		if err != nil || ok {
+			return err
+		}
+
+		ban.IncidentID = incident.ID
+		err = setJSON(txn, incidentBanKey(incident.ID, ban.UserID), ban, incidentRetention)
+		if err != nil {
+			return err
+		}
+
+		incident.BannedCount++
+		return setJSON(txn, incidentKey(groupID, incident.ID), incident, incidentRetention)
+	})
+}
+
+// ListIncidents will acquire the latest incidents of the groupID, newest first.
+func (b *badgerDatastore) ListIncidents(ctx context.Context, groupID int64, limit int) ([]underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.list_incidents")
+	defer span.Finish()
+
+	var incidents []underattack.Incident
+	err := b.db.View(func(txn *badger.Txn) error {
+		return eachJSON(txn, badgerKey("incident", formatID(groupID), ""), true, func(_ []byte, incident underattack.Incident) (bool, error) {
+			incidents = append(incidents, incident)
+			return len(incidents) < limit, nil
+		})
+	})
+	return incidents, err
+}
+
+// GetIncident will acquire a single incident of the groupID.
+func (b *badgerDatastore) GetIncident(ctx context.Context, groupID int64, incidentID int64) (underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.get_incident")
+	defer span.Finish()
+
+	var incident underattack.Incident
+	var ok bool
+	err := b.db.View(func(txn *badger.Txn) error {
+		var err error
+		ok, err = getJSON(txn, incidentKey(groupID, incidentID), &incident)
+		return err
+	})
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	if !ok {
+		return underattack.Incident{}, underattack.ErrIncidentNotFound
+	}
+
+	return incident, nil
+}
+
+// ListIncidentBans will acquire the users banned during the incident.
+func (b *badgerDatastore) ListIncidentBans(ctx context.Context, incidentID int64) ([]underattack.IncidentBan, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.list_incident_bans")
+	defer span.Finish()
+
+	var bans []underattack.IncidentBan
+	err := b.db.View(func(txn *badger.Txn) error {
+		return eachJSON(txn, badgerKey("incident_ban", formatSequence(incidentID), ""), false, func(_ []byte, ban underattack.IncidentBan) (bool, error) {
+			bans = append(bans, ban)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(bans, func(a, b underattack.IncidentBan) int {
+		return a.BannedAt.Compare(b.BannedAt)
+	})
+	return bans, nil
+}
+
+// MarkUnbanned will mark the given users of the incident as unbanned.
+func (b *badgerDatastore) MarkUnbanned(ctx context.Context, incidentID int64, userIDs []int64, unbannedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.mark_unbanned")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		for _, userID := range userIDs {
+			var ban underattack.IncidentBan
+			ok, err := getJSON(txn, incidentBanKey(incidentID, userID), &ban)
+			if err != nil {
+				return err
+			}
+
+			if !ok || !ban.UnbannedAt.IsZero() {
+				continue
+			}
+
+			ban.UnbannedAt = unbannedAt
+			err = setJSON(txn, incidentBanKey(incidentID, userID), ban, incidentRetention)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AddInviteLink will record an invite link of the group. Recording an existing
+// link replaces it.
+func (b *badgerDatastore) AddInviteLink(ctx context.Context, link underattack.InviteLink) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.add_invite_link")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, badgerKey("invite_link", formatID(link.GroupID), link.InviteLink), link, 0)
+	})
+}
+
+// ListInviteLinks will acquire the recorded invite links of the group, newest first.
+func (b *badgerDatastore) ListInviteLinks(ctx context.Context, groupID int64, limit int) ([]underattack.InviteLink, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.list_invite_links")
+	defer span.Finish()
+
+	var links []underattack.InviteLink
+	err := b.db.View(func(txn *badger.Txn) error {
+		return eachJSON(txn, badgerKey("invite_link", formatID(groupID), ""), false, func(_ []byte, link underattack.InviteLink) (bool, error) {
+			links = append(links, link)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(links, func(a, b underattack.InviteLink) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if len(links) > limit {
+		links = links[:limit]
+	}
+
+	return links, nil
+}
+
+// MarkInviteLinkRevoked will mark the invite link of the group as revoked.
+func (b *badgerDatastore) MarkInviteLinkRevoked(ctx context.Context, groupID int64, inviteLink string, revokedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.mark_invite_link_revoked")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		key := badgerKey("invite_link", formatID(groupID), inviteLink)
+
+		var link underattack.InviteLink
+		ok, err := getJSON(txn, key, &link)
+		if err != nil || !ok || !link.RevokedAt.IsZero() {
+			return err
+		}
+
+		link.RevokedAt = revokedAt
+		return setJSON(txn, key, link, 0)
+	})
+}
+
+func passKey(pass underattack.Pass) []byte {
+	return badgerKey("pass", formatID(pass.GroupID), formatID(pass.UserID), pass.Username)
+}
+
+// AddPass will add a pass to join the group while it is under attack. The pass
+// is removed by badger once it expires.
+func (b *badgerDatastore) AddPass(ctx context.Context, pass underattack.Pass) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.add_pass")
+	defer span.Finish()
+
+	ttl := time.Until(pass.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, passKey(pass), pass, ttl)
+	})
+}
+
+// ListPasses will acquire the passes of the group that are not expired yet.
+func (b *badgerDatastore) ListPasses(ctx context.Context, groupID int64, now time.Time) ([]underattack.Pass, error) {
+	span := sentry.StartSpan(ctx, "badger_datastore.list_passes")
+	defer span.Finish()
+
+	var passes []underattack.Pass
+	err := b.db.View(func(txn *badger.Txn) error {
+		return eachJSON(txn, badgerKey("pass", formatID(groupID), ""), false, func(_ []byte, pass underattack.Pass) (bool, error) {
+			if pass.ExpiresAt.After(now) {
+				passes = append(passes, pass)
+			}
+			return true, nil
+		})
+	})
+	return passes, err
+}
+
+// RemovePass will remove a pass.
+func (b *badgerDatastore) RemovePass(ctx context.Context, pass underattack.Pass) error {
+	span := sentry.StartSpan(ctx, "badger_datastore.remove_pass")
+	defer span.Finish()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(passKey(pass))
+	})
+}
+
+// Close does nothing, the badger DB is shared with the captcha state
+// and is closed by its owner.
+func (b *badgerDatastore) Close() error {
+	return nil
+}
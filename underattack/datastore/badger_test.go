@@ -0,0 +1,81 @@
+package datastore_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/captcha/underattack"
+	"github.com/teknologi-umum/captcha/underattack/datastore"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestBadgerDatastore(t *testing.T) {
+	var dependency underattack.Datastore
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("opening badger: %s", err.Error())
+	}
+
+	dependency, err = datastore.NewBadgerDatastore(db)
+	if err != nil {
+		t.Fatalf("creating new badger datastore: %s", err.Error())
+	}
+
+	setupCtx, setupCancel := context.WithTimeout(context.Background(), time.Second*30)
+
+	err = dependency.Migrate(setupCtx)
+	if err != nil {
+		t.Fatalf("migrating tables: %s", err.Error())
+	}
+
+	err = SeedBadgerDatastore(setupCtx, db)
+	if err != nil {
+		t.Fatalf("seeding data: %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+		setupCancel()
+
+		err := dependency.Close()
+		if err != nil {
+			t.Logf("closing badger datastore: %s", err.Error())
+		}
+
+		err = db.Close()
+		if err != nil {
+			t.Logf("closing badger: %s", err.Error())
+		}
+	})
+
+	t.Run("NewBadgerDatastore", func(t *testing.T) {
+		t.Run("Nil DB", func(t *testing.T) {
+			_, err := datastore.NewBadgerDatastore(nil)
+			if err.Error() != "nil db" {
+				t.Errorf("expecting an error of 'nil db', instead got %s", err.Error())
+			}
+		})
+	})
+
+	testDatastore(t, dependency)
+}
+
+func SeedBadgerDatastore(ctx context.Context, db *badger.DB) error {
+	value, err := json.Marshal(underattack.UnderAttack{
+		GroupID:               1,
+		IsUnderAttack:         true,
+		NotificationMessageID: 1002,
+		ExpiresAt:             time.Now().Add(time.Hour),
+		UpdatedAt:             time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("underattack:entry:1"), value)
+	})
+}
@@ -1,10 +1,16 @@
 package datastore_test
 
 import (
+	"context"
+	"errors"
 	"os"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/underattack"
 )
 
 func TestMain(m *testing.M) {
@@ -12,3 +18,494 @@ func TestMain(m *testing.M) {
 
 	os.Exit(m.Run())
 }
+
+// testDatastore runs the cases every implementation of underattack.Datastore must
+// pass. The datastore is expected to be seeded with an entry of group 1 that is
+// under attack for another hour, with 1002 as the notification message.
+func testDatastore(t *testing.T, dependency underattack.Datastore) {
+	t.Run("Migrate", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		err := dependency.Migrate(ctx)
+		if err != nil {
+			t.Errorf("migrating database: %s", err.Error())
+		}
+	})
+
+	t.Run("GetUnderAttackEntry", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		entry, err := dependency.GetUnderAttackEntry(ctx, 1)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if entry.IsUnderAttack == false {
+			t.Error("expecting IsUnderAttack to be true, got false")
+		}
+
+		if entry.ExpiresAt.Before(time.Now()) {
+			t.Errorf("expecting ExpiresAt to be after now, got: %v", entry.ExpiresAt)
+		}
+
+		if entry.NotificationMessageID != 1002 {
+			t.Errorf("expecting NotificationMessageID to be 1002, got: %v", entry.NotificationMessageID)
+		}
+	})
+
+	t.Run("GetUnderAttackEntry_NotExists", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		_, err := dependency.GetUnderAttackEntry(ctx, 20)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("CreateNewEntry", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		err := dependency.CreateNewEntry(ctx, 2)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("SetUnderAttackStatus", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		err := dependency.SetUnderAttackStatus(ctx, 3, true, time.Now().Add(time.Minute*30), 1003, underattack.TriggerManual)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("ListExpiredEntries", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		now := time.Now()
+
+		// Group 13 is still marked as under attack, group 14 still has a banned user,
+		// group 16 still has a restricted user, and group 15 has been released already.
+		err := dependency.SetUnderAttackStatus(ctx, 13, true, now.Add(-time.Minute), 1013, underattack.TriggerManual)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = dependency.SetUnderAttackStatus(ctx, 14, false, now.Add(-time.Minute), 0, underattack.TriggerManual)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = dependency.AddBannedUser(ctx, 14, 400)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = dependency.SetUnderAttackStatus(ctx, 15, false, now.Add(-time.Minute), 0, underattack.TriggerManual)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = dependency.SetUnderAttackStatus(ctx, 16, false, now.Add(-time.Minute), 0, underattack.TriggerManual)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = dependency.AddRestrictedUser(ctx, 16, 401)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		entries, err := dependency.ListExpiredEntries(ctx, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var groupIDs []int64
+		for _, entry := range entries {
+			groupIDs = append(groupIDs, entry.GroupID)
+		}
+
+		slices.Sort(groupIDs)
+		if !slices.Equal(groupIDs, []int64{13, 14, 16}) {
+			t.Errorf("expecting groups 13, 14 and 16 to be expired, got %v", groupIDs)
+		}
+
+		_, err = dependency.TakeBannedUsers(ctx, 14)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err = dependency.TakeRestrictedUsers(ctx, 16)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Schedules", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		schedule := underattack.Schedule{GroupID: 4, StartMinute: 60, EndMinute: 360}
+		err := dependency.AddSchedule(ctx, schedule)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Adding the same window twice should not duplicate it
+		err = dependency.AddSchedule(ctx, schedule)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		schedules, err := dependency.GetSchedules(ctx, 4)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(schedules) != 1 || schedules[0] != schedule {
+			t.Errorf("expecting only %v, got %v", schedule, schedules)
+		}
+
+		allSchedules, err := dependency.ListSchedules(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(allSchedules) == 0 {
+			t.Error("expecting ListSchedules to return the schedule, got nothing")
+		}
+
+		err = dependency.RemoveSchedule(ctx, schedule)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		schedules, err = dependency.GetSchedules(ctx, 4)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(schedules) != 0 {
+			t.Errorf("expecting no schedule, got %v", schedules)
+		}
+	})
+
+	t.Run("ClaimScheduledWindow", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		start := time.Now().Truncate(time.Second)
+		end := start.Add(time.Hour)
+		claimed, err := dependency.ClaimScheduledWindow(ctx, 12, start, end)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !claimed {
+			t.Error("expecting the first claim to succeed")
+		}
+
+		claimed, err = dependency.ClaimScheduledWindow(ctx, 12, start, end)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if claimed {
+			t.Error("expecting the second claim of the same occurrence to fail")
+		}
+
+		// The next occurrence of the window is a different one.
+		claimed, err = dependency.ClaimScheduledWindow(ctx, 12, start.AddDate(0, 0, 1), end.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !claimed {
+			t.Error("expecting the claim of the next occurrence to succeed")
+		}
+	})
+
+	t.Run("Strategy", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		strategy, err := dependency.GetStrategy(ctx, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if strategy != "" {
+			t.Errorf("expecting an empty strategy, got %q", strategy)
+		}
+
+		err = dependency.SetStrategy(ctx, 5, underattack.StrategyKick)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		strategy, err = dependency.GetStrategy(ctx, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if strategy != underattack.StrategyKick {
+			t.Errorf("expecting %q, got %q", underattack.StrategyKick, strategy)
+		}
+	})
+
+	t.Run("LockedPermissions", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		err := dependency.SaveLockedPermissions(ctx, 6, tb.Rights{CanSendMessages: true, CanSendPhotos: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Locking again must not overwrite the permissions from before the first lock.
+		err = dependency.SaveLockedPermissions(ctx, 6, tb.Rights{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		permissions, ok, err := dependency.TakeLockedPermissions(ctx, 6)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !ok || !permissions.CanSendMessages || !permissions.CanSendPhotos || permissions.CanSendPolls {
+			t.Errorf("unexpected permissions: %t %+v", ok, permissions)
+		}
+
+		_, ok, err = dependency.TakeLockedPermissions(ctx, 6)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if ok {
+			t.Error("expecting the permissions to be taken only once")
+		}
+	})
+
+	t.Run("BannedUsers", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		for _, userID := range []int64{100, 101, 100} {
+			err := dependency.AddBannedUser(ctx, 7, userID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		userIDs, err := dependency.TakeBannedUsers(ctx, 7)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(userIDs) != 2 {
+			t.Errorf("expecting 2 banned users, got %v", userIDs)
+		}
+
+		userIDs, err = dependency.TakeBannedUsers(ctx, 7)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(userIDs) != 0 {
+			t.Errorf("expecting the banned users to be taken only once, got %v", userIDs)
+		}
+	})
+
+	t.Run("RestrictedUsers", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		for _, userID := range []int64{200, 201, 200} {
+			err := dependency.AddRestrictedUser(ctx, 17, userID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		userIDs, err := dependency.TakeRestrictedUsers(ctx, 17)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(userIDs) != 2 {
+			t.Errorf("expecting 2 restricted users, got %v", userIDs)
+		}
+
+		userIDs, err = dependency.TakeRestrictedUsers(ctx, 17)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(userIDs) != 0 {
+			t.Errorf("expecting the restricted users to be taken only once, got %v", userIDs)
+		}
+	})
+
+	t.Run("Incidents", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		startedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		incident, err := dependency.StartIncident(ctx, underattack.Incident{
+			GroupID:     8,
+			TriggeredBy: underattack.TriggerManual,
+			ActorID:     42,
+			Strategy:    underattack.StrategyBan,
+			StartedAt:   startedAt,
+			ExpiresAt:   startedAt.Add(time.Hour * 2),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, userID := range []int64{200, 201, 200} {
+			err := dependency.RecordIncidentBan(ctx, 8, underattack.IncidentBan{UserID: userID, FullName: "Spammer", BannedAt: time.Now()})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		err = dependency.EndIncident(ctx, 8, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Bans after the incident ended should not be recorded.
+		err = dependency.RecordIncidentBan(ctx, 8, underattack.IncidentBan{UserID: 202, BannedAt: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		incidents, err := dependency.ListIncidents(ctx, 8, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(incidents) != 1 {
+			t.Fatalf("expecting 1 incident, got %d", len(incidents))
+		}
+
+		if incidents[0].ID != incident.ID || incidents[0].ActorID != 42 || incidents[0].BannedCount != 2 || incidents[0].EndedAt.IsZero() {
+			t.Errorf("unexpected incident: %+v", incidents[0])
+		}
+
+		_, err = dependency.GetIncident(ctx, 9, incident.ID)
+		if !errors.Is(err, underattack.ErrIncidentNotFound) {
+			t.Errorf("expecting ErrIncidentNotFound for another group, got %v", err)
+		}
+
+		err = dependency.MarkUnbanned(ctx, incident.ID, []int64{201}, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		bans, err := dependency.ListIncidentBans(ctx, incident.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(bans) != 2 {
+			t.Fatalf("expecting 2 bans, got %d", len(bans))
+		}
+
+		for _, ban := range bans {
+			if (ban.UserID == 201) == ban.UnbannedAt.IsZero() {
+				t.Errorf("unexpected unbanned state for user %d: %v", ban.UserID, ban.UnbannedAt)
+			}
+		}
+	})
+
+	t.Run("InviteLinks", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		now := time.Now().Truncate(time.Second)
+		for i, link := range []underattack.InviteLink{
+			{GroupID: 10, InviteLink: "https://t.me/+old", CreatedAt: now.Add(-time.Minute * 2), RevokedAt: now},
+			{GroupID: 10, InviteLink: "https://t.me/+new", JoinRequest: true, CreatedAt: now.Add(-time.Minute)},
+			{GroupID: 11, InviteLink: "https://t.me/+other", CreatedAt: now},
+		} {
+			err := dependency.AddInviteLink(ctx, link)
+			if err != nil {
+				t.Fatalf("adding link #%d: %v", i, err)
+			}
+		}
+
+		links, err := dependency.ListInviteLinks(ctx, 10, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(links) != 2 || links[0].InviteLink != "https://t.me/+new" || !links[0].RevokedAt.IsZero() {
+			t.Fatalf("unexpected links: %+v", links)
+		}
+
+		err = dependency.MarkInviteLinkRevoked(ctx, 10, "https://t.me/+new", now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		links, err = dependency.ListInviteLinks(ctx, 10, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(links) != 1 || links[0].RevokedAt.IsZero() {
+			t.Errorf("expecting the newest link to be revoked, got %+v", links)
+		}
+	})
+
+	t.Run("Passes", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		now := time.Now().Truncate(time.Second)
+		for i, pass := range []underattack.Pass{
+			{GroupID: 12, UserID: 300, GrantedBy: 1, ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+			{GroupID: 12, Username: "friend", GrantedBy: 1, ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+			{GroupID: 12, UserID: 301, GrantedBy: 1, ExpiresAt: now.Add(-time.Minute), CreatedAt: now},
+		} {
+			err := dependency.AddPass(ctx, pass)
+			if err != nil {
+				t.Fatalf("adding pass #%d: %v", i, err)
+			}
+		}
+
+		passes, err := dependency.ListPasses(ctx, 12, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(passes) != 2 {
+			t.Fatalf("expecting 2 passes that are not expired, got %+v", passes)
+		}
+
+		err = dependency.RemovePass(ctx, underattack.Pass{GroupID: 12, Username: "friend"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		passes, err = dependency.ListPasses(ctx, 12, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(passes) != 1 || passes[0].UserID != 300 {
+			t.Errorf("expecting only the pass of user 300 left, got %+v", passes)
+		}
+	})
+}
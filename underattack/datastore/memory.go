@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
 	"github.com/teknologi-umum/captcha/underattack"
 
 	"github.com/allegro/bigcache/v3"
@@ -16,6 +19,30 @@ import (
 
 type memoryDatastore struct {
 	db *bigcache.BigCache
+
+	// Everything other than the under attack entries are kept on plain maps
+	// instead of the cache, since they must not be evicted.
+	mu sync.RWMutex
+	// entryGroups are the groups that have an entry, so the expired
+	// entries can be found without going through the whole cache.
+	entryGroups       map[int64]struct{}
+	schedules         map[int64][]underattack.Schedule
+	strategies        map[int64]underattack.Strategy
+	lockedPermissions map[int64]tb.Rights
+	bannedUsers       map[int64][]int64
+	restrictedUsers   map[int64][]int64
+	incidents         []underattack.Incident
+	incidentBans      map[int64][]underattack.IncidentBan
+	inviteLinks       map[int64][]underattack.InviteLink
+	passes            map[int64][]underattack.Pass
+	// appliedWindows maps the claimed occurrences of the scheduled windows
+	// to the time they end.
+	appliedWindows map[appliedWindow]time.Time
+}
+
+type appliedWindow struct {
+	groupID int64
+	start   int64
 }
 
 func NewInMemoryDatastore(db *bigcache.BigCache) (underattack.Datastore, error) {
@@ -23,7 +50,19 @@ func NewInMemoryDatastore(db *bigcache.BigCache) (underattack.Datastore, error)
 		return nil, fmt.Errorf("nil db")
 	}
 
-	return &memoryDatastore{db: db}, nil
+	return &memoryDatastore{
+		db:                db,
+		entryGroups:       make(map[int64]struct{}),
+		schedules:         make(map[int64][]underattack.Schedule),
+		strategies:        make(map[int64]underattack.Strategy),
+		lockedPermissions: make(map[int64]tb.Rights),
+		bannedUsers:       make(map[int64][]int64),
+		restrictedUsers:   make(map[int64][]int64),
+		incidentBans:      make(map[int64][]underattack.IncidentBan),
+		inviteLinks:       make(map[int64][]underattack.InviteLink),
+		passes:            make(map[int64][]underattack.Pass),
+		appliedWindows:    make(map[appliedWindow]time.Time),
+	}, nil
 }
 
 func (m *memoryDatastore) Migrate(ctx context.Context) error {
@@ -91,7 +130,7 @@ func (m *memoryDatastore) CreateNewEntry(ctx context.Context, groupID int64) err
 	return m.db.Set(strconv.FormatInt(groupID, 10), value)
 }
 
-func (m *memoryDatastore) SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64) error {
+func (m *memoryDatastore) SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64, triggeredBy underattack.Trigger) error {
 	span := sentry.StartSpan(ctx, "memory_datastore.set_under_attack_status")
 	defer span.Finish()
 
@@ -101,15 +140,375 @@ func (m *memoryDatastore) SetUnderAttackStatus(ctx context.Context, groupID int6
 		IsUnderAttack:         underAttack,
 		NotificationMessageID: notificationMessageID,
 		ExpiresAt:             expiresAt,
+		TriggeredBy:           triggeredBy,
 		UpdatedAt:             time.Now(),
 	})
 	if err != nil {
 		return err
 	}
 
+	m.mu.Lock()
+	m.entryGroups[groupID] = struct{}{}
+	m.mu.Unlock()
+
 	return m.db.Set(strconv.FormatInt(groupID, 10), value)
 }
 
+func (m *memoryDatastore) ListExpiredEntries(ctx context.Context, now time.Time) ([]underattack.UnderAttack, error) {
+	span := sentry.StartSpan(ctx, "memory_datastore.list_expired_entries")
+	defer span.Finish()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []underattack.UnderAttack
+	for groupID := range m.entryGroups {
+		value, err := m.db.Get(strconv.FormatInt(groupID, 10))
+		if err != nil {
+			if errors.Is(err, bigcache.ErrEntryNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		var entry underattack.UnderAttack
+		err = json.Unmarshal(value, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.ExpiresAt.After(now) {
+			continue
+		}
+
+		_, locked := m.lockedPermissions[groupID]
+		if entry.IsUnderAttack || locked || len(m.bannedUsers[groupID]) > 0 || len(m.restrictedUsers[groupID]) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (m *memoryDatastore) ListSchedules(ctx context.Context) ([]underattack.Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var schedules []underattack.Schedule
+	for _, groupSchedules := range m.schedules {
+		schedules = append(schedules, groupSchedules...)
+	}
+
+	return schedules, nil
+}
+
+func (m *memoryDatastore) GetSchedules(ctx context.Context, groupID int64) ([]underattack.Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.schedules[groupID]), nil
+}
+
+func (m *memoryDatastore) AddSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.Contains(m.schedules[schedule.GroupID], schedule) {
+		return nil
+	}
+
+	m.schedules[schedule.GroupID] = append(m.schedules[schedule.GroupID], schedule)
+	return nil
+}
+
+func (m *memoryDatastore) RemoveSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.schedules[schedule.GroupID] = slices.DeleteFunc(m.schedules[schedule.GroupID], func(s underattack.Schedule) bool {
+		return s == schedule
+	})
+	return nil
+}
+
+func (m *memoryDatastore) ClaimScheduledWindow(ctx context.Context, groupID int64, start time.Time, end time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for window, windowEnd := range m.appliedWindows {
+		if !windowEnd.After(start) {
+			delete(m.appliedWindows, window)
+		}
+	}
+
+	window := appliedWindow{groupID: groupID, start: start.Unix()}
+	if _, ok := m.appliedWindows[window]; ok {
+		return false, nil
+	}
+
+	m.appliedWindows[window] = end
+	return true, nil
+}
+
+func (m *memoryDatastore) GetStrategy(ctx context.Context, groupID int64) (underattack.Strategy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.strategies[groupID], nil
+}
+
+func (m *memoryDatastore) SetStrategy(ctx context.Context, groupID int64, strategy underattack.Strategy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.strategies[groupID] = strategy
+	return nil
+}
+
+func (m *memoryDatastore) SaveLockedPermissions(ctx context.Context, groupID int64, permissions tb.Rights) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lockedPermissions[groupID]; !ok {
+		m.lockedPermissions[groupID] = permissions
+	}
+
+	return nil
+}
+
+func (m *memoryDatastore) TakeLockedPermissions(ctx context.Context, groupID int64) (tb.Rights, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	permissions, ok := m.lockedPermissions[groupID]
+	delete(m.lockedPermissions, groupID)
+	return permissions, ok, nil
+}
+
+func (m *memoryDatastore) AddBannedUser(ctx context.Context, groupID int64, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.Contains(m.bannedUsers[groupID], userID) {
+		m.bannedUsers[groupID] = append(m.bannedUsers[groupID], userID)
+	}
+	return nil
+}
+
+func (m *memoryDatastore) TakeBannedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userIDs := m.bannedUsers[groupID]
+	delete(m.bannedUsers, groupID)
+	return userIDs, nil
+}
+
+func (m *memoryDatastore) AddRestrictedUser(ctx context.Context, groupID int64, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.Contains(m.restrictedUsers[groupID], userID) {
+		m.restrictedUsers[groupID] = append(m.restrictedUsers[groupID], userID)
+	}
+	return nil
+}
+
+func (m *memoryDatastore) TakeRestrictedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userIDs := m.restrictedUsers[groupID]
+	delete(m.restrictedUsers, groupID)
+	return userIDs, nil
+}
+
+func (m *memoryDatastore) StartIncident(ctx context.Context, incident underattack.Incident) (underattack.Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	incident.ID = int64(len(m.incidents) + 1)
+	incident.EndedAt = time.Time{}
+	incident.BannedCount = 0
+	m.incidents = append(m.incidents, incident)
+	return incident, nil
+}
+
+func (m *memoryDatastore) EndIncident(ctx context.Context, groupID int64, endedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.incidents {
+		incident := &m.incidents[i]
+		if incident.GroupID != groupID || !incident.EndedAt.IsZero() {
+			continue
+		}
+
+		incident.EndedAt = endedAt
+		if incident.ExpiresAt.Before(endedAt) {
+			incident.EndedAt = incident.ExpiresAt
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryDatastore) RecordIncidentBan(ctx context.Context, groupID int64, ban underattack.IncidentBan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Attach the ban into the latest ongoing incident of the group.
+	for i := len(m.incidents) - 1; i >= 0; i-- {
+		incident := &m.incidents[i]
+		if incident.GroupID != groupID || !incident.EndedAt.IsZero() {
+			continue
+		}
+
+		ban.IncidentID = incident.ID
+		for _, existing := range m.incidentBans[incident.ID] {
+			if existing.UserID == ban.UserID {
+				return nil
+			}
+		}
+
+		m.incidentBans[incident.ID] = append(m.incidentBans[incident.ID], ban)
+		incident.BannedCount++
+		return nil
+	}
+
+	return nil
+}
+
+func (m *memoryDatastore) ListIncidents(ctx context.Context, groupID int64, limit int) ([]underattack.Incident, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var incidents []underattack.Incident
+	for i := len(m.incidents) - 1; i >= 0 && len(incidents) < limit; i-- {
+		if m.incidents[i].GroupID == groupID {
+			incidents = append(incidents, m.incidents[i])
+		}
+	}
+
+	return incidents, nil
+}
+
+func (m *memoryDatastore) GetIncident(ctx context.Context, groupID int64, incidentID int64) (underattack.Incident, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, incident := range m.incidents {
+		if incident.ID == incidentID && incident.GroupID == groupID {
+			return incident, nil
+		}
+	}
+
+	return underattack.Incident{}, underattack.ErrIncidentNotFound
+}
+
+func (m *memoryDatastore) ListIncidentBans(ctx context.Context, incidentID int64) ([]underattack.IncidentBan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.incidentBans[incidentID]), nil
+}
+
+func (m *memoryDatastore) MarkUnbanned(ctx context.Context, incidentID int64, userIDs []int64, unbannedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.incidentBans[incidentID] {
+		ban := &m.incidentBans[incidentID][i]
+		if ban.UnbannedAt.IsZero() && slices.Contains(userIDs, ban.UserID) {
+			ban.UnbannedAt = unbannedAt
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryDatastore) AddInviteLink(ctx context.Context, link underattack.InviteLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.inviteLinks[link.GroupID] {
+		if existing.InviteLink == link.InviteLink {
+			m.inviteLinks[link.GroupID][i] = link
+			return nil
+		}
+	}
+
+	m.inviteLinks[link.GroupID] = append(m.inviteLinks[link.GroupID], link)
+	return nil
+}
+
+func (m *memoryDatastore) ListInviteLinks(ctx context.Context, groupID int64, limit int) ([]underattack.InviteLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := m.inviteLinks[groupID]
+	var result []underattack.InviteLink
+	for i := len(links) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, links[i])
+	}
+
+	return result, nil
+}
+
+func (m *memoryDatastore) MarkInviteLinkRevoked(ctx context.Context, groupID int64, inviteLink string, revokedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.inviteLinks[groupID] {
+		link := &m.inviteLinks[groupID][i]
+		if link.InviteLink == inviteLink && link.RevokedAt.IsZero() {
+			link.RevokedAt = revokedAt
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryDatastore) AddPass(ctx context.Context, pass underattack.Pass) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.passes[pass.GroupID] {
+		if existing.UserID == pass.UserID && existing.Username == pass.Username {
+			m.passes[pass.GroupID][i] = pass
+			return nil
+		}
+	}
+
+	m.passes[pass.GroupID] = append(m.passes[pass.GroupID], pass)
+	return nil
+}
+
+func (m *memoryDatastore) ListPasses(ctx context.Context, groupID int64, now time.Time) ([]underattack.Pass, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var passes []underattack.Pass
+	for _, pass := range m.passes[groupID] {
+		if pass.ExpiresAt.After(now) {
+			passes = append(passes, pass)
+		}
+	}
+
+	return passes, nil
+}
+
+func (m *memoryDatastore) RemovePass(ctx context.Context, pass underattack.Pass) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.passes[pass.GroupID] = slices.DeleteFunc(m.passes[pass.GroupID], func(existing underattack.Pass) bool {
+		return existing.UserID == pass.UserID && existing.Username == pass.Username
+	})
+	return nil
+}
+
 func (m *memoryDatastore) Close() error {
 	return m.db.Close()
 }
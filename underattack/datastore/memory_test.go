@@ -55,67 +55,7 @@ func TestNewInMemoryDatastore(t *testing.T) {
 		})
 	})
 
-	t.Run("Migrate", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		err := dependency.Migrate(ctx)
-		if err != nil {
-			t.Errorf("migrating database: %s", err.Error())
-		}
-	})
-
-	t.Run("GetUnderAttackEntry", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		entry, err := dependency.GetUnderAttackEntry(ctx, 1)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-
-		if entry.IsUnderAttack == false {
-			t.Error("expecting IsUnderAttack to be true, got false")
-		}
-
-		if entry.ExpiresAt.Before(time.Now()) {
-			t.Errorf("expecting ExpiresAt to be after now, got: %v", entry.ExpiresAt)
-		}
-
-		if entry.NotificationMessageID != 1002 {
-			t.Errorf("expecting NotificationMessageID to be 1002, got: %v", entry.NotificationMessageID)
-		}
-	})
-
-	t.Run("GetUnderAttackEntry_NotExists", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		_, err := dependency.GetUnderAttackEntry(ctx, 20)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-	})
-
-	t.Run("CreateNewEntry", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		err := dependency.CreateNewEntry(ctx, 2)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-	})
-
-	t.Run("SetUnderAttackStatus", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		err := dependency.SetUnderAttackStatus(ctx, 3, true, time.Now().Add(time.Minute*30), 1003)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-	})
+	testDatastore(t, dependency)
 }
 
 func SeedMemoryDatastore(ctx context.Context, db *bigcache.BigCache) error {
@@ -3,11 +3,15 @@ package datastore
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/lib/pq"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
 	"github.com/teknologi-umum/captcha/underattack"
 )
 
@@ -70,6 +74,193 @@ func (p *postgresDatastore) Migrate(ctx context.Context) error {
 		return err
 	}
 
+	_, err = tx.ExecContext(
+		ctx,
+		`ALTER TABLE under_attack ADD COLUMN IF NOT EXISTS triggered_by TEXT NOT NULL DEFAULT 'manual'`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`ALTER TABLE under_attack
+			ADD COLUMN IF NOT EXISTS strategy TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS locked_permissions TEXT NOT NULL DEFAULT ''`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_banned_user (
+			group_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			banned_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (group_id, user_id)
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_restricted_user (
+			group_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			restricted_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (group_id, user_id)
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_incident (
+			id BIGSERIAL PRIMARY KEY,
+			group_id BIGINT NOT NULL,
+			triggered_by TEXT NOT NULL,
+			actor_id BIGINT NOT NULL DEFAULT 0,
+			strategy TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP NULL
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE INDEX IF NOT EXISTS idx_under_attack_incident_group_id_started_at ON under_attack_incident (group_id, started_at)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_incident_ban (
+			incident_id BIGINT NOT NULL REFERENCES under_attack_incident (id) ON DELETE CASCADE,
+			user_id BIGINT NOT NULL,
+			full_name TEXT NOT NULL,
+			username TEXT NOT NULL,
+			banned_at TIMESTAMP NOT NULL,
+			unbanned_at TIMESTAMP NULL,
+			PRIMARY KEY (incident_id, user_id)
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_schedule (
+			group_id BIGINT NOT NULL,
+			start_minute INTEGER NOT NULL,
+			end_minute INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (group_id, start_minute, end_minute)
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_applied_schedule (
+			group_id BIGINT NOT NULL,
+			window_start TIMESTAMP NOT NULL,
+			window_end TIMESTAMP NOT NULL,
+			PRIMARY KEY (group_id, window_start)
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_invite_link (
+			group_id BIGINT NOT NULL,
+			invite_link TEXT NOT NULL,
+			join_request BOOLEAN NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP NULL,
+			PRIMARY KEY (group_id, invite_link)
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS under_attack_pass (
+			group_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			username TEXT NOT NULL,
+			granted_by BIGINT NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (group_id, user_id, username)
+		)`,
+	)
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return err
+		}
+
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		if e := tx.Rollback(); e != nil {
@@ -112,6 +303,7 @@ func (p *postgresDatastore) GetUnderAttackEntry(ctx context.Context, groupID int
     	is_under_attack,
     	expires_at,
     	notification_message_id,
+    	triggered_by,
     	updated_at
     FROM
         under_attack
@@ -125,6 +317,7 @@ func (p *postgresDatastore) GetUnderAttackEntry(ctx context.Context, groupID int
 		&entry.IsUnderAttack,
 		&entry.ExpiresAt,
 		&entry.NotificationMessageID,
+		&entry.TriggeredBy,
 		&entry.UpdatedAt,
 	)
 	if err != nil {
@@ -222,8 +415,9 @@ func (p *postgresDatastore) CreateNewEntry(ctx context.Context, groupID int64) e
 }
 
 // SetUnderAttackStatus will update the given groupID entry to the given parameters.
-// If the groupID entry does not exists, it will create a new one.
-func (p *postgresDatastore) SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64) error {
+// If the groupID entry does not exists, it will create a new one. Every process
+// listening on UnderAttackChangedChannel is notified of the change.
+func (p *postgresDatastore) SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64, triggeredBy underattack.Trigger) error {
 	span := sentry.StartSpan(ctx, "postgres_datastore.set_under_attack_status")
 	defer span.Finish()
 
@@ -247,20 +441,22 @@ func (p *postgresDatastore) SetUnderAttackStatus(ctx context.Context, groupID in
 		ctx,
 		`INSERT INTO
 			under_attack
-			(group_id, is_under_attack, expires_at, notification_message_id, updated_at)
+			(group_id, is_under_attack, expires_at, notification_message_id, triggered_by, updated_at)
 		VALUES
-			($1, $2, $3, $4, $5)
+			($1, $2, $3, $4, $5, $6)
 		ON CONFLICT (group_id)
 		DO UPDATE
 		SET
 			is_under_attack = $2,
 			expires_at = $3,
 			notification_message_id = $4,
-			updated_at = $5`,
+			triggered_by = $5,
+			updated_at = $6`,
 		groupID,
 		underAttack,
 		expiresAt,
 		notificationMessageID,
+		triggeredBy,
 		time.Now(),
 	)
 	if err != nil {
@@ -271,6 +467,16 @@ func (p *postgresDatastore) SetUnderAttackStatus(ctx context.Context, groupID in
 		return err
 	}
 
+	// Postgres only delivers the notification once the transaction is committed.
+	_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, UnderAttackChangedChannel, strconv.FormatInt(groupID, 10))
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			return e
+		}
+
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		if e := tx.Rollback(); e != nil {
@@ -283,6 +489,836 @@ func (p *postgresDatastore) SetUnderAttackStatus(ctx context.Context, groupID in
 	return nil
 }
 
+// ListExpiredEntries will acquire the entries that have expired by now, but are still
+// marked as under attack, or still have locked permissions, banned or restricted users.
+func (p *postgresDatastore) ListExpiredEntries(ctx context.Context, now time.Time) ([]underattack.UnderAttack, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.list_expired_entries")
+	defer span.Finish()
+
+	c, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := c.Close()
+		if err != nil && !errors.Is(err, sql.ErrConnDone) {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	rows, err := c.QueryContext(
+		ctx,
+		`SELECT
+			group_id,
+			is_under_attack,
+			expires_at,
+			notification_message_id,
+			triggered_by,
+			updated_at
+		FROM
+			under_attack
+		WHERE
+			expires_at <= $1
+			AND (
+				is_under_attack
+				OR locked_permissions <> ''
+				OR EXISTS (SELECT 1 FROM under_attack_banned_user WHERE under_attack_banned_user.group_id = under_attack.group_id)
+				OR EXISTS (SELECT 1 FROM under_attack_restricted_user WHERE under_attack_restricted_user.group_id = under_attack.group_id)
+			)`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	var entries []underattack.UnderAttack
+	for rows.Next() {
+		var entry underattack.UnderAttack
+		err := rows.Scan(
+			&entry.GroupID,
+			&entry.IsUnderAttack,
+			&entry.ExpiresAt,
+			&entry.NotificationMessageID,
+			&entry.TriggeredBy,
+			&entry.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// ListSchedules will acquire every scheduled window of every group.
+func (p *postgresDatastore) ListSchedules(ctx context.Context) ([]underattack.Schedule, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.list_schedules")
+	defer span.Finish()
+
+	return p.querySchedules(ctx, `SELECT group_id, start_minute, end_minute FROM under_attack_schedule ORDER BY group_id, start_minute`)
+}
+
+// GetSchedules will acquire the scheduled windows for specified groupID.
+func (p *postgresDatastore) GetSchedules(ctx context.Context, groupID int64) ([]underattack.Schedule, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.get_schedules")
+	defer span.Finish()
+
+	return p.querySchedules(ctx, `SELECT group_id, start_minute, end_minute FROM under_attack_schedule WHERE group_id = $1 ORDER BY start_minute`, groupID)
+}
+
+func (p *postgresDatastore) querySchedules(ctx context.Context, query string, args ...any) ([]underattack.Schedule, error) {
+	c, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := c.Close()
+		if err != nil && !errors.Is(err, sql.ErrConnDone) {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	var schedules []underattack.Schedule
+	for rows.Next() {
+		var schedule underattack.Schedule
+		err := rows.Scan(&schedule.GroupID, &schedule.StartMinute, &schedule.EndMinute)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// AddSchedule will add a scheduled window. Adding an existing window does nothing.
+func (p *postgresDatastore) AddSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.add_schedule")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack_schedule
+			(group_id, start_minute, end_minute, created_at)
+		VALUES
+			($1, $2, $3, $4)
+		ON CONFLICT (group_id, start_minute, end_minute)
+		DO NOTHING`,
+		schedule.GroupID,
+		schedule.StartMinute,
+		schedule.EndMinute,
+		time.Now(),
+	)
+	return err
+}
+
+// RemoveSchedule will remove a scheduled window.
+func (p *postgresDatastore) RemoveSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.remove_schedule")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`DELETE FROM under_attack_schedule WHERE group_id = $1 AND start_minute = $2 AND end_minute = $3`,
+		schedule.GroupID,
+		schedule.StartMinute,
+		schedule.EndMinute,
+	)
+	return err
+}
+
+// ClaimScheduledWindow will mark the occurrence of a scheduled window as applied until end.
+// It returns false if the occurrence was claimed already.
+func (p *postgresDatastore) ClaimScheduledWindow(ctx context.Context, groupID int64, start time.Time, end time.Time) (bool, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.claim_scheduled_window")
+	defer span.Finish()
+
+	// The occurrences that ended before this one started are no longer needed.
+	_, err := p.db.ExecContext(
+		ctx,
+		`DELETE FROM under_attack_applied_schedule WHERE group_id = $1 AND window_end <= $2`,
+		groupID,
+		start,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack_applied_schedule
+			(group_id, window_start, window_end)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (group_id, window_start)
+		DO NOTHING`,
+		groupID,
+		start,
+		end,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// GetStrategy will acquire the chosen strategy for specified groupID.
+// It returns an empty strategy if the group has not chosen one.
+func (p *postgresDatastore) GetStrategy(ctx context.Context, groupID int64) (underattack.Strategy, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.get_strategy")
+	defer span.Finish()
+
+	var strategy underattack.Strategy
+	err := p.db.QueryRowContext(ctx, `SELECT strategy FROM under_attack WHERE group_id = $1`, groupID).Scan(&strategy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return strategy, nil
+}
+
+// SetStrategy will set the strategy for specified groupID.
+// If the groupID entry does not exists, it will create a new one.
+func (p *postgresDatastore) SetStrategy(ctx context.Context, groupID int64, strategy underattack.Strategy) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.set_strategy")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack
+			(group_id, is_under_attack, expires_at, notification_message_id, updated_at, strategy)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (group_id)
+		DO UPDATE
+		SET
+			strategy = $6`,
+		groupID,
+		false,
+		time.Time{},
+		0,
+		time.Now(),
+		strategy,
+	)
+	return err
+}
+
+// SaveLockedPermissions will keep the group permissions before they were locked.
+// It does nothing if the permissions of the groupID are already kept.
+func (p *postgresDatastore) SaveLockedPermissions(ctx context.Context, groupID int64, permissions tb.Rights) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.save_locked_permissions")
+	defer span.Finish()
+
+	value, err := json.Marshal(permissions)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack
+			(group_id, is_under_attack, expires_at, notification_message_id, updated_at, locked_permissions)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (group_id)
+		DO UPDATE
+		SET
+			locked_permissions = $6
+		WHERE
+			under_attack.locked_permissions = ''`,
+		groupID,
+		false,
+		time.Time{},
+		0,
+		time.Now(),
+		string(value),
+	)
+	return err
+}
+
+// TakeLockedPermissions will acquire and clear the group permissions before they were locked.
+func (p *postgresDatastore) TakeLockedPermissions(ctx context.Context, groupID int64) (tb.Rights, bool, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.take_locked_permissions")
+	defer span.Finish()
+
+	var value string
+	err := p.db.QueryRowContext(
+		ctx,
+		`UPDATE
+			under_attack AS entry
+		SET
+			locked_permissions = ''
+		FROM
+			(SELECT group_id, locked_permissions FROM under_attack WHERE group_id = $1 FOR UPDATE) AS previous
+		WHERE
+			entry.group_id = previous.group_id
+		RETURNING
+			previous.locked_permissions`,
+		groupID,
+	).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return tb.Rights{}, false, nil
+		}
+
+		return tb.Rights{}, false, err
+	}
+
+	if value == "" {
+		return tb.Rights{}, false, nil
+	}
+
+	var permissions tb.Rights
+	err = json.Unmarshal([]byte(value), &permissions)
+	if err != nil {
+		return tb.Rights{}, false, err
+	}
+
+	return permissions, true, nil
+}
+
+// AddBannedUser will record a user that is banned during the current under attack mode.
+func (p *postgresDatastore) AddBannedUser(ctx context.Context, groupID int64, userID int64) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.add_banned_user")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack_banned_user
+			(group_id, user_id, banned_at)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (group_id, user_id)
+		DO NOTHING`,
+		groupID,
+		userID,
+		time.Now(),
+	)
+	return err
+}
+
+// TakeBannedUsers will acquire and clear the users banned during the current under attack mode.
+func (p *postgresDatastore) TakeBannedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.take_banned_users")
+	defer span.Finish()
+
+	return p.takeUsers(ctx, `DELETE FROM under_attack_banned_user WHERE group_id = $1 RETURNING user_id`, groupID)
+}
+
+// AddRestrictedUser will record a user who joined while the group was locked.
+func (p *postgresDatastore) AddRestrictedUser(ctx context.Context, groupID int64, userID int64) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.add_restricted_user")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack_restricted_user
+			(group_id, user_id, restricted_at)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (group_id, user_id)
+		DO NOTHING`,
+		groupID,
+		userID,
+		time.Now(),
+	)
+	return err
+}
+
+// TakeRestrictedUsers will acquire and clear the users recorded by AddRestrictedUser.
+func (p *postgresDatastore) TakeRestrictedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.take_restricted_users")
+	defer span.Finish()
+
+	return p.takeUsers(ctx, `DELETE FROM under_attack_restricted_user WHERE group_id = $1 RETURNING user_id`, groupID)
+}
+
+// takeUsers runs a DELETE query that returns the user IDs it deleted.
+func (p *postgresDatastore) takeUsers(ctx context.Context, query string, groupID int64) ([]int64, error) {
+	rows, err := p.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		err := rows.Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// StartIncident will record a new incident, and return it along with its ID.
+func (p *postgresDatastore) StartIncident(ctx context.Context, incident underattack.Incident) (underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.start_incident")
+	defer span.Finish()
+
+	err := p.db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			under_attack_incident
+			(group_id, triggered_by, actor_id, strategy, started_at, expires_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		incident.GroupID,
+		incident.TriggeredBy,
+		incident.ActorID,
+		incident.Strategy,
+		incident.StartedAt,
+		incident.ExpiresAt,
+	).Scan(&incident.ID)
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	incident.EndedAt = time.Time{}
+	incident.BannedCount = 0
+	return incident, nil
+}
+
+// EndIncident will end every ongoing incident of the groupID. An incident can't
+// end after it expires, so incidents that were left ongoing (for example, because
+// the bot was restarted) end at their expiry time.
+func (p *postgresDatastore) EndIncident(ctx context.Context, groupID int64, endedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.end_incident")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`UPDATE under_attack_incident SET ended_at = LEAST($2, expires_at) WHERE group_id = $1 AND ended_at IS NULL`,
+		groupID,
+		endedAt,
+	)
+	return err
+}
+
+// RecordIncidentBan will record a banned user into the latest ongoing incident of the groupID.
+func (p *postgresDatastore) RecordIncidentBan(ctx context.Context, groupID int64, ban underattack.IncidentBan) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.record_incident_ban")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack_incident_ban
+			(incident_id, user_id, full_name, username, banned_at)
+		SELECT
+			id, $2, $3, $4, $5
+		FROM
+			under_attack_incident
+		WHERE
+			group_id = $1 AND ended_at IS NULL
+		ORDER BY
+			started_at DESC
+		LIMIT 1
+		ON CONFLICT (incident_id, user_id)
+		DO NOTHING`,
+		groupID,
+		ban.UserID,
+		ban.FullName,
+		ban.Username,
+		ban.BannedAt,
+	)
+	return err
+}
+
+const selectIncident = `SELECT
+		id,
+		group_id,
+		triggered_by,
+		actor_id,
+		strategy,
+		started_at,
+		expires_at,
+		ended_at,
+		(SELECT COUNT(*) FROM under_attack_incident_ban WHERE incident_id = under_attack_incident.id)
+	FROM
+		under_attack_incident`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanIncident(row rowScanner) (underattack.Incident, error) {
+	var incident underattack.Incident
+	var endedAt sql.NullTime
+	err := row.Scan(
+		&incident.ID,
+		&incident.GroupID,
+		&incident.TriggeredBy,
+		&incident.ActorID,
+		&incident.Strategy,
+		&incident.StartedAt,
+		&incident.ExpiresAt,
+		&endedAt,
+		&incident.BannedCount,
+	)
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	incident.EndedAt = endedAt.Time
+	return incident, nil
+}
+
+// ListIncidents will acquire the latest incidents of the groupID.
+func (p *postgresDatastore) ListIncidents(ctx context.Context, groupID int64, limit int) ([]underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.list_incidents")
+	defer span.Finish()
+
+	rows, err := p.db.QueryContext(ctx, selectIncident+` WHERE group_id = $1 ORDER BY started_at DESC LIMIT $2`, groupID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	var incidents []underattack.Incident
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		incidents = append(incidents, incident)
+	}
+
+	return incidents, rows.Err()
+}
+
+// GetIncident will acquire a single incident of the groupID.
+func (p *postgresDatastore) GetIncident(ctx context.Context, groupID int64, incidentID int64) (underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.get_incident")
+	defer span.Finish()
+
+	incident, err := scanIncident(p.db.QueryRowContext(ctx, selectIncident+` WHERE group_id = $1 AND id = $2`, groupID, incidentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return underattack.Incident{}, underattack.ErrIncidentNotFound
+		}
+
+		return underattack.Incident{}, err
+	}
+
+	return incident, nil
+}
+
+// ListIncidentBans will acquire the users banned during the incident.
+func (p *postgresDatastore) ListIncidentBans(ctx context.Context, incidentID int64) ([]underattack.IncidentBan, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.list_incident_bans")
+	defer span.Finish()
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT
+			incident_id,
+			user_id,
+			full_name,
+			username,
+			banned_at,
+			unbanned_at
+		FROM
+			under_attack_incident_ban
+		WHERE
+			incident_id = $1
+		ORDER BY
+			banned_at`,
+		incidentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	var bans []underattack.IncidentBan
+	for rows.Next() {
+		var ban underattack.IncidentBan
+		var unbannedAt sql.NullTime
+		err := rows.Scan(&ban.IncidentID, &ban.UserID, &ban.FullName, &ban.Username, &ban.BannedAt, &unbannedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		ban.UnbannedAt = unbannedAt.Time
+		bans = append(bans, ban)
+	}
+
+	return bans, rows.Err()
+}
+
+// MarkUnbanned will mark the given users of the incident as unbanned.
+func (p *postgresDatastore) MarkUnbanned(ctx context.Context, incidentID int64, userIDs []int64, unbannedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.mark_unbanned")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`UPDATE
+			under_attack_incident_ban
+		SET
+			unbanned_at = $3
+		WHERE
+			incident_id = $1 AND user_id = ANY($2) AND unbanned_at IS NULL`,
+		incidentID,
+		pq.Array(userIDs),
+		unbannedAt,
+	)
+	return err
+}
+
+// AddInviteLink will record an invite link of the group. Recording an existing
+// link replaces it.
+func (p *postgresDatastore) AddInviteLink(ctx context.Context, link underattack.InviteLink) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.add_invite_link")
+	defer span.Finish()
+
+	var revokedAt sql.NullTime
+	if !link.RevokedAt.IsZero() {
+		revokedAt = sql.NullTime{Time: link.RevokedAt, Valid: true}
+	}
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack_invite_link
+			(group_id, invite_link, join_request, created_at, revoked_at)
+		VALUES
+			($1, $2, $3, $4, $5)
+		ON CONFLICT (group_id, invite_link)
+		DO UPDATE
+		SET
+			join_request = $3,
+			created_at = $4,
+			revoked_at = $5`,
+		link.GroupID,
+		link.InviteLink,
+		link.JoinRequest,
+		link.CreatedAt,
+		revokedAt,
+	)
+	return err
+}
+
+// ListInviteLinks will acquire the recorded invite links of the group, newest first.
+func (p *postgresDatastore) ListInviteLinks(ctx context.Context, groupID int64, limit int) ([]underattack.InviteLink, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.list_invite_links")
+	defer span.Finish()
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT
+			group_id,
+			invite_link,
+			join_request,
+			created_at,
+			revoked_at
+		FROM
+			under_attack_invite_link
+		WHERE
+			group_id = $1
+		ORDER BY
+			created_at DESC
+		LIMIT $2`,
+		groupID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	var links []underattack.InviteLink
+	for rows.Next() {
+		var link underattack.InviteLink
+		var revokedAt sql.NullTime
+		err := rows.Scan(&link.GroupID, &link.InviteLink, &link.JoinRequest, &link.CreatedAt, &revokedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		link.RevokedAt = revokedAt.Time
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// MarkInviteLinkRevoked will mark the invite link of the group as revoked.
+func (p *postgresDatastore) MarkInviteLinkRevoked(ctx context.Context, groupID int64, inviteLink string, revokedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.mark_invite_link_revoked")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`UPDATE
+			under_attack_invite_link
+		SET
+			revoked_at = $3
+		WHERE
+			group_id = $1 AND invite_link = $2 AND revoked_at IS NULL`,
+		groupID,
+		inviteLink,
+		revokedAt,
+	)
+	return err
+}
+
+// AddPass will add a pass to join the group while it is under attack. Adding a pass
+// to the same user again replaces the previous one.
+func (p *postgresDatastore) AddPass(ctx context.Context, pass underattack.Pass) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.add_pass")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`INSERT INTO
+			under_attack_pass
+			(group_id, user_id, username, granted_by, expires_at, created_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (group_id, user_id, username)
+		DO UPDATE
+		SET
+			granted_by = $4,
+			expires_at = $5,
+			created_at = $6`,
+		pass.GroupID,
+		pass.UserID,
+		pass.Username,
+		pass.GrantedBy,
+		pass.ExpiresAt,
+		pass.CreatedAt,
+	)
+	return err
+}
+
+// ListPasses will acquire the passes of the group that are not expired yet.
+func (p *postgresDatastore) ListPasses(ctx context.Context, groupID int64, now time.Time) ([]underattack.Pass, error) {
+	span := sentry.StartSpan(ctx, "postgres_datastore.list_passes")
+	defer span.Finish()
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT
+			group_id,
+			user_id,
+			username,
+			granted_by,
+			expires_at,
+			created_at
+		FROM
+			under_attack_pass
+		WHERE
+			group_id = $1 AND expires_at > $2`,
+		groupID,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}()
+
+	var passes []underattack.Pass
+	for rows.Next() {
+		var pass underattack.Pass
+		err := rows.Scan(&pass.GroupID, &pass.UserID, &pass.Username, &pass.GrantedBy, &pass.ExpiresAt, &pass.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		passes = append(passes, pass)
+	}
+
+	return passes, rows.Err()
+}
+
+// RemovePass will remove a pass, along with the expired passes of the group.
+func (p *postgresDatastore) RemovePass(ctx context.Context, pass underattack.Pass) error {
+	span := sentry.StartSpan(ctx, "postgres_datastore.remove_pass")
+	defer span.Finish()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`DELETE FROM
+			under_attack_pass
+		WHERE
+			group_id = $1 AND ((user_id = $2 AND username = $3) OR expires_at <= $4)`,
+		pass.GroupID,
+		pass.UserID,
+		pass.Username,
+		time.Now(),
+	)
+	return err
+}
+
 func (p *postgresDatastore) Close() error {
 	return p.db.Close()
 }
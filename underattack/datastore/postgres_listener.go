@@ -0,0 +1,100 @@
+package datastore
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// UnderAttackChangedChannel is the Postgres channel notified by the postgres datastore
+// whenever an under attack entry changes. The payload is the group ID.
+const UnderAttackChangedChannel = "under_attack_changed"
+
+// PostgresListener implements underattack.ChangeListener by listening on
+// UnderAttackChangedChannel, so every replica of the bot learns about the
+// changes made by the others.
+type PostgresListener struct {
+	listener *pq.Listener
+	changes  chan int64
+
+	mu             sync.Mutex
+	listeningSince time.Time
+}
+
+func NewPostgresListener(postgresUrl string) (*PostgresListener, error) {
+	if postgresUrl == "" {
+		return nil, fmt.Errorf("empty postgres url")
+	}
+
+	l := &PostgresListener{changes: make(chan int64, 64)}
+	l.listener = pq.NewListener(postgresUrl, time.Second, time.Minute, l.handleEvent)
+
+	err := l.listener.Listen(UnderAttackChangedChannel)
+	if err != nil {
+		_ = l.listener.Close()
+		return nil, fmt.Errorf("listening on %s: %w", UnderAttackChangedChannel, err)
+	}
+
+	go l.run()
+
+	return l, nil
+}
+
+func (l *PostgresListener) handleEvent(event pq.ListenerEventType, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch event {
+	case pq.ListenerEventConnected, pq.ListenerEventReconnected:
+		l.listeningSince = time.Now()
+	case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+		if !l.listeningSince.IsZero() {
+			slog.Warn("Lost the under attack listener connection", slog.Any("error", err))
+		}
+
+		l.listeningSince = time.Time{}
+	}
+}
+
+func (l *PostgresListener) run() {
+	defer close(l.changes)
+
+	for notification := range l.listener.Notify {
+		// A nil notification means the connection was re-established,
+		// which is already handled by handleEvent.
+		if notification == nil {
+			continue
+		}
+
+		groupID, err := strconv.ParseInt(notification.Extra, 10, 64)
+		if err != nil {
+			slog.Warn("Parsing under attack notification payload", slog.String("payload", notification.Extra), slog.String("error", err.Error()))
+			continue
+		}
+
+		l.changes <- groupID
+	}
+}
+
+// Changes delivers the ID of every group whose under attack entry was changed.
+// It is closed once the listener is closed.
+func (l *PostgresListener) Changes() <-chan int64 {
+	return l.changes
+}
+
+// ListeningSince returns when the listener last connected, or the zero time
+// if it is disconnected.
+func (l *PostgresListener) ListeningSince() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.listeningSince
+}
+
+func (l *PostgresListener) Close() error {
+	return l.listener.Close()
+}
@@ -61,67 +61,45 @@ func TestPostgresDatastore(t *testing.T) {
 		})
 	})
 
-	t.Run("Migrate", func(t *testing.T) {
+	t.Run("Listener", func(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 		defer cancel()
 
-		err := dependency.Migrate(ctx)
+		listener, err := datastore.NewPostgresListener(postgresUrl)
 		if err != nil {
-			t.Errorf("migrating database: %s", err.Error())
-		}
-	})
-
-	t.Run("GetUnderAttackEntry", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		entry, err := dependency.GetUnderAttackEntry(ctx, 1)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-
-		if entry.IsUnderAttack == false {
-			t.Error("expecting IsUnderAttack to be true, got false")
-		}
-
-		if entry.ExpiresAt.Before(time.Now()) {
-			t.Errorf("expecting ExpiresAt to be after now, got: %v", entry.ExpiresAt)
+			t.Fatalf("creating postgres listener: %s", err.Error())
 		}
+		defer func() {
+			err := listener.Close()
+			if err != nil {
+				t.Logf("closing postgres listener: %s", err.Error())
+			}
+		}()
 
-		if entry.NotificationMessageID != 1002 {
-			t.Errorf("expecting NotificationMessageID to be 1002, got: %v", entry.NotificationMessageID)
+		for listener.ListeningSince().IsZero() {
+			select {
+			case <-ctx.Done():
+				t.Fatal("listener never connected")
+			case <-time.After(time.Millisecond * 10):
+			}
 		}
-	})
 
-	t.Run("GetUnderAttackEntry_NotExists", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		_, err := dependency.GetUnderAttackEntry(ctx, 20)
+		err = dependency.SetUnderAttackStatus(ctx, 4, true, time.Now().Add(time.Minute*30), 1004, underattack.TriggerManual)
 		if err != nil {
-			t.Errorf("unexpected error: %v", err)
+			t.Fatalf("unexpected error: %v", err)
 		}
-	})
 
-	t.Run("CreateNewEntry", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		err := dependency.CreateNewEntry(ctx, 2)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
+		select {
+		case groupID := <-listener.Changes():
+			if groupID != 4 {
+				t.Errorf("expecting a change on group 4, got %d", groupID)
+			}
+		case <-ctx.Done():
+			t.Error("expecting a change notification, got nothing")
 		}
 	})
 
-	t.Run("SetUnderAttackStatus", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
-
-		err := dependency.SetUnderAttackStatus(ctx, 3, true, time.Now().Add(time.Minute*30), 1003)
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-	})
+	testDatastore(t, dependency)
 }
 
 func SeedPostgres(ctx context.Context, db *sql.DB) error {
@@ -0,0 +1,809 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/redis/go-redis/v9"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+// redisDatastore keeps every record of the under attack domain under the
+// "underattack:" prefix, so several replicas of the bot can share them.
+type redisDatastore struct {
+	client *redis.Client
+}
+
+func NewRedisDatastore(client *redis.Client) (underattack.Datastore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("nil client")
+	}
+
+	return &redisDatastore{client: client}, nil
+}
+
+func redisKey(parts ...string) string {
+	key := "underattack"
+	for _, part := range parts {
+		key += ":" + part
+	}
+
+	return key
+}
+
+// getRedisJSON decodes the value of the key into v. It returns false if the key does not exist.
+func getRedisJSON(ctx context.Context, client redis.Cmdable, key string, v any) (bool, error) {
+	value, err := client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, json.Unmarshal(value, v)
+}
+
+// Migrate does nothing, Redis has no schema.
+func (r *redisDatastore) Migrate(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// GetUnderAttackEntry will acquire under attack entry for specified groupID.
+// It returns an empty entry if the group has none.
+func (r *redisDatastore) GetUnderAttackEntry(ctx context.Context, groupID int64) (underattack.UnderAttack, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.get_under_attack_entry")
+	defer span.Finish()
+
+	var entry underattack.UnderAttack
+	_, err := getRedisJSON(ctx, r.client, redisKey("entry", formatID(groupID)), &entry)
+	if err != nil {
+		return underattack.UnderAttack{}, err
+	}
+
+	return entry, nil
+}
+
+// CreateNewEntry will create a new entry for given groupID.
+// It does nothing if the entry already exists.
+func (r *redisDatastore) CreateNewEntry(ctx context.Context, groupID int64) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.create_new_entry")
+	defer span.Finish()
+
+	value, err := json.Marshal(underattack.UnderAttack{
+		GroupID:     groupID,
+		TriggeredBy: underattack.TriggerManual,
+		UpdatedAt:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.client.SetNX(ctx, redisKey("entry", formatID(groupID)), value, entryRetention).Err()
+}
+
+// SetUnderAttackStatus will update the given groupID entry to the given parameters.
+// The entry is kept until a while after it expires.
+func (r *redisDatastore) SetUnderAttackStatus(ctx context.Context, groupID int64, underAttack bool, expiresAt time.Time, notificationMessageID int64, triggeredBy underattack.Trigger) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.set_under_attack_status")
+	defer span.Finish()
+
+	value, err := json.Marshal(underattack.UnderAttack{
+		GroupID:               groupID,
+		IsUnderAttack:         underAttack,
+		NotificationMessageID: notificationMessageID,
+		ExpiresAt:             expiresAt,
+		TriggeredBy:           triggeredBy,
+		UpdatedAt:             time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ttl := entryRetention
+	if remaining := time.Until(expiresAt); remaining > 0 {
+		ttl += remaining
+	}
+
+	return r.client.Set(ctx, redisKey("entry", formatID(groupID)), value, ttl).Err()
+}
+
+// ListExpiredEntries will acquire the entries that have expired by now, but are still
+// marked as under attack, or still have locked permissions, banned or restricted users.
+func (r *redisDatastore) ListExpiredEntries(ctx context.Context, now time.Time) ([]underattack.UnderAttack, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.list_expired_entries")
+	defer span.Finish()
+
+	var entries []underattack.UnderAttack
+	iterator := r.client.Scan(ctx, 0, redisKey("entry", "*"), 100).Iterator()
+	for iterator.Next(ctx) {
+		var entry underattack.UnderAttack
+		ok, err := getRedisJSON(ctx, r.client, iterator.Val(), &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok || entry.ExpiresAt.After(now) {
+			continue
+		}
+
+		if !entry.IsUnderAttack {
+			leftovers, err := r.client.Exists(
+				ctx,
+				redisKey("locked_permissions", formatID(entry.GroupID)),
+				redisKey("banned_users", formatID(entry.GroupID)),
+				redisKey("restricted_users", formatID(entry.GroupID)),
+			).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			if leftovers == 0 {
+				continue
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := iterator.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func formatSchedule(schedule underattack.Schedule) string {
+	return strconv.Itoa(schedule.StartMinute) + "-" + strconv.Itoa(schedule.EndMinute)
+}
+
+func parseSchedule(groupID int64, member string) (underattack.Schedule, error) {
+	var schedule underattack.Schedule
+	_, err := fmt.Sscanf(member, "%d-%d", &schedule.StartMinute, &schedule.EndMinute)
+	if err != nil {
+		return underattack.Schedule{}, fmt.Errorf("parsing schedule %q: %w", member, err)
+	}
+
+	schedule.GroupID = groupID
+	return schedule, nil
+}
+
+// ListSchedules will acquire the scheduled windows of every group.
+func (r *redisDatastore) ListSchedules(ctx context.Context) ([]underattack.Schedule, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.list_schedules")
+	defer span.Finish()
+
+	groups, err := r.client.SMembers(ctx, redisKey("schedule_groups")).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []underattack.Schedule
+	for _, group := range groups {
+		groupID, err := strconv.ParseInt(group, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing group id %q: %w", group, err)
+		}
+
+		groupSchedules, err := r.GetSchedules(ctx, groupID)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, groupSchedules...)
+	}
+
+	return schedules, nil
+}
+
+// GetSchedules will acquire the scheduled windows of the groupID.
+func (r *redisDatastore) GetSchedules(ctx context.Context, groupID int64) ([]underattack.Schedule, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.get_schedules")
+	defer span.Finish()
+
+	members, err := r.client.SMembers(ctx, redisKey("schedule", formatID(groupID))).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []underattack.Schedule
+	for _, member := range members {
+		schedule, err := parseSchedule(groupID, member)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	slices.SortFunc(schedules, func(a, b underattack.Schedule) int {
+		return a.StartMinute - b.StartMinute
+	})
+	return schedules, nil
+}
+
+// AddSchedule will add a scheduled window. Adding an existing window does nothing.
+func (r *redisDatastore) AddSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.add_schedule")
+	defer span.Finish()
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, redisKey("schedule", formatID(schedule.GroupID)), formatSchedule(schedule))
+		pipe.SAdd(ctx, redisKey("schedule_groups"), formatID(schedule.GroupID))
+		return nil
+	})
+	return err
+}
+
+// RemoveSchedule will remove a scheduled window. The group is kept on the list
+// of groups with schedules, ListSchedules simply finds nothing for it.
+func (r *redisDatastore) RemoveSchedule(ctx context.Context, schedule underattack.Schedule) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.remove_schedule")
+	defer span.Finish()
+
+	return r.client.SRem(ctx, redisKey("schedule", formatID(schedule.GroupID)), formatSchedule(schedule)).Err()
+}
+
+// ClaimScheduledWindow will mark the occurrence of a scheduled window as applied until end.
+// It returns false if the occurrence was claimed already.
+func (r *redisDatastore) ClaimScheduledWindow(ctx context.Context, groupID int64, start time.Time, end time.Time) (bool, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.claim_scheduled_window")
+	defer span.Finish()
+
+	return r.client.SetNX(
+		ctx,
+		redisKey("applied_schedule", formatID(groupID), strconv.FormatInt(start.Unix(), 10)),
+		end.Unix(),
+		max(time.Until(end), time.Second),
+	).Result()
+}
+
+// GetStrategy will acquire the chosen strategy for specified groupID.
+// It returns an empty strategy if the group has not chosen one.
+func (r *redisDatastore) GetStrategy(ctx context.Context, groupID int64) (underattack.Strategy, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.get_strategy")
+	defer span.Finish()
+
+	strategy, err := r.client.Get(ctx, redisKey("strategy", formatID(groupID))).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return underattack.Strategy(strategy), nil
+}
+
+// SetStrategy will set the strategy for specified groupID.
+func (r *redisDatastore) SetStrategy(ctx context.Context, groupID int64, strategy underattack.Strategy) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.set_strategy")
+	defer span.Finish()
+
+	return r.client.Set(ctx, redisKey("strategy", formatID(groupID)), string(strategy), 0).Err()
+}
+
+// SaveLockedPermissions will keep the group permissions before they were locked.
+// It does nothing if the permissions of the groupID are already kept.
+func (r *redisDatastore) SaveLockedPermissions(ctx context.Context, groupID int64, permissions tb.Rights) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.save_locked_permissions")
+	defer span.Finish()
+
+	value, err := json.Marshal(permissions)
+	if err != nil {
+		return err
+	}
+
+	return r.client.SetNX(ctx, redisKey("locked_permissions", formatID(groupID)), value, 0).Err()
+}
+
+// TakeLockedPermissions will acquire and forget the permissions kept by SaveLockedPermissions.
+// It returns false if there are no permissions kept for the groupID.
+func (r *redisDatastore) TakeLockedPermissions(ctx context.Context, groupID int64) (tb.Rights, bool, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.take_locked_permissions")
+	defer span.Finish()
+
+	value, err := r.client.GetDel(ctx, redisKey("locked_permissions", formatID(groupID))).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return tb.Rights{}, false, nil
+		}
+
+		return tb.Rights{}, false, err
+	}
+
+	var permissions tb.Rights
+	err = json.Unmarshal(value, &permissions)
+	if err != nil {
+		return tb.Rights{}, false, err
+	}
+
+	return permissions, true, nil
+}
+
+// AddBannedUser will record a user that is banned until the under attack mode is over.
+func (r *redisDatastore) AddBannedUser(ctx context.Context, groupID int64, userID int64) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.add_banned_user")
+	defer span.Finish()
+
+	return r.client.SAdd(ctx, redisKey("banned_users", formatID(groupID)), userID).Err()
+}
+
+// TakeBannedUsers will acquire and forget the users recorded by AddBannedUser.
+func (r *redisDatastore) TakeBannedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.take_banned_users")
+	defer span.Finish()
+
+	return r.takeUsers(ctx, redisKey("banned_users", formatID(groupID)))
+}
+
+// AddRestrictedUser will record a user who joined while the group was locked.
+func (r *redisDatastore) AddRestrictedUser(ctx context.Context, groupID int64, userID int64) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.add_restricted_user")
+	defer span.Finish()
+
+	return r.client.SAdd(ctx, redisKey("restricted_users", formatID(groupID)), userID).Err()
+}
+
+// TakeRestrictedUsers will acquire and forget the users recorded by AddRestrictedUser.
+func (r *redisDatastore) TakeRestrictedUsers(ctx context.Context, groupID int64) ([]int64, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.take_restricted_users")
+	defer span.Finish()
+
+	return r.takeUsers(ctx, redisKey("restricted_users", formatID(groupID)))
+}
+
+// takeUsers acquires and deletes the set of user IDs on the key.
+func (r *redisDatastore) takeUsers(ctx context.Context, key string) ([]int64, error) {
+	var members *redis.StringSliceCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		members = pipe.SMembers(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []int64
+	for _, member := range members.Val() {
+		userID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing user id %q: %w", member, err)
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+// The incidents of a group are kept on a sorted set scored by their ID, while the
+// ongoing ones are also kept on a plain set. The banned count is not stored on the
+// incident, it is the size of the hash of its bans.
+
+func redisIncidentKey(incidentID int64) string {
+	return redisKey("incident", formatID(incidentID))
+}
+
+func redisIncidentBansKey(incidentID int64) string {
+	return redisKey("incident_bans", formatID(incidentID))
+}
+
+// getIncidents acquires the incidents by their IDs, skipping the ones that are gone.
+func (r *redisDatastore) getIncidents(ctx context.Context, incidentIDs []string) ([]underattack.Incident, error) {
+	if len(incidentIDs) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(incidentIDs))
+	for _, incidentID := range incidentIDs {
+		keys = append(keys, redisKey("incident", incidentID))
+	}
+
+	var values *redis.SliceCmd
+	bannedCounts := make([]*redis.IntCmd, len(incidentIDs))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.MGet(ctx, keys...)
+		for i, incidentID := range incidentIDs {
+			bannedCounts[i] = pipe.HLen(ctx, redisKey("incident_bans", incidentID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var incidents []underattack.Incident
+	for i, value := range values.Val() {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var incident underattack.Incident
+		err := json.Unmarshal([]byte(raw), &incident)
+		if err != nil {
+			return nil, err
+		}
+
+		incident.BannedCount = int(bannedCounts[i].Val())
+		incidents = append(incidents, incident)
+	}
+
+	return incidents, nil
+}
+
+// StartIncident will record a new incident, and returns it with its ID.
+func (r *redisDatastore) StartIncident(ctx context.Context, incident underattack.Incident) (underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.start_incident")
+	defer span.Finish()
+
+	incidentID, err := r.client.Incr(ctx, redisKey("incident_sequence")).Result()
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	incident.ID = incidentID
+	incident.EndedAt = time.Time{}
+	incident.BannedCount = 0
+
+	value, err := json.Marshal(incident)
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisIncidentKey(incidentID), value, incidentRetention)
+		pipe.ZAdd(ctx, redisKey("incidents", formatID(incident.GroupID)), redis.Z{Score: float64(incidentID), Member: incidentID})
+		pipe.SAdd(ctx, redisKey("ongoing_incidents", formatID(incident.GroupID)), incidentID)
+		return nil
+	})
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	return incident, nil
+}
+
+// EndIncident will end every ongoing incident of the groupID. An incident never
+// ends after it expires.
+func (r *redisDatastore) EndIncident(ctx context.Context, groupID int64, endedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.end_incident")
+	defer span.Finish()
+
+	ongoingKey := redisKey("ongoing_incidents", formatID(groupID))
+	incidentIDs, err := r.client.SMembers(ctx, ongoingKey).Result()
+	if err != nil {
+		return err
+	}
+
+	incidents, err := r.getIncidents(ctx, incidentIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, incident := range incidents {
+			incident.EndedAt = endedAt
+			if incident.ExpiresAt.Before(endedAt) {
+				incident.EndedAt = incident.ExpiresAt
+			}
+
+			value, err := json.Marshal(incident)
+			if err != nil {
+				return err
+			}
+
+			pipe.Set(ctx, redisIncidentKey(incident.ID), value, incidentRetention)
+		}
+
+		if len(incidentIDs) > 0 {
+			pipe.SRem(ctx, ongoingKey, incidentIDs)
+		}
+		return nil
+	})
+	return err
+}
+
+// RecordIncidentBan will record a banned user into the latest ongoing incident of the groupID.
+// It does nothing if there is no ongoing incident, or if the user is already recorded.
+func (r *redisDatastore) RecordIncidentBan(ctx context.Context, groupID int64, ban underattack.IncidentBan) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.record_incident_ban")
+	defer span.Finish()
+
+	members, err := r.client.SMembers(ctx, redisKey("ongoing_incidents", formatID(groupID))).Result()
+	if err != nil {
+		return err
+	}
+
+	var latest int64
+	for _, member := range members {
+		incidentID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return fmt.Errorf("parsing incident id %q: %w", member, err)
+		}
+
+		latest = max(latest, incidentID)
+	}
+
+	if latest == 0 {
+		return nil
+	}
+
+	ban.IncidentID = latest
+	value, err := json.Marshal(ban)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, redisIncidentBansKey(latest), formatID(ban.UserID), value)
+		pipe.Expire(ctx, redisIncidentBansKey(latest), incidentRetention)
+		return nil
+	})
+	return err
+}
+
+// ListIncidents will acquire the latest incidents of the groupID, newest first.
+func (r *redisDatastore) ListIncidents(ctx context.Context, groupID int64, limit int) ([]underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.list_incidents")
+	defer span.Finish()
+
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	incidentIDs, err := r.client.ZRevRange(ctx, redisKey("incidents", formatID(groupID)), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.getIncidents(ctx, incidentIDs)
+}
+
+// GetIncident will acquire a single incident of the groupID.
+func (r *redisDatastore) GetIncident(ctx context.Context, groupID int64, incidentID int64) (underattack.Incident, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.get_incident")
+	defer span.Finish()
+
+	incidents, err := r.getIncidents(ctx, []string{formatID(incidentID)})
+	if err != nil {
+		return underattack.Incident{}, err
+	}
+
+	if len(incidents) == 0 || incidents[0].GroupID != groupID {
+		return underattack.Incident{}, underattack.ErrIncidentNotFound
+	}
+
+	return incidents[0], nil
+}
+
+// ListIncidentBans will acquire the users banned during the incident.
+func (r *redisDatastore) ListIncidentBans(ctx context.Context, incidentID int64) ([]underattack.IncidentBan, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.list_incident_bans")
+	defer span.Finish()
+
+	values, err := r.client.HVals(ctx, redisIncidentBansKey(incidentID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var bans []underattack.IncidentBan
+	for _, value := range values {
+		var ban underattack.IncidentBan
+		err := json.Unmarshal([]byte(value), &ban)
+		if err != nil {
+			return nil, err
+		}
+
+		bans = append(bans, ban)
+	}
+
+	slices.SortStableFunc(bans, func(a, b underattack.IncidentBan) int {
+		return a.BannedAt.Compare(b.BannedAt)
+	})
+	return bans, nil
+}
+
+// MarkUnbanned will mark the given users of the incident as unbanned.
+func (r *redisDatastore) MarkUnbanned(ctx context.Context, incidentID int64, userIDs []int64, unbannedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.mark_unbanned")
+	defer span.Finish()
+
+	key := redisIncidentBansKey(incidentID)
+	for _, userID := range userIDs {
+		value, err := r.client.HGet(ctx, key, formatID(userID)).Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+
+			return err
+		}
+
+		var ban underattack.IncidentBan
+		err = json.Unmarshal(value, &ban)
+		if err != nil {
+			return err
+		}
+
+		if !ban.UnbannedAt.IsZero() {
+			continue
+		}
+
+		ban.UnbannedAt = unbannedAt
+		value, err = json.Marshal(ban)
+		if err != nil {
+			return err
+		}
+
+		err = r.client.HSet(ctx, key, formatID(userID), value).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddInviteLink will record an invite link of the group. Recording an existing
+// link replaces it.
+func (r *redisDatastore) AddInviteLink(ctx context.Context, link underattack.InviteLink) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.add_invite_link")
+	defer span.Finish()
+
+	value, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	return r.client.HSet(ctx, redisKey("invite_links", formatID(link.GroupID)), link.InviteLink, value).Err()
+}
+
+// ListInviteLinks will acquire the recorded invite links of the group, newest first.
+func (r *redisDatastore) ListInviteLinks(ctx context.Context, groupID int64, limit int) ([]underattack.InviteLink, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.list_invite_links")
+	defer span.Finish()
+
+	values, err := r.client.HVals(ctx, redisKey("invite_links", formatID(groupID))).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var links []underattack.InviteLink
+	for _, value := range values {
+		var link underattack.InviteLink
+		err := json.Unmarshal([]byte(value), &link)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+	}
+
+	slices.SortStableFunc(links, func(a, b underattack.InviteLink) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if len(links) > limit {
+		links = links[:limit]
+	}
+
+	return links, nil
+}
+
+// MarkInviteLinkRevoked will mark the invite link of the group as revoked.
+func (r *redisDatastore) MarkInviteLinkRevoked(ctx context.Context, groupID int64, inviteLink string, revokedAt time.Time) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.mark_invite_link_revoked")
+	defer span.Finish()
+
+	key := redisKey("invite_links", formatID(groupID))
+	value, err := r.client.HGet(ctx, key, inviteLink).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+
+		return err
+	}
+
+	var link underattack.InviteLink
+	err = json.Unmarshal(value, &link)
+	if err != nil {
+		return err
+	}
+
+	if !link.RevokedAt.IsZero() {
+		return nil
+	}
+
+	link.RevokedAt = revokedAt
+	value, err = json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	return r.client.HSet(ctx, key, inviteLink, value).Err()
+}
+
+func redisPassField(pass underattack.Pass) string {
+	return formatID(pass.UserID) + ":" + pass.Username
+}
+
+// AddPass will add a pass to join the group while it is under attack. Adding a pass
+// to the same user again replaces the previous one.
+func (r *redisDatastore) AddPass(ctx context.Context, pass underattack.Pass) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.add_pass")
+	defer span.Finish()
+
+	value, err := json.Marshal(pass)
+	if err != nil {
+		return err
+	}
+
+	return r.client.HSet(ctx, redisKey("passes", formatID(pass.GroupID)), redisPassField(pass), value).Err()
+}
+
+// ListPasses will acquire the passes of the group that are not expired yet.
+func (r *redisDatastore) ListPasses(ctx context.Context, groupID int64, now time.Time) ([]underattack.Pass, error) {
+	span := sentry.StartSpan(ctx, "redis_datastore.list_passes")
+	defer span.Finish()
+
+	values, err := r.client.HVals(ctx, redisKey("passes", formatID(groupID))).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var passes []underattack.Pass
+	for _, value := range values {
+		var pass underattack.Pass
+		err := json.Unmarshal([]byte(value), &pass)
+		if err != nil {
+			return nil, err
+		}
+
+		if pass.ExpiresAt.After(now) {
+			passes = append(passes, pass)
+		}
+	}
+
+	return passes, nil
+}
+
+// RemovePass will remove a pass, along with the expired passes of the group.
+func (r *redisDatastore) RemovePass(ctx context.Context, pass underattack.Pass) error {
+	span := sentry.StartSpan(ctx, "redis_datastore.remove_pass")
+	defer span.Finish()
+
+	key := redisKey("passes", formatID(pass.GroupID))
+	values, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	fields := []string{redisPassField(pass)}
+	for field, value := range values {
+		var existing underattack.Pass
+		err := json.Unmarshal([]byte(value), &existing)
+		if err == nil && !existing.ExpiresAt.After(time.Now()) {
+			fields = append(fields, field)
+		}
+	}
+
+	return r.client.HDel(ctx, key, fields...).Err()
+}
+
+// Close does nothing, the Redis client is shared with the cache
+// and is closed by its owner.
+func (r *redisDatastore) Close() error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package datastore_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/captcha/underattack"
+	"github.com/teknologi-umum/captcha/underattack/datastore"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisDatastore(t *testing.T) {
+	var dependency underattack.Datastore
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	dependency, err := datastore.NewRedisDatastore(client)
+	if err != nil {
+		t.Fatalf("creating new redis datastore: %s", err.Error())
+	}
+
+	setupCtx, setupCancel := context.WithTimeout(context.Background(), time.Second*30)
+
+	err = dependency.Migrate(setupCtx)
+	if err != nil {
+		t.Fatalf("migrating tables: %s", err.Error())
+	}
+
+	err = SeedRedisDatastore(setupCtx, client)
+	if err != nil {
+		t.Fatalf("seeding data: %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+		setupCancel()
+
+		err := dependency.Close()
+		if err != nil {
+			t.Logf("closing redis datastore: %s", err.Error())
+		}
+
+		err = client.Close()
+		if err != nil {
+			t.Logf("closing redis client: %s", err.Error())
+		}
+	})
+
+	t.Run("NewRedisDatastore", func(t *testing.T) {
+		t.Run("Nil client", func(t *testing.T) {
+			_, err := datastore.NewRedisDatastore(nil)
+			if err.Error() != "nil client" {
+				t.Errorf("expecting an error of 'nil client', instead got %s", err.Error())
+			}
+		})
+	})
+
+	testDatastore(t, dependency)
+}
+
+func SeedRedisDatastore(ctx context.Context, client *redis.Client) error {
+	value, err := json.Marshal(underattack.UnderAttack{
+		GroupID:               1,
+		IsUnderAttack:         true,
+		NotificationMessageID: 1002,
+		ExpiresAt:             time.Now().Add(time.Hour),
+		UpdatedAt:             time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return client.Set(ctx, "underattack:entry:1", value, 0).Err()
+}
@@ -0,0 +1,152 @@
+package underattack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+// Disable turns off the under attack mode of a group before it expires, releasing
+// whatever the strategy of the group has taken away. The actorID is the admin who
+// turned it off, or zero if it is not turned off from Telegram.
+func (d *Dependency) Disable(ctx context.Context, chat *tb.Chat, actorID int64) error {
+	span := sentry.StartSpan(ctx, "underattack.disable")
+	defer span.Finish()
+	ctx = span.Context()
+
+	underAttackEntry, err := d.Datastore.GetUnderAttackEntry(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("getting under attack entry: %w", err)
+	}
+
+	err = d.Datastore.SetUnderAttackStatus(ctx, chat.ID, false, time.Now(), 0, underAttackEntry.TriggeredBy)
+	if err != nil {
+		return fmt.Errorf("setting under attack status: %w", err)
+	}
+
+	err = d.Memory.Delete(underAttackCacheKey(chat.ID))
+	if err != nil {
+		return fmt.Errorf("deleting under attack cache: %w", err)
+	}
+
+	err = d.Datastore.EndIncident(ctx, chat.ID, time.Now())
+	if err != nil {
+		return fmt.Errorf("ending incident: %w", err)
+	}
+
+	err = d.release(ctx, chat)
+	if err != nil {
+		return err
+	}
+
+	err = d.offerInviteLinkRestore(ctx, chat)
+	if err != nil {
+		shared.HandleError(ctx, err)
+	}
+
+	err = d.Bot.Unpin(ctx, chat, int(underAttackEntry.NotificationMessageID))
+	if err != nil {
+		return fmt.Errorf("unpinning notification message: %w", err)
+	}
+
+	sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
+		Type:     "debug",
+		Category: "underattack.state",
+		Message:  "Under attack mode is disabled",
+		Data: map[string]interface{}{
+			"chat":  chat,
+			"actor": actorID,
+		},
+		Level:     sentry.LevelDebug,
+		Timestamp: time.Now(),
+	}, &sentry.BreadcrumbHint{})
+
+	return nil
+}
+
+// releaseExpired ends the under attack mode of every group that has expired by now,
+// and releases whatever the strategy of the group has taken away. The expiry is read
+// from the datastore, so an under attack mode that expires while the bot is down is
+// released once it is up again.
+func (d *Dependency) releaseExpired(ctx context.Context, now time.Time) error {
+	span := sentry.StartSpan(ctx, "underattack.release_expired")
+	defer span.Finish()
+	ctx = span.Context()
+
+	entries, err := d.Datastore.ListExpiredEntries(ctx, now)
+	if err != nil {
+		return fmt.Errorf("listing expired entries: %w", err)
+	}
+
+	for _, entry := range entries {
+		chat := &tb.Chat{ID: entry.GroupID}
+
+		// Entries that are no longer marked as under attack only have something
+		// left to release, for example because releasing them failed before.
+		if entry.IsUnderAttack {
+			err := d.expire(ctx, chat, entry)
+			if err != nil {
+				shared.HandleError(ctx, fmt.Errorf("expiring under attack mode for %d: %w", entry.GroupID, err))
+				continue
+			}
+		}
+
+		err := d.release(ctx, chat)
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("releasing under attack mode for %d: %w", entry.GroupID, err))
+			continue
+		}
+
+		if entry.IsUnderAttack {
+			err := d.offerInviteLinkRestore(ctx, chat)
+			if err != nil {
+				shared.HandleError(ctx, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// expire marks the entry as no longer under attack, so it is only expired once,
+// then ends its incident and unpins its notification message.
+func (d *Dependency) expire(ctx context.Context, chat *tb.Chat, entry UnderAttack) error {
+	err := d.Datastore.SetUnderAttackStatus(ctx, chat.ID, false, entry.ExpiresAt, 0, entry.TriggeredBy)
+	if err != nil {
+		return fmt.Errorf("setting under attack status: %w", err)
+	}
+
+	err = d.Memory.Delete(underAttackCacheKey(chat.ID))
+	if err != nil {
+		return fmt.Errorf("deleting under attack cache: %w", err)
+	}
+
+	err = d.Datastore.EndIncident(ctx, chat.ID, entry.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("ending incident: %w", err)
+	}
+
+	sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
+		Type:     "debug",
+		Category: "underattack.state",
+		Message:  "Under attack mode ends",
+		Data: map[string]interface{}{
+			"chat":    chat,
+			"trigger": entry.TriggeredBy,
+		},
+		Level:     sentry.LevelDebug,
+		Timestamp: time.Now(),
+	}, &sentry.BreadcrumbHint{})
+
+	// The message might have been unpinned by an admin already.
+	err = d.Bot.Unpin(ctx, chat, int(entry.NotificationMessageID))
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("unpinning notification message: %w", err))
+	}
+
+	return nil
+}
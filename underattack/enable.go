@@ -0,0 +1,251 @@
+package underattack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+const (
+	// DefaultDuration is how long the under attack mode lasts when no duration is given.
+	DefaultDuration = time.Minute * 30
+	// MinDuration is the shortest duration that can be given to the /underattack
+	// and /allowjoin commands.
+	// A number without a unit is parsed as nanoseconds, and ends up below it.
+	MinDuration = time.Minute
+	// MaxDuration is the longest duration that can be given to the /underattack
+	// and /allowjoin commands.
+	MaxDuration = time.Hour * 24 * 7
+)
+
+// ValidDuration tells whether the duration is within MinDuration and MaxDuration.
+func ValidDuration(duration time.Duration) bool {
+	return duration >= MinDuration && duration <= MaxDuration
+}
+
+// Enable turns on the under attack mode of a group until expiresAt. It announces
+// and pins the notification message on the group, the message is unpinned by
+// releaseExpired once the under attack mode is over. The actorID is the admin
+// who turned it on, or zero if it is not triggered manually.
+func (d *Dependency) Enable(ctx context.Context, chat *tb.Chat, expiresAt time.Time, trigger Trigger, actorID int64) error {
+	span := sentry.StartSpan(ctx, "underattack.enable")
+	defer span.Finish()
+	ctx = span.Context()
+
+	var reason string
+	switch trigger {
+	case TriggerAutomatic:
+		reason = "Terdeteksi lonjakan anggota baru, mode under attack dinyalakan secara otomatis.\n" +
+			"A spike of new members is detected, under attack mode is turned on automatically.\n\n"
+	case TriggerScheduled:
+		reason = "Mode under attack dinyalakan sesuai jadwal.\n" +
+			"Under attack mode is turned on as scheduled.\n\n"
+	}
+
+	strategy, err := d.StrategyOf(ctx, chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var consequenceID, consequenceEN string
+	switch strategy {
+	case StrategyTemporaryBan:
+		consequenceID = "Semua yang baru masuk ke grup ini akan di ban sampai under attack mode berakhir. "
+		consequenceEN = "Everyone that is joining this group will be banned until the under attack mode is over. "
+	case StrategyKick, StrategyDecline:
+		consequenceID = "Semua yang baru masuk ke grup ini akan langsung dikeluarkan. "
+		consequenceEN = "Everyone that is joining this group will be removed right away. "
+	case StrategyLock:
+		consequenceID = "Untuk sementara, semua anggota tidak bisa mengirim pesan. "
+		consequenceEN = "For now, nobody can send any message. "
+	default:
+		consequenceID = "Semua yang baru masuk ke grup ini akan langsung di ban selamanya. "
+		consequenceEN = "Everyone that is joining this group will be banned forever. "
+	}
+
+	// Only mention the date if it is not today.
+	clockFormat := "15:04 MST"
+	if expiresAt.In(wib).Format(time.DateOnly) != time.Now().In(wib).Format(time.DateOnly) {
+		clockFormat = "02 Jan 15:04 MST"
+	}
+
+	notificationMessage, err := d.Bot.Send(
+		ctx,
+		chat,
+		reason+
+			"Grup ini dalam kondisi under attack sampai pukul "+
+			expiresAt.In(wib).Format(clockFormat)+
+			". "+consequenceID+
+			"Untuk bisa bergabung, tunggu sampai under attack mode berakhir, atau hubungi admin grup.\n\n"+
+			"This group is in under attack mode until "+
+			expiresAt.In(time.FixedZone("UTC +7", 7*60*60)).Format(clockFormat)+
+			". "+consequenceEN+
+			"To be able to join, wait until the under attack mode is over, or contact the group's administrator.",
+		&tb.SendOptions{
+			ParseMode: tb.ModeDefault,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("sending notification message: %w", err)
+	}
+
+	// The previous under attack mode might have expired without being released
+	// yet, its notification message is replaced by this one.
+	previous, err := d.Datastore.GetUnderAttackEntry(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("getting under attack entry: %w", err)
+	}
+
+	if previous.IsUnderAttack && previous.NotificationMessageID != 0 {
+		err := d.Bot.Unpin(ctx, chat, int(previous.NotificationMessageID))
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("unpinning previous notification message: %w", err))
+		}
+	}
+
+	err = d.Datastore.SetUnderAttackStatus(ctx, chat.ID, true, expiresAt, int64(notificationMessage.ID), trigger)
+	if err != nil {
+		return fmt.Errorf("setting under attack status: %w", err)
+	}
+
+	err = d.Memory.Delete(underAttackCacheKey(chat.ID))
+	if err != nil {
+		return fmt.Errorf("deleting under attack cache: %w", err)
+	}
+
+	// Any incident that is still ongoing must have been left behind, for example
+	// because the bot was restarted before it ends.
+	err = d.Datastore.EndIncident(ctx, chat.ID, time.Now())
+	if err != nil {
+		return fmt.Errorf("ending previous incident: %w", err)
+	}
+
+	_, err = d.Datastore.StartIncident(ctx, Incident{
+		GroupID:     chat.ID,
+		TriggeredBy: trigger,
+		ActorID:     actorID,
+		Strategy:    strategy,
+		StartedAt:   time.Now(),
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("starting incident: %w", err)
+	}
+
+	activationsTotal.Inc(chatLabel(chat.ID), string(trigger))
+
+	err = d.Bot.Pin(ctx, notificationMessage)
+	if err != nil {
+		return fmt.Errorf("pinning notification message: %w", err)
+	}
+
+	if strategy == StrategyLock {
+		err := d.lock(ctx, chat)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Failing to rotate the invite link should not keep the under attack
+	// mode from protecting the group.
+	if d.RotateInviteLink {
+		err := d.rotateInviteLink(ctx, chat)
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+	}
+
+	sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
+		Type:     "debug",
+		Category: "underattack.state",
+		Message:  "Under attack mode is enabled",
+		Data: map[string]interface{}{
+			"chat":    chat,
+			"trigger": trigger,
+		},
+		Level:     sentry.LevelDebug,
+		Timestamp: time.Now(),
+	}, &sentry.BreadcrumbHint{})
+
+	return nil
+}
+
+// ObserveJoin feeds a new member join into the join-rate detector, and enables the
+// under attack mode automatically when the joins of the group spike. It does nothing
+// if the join-rate detector is not configured.
+func (d *Dependency) ObserveJoin(ctx context.Context, chat *tb.Chat) error {
+	if d.JoinRate == nil {
+		return nil
+	}
+
+	if !d.JoinRate.Record(chat.ID, time.Now()) {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "underattack.observe_join")
+	defer span.Finish()
+	ctx = span.Context()
+
+	underAttack, err := d.AreWe(ctx, chat.ID)
+	if err != nil {
+		return err
+	}
+
+	if underAttack {
+		return nil
+	}
+
+	slog.WarnContext(
+		ctx,
+		"Join-rate spike detected, enabling under attack mode",
+		slog.Int64("group_id", chat.ID),
+		slog.Int("threshold", d.JoinRate.Threshold),
+		slog.Duration("window", d.JoinRate.Window),
+	)
+
+	err = d.Enable(ctx, chat, time.Now().Add(DefaultDuration), TriggerAutomatic, 0)
+	if err != nil {
+		return err
+	}
+
+	d.notifyAdmins(ctx, chat)
+	return nil
+}
+
+// notifyAdmins tells the group admins privately that the under attack mode has been
+// enabled automatically. Not every admin has started a conversation with the bot,
+// those who haven't will only see the pinned message on the group.
+func (d *Dependency) notifyAdmins(ctx context.Context, chat *tb.Chat) {
+	text := "Mode under attack di grup " + chat.Title + " dinyalakan secara otomatis karena ada lonjakan anggota baru " +
+		"(" + strconv.Itoa(d.JoinRate.Threshold) + " anggota dalam " + d.JoinRate.Window.String() + "). " +
+		"Kirim /disableunderattack di grup untuk mematikannya."
+
+	d.dmAdmins(ctx, chat, text)
+}
+
+// dmAdmins sends the text privately to every admin of the group. Not every admin has
+// started a conversation with the bot, those who haven't are skipped.
+func (d *Dependency) dmAdmins(ctx context.Context, chat *tb.Chat, text string, opts ...interface{}) {
+	admins, err := d.Bot.AdminsOf(ctx, chat)
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("getting group admins: %w", err))
+		return
+	}
+
+	for _, admin := range admins {
+		if admin.User == nil || admin.User.IsBot {
+			continue
+		}
+
+		_, err := d.Bot.Send(ctx, admin.User, text, opts...)
+		if err != nil {
+			slog.DebugContext(ctx, "Failed to notify an admin privately", slog.String("error", err.Error()), slog.Int64("admin_id", admin.User.ID))
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package underattack_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/captcha/deletion"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+func TestValidDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{input: "2h", valid: true},
+		{input: "90 menit", valid: true},
+		{input: "2", valid: false},
+		{input: "30s", valid: false},
+		{input: "8d", valid: false},
+	}
+
+	for _, test := range tests {
+		duration, err := deletion.ParseDuration(context.Background(), test.input)
+		if err != nil {
+			t.Fatalf("%s: %s", test.input, err.Error())
+		}
+
+		if underattack.ValidDuration(duration) != test.valid {
+			t.Errorf("%s: expecting valid to be %v, got %s", test.input, test.valid, duration)
+		}
+	}
+
+	if !underattack.ValidDuration(time.Minute) || underattack.ValidDuration(underattack.MaxDuration+time.Second) {
+		t.Error("expecting the bounds to be inclusive")
+	}
+}
@@ -3,10 +3,10 @@ package underattack
 import (
 	"context"
 	"errors"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/teknologi-umum/captcha/deletion"
 	"github.com/teknologi-umum/captcha/shared"
 	"github.com/teknologi-umum/captcha/utils"
 
@@ -46,46 +46,17 @@ func (d *Dependency) EnableUnderAttackModeHandler(ctx context.Context, c tb.Cont
 	if !utils.IsAdmin(admins, c.Sender()) {
 		// It turns out, for contingency reasons, people should be aware that the command and bot
 		// is working, yet the bot is rate limited by Telegram because of sending too many messages
-		// at one. Hence, we should retry every enabling command for under attack.
-		for {
-			_, err := c.Bot().Send(
-				ctx,
-				c.Chat(),
-				"Cuma admin yang boleh jalanin command ini. Ada baiknya kamu ping adminnya langsung :)",
-				&tb.SendOptions{
-					ReplyTo:           c.Message(),
-					AllowWithoutReply: true,
-				},
-			)
-			if err != nil {
-				var floodError tb.FloodError
-				if errors.As(err, &floodError) {
-					if floodError.RetryAfter == 0 {
-						floodError.RetryAfter = 15
-					}
-
-					time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-					continue
-				}
-
-				if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-					time.Sleep(time.Second * 10)
-					continue
-				}
-
-				shared.HandleBotError(ctx, err, d.Bot, c.Message())
-				return nil
-			}
-
-			break
+		// at one. The reply waits in the bot's send queue instead of being dropped.
+		err := d.reply(ctx, c, "Cuma admin yang boleh jalanin command ini. Ada baiknya kamu ping adminnya langsung :)")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
 		}
 
 		return nil
 	}
 
 	// Rate limit per group. Drop if limited
-	allowedThrough := RateLimitCall(c.Chat().ID, time.Second*10)
-	if !allowedThrough {
+	if !d.allowCommand(ctx, c.Chat().ID) {
 		return nil
 	}
 
@@ -93,143 +64,65 @@ func (d *Dependency) EnableUnderAttackModeHandler(ctx context.Context, c tb.Cont
 	ctx, cancel := context.WithTimeout(ctx, time.Minute*1)
 	defer cancel()
 
-	// Check if we are on the under attack mode right now.
-	underAttackModeEnabled, err := d.AreWe(ctx, c.Chat().ID)
-	if err != nil {
-		shared.HandleBotError(ctx, err, d.Bot, c.Message())
-		return nil
+	payload := strings.TrimSpace(c.Message().Payload)
+	subcommand, argument, _ := strings.Cut(payload, " ")
+	switch strings.ToLower(subcommand) {
+	case "schedule":
+		return d.scheduleHandler(ctx, c, strings.TrimSpace(argument))
+	case "unschedule":
+		return d.unscheduleHandler(ctx, c, strings.TrimSpace(argument))
+	case "strategy":
+		return d.strategyHandler(ctx, c, strings.ToLower(strings.TrimSpace(argument)))
 	}
 
-	if underAttackModeEnabled {
-		for {
-			_, err := c.Bot().Send(
-				ctx,
-				c.Chat(),
-				"Mode under attack sudah menyala. Untuk mematikan, kirim /disableunderattack",
-				&tb.SendOptions{
-					ReplyTo:           c.Message(),
-					AllowWithoutReply: true,
-				},
-			)
-			if err != nil {
-				var floodError tb.FloodError
-				if errors.As(err, &floodError) {
-					if floodError.RetryAfter == 0 {
-						floodError.RetryAfter = 15
-					}
-
-					time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-					continue
-				}
-
-				if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-					time.Sleep(time.Second * 10)
-					continue
-				}
-
-				shared.HandleBotError(ctx, err, d.Bot, c.Message())
-				return nil
-			}
-
-			break
+	expiresAt := time.Now().Add(DefaultDuration)
+	if payload != "" {
+		duration, err := deletion.ParseDuration(ctx, strings.ToLower(payload))
+		if err != nil && !errors.Is(err, deletion.ErrParseClock) {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+			return nil
 		}
 
-		return nil
-	}
-
-	expiresAt := time.Now().Add(time.Minute * 30)
-	var notificationMessage *tb.Message
-	for {
-		notificationMessage, err = c.Bot().Send(
-			ctx,
-			c.Chat(),
-			"Grup ini dalam kondisi under attack sampai pukul "+
-				expiresAt.In(time.FixedZone("WIB", 7*60*60)).Format("15:04 MST")+
-				". Semua yang baru masuk ke grup ini akan langsung di ban selamanya. "+
-				"Untuk bisa bergabung, tunggu sampai under attack mode berakhir, atau hubungi admin grup.\n\n"+
-				"This group is in under attack mode until "+
-				expiresAt.In(time.FixedZone("UTC +7", 7*60*60)).Format("15:04 MST")+
-				". Everyone that is joining this group will be banned forever. "+
-				"To be able to join, wait until the under attack mode is over, or contact the group's administrator.",
-			&tb.SendOptions{
-				ParseMode: tb.ModeDefault,
-			},
-		)
-		if err != nil {
-			var floodError tb.FloodError
-			if errors.As(err, &floodError) {
-				if floodError.RetryAfter == 0 {
-					floodError.RetryAfter = 15
-				}
-
-				time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-				continue
-			}
+		// A clock that has already passed today means tomorrow.
+		if err == nil && duration < 0 {
+			duration += time.Hour * 24
+		}
 
-			if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-				time.Sleep(time.Second * 10)
-				continue
+		if err != nil || !ValidDuration(duration) {
+			err := d.reply(ctx, c, "Durasinya nggak valid. Contoh: /underattack 2h, /underattack 90 menit, atau /underattack until 23:00. "+
+				"Minimal 1 menit, maksimal 7 hari.")
+			if err != nil {
+				shared.HandleBotError(ctx, err, d.Bot, c.Message())
 			}
 
-			shared.HandleBotError(ctx, err, d.Bot, c.Message())
 			return nil
 		}
 
-		break
+		expiresAt = time.Now().Add(duration)
 	}
 
-	err = d.Datastore.SetUnderAttackStatus(ctx, c.Chat().ID, true, time.Now().Add(time.Minute*30), int64(notificationMessage.ID))
+	// Check if we are on the under attack mode right now.
+	underAttackModeEnabled, err := d.AreWe(ctx, c.Chat().ID)
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, c.Message())
 		return nil
 	}
 
-	err = d.Memory.Delete("UnderAttack:" + strconv.FormatInt(c.Chat().ID, 10))
-	if err != nil {
-		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	if underAttackModeEnabled {
+		err := d.reply(ctx, c, "Mode under attack sudah menyala. Untuk mematikan, kirim /disableunderattack")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
 		return nil
 	}
 
-	err = c.Bot().Pin(ctx, notificationMessage)
+	err = d.Enable(ctx, c.Chat(), expiresAt, TriggerManual, c.Sender().ID)
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, c.Message())
 		return nil
 	}
 
-	sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
-		Type:     "debug",
-		Category: "underattack.state",
-		Message:  "Under attack mode is enabled",
-		Data: map[string]interface{}{
-			"user": c.Sender(),
-			"chat": c.Chat(),
-		},
-		Level:     sentry.LevelDebug,
-		Timestamp: time.Now(),
-	}, &sentry.BreadcrumbHint{})
-
-	go func() {
-		// Set a timer to unpin the notification message
-		time.Sleep(time.Until(expiresAt))
-
-		sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
-			Type:     "debug",
-			Category: "underattack.state",
-			Message:  "Under attack mode ends",
-			Data: map[string]interface{}{
-				"user": c.Sender(),
-				"chat": c.Chat(),
-			},
-			Level:     sentry.LevelDebug,
-			Timestamp: time.Now(),
-		}, &sentry.BreadcrumbHint{})
-
-		err := c.Bot().Unpin(ctx, notificationMessage.Chat, notificationMessage.ID)
-		if err != nil {
-			shared.HandleBotError(ctx, err, d.Bot, c.Message())
-		}
-	}()
-
 	return nil
 }
 
@@ -269,8 +162,7 @@ func (d *Dependency) DisableUnderAttackModeHandler(ctx context.Context, c tb.Con
 	}
 
 	// Rate limit per group. Drop if limited
-	allowedThrough := RateLimitCall(c.Chat().ID, time.Second*10)
-	if !allowedThrough {
+	if !d.allowCommand(ctx, c.Chat().ID) {
 		return nil
 	}
 
@@ -289,41 +181,150 @@ func (d *Dependency) DisableUnderAttackModeHandler(ctx context.Context, c tb.Con
 		return nil
 	}
 
-	underAttackEntry, err := d.Datastore.GetUnderAttackEntry(ctx, c.Chat().ID)
+	err = d.Disable(ctx, c.Chat(), c.Sender().ID)
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, c.Message())
 		return nil
 	}
 
-	err = d.Datastore.SetUnderAttackStatus(ctx, c.Chat().ID, false, time.Now(), 0)
+	return nil
+}
+
+// scheduleHandler adds a recurring window, or lists the windows of the group
+// if no window is given.
+func (d *Dependency) scheduleHandler(ctx context.Context, c tb.Context, argument string) error {
+	if argument == "" {
+		schedules, err := d.Datastore.GetSchedules(ctx, c.Chat().ID)
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+			return nil
+		}
+
+		text := "Belum ada jadwal under attack untuk grup ini. Tambahkan dengan /underattack schedule 01:00-06:00"
+		if len(schedules) > 0 {
+			text = "Jadwal under attack grup ini (WIB):"
+			for _, schedule := range schedules {
+				text += "\n• " + schedule.String()
+			}
+		}
+
+		err = d.reply(ctx, c, text)
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	schedule, err := ParseSchedule(c.Chat().ID, argument)
+	if err != nil {
+		err := d.reply(ctx, c, "Format jadwalnya nggak valid. Contoh: /underattack schedule 01:00-06:00")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	err = d.Datastore.AddSchedule(ctx, schedule)
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, c.Message())
 		return nil
 	}
 
-	err = d.Memory.Delete("UnderAttack:" + strconv.FormatInt(c.Chat().ID, 10))
+	err = d.reply(ctx, c, "Mode under attack akan menyala setiap hari pukul "+schedule.String()+" WIB. "+
+		"Untuk menghapus jadwal ini, kirim /underattack unschedule "+schedule.String())
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
+
+	return nil
+}
+
+// unscheduleHandler removes a recurring window.
+func (d *Dependency) unscheduleHandler(ctx context.Context, c tb.Context, argument string) error {
+	schedule, err := ParseSchedule(c.Chat().ID, argument)
+	if err != nil {
+		err := d.reply(ctx, c, "Format jadwalnya nggak valid. Contoh: /underattack unschedule 01:00-06:00")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
 		return nil
 	}
 
-	err = c.Bot().Unpin(ctx, c.Chat(), int(underAttackEntry.NotificationMessageID))
+	err = d.Datastore.RemoveSchedule(ctx, schedule)
 	if err != nil {
 		shared.HandleBotError(ctx, err, d.Bot, c.Message())
 		return nil
 	}
 
-	sentry.GetHubFromContext(ctx).AddBreadcrumb(&sentry.Breadcrumb{
-		Type:     "debug",
-		Category: "underattack.state",
-		Message:  "Under attack mode is disabled",
-		Data: map[string]interface{}{
-			"user": c.Sender(),
-			"chat": c.Chat(),
-		},
-		Level:     sentry.LevelDebug,
-		Timestamp: time.Now(),
-	}, &sentry.BreadcrumbHint{})
+	err = d.reply(ctx, c, "Jadwal under attack pukul "+schedule.String()+" WIB sudah dihapus.")
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
 
 	return nil
 }
+
+// strategyHandler sets the strategy of the group, or tells the current strategy
+// if no strategy is given.
+func (d *Dependency) strategyHandler(ctx context.Context, c tb.Context, argument string) error {
+	var names []string
+	for _, strategy := range Strategies {
+		names = append(names, string(strategy))
+	}
+
+	if argument == "" {
+		strategy, err := d.StrategyOf(ctx, c.Chat().ID)
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+			return nil
+		}
+
+		err = d.reply(ctx, c, "Strategi under attack grup ini: "+string(strategy)+". "+
+			"Pilihan yang tersedia: "+strings.Join(names, ", ")+".")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	strategy := Strategy(argument)
+	if !strategy.Valid() {
+		err := d.reply(ctx, c, "Strateginya nggak dikenal. Pilihan yang tersedia: "+strings.Join(names, ", ")+".")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	err := d.SetStrategy(ctx, c.Chat().ID, strategy)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	err = d.reply(ctx, c, "Strategi under attack grup ini sekarang: "+string(strategy)+".")
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
+
+	return nil
+}
+
+// reply replies to the command message.
+func (d *Dependency) reply(ctx context.Context, c tb.Context, text string) error {
+	_, err := d.Bot.Send(
+		ctx,
+		c.Chat(),
+		text,
+		&tb.SendOptions{
+			ReplyTo:           c.Message(),
+			AllowWithoutReply: true,
+		},
+	)
+	return err
+}
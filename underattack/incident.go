@@ -0,0 +1,407 @@
+package underattack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/cache"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// ReviewCallbackUnique is the unique of the inline buttons sent by /attacks.
+// The bot must handle "\f" + ReviewCallbackUnique with ReviewCallbackHandler.
+const ReviewCallbackUnique = "underattack_review"
+
+const (
+	// attacksLimit is how many recent incidents are listed by /attacks.
+	attacksLimit = 5
+	// reviewLimit is how many banned users are shown as buttons on a review,
+	// Telegram refuses a keyboard with too many buttons.
+	reviewLimit = 50
+)
+
+// AttacksHandler provides a handler for /attacks command. It lists the recent
+// incidents of the group, with a button to review the users banned on each one.
+func (d *Dependency) AttacksHandler(ctx context.Context, c tb.Context) error {
+	if c.Message().Private() || c.Sender().IsBot {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "bot.attacks_handler", sentry.WithTransactionSource(sentry.SourceTask),
+		sentry.WithTransactionName("Captcha AttacksHandler"))
+	defer span.Finish()
+	ctx = span.Context()
+
+	admins, err := c.Bot().AdminsOf(ctx, c.Chat())
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	if !utils.IsAdmin(admins, c.Sender()) {
+		err := d.reply(ctx, c, "Cuma admin yang boleh jalanin command ini. Ada baiknya kamu ping adminnya langsung :)")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	text, markup, err := d.renderIncidents(ctx, c.Chat().ID)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	_, err = d.Bot.Send(
+		ctx,
+		c.Chat(),
+		text,
+		&tb.SendOptions{
+			ReplyTo:           c.Message(),
+			AllowWithoutReply: true,
+			ReplyMarkup:       markup,
+		},
+	)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
+
+	return nil
+}
+
+// ReviewCallbackHandler handles the inline buttons sent by /attacks. The callback
+// data is one of:
+//
+//	r|<incident>         review the banned users of an incident
+//	t|<incident>|<user>  select or unselect a banned user
+//	u|<incident>         unban the selected users
+//	a|<incident>         unban every user that is still banned
+//	l                    go back to the list of incidents
+func (d *Dependency) ReviewCallbackHandler(ctx context.Context, c tb.Context) error {
+	callback := c.Callback()
+	if callback == nil || callback.Message == nil || callback.Sender == nil {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "bot.review_callback_handler", sentry.WithTransactionSource(sentry.SourceTask),
+		sentry.WithTransactionName("Captcha ReviewCallbackHandler"))
+	defer span.Finish()
+	ctx = span.Context()
+
+	chat := callback.Message.Chat
+
+	admins, err := d.Bot.AdminsOf(ctx, chat)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	if !utils.IsAdmin(admins, callback.Sender) {
+		err := c.Respond(ctx, &tb.CallbackResponse{Text: "Cuma admin yang boleh pakai tombol ini.", ShowAlert: true})
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+
+		return nil
+	}
+
+	action, arguments, _ := strings.Cut(callback.Data, "|")
+	if action == "l" {
+		text, markup, err := d.renderIncidents(ctx, chat.ID)
+		if err != nil {
+			shared.HandleError(ctx, err)
+			return nil
+		}
+
+		return d.answer(ctx, c, "", text, markup)
+	}
+
+	incidentArgument, userArgument, _ := strings.Cut(arguments, "|")
+	incidentID, err := strconv.ParseInt(incidentArgument, 10, 64)
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("parsing incident id from %q: %w", callback.Data, err))
+		return nil
+	}
+
+	incident, err := d.Datastore.GetIncident(ctx, chat.ID, incidentID)
+	if err != nil {
+		if errors.Is(err, ErrIncidentNotFound) {
+			err := c.Respond(ctx, &tb.CallbackResponse{Text: "Insiden ini sudah nggak ada.", ShowAlert: true})
+			if err != nil {
+				shared.HandleError(ctx, err)
+			}
+
+			return nil
+		}
+
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	selectionKey := "UnderAttack:Review:" + strconv.FormatInt(chat.ID, 10) + ":" + strconv.Itoa(callback.Message.ID)
+	selected, err := d.getSelection(selectionKey)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	var notice string
+	switch action {
+	case "r":
+		selected = nil
+	case "t":
+		userID, err := strconv.ParseInt(userArgument, 10, 64)
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("parsing user id from %q: %w", callback.Data, err))
+			return nil
+		}
+
+		if index := slices.Index(selected, userID); index >= 0 {
+			selected = slices.Delete(selected, index, index+1)
+		} else {
+			selected = append(selected, userID)
+		}
+	case "u", "a":
+		bans, err := d.Datastore.ListIncidentBans(ctx, incident.ID)
+		if err != nil {
+			shared.HandleError(ctx, err)
+			return nil
+		}
+
+		var userIDs []int64
+		for _, ban := range bans {
+			if ban.UnbannedAt.IsZero() && (action == "a" || slices.Contains(selected, ban.UserID)) {
+				userIDs = append(userIDs, ban.UserID)
+			}
+		}
+
+		if len(userIDs) == 0 {
+			notice = "Belum ada user yang dipilih."
+			break
+		}
+
+		unbanned, err := d.unbanIncident(ctx, chat, incident, userIDs)
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+
+		notice = strconv.Itoa(len(unbanned)) + " user sudah di-unban."
+		selected = nil
+
+		slog.InfoContext(ctx, "Unbanned users from an incident", slog.Int64("group_id", chat.ID), slog.Int64("incident_id", incident.ID), slog.Int64("admin_id", callback.Sender.ID), slog.Int("unbanned_users", len(unbanned)))
+	default:
+		return nil
+	}
+
+	err = d.setSelection(selectionKey, selected)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	text, markup, err := d.renderReview(ctx, incident, selected)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	return d.answer(ctx, c, notice, text, markup)
+}
+
+// unbanIncident unbans the given users and marks them as unbanned on the incident.
+// It returns the users that are unbanned successfully.
+func (d *Dependency) unbanIncident(ctx context.Context, chat *tb.Chat, incident Incident, userIDs []int64) ([]int64, error) {
+	var unbanned []int64
+	for _, userID := range userIDs {
+		err := d.Bot.Unban(ctx, chat, &tb.User{ID: userID}, true)
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("unbanning user %d: %w", userID, err))
+			continue
+		}
+
+		unbanned = append(unbanned, userID)
+	}
+
+	if len(unbanned) == 0 {
+		return nil, nil
+	}
+
+	err := d.Datastore.MarkUnbanned(ctx, incident.ID, unbanned, time.Now())
+	if err != nil {
+		return unbanned, fmt.Errorf("marking unbanned users: %w", err)
+	}
+
+	return unbanned, nil
+}
+
+// renderIncidents renders the recent incidents of the group.
+func (d *Dependency) renderIncidents(ctx context.Context, groupID int64) (string, *tb.ReplyMarkup, error) {
+	incidents, err := d.Datastore.ListIncidents(ctx, groupID, attacksLimit)
+	if err != nil {
+		return "", nil, fmt.Errorf("listing incidents: %w", err)
+	}
+
+	markup := &tb.ReplyMarkup{}
+	if len(incidents) == 0 {
+		return "Belum ada catatan under attack untuk grup ini.", markup, nil
+	}
+
+	var text strings.Builder
+	text.WriteString("Catatan under attack terakhir grup ini:\n")
+
+	var rows []tb.Row
+	for _, incident := range incidents {
+		text.WriteString("\n" + formatIncident(incident))
+
+		if incident.BannedCount > 0 {
+			rows = append(rows, markup.Row(markup.Data("Review #"+strconv.FormatInt(incident.ID, 10), ReviewCallbackUnique, "r", strconv.FormatInt(incident.ID, 10))))
+		}
+	}
+
+	markup.Inline(rows...)
+	return text.String(), markup, nil
+}
+
+// renderReview renders the banned users of an incident, the selected users are checked.
+func (d *Dependency) renderReview(ctx context.Context, incident Incident, selected []int64) (string, *tb.ReplyMarkup, error) {
+	bans, err := d.Datastore.ListIncidentBans(ctx, incident.ID)
+	if err != nil {
+		return "", nil, fmt.Errorf("listing incident bans: %w", err)
+	}
+
+	incidentID := strconv.FormatInt(incident.ID, 10)
+	markup := &tb.ReplyMarkup{}
+
+	var text strings.Builder
+	text.WriteString(formatIncident(incident) + "\n\n")
+
+	var rows []tb.Row
+	var stillBanned int
+	for _, ban := range bans {
+		if !ban.UnbannedAt.IsZero() {
+			continue
+		}
+
+		stillBanned++
+		if len(rows) >= reviewLimit {
+			continue
+		}
+
+		label := "☐ "
+		if slices.Contains(selected, ban.UserID) {
+			label = "☑ "
+		}
+
+		label += ban.FullName
+		if ban.Username != "" {
+			label += " (@" + ban.Username + ")"
+		}
+
+		rows = append(rows, markup.Row(markup.Data(label, ReviewCallbackUnique, "t", incidentID, strconv.FormatInt(ban.UserID, 10))))
+	}
+
+	switch {
+	case stillBanned == 0:
+		text.WriteString("Semua user pada insiden ini sudah di-unban.")
+	case stillBanned > reviewLimit:
+		text.WriteString(fmt.Sprintf("%d user masih di-ban, %d yang pertama ditampilkan. Pilih user yang mau di-unban.", stillBanned, reviewLimit))
+	default:
+		text.WriteString(fmt.Sprintf("%d user masih di-ban. Pilih user yang mau di-unban.", stillBanned))
+	}
+
+	if stillBanned > 0 {
+		rows = append(rows, markup.Row(
+			markup.Data(fmt.Sprintf("Unban terpilih (%d)", len(selected)), ReviewCallbackUnique, "u", incidentID),
+			markup.Data("Unban semua", ReviewCallbackUnique, "a", incidentID),
+		))
+	}
+
+	rows = append(rows, markup.Row(markup.Data("« Kembali", ReviewCallbackUnique, "l")))
+	markup.Inline(rows...)
+
+	return text.String(), markup, nil
+}
+
+// formatIncident renders a single line that describes the incident.
+func formatIncident(incident Incident) string {
+	line := "#" + strconv.FormatInt(incident.ID, 10) + " " +
+		incident.StartedAt.In(wib).Format("02 Jan 15:04")
+
+	if incident.EndedAt.IsZero() {
+		line += " - sekarang"
+	} else {
+		line += " - " + incident.EndedAt.In(wib).Format("02 Jan 15:04")
+	}
+
+	line += " WIB, " + string(incident.TriggeredBy) + ", " + string(incident.Strategy) +
+		", " + strconv.Itoa(incident.BannedCount) + " user di-ban"
+	return line
+}
+
+// answer responds to the callback and edits the message with the new view.
+func (d *Dependency) answer(ctx context.Context, c tb.Context, notice string, text string, markup *tb.ReplyMarkup) error {
+	err := c.Respond(ctx, &tb.CallbackResponse{Text: notice})
+	if err != nil {
+		shared.HandleError(ctx, err)
+	}
+
+	err = c.Edit(ctx, text, markup)
+	if err != nil && !errors.Is(err, tb.ErrMessageNotModified) {
+		shared.HandleError(ctx, err)
+	}
+
+	return nil
+}
+
+// getSelection returns the users selected on a review message.
+func (d *Dependency) getSelection(key string) ([]int64, error) {
+	value, err := d.Memory.Get(key)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting review selection: %w", err)
+	}
+
+	var selected []int64
+	for _, field := range strings.Split(string(value), ",") {
+		if field == "" {
+			continue
+		}
+
+		userID, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing review selection: %w", err)
+		}
+
+		selected = append(selected, userID)
+	}
+
+	return selected, nil
+}
+
+// setSelection stores the users selected on a review message.
+func (d *Dependency) setSelection(key string, selected []int64) error {
+	fields := make([]string, 0, len(selected))
+	for _, userID := range selected {
+		fields = append(fields, strconv.FormatInt(userID, 10))
+	}
+
+	err := d.Memory.Set(key, []byte(strings.Join(fields, ",")))
+	if err != nil {
+		return fmt.Errorf("setting review selection: %w", err)
+	}
+
+	return nil
+}
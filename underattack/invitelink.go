@@ -0,0 +1,241 @@
+package underattack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// InviteLinkCallbackUnique is the unique of the inline button that restores a normal
+// invite link once the under attack mode is over. The bot must handle
+// "\f" + InviteLinkCallbackUnique with InviteLinkCallbackHandler.
+const InviteLinkCallbackUnique = "underattack_invite_link"
+
+// inviteLinkHistoryLimit is how many recorded invite links are looked at when
+// revoking the links created by the bot.
+const inviteLinkHistoryLimit = 20
+
+// rotateInviteLink revokes the invite links of the group, since the attacks usually
+// come through a leaked one, and creates a fresh link that needs the approval of an
+// admin. The new link is sent privately to the admins.
+func (d *Dependency) rotateInviteLink(ctx context.Context, chat *tb.Chat) error {
+	span := sentry.StartSpan(ctx, "underattack.rotate_invite_link")
+	defer span.Finish()
+	ctx = span.Context()
+
+	fullChat, err := d.Bot.ChatByID(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("getting chat: %w", err)
+	}
+
+	// Revoking the primary link makes Telegram generate a new one,
+	// which is fine since nobody has seen it yet.
+	if fullChat.InviteLink != "" {
+		_, err := d.Bot.RevokeInviteLink(ctx, chat, fullChat.InviteLink)
+		if err != nil {
+			return fmt.Errorf("revoking primary invite link: %w", err)
+		}
+
+		err = d.Datastore.AddInviteLink(ctx, InviteLink{
+			GroupID:    chat.ID,
+			InviteLink: fullChat.InviteLink,
+			CreatedAt:  time.Now(),
+			RevokedAt:  time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("recording revoked invite link: %w", err)
+		}
+	}
+
+	err = d.revokeInviteLinks(ctx, chat, false)
+	if err != nil {
+		return err
+	}
+
+	created, err := d.Bot.CreateInviteLink(ctx, chat, &tb.ChatInviteLink{Name: "Under attack", JoinRequest: true})
+	if err != nil {
+		return fmt.Errorf("creating invite link: %w", err)
+	}
+
+	err = d.Datastore.AddInviteLink(ctx, InviteLink{
+		GroupID:     chat.ID,
+		InviteLink:  created.InviteLink,
+		JoinRequest: true,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("recording invite link: %w", err)
+	}
+
+	d.dmAdmins(ctx, chat, "Mode under attack di grup "+fullChat.Title+" menyala, jadi semua link undangan grup sudah dicabut. "+
+		"Link undangan yang baru butuh persetujuan admin untuk setiap anggota baru:\n"+created.InviteLink)
+
+	slog.InfoContext(ctx, "Rotated the invite link", slog.Int64("group_id", chat.ID))
+	return nil
+}
+
+// revokeInviteLinks revokes the invite links recorded for the group that are still
+// usable. If onlyJoinRequest is true, only the links created during the under
+// attack mode are revoked.
+func (d *Dependency) revokeInviteLinks(ctx context.Context, chat *tb.Chat, onlyJoinRequest bool) error {
+	links, err := d.Datastore.ListInviteLinks(ctx, chat.ID, inviteLinkHistoryLimit)
+	if err != nil {
+		return fmt.Errorf("listing invite links: %w", err)
+	}
+
+	for _, link := range links {
+		if !link.RevokedAt.IsZero() || (onlyJoinRequest && !link.JoinRequest) {
+			continue
+		}
+
+		_, err := d.Bot.RevokeInviteLink(ctx, chat, link.InviteLink)
+		// The link might have been revoked by an admin by hand.
+		if err != nil && !errors.Is(err, tb.ErrInviteHashExpired) {
+			shared.HandleError(ctx, fmt.Errorf("revoking invite link: %w", err))
+			continue
+		}
+
+		err = d.Datastore.MarkInviteLinkRevoked(ctx, chat.ID, link.InviteLink, time.Now())
+		if err != nil {
+			return fmt.Errorf("marking invite link as revoked: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// offerInviteLinkRestore asks the admins privately whether the invite link created
+// during the under attack mode should be replaced with a normal one. It does nothing
+// if the invite link was not rotated.
+func (d *Dependency) offerInviteLinkRestore(ctx context.Context, chat *tb.Chat) error {
+	links, err := d.Datastore.ListInviteLinks(ctx, chat.ID, inviteLinkHistoryLimit)
+	if err != nil {
+		return fmt.Errorf("listing invite links: %w", err)
+	}
+
+	var active *InviteLink
+	for i := range links {
+		if links[i].JoinRequest && links[i].RevokedAt.IsZero() {
+			active = &links[i]
+			break
+		}
+	}
+
+	if active == nil {
+		return nil
+	}
+
+	fullChat, err := d.Bot.ChatByID(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("getting chat: %w", err)
+	}
+
+	markup := &tb.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data("Buat link undangan biasa", InviteLinkCallbackUnique, strconv.FormatInt(chat.ID, 10))))
+
+	d.dmAdmins(
+		ctx,
+		chat,
+		"Mode under attack di grup "+fullChat.Title+" sudah berakhir. Link undangan grup saat ini masih butuh persetujuan admin:\n"+
+			active.InviteLink+"\n\nTekan tombol di bawah untuk menggantinya dengan link undangan biasa.",
+		&tb.SendOptions{ReplyMarkup: markup},
+	)
+
+	return nil
+}
+
+// InviteLinkCallbackHandler replaces the invite link created during the under
+// attack mode with a normal one, when an admin presses the button sent by
+// offerInviteLinkRestore.
+func (d *Dependency) InviteLinkCallbackHandler(ctx context.Context, c tb.Context) error {
+	callback := c.Callback()
+	if callback == nil || callback.Sender == nil {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "bot.invite_link_callback_handler", sentry.WithTransactionSource(sentry.SourceTask),
+		sentry.WithTransactionName("Captcha InviteLinkCallbackHandler"))
+	defer span.Finish()
+	ctx = span.Context()
+
+	groupID, err := strconv.ParseInt(callback.Data, 10, 64)
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("parsing group id from %q: %w", callback.Data, err))
+		return nil
+	}
+
+	chat := &tb.Chat{ID: groupID}
+
+	admins, err := d.Bot.AdminsOf(ctx, chat)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	if !utils.IsAdmin(admins, callback.Sender) {
+		err := c.Respond(ctx, &tb.CallbackResponse{Text: "Kamu sudah bukan admin grup ini.", ShowAlert: true})
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+
+		return nil
+	}
+
+	underAttack, err := d.AreWe(ctx, groupID)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	if underAttack {
+		err := c.Respond(ctx, &tb.CallbackResponse{Text: "Grup ini sedang under attack lagi, coba lagi nanti.", ShowAlert: true})
+		if err != nil {
+			shared.HandleError(ctx, err)
+		}
+
+		return nil
+	}
+
+	inviteLink, err := d.Bot.InviteLink(ctx, chat)
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("exporting invite link: %w", err))
+		return nil
+	}
+
+	err = d.Datastore.AddInviteLink(ctx, InviteLink{
+		GroupID:    groupID,
+		InviteLink: inviteLink,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("recording invite link: %w", err))
+		return nil
+	}
+
+	err = d.revokeInviteLinks(ctx, chat, true)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	err = c.Respond(ctx, &tb.CallbackResponse{})
+	if err != nil {
+		shared.HandleError(ctx, err)
+	}
+
+	err = c.Edit(ctx, "Link undangan grup sudah diganti dengan link undangan biasa:\n"+inviteLink)
+	if err != nil {
+		shared.HandleError(ctx, err)
+	}
+
+	slog.InfoContext(ctx, "Restored a normal invite link", slog.Int64("group_id", groupID), slog.Int64("admin_id", callback.Sender.ID))
+	return nil
+}
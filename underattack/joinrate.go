@@ -0,0 +1,52 @@
+package underattack
+
+import (
+	"sync"
+	"time"
+)
+
+// JoinRateDetector keeps a rolling window of join times for every chat,
+// to detect a spike of new members that usually means a bot raid.
+type JoinRateDetector struct {
+	// Threshold is the amount of joins within Window that is considered a spike.
+	Threshold int
+	Window    time.Duration
+
+	mu    sync.Mutex
+	joins map[int64][]time.Time
+}
+
+// NewJoinRateDetector creates a new join-rate detector.
+func NewJoinRateDetector(threshold int, window time.Duration) *JoinRateDetector {
+	return &JoinRateDetector{
+		Threshold: threshold,
+		Window:    window,
+		joins:     make(map[int64][]time.Time),
+	}
+}
+
+// Record records a join on the given chat, and returns true if the amount of joins
+// within the window has reached the threshold. Once it returns true, the window of
+// that chat is reset, so a single spike is only reported once.
+func (j *JoinRateDetector) Record(chatID int64, now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	since := now.Add(-j.Window)
+	joins := j.joins[chatID]
+
+	// Drop the joins that are already outside the window.
+	var i int
+	for i < len(joins) && !joins[i].After(since) {
+		i++
+	}
+
+	joins = append(joins[i:], now)
+	if len(joins) >= j.Threshold {
+		delete(j.joins, chatID)
+		return true
+	}
+
+	j.joins[chatID] = joins
+	return false
+}
@@ -0,0 +1,49 @@
+package underattack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+func TestJoinRateDetector(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Spike", func(t *testing.T) {
+		detector := underattack.NewJoinRateDetector(3, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			if detector.Record(1, now.Add(time.Second*time.Duration(i))) {
+				t.Errorf("join #%d should not trigger", i+1)
+			}
+		}
+
+		if !detector.Record(1, now.Add(time.Second*2)) {
+			t.Error("third join within a minute should trigger")
+		}
+
+		if detector.Record(1, now.Add(time.Second*3)) {
+			t.Error("window should be reset after a trigger")
+		}
+	})
+
+	t.Run("Outside window", func(t *testing.T) {
+		detector := underattack.NewJoinRateDetector(3, time.Minute)
+
+		detector.Record(1, now)
+		detector.Record(1, now.Add(time.Second*30))
+		if detector.Record(1, now.Add(time.Second*61)) {
+			t.Error("the first join is outside the window and should not be counted")
+		}
+	})
+
+	t.Run("Per chat", func(t *testing.T) {
+		detector := underattack.NewJoinRateDetector(2, time.Minute)
+
+		detector.Record(1, now)
+		if detector.Record(2, now) {
+			t.Error("joins of another chat should not be counted")
+		}
+	})
+}
@@ -5,68 +5,114 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 
 	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/utils"
 )
 
+// Kicker handles a new member while the group is under attack,
+// according to the strategy of the group.
 func (d *Dependency) Kicker(ctx context.Context, c tb.Context) error {
 	span := sentry.StartSpan(ctx, "underattack.kicker")
 	ctx = span.Context()
 	defer span.Finish()
 
-	for {
-		err := c.Bot().Ban(ctx, c.Chat(), &tb.ChatMember{User: c.Sender(), RestrictedUntil: tb.Forever()})
+	user := c.Sender()
+	if c.Message().UserJoined != nil && c.Message().UserJoined.ID != 0 {
+		user = c.Message().UserJoined
+	}
+
+	strategy, err := d.StrategyOf(ctx, c.Chat().ID)
+	if err != nil {
+		return err
+	}
+
+	switch strategy {
+	case StrategyLock:
+		// The group permissions are restored once the under attack mode is over,
+		// so the new member is restricted on their own until release removes them.
+		err := c.Bot().Restrict(ctx, c.Chat(), &tb.ChatMember{User: user, Rights: tb.NoRights(), RestrictedUntil: tb.Forever()})
 		if err != nil {
-			var floodError tb.FloodError
-			if errors.As(err, &floodError) {
-				if floodError.RetryAfter == 0 {
-					floodError.RetryAfter = 15
-				}
+			return fmt.Errorf("error restricting user: %w", err)
+		}
 
-				time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-				continue
-			}
+		err = d.Datastore.AddRestrictedUser(ctx, c.Chat().ID, user.ID)
+		if err != nil {
+			return fmt.Errorf("recording restricted user: %w", err)
+		}
 
-			if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-				time.Sleep(time.Second * 10)
-				continue
-			}
+		d.recordBan(ctx, c.Chat(), user)
 
+		slog.DebugContext(ctx, "Succesfully restricted user", slog.String("user_name", user.Username), slog.Int64("user_id", user.ID))
+	case StrategyKick, StrategyDecline:
+		err := c.Bot().Ban(ctx, c.Chat(), &tb.ChatMember{User: user, RestrictedUntil: tb.Forever()})
+		if err != nil {
 			return fmt.Errorf("error banning user: %w", err)
 		}
 
-		slog.DebugContext(ctx, "Succesfully banned user", slog.String("user_name", c.Sender().Username), slog.Int64("user_id", c.Sender().ID))
-		break
-	}
+		err = c.Bot().Unban(ctx, c.Chat(), user, true)
+		if err != nil {
+			return fmt.Errorf("error unbanning user: %w", err)
+		}
+
+		slog.DebugContext(ctx, "Succesfully kicked user", slog.String("user_name", user.Username), slog.Int64("user_id", user.ID))
+	case StrategyTemporaryBan:
+		// The ban ends when the under attack mode ends, even if we failed to unban them.
+		entry, err := d.Datastore.GetUnderAttackEntry(ctx, c.Chat().ID)
+		if err != nil {
+			return fmt.Errorf("getting under attack entry: %w", err)
+		}
 
-	for {
-		err := d.Bot.Delete(ctx, c.Message())
-		if err != nil && !strings.Contains(err.Error(), "message to delete not found") {
-			var floodError tb.FloodError
-			if errors.As(err, &floodError) {
-				if floodError.RetryAfter == 0 {
-					floodError.RetryAfter = 15
-				}
-
-				time.Sleep(time.Second * time.Duration(floodError.RetryAfter))
-				continue
-			}
-
-			if strings.Contains(err.Error(), "Gateway Timeout (504)") {
-				time.Sleep(time.Second * 10)
-				continue
-			}
-
-			return fmt.Errorf("error deleting message: %w", err)
+		err = c.Bot().Ban(ctx, c.Chat(), &tb.ChatMember{User: user, RestrictedUntil: entry.ExpiresAt.Add(time.Minute).Unix()})
+		if err != nil {
+			return fmt.Errorf("error banning user: %w", err)
 		}
 
-		slog.DebugContext(ctx, "Succesfully deleted message", slog.String("user_name", c.Sender().Username), slog.Int64("user_id", c.Sender().ID), slog.Int("message_id", c.Message().ID))
-		break
+		err = d.Datastore.AddBannedUser(ctx, c.Chat().ID, user.ID)
+		if err != nil {
+			return fmt.Errorf("recording banned user: %w", err)
+		}
+
+		d.recordBan(ctx, c.Chat(), user)
+
+		slog.DebugContext(ctx, "Succesfully banned user temporarily", slog.String("user_name", user.Username), slog.Int64("user_id", user.ID))
+	default:
+		err := c.Bot().Ban(ctx, c.Chat(), &tb.ChatMember{User: user, RestrictedUntil: tb.Forever()})
+		if err != nil {
+			return fmt.Errorf("error banning user: %w", err)
+		}
+
+		d.recordBan(ctx, c.Chat(), user)
+		slog.DebugContext(ctx, "Succesfully banned user", slog.String("user_name", user.Username), slog.Int64("user_id", user.ID))
 	}
 
+	if strategy != StrategyLock {
+		kicksTotal.Inc(chatLabel(c.Chat().ID), string(strategy))
+	}
+
+	err = d.Bot.Delete(ctx, c.Message())
+	if err != nil && !errors.Is(err, tb.ErrNotFoundToDelete) {
+		return fmt.Errorf("error deleting message: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Succesfully deleted message", slog.String("user_name", user.Username), slog.Int64("user_id", user.ID), slog.Int("message_id", c.Message().ID))
 	return nil
 }
+
+// recordBan records the banned user into the ongoing incident, so they can be
+// reviewed later. Failing to record it should not stop the user from being banned.
+func (d *Dependency) recordBan(ctx context.Context, chat *tb.Chat, user *tb.User) {
+	err := d.Datastore.RecordIncidentBan(ctx, chat.ID, IncidentBan{
+		UserID:   user.ID,
+		FullName: user.FirstName + utils.ShouldAddSpace(user) + user.LastName,
+		Username: user.Username,
+		BannedAt: time.Now(),
+	})
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("recording incident ban: %w", err))
+	}
+}
@@ -0,0 +1,16 @@
+package underattack
+
+import (
+	"strconv"
+
+	"github.com/teknologi-umum/captcha/metrics"
+)
+
+var (
+	activationsTotal = metrics.NewCounter("underattack_activations_total", "Times the under attack mode was turned on.", "chat_id", "trigger")
+	kicksTotal       = metrics.NewCounter("underattack_kicks_total", "New members removed while the group is under attack.", "chat_id", "strategy")
+)
+
+func chatLabel(chatID int64) string {
+	return strconv.FormatInt(chatID, 10)
+}
@@ -0,0 +1,243 @@
+package underattack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/cache"
+	"github.com/teknologi-umum/captcha/deletion"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+	"github.com/teknologi-umum/captcha/utils"
+)
+
+// DefaultPassDuration is how long a pass lasts when no duration is given to /allowjoin.
+const DefaultPassDuration = time.Hour
+
+// ErrInvalidPassTarget is returned when the /allowjoin argument is neither
+// a user ID nor a username.
+var ErrInvalidPassTarget = errors.New("invalid pass target")
+
+// ParsePassTarget parses the user of a pass, written as a user ID or as
+// a username with or without the leading "@".
+func ParsePassTarget(s string) (userID int64, username string, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, "", fmt.Errorf("%w: empty", ErrInvalidPassTarget)
+	}
+
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if id <= 0 {
+			return 0, "", fmt.Errorf("%w: %q is not a user ID", ErrInvalidPassTarget, s)
+		}
+
+		return id, "", nil
+	}
+
+	username = strings.ToLower(strings.TrimPrefix(s, "@"))
+	if username == "" {
+		return 0, "", fmt.Errorf("%w: empty username", ErrInvalidPassTarget)
+	}
+
+	for _, r := range username {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return 0, "", fmt.Errorf("%w: %q is not a username", ErrInvalidPassTarget, s)
+		}
+	}
+
+	return 0, username, nil
+}
+
+// Matches checks whether the pass belongs to the user.
+func (p Pass) Matches(user *tb.User) bool {
+	if user == nil {
+		return false
+	}
+
+	if p.UserID != 0 {
+		return p.UserID == user.ID
+	}
+
+	return p.Username != "" && strings.EqualFold(p.Username, user.Username)
+}
+
+// PassOf returns the pass of the user on the group, or false if the user
+// does not have one.
+func (d *Dependency) PassOf(ctx context.Context, groupID int64, user *tb.User) (Pass, bool, error) {
+	passes, err := d.passesOf(ctx, groupID)
+	if err != nil {
+		return Pass{}, false, err
+	}
+
+	for _, pass := range passes {
+		if pass.ExpiresAt.After(time.Now()) && pass.Matches(user) {
+			return pass, true, nil
+		}
+	}
+
+	return Pass{}, false, nil
+}
+
+// UsePass checks whether the user has a pass to join the group, and removes
+// the pass if they do, since it is only meant for a single join.
+func (d *Dependency) UsePass(ctx context.Context, groupID int64, user *tb.User) (bool, error) {
+	span := sentry.StartSpan(ctx, "underattack.use_pass")
+	defer span.Finish()
+	ctx = span.Context()
+
+	pass, ok, err := d.PassOf(ctx, groupID, user)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	err = d.Datastore.RemovePass(ctx, pass)
+	if err != nil {
+		return false, fmt.Errorf("removing pass: %w", err)
+	}
+
+	err = d.Memory.Delete(passesCacheKey(groupID))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return false, fmt.Errorf("deleting passes cache: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Let a user with a pass through", slog.Int64("group_id", groupID), slog.Int64("user_id", user.ID), slog.Int64("granted_by", pass.GrantedBy))
+	return true, nil
+}
+
+// passesOf returns the passes of the group, from the cache if possible.
+func (d *Dependency) passesOf(ctx context.Context, groupID int64) ([]Pass, error) {
+	cached, err := d.Memory.Get(passesCacheKey(groupID))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return nil, fmt.Errorf("getting passes cache: %w", err)
+	}
+
+	if err == nil {
+		var passes []Pass
+		err := json.Unmarshal(cached, &passes)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshaling passes cache: %w", err)
+		}
+
+		return passes, nil
+	}
+
+	passes, err := d.Datastore.ListPasses(ctx, groupID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("listing passes: %w", err)
+	}
+
+	value, err := json.Marshal(passes)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling passes cache: %w", err)
+	}
+
+	err = d.Memory.Set(passesCacheKey(groupID), value)
+	if err != nil {
+		return nil, fmt.Errorf("setting passes cache: %w", err)
+	}
+
+	return passes, nil
+}
+
+func passesCacheKey(groupID int64) string {
+	return "UnderAttack:Passes:" + strconv.FormatInt(groupID, 10)
+}
+
+// AllowJoinHandler provides a handler for /allowjoin command. It gives a pass to
+// a user, written as a user ID or a username, optionally followed by how long the
+// pass lasts.
+func (d *Dependency) AllowJoinHandler(ctx context.Context, c tb.Context) error {
+	if c.Message().Private() || c.Sender().IsBot {
+		return nil
+	}
+
+	span := sentry.StartSpan(ctx, "bot.allow_join_handler", sentry.WithTransactionSource(sentry.SourceTask),
+		sentry.WithTransactionName("Captcha AllowJoinHandler"))
+	defer span.Finish()
+	ctx = span.Context()
+
+	admins, err := c.Bot().AdminsOf(ctx, c.Chat())
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	if !utils.IsAdmin(admins, c.Sender()) {
+		err := d.reply(ctx, c, "Cuma admin yang boleh jalanin command ini. Ada baiknya kamu ping adminnya langsung :)")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	target, durationArgument, _ := strings.Cut(strings.TrimSpace(c.Message().Payload), " ")
+	userID, username, err := ParsePassTarget(target)
+	if err != nil {
+		err := d.reply(ctx, c, "Tulis user ID atau username yang mau diizinkan masuk. Contoh: /allowjoin @username atau /allowjoin 12345678 2h")
+		if err != nil {
+			shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		}
+
+		return nil
+	}
+
+	duration := DefaultPassDuration
+	if durationArgument = strings.TrimSpace(durationArgument); durationArgument != "" {
+		duration, err = deletion.ParseDuration(ctx, strings.ToLower(durationArgument))
+		if err != nil || !ValidDuration(duration) {
+			err := d.reply(ctx, c, "Durasinya nggak valid. Contoh: /allowjoin @username 2h. Minimal 1 menit, maksimal 7 hari.")
+			if err != nil {
+				shared.HandleBotError(ctx, err, d.Bot, c.Message())
+			}
+
+			return nil
+		}
+	}
+
+	pass := Pass{
+		GroupID:   c.Chat().ID,
+		UserID:    userID,
+		Username:  username,
+		GrantedBy: c.Sender().ID,
+		ExpiresAt: time.Now().Add(duration),
+		CreatedAt: time.Now(),
+	}
+
+	err = d.Datastore.AddPass(ctx, pass)
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	err = d.Memory.Delete(passesCacheKey(c.Chat().ID))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+		return nil
+	}
+
+	who := "@" + username
+	if userID != 0 {
+		who = "user " + strconv.FormatInt(userID, 10)
+	}
+
+	clockFormat := "15:04 MST"
+	if pass.ExpiresAt.In(wib).Format(time.DateOnly) != time.Now().In(wib).Format(time.DateOnly) {
+		clockFormat = "02 Jan 15:04 MST"
+	}
+
+	err = d.reply(ctx, c, who+" boleh masuk walaupun grup sedang under attack, sampai pukul "+
+		pass.ExpiresAt.In(wib).Format(clockFormat)+". Setelah masuk, mereka tetap harus menjawab captcha.")
+	if err != nil {
+		shared.HandleBotError(ctx, err, d.Bot, c.Message())
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package underattack_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+func TestParsePassTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		userID   int64
+		username string
+		wantErr  bool
+	}{
+		{input: "12345678", userID: 12345678},
+		{input: "@Some_User", username: "some_user"},
+		{input: "some_user", username: "some_user"},
+		{input: "", wantErr: true},
+		{input: "@", wantErr: true},
+		{input: "-100", wantErr: true},
+		{input: "@some-user", wantErr: true},
+	}
+
+	for _, test := range tests {
+		userID, username, err := underattack.ParsePassTarget(test.input)
+		if test.wantErr {
+			if !errors.Is(err, underattack.ErrInvalidPassTarget) {
+				t.Errorf("%q: expecting ErrInvalidPassTarget, got %v", test.input, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.input, err)
+			continue
+		}
+
+		if userID != test.userID || username != test.username {
+			t.Errorf("%q: expecting (%d, %q), got (%d, %q)", test.input, test.userID, test.username, userID, username)
+		}
+	}
+}
+
+func TestUsePass(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	err := dependency.Datastore.AddPass(ctx, underattack.Pass{
+		GroupID:   20,
+		Username:  "friend",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("adding pass: %s", err.Error())
+	}
+
+	stranger := &tb.User{ID: 1, Username: "stranger"}
+	allowed, err := dependency.UsePass(ctx, 20, stranger)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if allowed {
+		t.Error("expecting a user without a pass to not be allowed")
+	}
+
+	friend := &tb.User{ID: 2, Username: "Friend"}
+	allowed, err = dependency.UsePass(ctx, 20, friend)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !allowed {
+		t.Error("expecting the user with a pass to be allowed")
+	}
+
+	allowed, err = dependency.UsePass(ctx, 20, friend)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if allowed {
+		t.Error("expecting the pass to be used only once")
+	}
+}
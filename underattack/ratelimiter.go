@@ -1,27 +1,42 @@
 package underattack
 
 import (
+	"context"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/teknologi-umum/captcha/ratelimit"
+	"github.com/teknologi-umum/captcha/shared"
 )
 
-var handlerRateLimiter sync.Map
+// DefaultRateLimit is how often a group may toggle the under attack mode, when
+// Dependency.RateLimiter is nil.
+const DefaultRateLimit = time.Second * 10
+
+// defaultRateLimiter is used when Dependency.RateLimiter is nil.
+var defaultRateLimiter = sync.OnceValue(func() *ratelimit.Limiter {
+	limiter, err := ratelimit.NewTokenBucket("underattack", ratelimit.NewMemoryStore(0, 0), DefaultRateLimit, 1)
+	if err != nil {
+		panic(err)
+	}
 
-// RateLimitCall provides a simple rate limiter by a specified ID.
-func RateLimitCall(id int64, duration time.Duration) bool {
-	_, ok := handlerRateLimiter.Load(id)
-	if !ok {
-		// Does not exist, we generate one.
-		handlerRateLimiter.Store(id, time.Now().Add(duration).Format(time.RFC3339))
+	return limiter
+})
 
-		go func(id int64, duration time.Duration) {
-			time.Sleep(duration)
+// allowCommand reports whether the group may toggle the under attack mode again.
+// If the limiter fails, the command goes through.
+func (d *Dependency) allowCommand(ctx context.Context, groupID int64) bool {
+	limiter := d.RateLimiter
+	if limiter == nil {
+		limiter = defaultRateLimiter()
+	}
 
-			handlerRateLimiter.Delete(id)
-		}(id, duration)
+	allowed, err := limiter.Allow(ctx, strconv.FormatInt(groupID, 10))
+	if err != nil {
+		shared.HandleError(ctx, err)
 		return true
 	}
 
-	// Exists, we rate limit them
-	return false
+	return allowed
 }
@@ -1,24 +0,0 @@
-package underattack_test
-
-import (
-	"testing"
-	"time"
-
-	"github.com/teknologi-umum/captcha/underattack"
-)
-
-func TestRateLimitCall(t *testing.T) {
-	id := int64(123)
-	duration := time.Second
-
-	rateLimited := underattack.RateLimitCall(id, duration)
-	if !rateLimited {
-		t.Error("expecting true, got false")
-	}
-
-	rateLimited = underattack.RateLimitCall(id, duration)
-	if rateLimited {
-		t.Error("expecting false, got true")
-	}
-
-}
@@ -0,0 +1,159 @@
+package underattack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/teknologi-umum/captcha/deletion"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+// ErrInvalidSchedule is returned when a schedule window can't be parsed.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+var wib = time.FixedZone("WIB", 7*60*60)
+
+// ParseSchedule parses a daily window written as "01:00-06:00" in WIB.
+func ParseSchedule(groupID int64, s string) (Schedule, error) {
+	start, end, ok := strings.Cut(strings.ReplaceAll(s, " ", ""), "-")
+	if !ok {
+		return Schedule{}, fmt.Errorf("%w: expecting start-end, got %q", ErrInvalidSchedule, s)
+	}
+
+	startHour, startMinute, err := deletion.ParseClock(start)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("%w: start: %s", ErrInvalidSchedule, err.Error())
+	}
+
+	endHour, endMinute, err := deletion.ParseClock(end)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("%w: end: %s", ErrInvalidSchedule, err.Error())
+	}
+
+	schedule := Schedule{
+		GroupID:     groupID,
+		StartMinute: startHour*60 + startMinute,
+		EndMinute:   endHour*60 + endMinute,
+	}
+	if schedule.StartMinute == schedule.EndMinute {
+		return Schedule{}, fmt.Errorf("%w: start and end are the same", ErrInvalidSchedule)
+	}
+
+	return schedule, nil
+}
+
+// String returns the window as "01:00-06:00".
+func (s Schedule) String() string {
+	return formatMinute(s.StartMinute) + "-" + formatMinute(s.EndMinute)
+}
+
+// Window returns the occurrence of the schedule that contains the given time,
+// or false if the time is outside the schedule.
+func (s Schedule) Window(now time.Time) (start time.Time, end time.Time, ok bool) {
+	now = now.In(wib)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, wib)
+	minute := now.Hour()*60 + now.Minute()
+
+	switch {
+	case s.StartMinute < s.EndMinute && minute >= s.StartMinute && minute < s.EndMinute:
+		start = midnight.Add(time.Duration(s.StartMinute) * time.Minute)
+		end = midnight.Add(time.Duration(s.EndMinute) * time.Minute)
+	case s.StartMinute > s.EndMinute && minute >= s.StartMinute:
+		start = midnight.Add(time.Duration(s.StartMinute) * time.Minute)
+		end = midnight.AddDate(0, 0, 1).Add(time.Duration(s.EndMinute) * time.Minute)
+	case s.StartMinute > s.EndMinute && minute < s.EndMinute:
+		start = midnight.AddDate(0, 0, -1).Add(time.Duration(s.StartMinute) * time.Minute)
+		end = midnight.Add(time.Duration(s.EndMinute) * time.Minute)
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+
+	return start, end, true
+}
+
+func formatMinute(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// RunScheduler releases the expired under attack modes right away, then releases
+// them and applies the scheduled windows every minute, until the context is done.
+func (d *Dependency) RunScheduler(ctx context.Context) {
+	err := d.releaseExpired(ctx, time.Now())
+	if err != nil {
+		shared.HandleError(ctx, err)
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := d.releaseExpired(ctx, now)
+			if err != nil {
+				shared.HandleError(ctx, err)
+			}
+
+			err = d.applySchedules(ctx, now)
+			if err != nil {
+				shared.HandleError(ctx, err)
+			}
+		}
+	}
+}
+
+// applySchedules enables the under attack mode of every group that is inside
+// one of its scheduled windows. Every occurrence is only applied once, so an
+// admin can still disable the under attack mode in the middle of a window.
+func (d *Dependency) applySchedules(ctx context.Context, now time.Time) error {
+	span := sentry.StartSpan(ctx, "underattack.apply_schedules")
+	defer span.Finish()
+	ctx = span.Context()
+
+	schedules, err := d.Datastore.ListSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("listing schedules: %w", err)
+	}
+
+	for _, schedule := range schedules {
+		start, end, ok := schedule.Window(now)
+		if !ok {
+			continue
+		}
+
+		// The claim is kept on the datastore, so a restart or another
+		// replica does not apply the same occurrence again.
+		claimed, err := d.Datastore.ClaimScheduledWindow(ctx, schedule.GroupID, start, end)
+		if err != nil {
+			return fmt.Errorf("claiming scheduled window: %w", err)
+		}
+
+		if !claimed {
+			continue
+		}
+
+		underAttack, err := d.AreWe(ctx, schedule.GroupID)
+		if err != nil {
+			return err
+		}
+
+		if !underAttack {
+			slog.InfoContext(ctx, "Enabling scheduled under attack mode", slog.Int64("group_id", schedule.GroupID), slog.String("schedule", schedule.String()))
+
+			err := d.Enable(ctx, &tb.Chat{ID: schedule.GroupID}, end, TriggerScheduled, 0)
+			if err != nil {
+				shared.HandleError(ctx, fmt.Errorf("enabling scheduled under attack mode for %d: %w", schedule.GroupID, err))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package underattack_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teknologi-umum/captcha/underattack"
+)
+
+func TestParseSchedule(t *testing.T) {
+	schedule, err := underattack.ParseSchedule(1, "01:00-06:30")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if schedule.StartMinute != 60 || schedule.EndMinute != 390 {
+		t.Errorf("expecting 60-390, got %d-%d", schedule.StartMinute, schedule.EndMinute)
+	}
+
+	if schedule.String() != "01:00-06:30" {
+		t.Errorf("expecting 01:00-06:30, got %s", schedule.String())
+	}
+
+	for _, input := range []string{"", "01:00", "01:00-01:00", "25:00-06:00", "aa-bb"} {
+		_, err := underattack.ParseSchedule(1, input)
+		if !errors.Is(err, underattack.ErrInvalidSchedule) {
+			t.Errorf("expecting ErrInvalidSchedule for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestScheduleWindow(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, wib)
+	}
+
+	tests := []struct {
+		name     string
+		schedule underattack.Schedule
+		now      time.Time
+		ok       bool
+		start    time.Time
+		end      time.Time
+	}{
+		{
+			name:     "Inside",
+			schedule: underattack.Schedule{StartMinute: 60, EndMinute: 360},
+			now:      at(10, 3, 0),
+			ok:       true,
+			start:    at(10, 1, 0),
+			end:      at(10, 6, 0),
+		},
+		{
+			name:     "Outside",
+			schedule: underattack.Schedule{StartMinute: 60, EndMinute: 360},
+			now:      at(10, 6, 0),
+		},
+		{
+			name:     "Crossing midnight, before midnight",
+			schedule: underattack.Schedule{StartMinute: 23 * 60, EndMinute: 120},
+			now:      at(10, 23, 30),
+			ok:       true,
+			start:    at(10, 23, 0),
+			end:      at(11, 2, 0),
+		},
+		{
+			name:     "Crossing midnight, after midnight",
+			schedule: underattack.Schedule{StartMinute: 23 * 60, EndMinute: 120},
+			now:      at(11, 1, 0),
+			ok:       true,
+			start:    at(10, 23, 0),
+			end:      at(11, 2, 0),
+		},
+		{
+			name:     "Other timezone",
+			schedule: underattack.Schedule{StartMinute: 60, EndMinute: 360},
+			now:      time.Date(2024, time.January, 9, 20, 0, 0, 0, time.UTC),
+			ok:       true,
+			start:    at(10, 1, 0),
+			end:      at(10, 6, 0),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end, ok := test.schedule.Window(test.now)
+			if ok != test.ok {
+				t.Fatalf("expecting ok to be %t, got %t", test.ok, ok)
+			}
+
+			if !start.Equal(test.start) || !end.Equal(test.end) {
+				t.Errorf("expecting %v - %v, got %v - %v", test.start, test.end, start, end)
+			}
+		})
+	}
+}
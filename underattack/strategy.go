@@ -0,0 +1,218 @@
+package underattack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/shared"
+)
+
+// StrategyOf returns the strategy chosen by the group, or the default
+// strategy if the group has not chosen one.
+func (d *Dependency) StrategyOf(ctx context.Context, groupID int64) (Strategy, error) {
+	strategy, err := d.Datastore.GetStrategy(ctx, groupID)
+	if err != nil {
+		return "", fmt.Errorf("getting strategy: %w", err)
+	}
+
+	if strategy.Valid() {
+		return strategy, nil
+	}
+
+	if d.DefaultStrategy.Valid() {
+		return d.DefaultStrategy, nil
+	}
+
+	return StrategyBan, nil
+}
+
+// ErrInvalidStrategy is returned when the strategy is not one of Strategies.
+var ErrInvalidStrategy = errors.New("invalid strategy")
+
+// SetStrategy chooses the strategy of the group.
+func (d *Dependency) SetStrategy(ctx context.Context, groupID int64, strategy Strategy) error {
+	if !strategy.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidStrategy, strategy)
+	}
+
+	err := d.Datastore.SetStrategy(ctx, groupID, strategy)
+	if err != nil {
+		return fmt.Errorf("setting strategy: %w", err)
+	}
+
+	return nil
+}
+
+// lock takes the permissions of every member away, keeping the current
+// permissions so they can be restored by release. Locking a group that is
+// already locked keeps the permissions from before the first lock.
+func (d *Dependency) lock(ctx context.Context, chat *tb.Chat) error {
+	span := sentry.StartSpan(ctx, "underattack.lock")
+	defer span.Finish()
+	ctx = span.Context()
+
+	fullChat, err := d.Bot.ChatByID(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("getting chat: %w", err)
+	}
+
+	if fullChat.Permissions != nil {
+		err = d.Datastore.SaveLockedPermissions(ctx, chat.ID, *fullChat.Permissions)
+		if err != nil {
+			return fmt.Errorf("saving locked permissions: %w", err)
+		}
+	}
+
+	err = d.Bot.SetGroupPermissions(ctx, chat, tb.NoRights())
+	if err != nil {
+		return fmt.Errorf("locking group permissions: %w", err)
+	}
+
+	return nil
+}
+
+// release undoes whatever the strategy did during the under attack mode: it
+// restores the locked permissions, unbans the temporarily banned users, and
+// removes the users who joined while the group was locked.
+// It is safe to be called more than once.
+func (d *Dependency) release(ctx context.Context, chat *tb.Chat) error {
+	span := sentry.StartSpan(ctx, "underattack.release")
+	defer span.Finish()
+	ctx = span.Context()
+
+	permissions, ok, err := d.Datastore.TakeLockedPermissions(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("taking locked permissions: %w", err)
+	}
+
+	if ok {
+		err := d.Bot.SetGroupPermissions(ctx, chat, permissions)
+		if err != nil {
+			return fmt.Errorf("restoring group permissions: %w", err)
+		}
+	}
+
+	userIDs, err := d.Datastore.TakeBannedUsers(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("taking banned users: %w", err)
+	}
+
+	var unbanned []int64
+	for _, userID := range userIDs {
+		err := d.Bot.Unban(ctx, chat, &tb.User{ID: userID}, true)
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("unbanning user %d: %w", userID, err))
+			continue
+		}
+
+		unbanned = append(unbanned, userID)
+	}
+
+	// The users who joined while the group was locked never solved a captcha,
+	// so they are removed instead of being let in. They can join again and
+	// go through the captcha like everyone else.
+	restrictedUserIDs, err := d.Datastore.TakeRestrictedUsers(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("taking restricted users: %w", err)
+	}
+
+	for _, userID := range restrictedUserIDs {
+		err := d.Bot.Ban(ctx, chat, &tb.ChatMember{User: &tb.User{ID: userID}, RestrictedUntil: tb.Forever()})
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("removing restricted user %d: %w", userID, err))
+			continue
+		}
+
+		err = d.Bot.Unban(ctx, chat, &tb.User{ID: userID}, true)
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("unbanning removed user %d: %w", userID, err))
+			continue
+		}
+
+		unbanned = append(unbanned, userID)
+	}
+
+	if len(unbanned) > 0 {
+		incidents, err := d.Datastore.ListIncidents(ctx, chat.ID, 1)
+		if err != nil {
+			return fmt.Errorf("listing incidents: %w", err)
+		}
+
+		if len(incidents) > 0 {
+			err := d.Datastore.MarkUnbanned(ctx, incidents[0].ID, unbanned, time.Now())
+			if err != nil {
+				return fmt.Errorf("marking unbanned users: %w", err)
+			}
+		}
+	}
+
+	slog.DebugContext(
+		ctx,
+		"Under attack enforcement released",
+		slog.Int64("group_id", chat.ID),
+		slog.Bool("permissions_restored", ok),
+		slog.Int("unbanned_users", len(userIDs)),
+		slog.Int("removed_users", len(restrictedUserIDs)),
+	)
+	return nil
+}
+
+// JoinRequestHandler declines pending join requests while the group is under
+// attack, if the group has chosen StrategyDecline. Join requests of the users
+// with a pass are approved instead.
+func (d *Dependency) JoinRequestHandler(ctx context.Context, c tb.Context) error {
+	request := c.ChatJoinRequest()
+	if request == nil || request.Chat == nil || request.Sender == nil {
+		return nil
+	}
+
+	underAttack, err := d.AreWe(ctx, request.Chat.ID)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	if !underAttack {
+		return nil
+	}
+
+	strategy, err := d.StrategyOf(ctx, request.Chat.ID)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	if strategy != StrategyDecline {
+		return nil
+	}
+
+	// Users with a pass are approved, the pass is used once they join.
+	_, allowed, err := d.PassOf(ctx, request.Chat.ID, request.Sender)
+	if err != nil {
+		shared.HandleError(ctx, err)
+		return nil
+	}
+
+	if allowed {
+		err := d.Bot.ApproveJoinRequest(ctx, request.Chat, request.Sender)
+		if err != nil {
+			shared.HandleError(ctx, fmt.Errorf("approving join request: %w", err))
+		}
+
+		return nil
+	}
+
+	err = d.Bot.DeclineJoinRequest(ctx, request.Chat, request.Sender)
+	if err != nil {
+		shared.HandleError(ctx, fmt.Errorf("declining join request: %w", err))
+		return nil
+	}
+
+	slog.DebugContext(ctx, "Declined a join request", slog.Int64("group_id", request.Chat.ID), slog.Int64("user_id", request.Sender.ID))
+	return nil
+}
@@ -0,0 +1,147 @@
+package underattack_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/getsentry/sentry-go"
+	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/underattack"
+	"github.com/teknologi-umum/captcha/underattack/datastore"
+)
+
+func TestStrategyOf(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	strategy, err := dependency.StrategyOf(ctx, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if strategy != underattack.StrategyBan {
+		t.Errorf("expecting the fallback strategy %q, got %q", underattack.StrategyBan, strategy)
+	}
+
+	err = dependency.Datastore.SetStrategy(ctx, 10, underattack.StrategyLock)
+	if err != nil {
+		t.Fatalf("setting strategy: %s", err.Error())
+	}
+
+	strategy, err = dependency.StrategyOf(ctx, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if strategy != underattack.StrategyLock {
+		t.Errorf("expecting %q, got %q", underattack.StrategyLock, strategy)
+	}
+}
+
+// fakeTelegram answers every Bot API method with true, and keeps the
+// methods that were called along with their user_id.
+type fakeTelegram struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	var params map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&params)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, fmt.Sprintf("%s %v", method, params["user_id"]))
+	f.mu.Unlock()
+
+	_, _ = io.WriteString(w, `{"ok":true,"result":true}`)
+}
+
+func (f *fakeTelegram) called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range f.calls {
+		if c == call {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestLockStrategy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(telegram)
+	defer server.Close()
+
+	bot, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "token", Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatalf("creating bot: %s", err.Error())
+	}
+
+	memory, err := bigcache.New(ctx, bigcache.DefaultConfig(time.Hour))
+	if err != nil {
+		t.Fatalf("creating bigcache: %s", err.Error())
+	}
+
+	memoryDatastore, err := datastore.NewInMemoryDatastore(memory)
+	if err != nil {
+		t.Fatalf("creating in memory datastore: %s", err.Error())
+	}
+
+	dependency := &underattack.Dependency{
+		Memory:    memory,
+		Datastore: memoryDatastore,
+		Bot:       bot,
+	}
+
+	chat := &tb.Chat{ID: -1002, Type: tb.ChatSuperGroup}
+	err = memoryDatastore.SetStrategy(ctx, chat.ID, underattack.StrategyLock)
+	if err != nil {
+		t.Fatalf("setting strategy: %s", err.Error())
+	}
+
+	err = memoryDatastore.SetUnderAttackStatus(ctx, chat.ID, true, time.Now().Add(time.Hour), 0, underattack.TriggerManual)
+	if err != nil {
+		t.Fatalf("setting under attack status: %s", err.Error())
+	}
+
+	_, err = dependency.AreWe(ctx, chat.ID)
+	if err != nil {
+		t.Fatalf("checking under attack status: %s", err.Error())
+	}
+
+	raider := &tb.User{ID: 777, FirstName: "Raider"}
+	err = dependency.Kicker(ctx, bot.NewContext(tb.Update{Message: &tb.Message{ID: 10, Chat: chat, Sender: raider, UserJoined: raider}}))
+	if err != nil {
+		t.Fatalf("handling new member: %s", err.Error())
+	}
+
+	if !telegram.called("restrictChatMember 777") {
+		t.Errorf("expecting the new member to be restricted, got %v", telegram.calls)
+	}
+
+	err = dependency.Disable(ctx, chat, 1)
+	if err != nil {
+		t.Fatalf("disabling under attack mode: %s", err.Error())
+	}
+
+	if !telegram.called("kickChatMember 777") || !telegram.called("unbanChatMember 777") {
+		t.Errorf("expecting the new member to be removed on release, got %v", telegram.calls)
+	}
+}
@@ -1,20 +1,52 @@
 package underattack
 
 import (
+	"slices"
 	"time"
 
-	"github.com/allegro/bigcache/v3"
+	"github.com/teknologi-umum/captcha/cache"
 	tb "github.com/teknologi-umum/captcha/internal/telebot"
+	"github.com/teknologi-umum/captcha/ratelimit"
 )
 
 // Dependency contains the dependency injection struct
 // for methods in the UnderAttack package
 type Dependency struct {
 	Datastore Datastore
-	Memory    *bigcache.BigCache
+	Memory    cache.Cache
 	Bot       *tb.Bot
+	// JoinRate enables under attack mode automatically on a join-rate spike.
+	// It is optional, nil means under attack mode can only be enabled manually.
+	JoinRate *JoinRateDetector
+	// DefaultStrategy is used for groups that have not chosen their own strategy.
+	// Defaults to StrategyBan.
+	DefaultStrategy Strategy
+	// RotateInviteLink revokes the invite links of the group when the under attack
+	// mode is enabled, and creates a fresh link that needs the approval of an admin.
+	RotateInviteLink bool
+	// Changes reports the entries changed by other replicas of the bot. It is
+	// optional, nil means the cached entries are trusted until they are evicted.
+	Changes ChangeListener
+	// PollInterval is how long a cached entry is trusted while Changes is
+	// disconnected. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// RateLimiter limits how often each group may toggle the under attack mode.
+	// Defaults to one toggle every DefaultRateLimit, kept in the process.
+	RateLimiter *ratelimit.Limiter
 }
 
+// Trigger describes what enabled the under attack mode.
+type Trigger string
+
+const (
+	// TriggerManual means an admin enabled it with the /underattack command.
+	TriggerManual Trigger = "manual"
+	// TriggerAutomatic means it was enabled by the join-rate detector.
+	TriggerAutomatic Trigger = "automatic"
+	// TriggerScheduled means it was enabled by a recurring schedule.
+	TriggerScheduled Trigger = "scheduled"
+)
+
 // UnderAttack provides a data struct to interact with
 // the database table.
 type UnderAttack struct {
@@ -22,5 +54,100 @@ type UnderAttack struct {
 	IsUnderAttack         bool      `db:"is_under_attack"`
 	NotificationMessageID int64     `db:"notification_message_id"`
 	ExpiresAt             time.Time `db:"expires_at"`
+	TriggeredBy           Trigger   `db:"triggered_by"`
 	UpdatedAt             time.Time `db:"updated_at"`
 }
+
+// Strategy is how the new members are handled while the group is under attack.
+type Strategy string
+
+const (
+	// StrategyBan bans every new member forever.
+	StrategyBan Strategy = "ban"
+	// StrategyTemporaryBan bans every new member, and unbans them once
+	// the under attack mode is over.
+	StrategyTemporaryBan Strategy = "temporary_ban"
+	// StrategyKick removes every new member without banning them,
+	// so they can join again later.
+	StrategyKick Strategy = "kick"
+	// StrategyDecline declines pending join requests. Members that
+	// join directly are kicked.
+	StrategyDecline Strategy = "decline"
+	// StrategyLock takes the permissions of every member away, and
+	// restores them once the under attack mode is over. New members
+	// are left alone since they can't send anything.
+	StrategyLock Strategy = "lock"
+)
+
+// Strategies lists every available strategy.
+var Strategies = []Strategy{StrategyBan, StrategyTemporaryBan, StrategyKick, StrategyDecline, StrategyLock}
+
+// Valid checks whether the strategy is one of the known strategies.
+func (s Strategy) Valid() bool {
+	return slices.Contains(Strategies, s)
+}
+
+// Schedule is a recurring daily window in which the under attack mode
+// is enabled for a group. Both ends are minutes after midnight WIB. A window
+// whose end is before its start crosses midnight.
+type Schedule struct {
+	GroupID     int64 `db:"group_id"`
+	StartMinute int   `db:"start_minute"`
+	EndMinute   int   `db:"end_minute"`
+}
+
+// Incident is a single period of under attack mode of a group.
+type Incident struct {
+	ID          int64   `db:"id"`
+	GroupID     int64   `db:"group_id"`
+	TriggeredBy Trigger `db:"triggered_by"`
+	// ActorID is the admin who enabled the under attack mode,
+	// it is zero unless the incident is triggered manually.
+	ActorID   int64     `db:"actor_id"`
+	Strategy  Strategy  `db:"strategy"`
+	StartedAt time.Time `db:"started_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+	// EndedAt is zero while the incident is still ongoing.
+	EndedAt     time.Time `db:"ended_at"`
+	BannedCount int       `db:"banned_count"`
+}
+
+// IncidentBan is a user that was banned during an incident.
+type IncidentBan struct {
+	IncidentID int64     `db:"incident_id"`
+	UserID     int64     `db:"user_id"`
+	FullName   string    `db:"full_name"`
+	Username   string    `db:"username"`
+	BannedAt   time.Time `db:"banned_at"`
+	// UnbannedAt is zero while the user is still banned.
+	UnbannedAt time.Time `db:"unbanned_at"`
+}
+
+// InviteLink is an invite link of a group that is created or revoked by the bot.
+type InviteLink struct {
+	GroupID    int64  `db:"group_id"`
+	InviteLink string `db:"invite_link"`
+	// JoinRequest is true if the new members must be approved by an admin,
+	// which is the case for the links created during the under attack mode.
+	JoinRequest bool `db:"join_request"`
+	// CreatedAt is when the bot created the link, or when it first saw
+	// the link it did not create.
+	CreatedAt time.Time `db:"created_at"`
+	// RevokedAt is zero while the link can still be used.
+	RevokedAt time.Time `db:"revoked_at"`
+}
+
+// Pass lets a specific user join the group while it is under attack. The user goes
+// through the normal captcha instead of being kicked. A pass is identified by the
+// user ID, or by the username if the admin only knows the username.
+type Pass struct {
+	GroupID int64 `db:"group_id"`
+	// UserID is zero if the pass is given to a username.
+	UserID int64 `db:"user_id"`
+	// Username is lowercase and without the leading "@". It is empty if the pass
+	// is given to a user ID.
+	Username  string    `db:"username"`
+	GrantedBy int64     `db:"granted_by"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
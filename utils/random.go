@@ -8,8 +8,14 @@ import (
 
 // GenerateRandomNumber generates a random sequence string
 func GenerateRandomNumber() string {
+	return GenerateRandomNumberWithLength(3)
+}
+
+// GenerateRandomNumberWithLength generates a random sequence string
+// with the given amount of characters.
+func GenerateRandomNumberWithLength(length int) string {
 	var out strings.Builder
-	for i := 0; i < 3; i++ {
+	for i := 0; i < length; i++ {
 		randomNumber := rand.IntN(14)
 		if randomNumber == 10 {
 			out.WriteString("V")
@@ -12,3 +12,10 @@ func TestGenerateRandomNumber(t *testing.T) {
 		t.Errorf("GenerateRandomNumber() should return 3 digits, got %d", len(n))
 	}
 }
+
+func TestGenerateRandomNumberWithLength(t *testing.T) {
+	n := utils.GenerateRandomNumberWithLength(5)
+	if len(n) != 5 {
+		t.Errorf("GenerateRandomNumberWithLength(5) should return 5 digits, got %d", len(n))
+	}
+}